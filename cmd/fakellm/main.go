@@ -0,0 +1,55 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Поддельный LLM сервер для проверки бота без модели:
+//
+//	go run ./cmd/fakellm -addr 127.0.0.1:11435
+//
+// Ollama: "backend": "ollama", "base_url": "http://127.0.0.1:11435"
+// OpenAI-совместимый: "backend": "openai", "base_url": "http://127.0.0.1:11435/v1"
+package main
+
+import (
+	"Unbewohnte/ACASbot/internal/inference/fakellm"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:11435", "Адрес для прослушивания")
+	model := flag.String("model", fakellm.DefaultModel, "Имя модели")
+	embeddingModel := flag.String("embedding-model", fakellm.DefaultEmbeddingModel, "Имя модели векторизации")
+	latency := flag.Duration("latency", 0, "Искусственная задержка каждого ответа")
+	flag.Parse()
+
+	server := fakellm.NewServer()
+	server.Model = *model
+	server.EmbeddingModel = *embeddingModel
+	server.Latency = *latency
+
+	log.Printf("Поддельный LLM сервер слушает %s (модель \"%s\", векторизация \"%s\")", *addr, *model, *embeddingModel)
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Fatal(httpServer.ListenAndServe())
+}
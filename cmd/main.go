@@ -20,6 +20,8 @@ package main
 
 import (
 	"Unbewohnte/ACASbot/internal/bot"
+	"context"
+	"flag"
 	"io"
 	"log"
 	"os"
@@ -31,7 +33,7 @@ var (
 	CONFIG *bot.Config
 )
 
-func init() {
+func loadConfig() {
 	var err error
 	CONFIG, err = bot.ConfigFrom(CONFIG_NAME)
 	if err != nil {
@@ -67,6 +69,42 @@ func init() {
 }
 
 func main() {
+	backup := flag.Bool("backup", false, "Создать резервную копию базы и конфигурации в каталоге из настроек и выйти")
+	restore := flag.String("restore", "", "Восстановить базу и конфигурацию из резервной копии и выйти (бот должен быть остановлен)")
+	flag.Parse()
+
+	// Восстановление возможно и без конфигурационного файла, например на новой машине
+	if *restore != "" {
+		report, err := bot.RestoreBackup(*restore, CONFIG_NAME)
+		if err != nil {
+			log.Fatalf("Не удалось восстановить резервную копию: %v", err)
+		}
+		log.Print(report)
+		return
+	}
+
+	// Восстановление, подготовленное командой restore, выполняется до открытия базы
+	if report, err := bot.ApplyPendingRestore(CONFIG_NAME); err != nil {
+		log.Printf("Не удалось восстановить подготовленную резервную копию: %v", err)
+	} else if report != "" {
+		log.Print(report)
+	}
+
+	loadConfig()
+
+	if *backup {
+		if _, err := CONFIG.OpenDB(); err != nil {
+			log.Fatalf("Не удалось открыть базу данных: %v", err)
+		}
+
+		result, err := CONFIG.CreateBackup(context.Background(), CONFIG.Backup.RedactSecrets)
+		if err != nil {
+			log.Fatalf("Не удалось создать резервную копию: %v", err)
+		}
+		log.Printf("Резервная копия создана: %s, удалено старых: %d", result.Path, len(result.Removed))
+		return
+	}
+
 	bot, err := bot.NewBot(CONFIG)
 	if err != nil {
 		log.Panic(err)
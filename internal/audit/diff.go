@@ -0,0 +1,102 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Снимок конфигурации: путь к каждому значению и само значение в JSON
+type Snapshot map[string]string
+
+// Делает снимок произвольной структуры, сериализуемой в JSON
+func Take(value any) (Snapshot, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, err
+	}
+
+	snapshot := make(Snapshot)
+	flatten("", decoded, snapshot)
+
+	return snapshot, nil
+}
+
+func flatten(path string, value any, snapshot Snapshot) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			snapshot[path] = "{}"
+			return
+		}
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			flatten(childPath, child, snapshot)
+		}
+	case []any:
+		if len(v) == 0 {
+			snapshot[path] = "[]"
+			return
+		}
+		for i, child := range v {
+			flatten(fmt.Sprintf("%s[%d]", path, i), child, snapshot)
+		}
+	default:
+		encoded, _ := json.Marshal(v)
+		snapshot[path] = string(encoded)
+	}
+}
+
+// Изменения между двумя снимками, упорядоченные по пути
+func Diff(before Snapshot, after Snapshot) []domain.ConfigChange {
+	var changes []domain.ConfigChange
+	for path, old := range before {
+		current, ok := after[path]
+		if !ok {
+			changes = append(changes, domain.ConfigChange{Path: path, Before: old})
+			continue
+		}
+		if current != old {
+			changes = append(changes, domain.ConfigChange{Path: path, Before: old, After: current})
+		}
+	}
+
+	for path, current := range after {
+		if _, ok := before[path]; !ok {
+			changes = append(changes, domain.ConfigChange{Path: path, After: current})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
@@ -0,0 +1,231 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"Unbewohnte/ACASbot/internal/inference"
+	"Unbewohnte/ACASbot/internal/inference/fakellm"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const testArticleText = "Жители города отметили рекордный рост числа новых рабочих мест. " +
+	"Администрация назвала это успехом программы развития, а горожане поддержали открытие нового парка. " +
+	"По словам жителей, улучшение городской среды заметно уже сейчас, и достижения программы видны каждому."
+
+// Страница статьи для загрузки обычным запросом
+func testArticleServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	paragraph := "<p>" + testArticleText + "</p>"
+	page := "<html><head><title>Рекордный рост рабочих мест</title></head><body><article>" +
+		"<h1>Рекордный рост рабочих мест</h1>" + strings.Repeat(paragraph, 3) +
+		"</article></body></html>"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// Бот с временной базой и моделью по адресу llm
+func testAnalysisBot(t *testing.T, llm http.Handler, structured bool) *Bot {
+	t.Helper()
+
+	server := httptest.NewServer(llm)
+	t.Cleanup(server.Close)
+
+	conf := DefaultConfig()
+	conf.DB.File = filepath.Join(t.TempDir(), "database.sqlite3")
+	conf.Sheets.PushToGoogleSheet = false
+	conf.Ollama.BaseURL = server.URL
+	conf.Ollama.GeneralModel = fakellm.DefaultModel
+	conf.Ollama.EmbeddingModel = fakellm.DefaultEmbeddingModel
+	conf.Ollama.StructuredOutput = structured
+	openTestDB(t, conf)
+
+	model, err := inference.NewClient(conf.Ollama.ClientOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Bot{conf: conf, model: model}
+}
+
+// Сервер модели, отвечающий на запросы генерации текстом answer, остальные запросы - как fakellm
+func malformedLLM(answer string, generated *atomic.Int64) http.Handler {
+	fake := fakellm.NewServer().Handler()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			fake.ServeHTTP(w, r)
+			return
+		}
+
+		generated.Add(1)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		encoder.Encode(map[string]any{"model": fakellm.DefaultModel, "response": answer, "done": false})
+		encoder.Encode(map[string]any{"model": fakellm.DefaultModel, "response": "", "done": true})
+	})
+}
+
+func TestProcessArticle(t *testing.T) {
+	for _, structured := range []bool{true, false} {
+		t.Run(fmt.Sprintf("structured=%v", structured), func(t *testing.T) {
+			bot := testAnalysisBot(t, fakellm.NewServer().Handler(), structured)
+			page := testArticleServer(t)
+			ctx := context.Background()
+
+			outcome, err := bot.processArticle(ctx, page.URL+"/news/1", bot.settingsFor(nil), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if outcome.Duplicate != nil || !outcome.Saved {
+				t.Fatalf("статья не сохранена: %+v", outcome)
+			}
+
+			art := outcome.Article
+			if len(art.Errors) > 0 {
+				t.Fatalf("ошибки анализа: %v", art.Errors)
+			}
+			if art.Sentiment != "Позитивный" {
+				t.Fatalf("отношение %q, ожидалось Позитивный", art.Sentiment)
+			}
+			if art.Affiliation == "" || art.Justification == "" || art.Title == "" {
+				t.Fatalf("нет связи с объектом, обоснования или заголовка: %+v", art)
+			}
+			if art.Provenance == nil || art.Provenance.Model != fakellm.DefaultModel || len(art.Provenance.Prompts) == 0 {
+				t.Fatalf("происхождение не заполнено: %+v", art.Provenance)
+			}
+
+			stored, err := bot.storedArticleByURL(ctx, art.SourceURL)
+			if err != nil || stored == nil {
+				t.Fatalf("статья не найдена в базе: %v", err)
+			}
+			if stored.Sentiment != art.Sentiment || len(stored.Embedding) != fakellm.EmbeddingDimensions {
+				t.Fatalf("в базе отношение %q и вектор длины %d", stored.Sentiment, len(stored.Embedding))
+			}
+
+			// Повторная отправка того же адреса не анализируется
+			again, err := bot.processArticle(ctx, page.URL+"/news/1", bot.settingsFor(nil), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.Duplicate == nil || again.DuplicateReason != duplicateByURL {
+				t.Fatalf("дубликат по адресу не найден: %+v", again)
+			}
+		})
+	}
+}
+
+func TestAnalyzeContentMalformedJSON(t *testing.T) {
+	var generated atomic.Int64
+	bot := testAnalysisBot(t, malformedLLM("Отношение скорее отрицательное, {\"sentiment\":", &generated), true)
+
+	art := &domain.Article{Title: "Заголовок", Content: testArticleText}
+	if err := bot.analyzeContent(context.Background(), art, bot.settingsFor(nil), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Все попытки структурированного ответа, затем два текстовых запроса
+	want := int64(bot.conf.Ollama.StructuredRetries) + 1 + 2
+	if generated.Load() != want {
+		t.Fatalf("запросов к модели %d, ожидалось %d", generated.Load(), want)
+	}
+	if len(art.Errors) > 0 {
+		t.Fatalf("ошибки анализа: %v", art.Errors)
+	}
+	if art.Sentiment != "Отрицательный" {
+		t.Fatalf("отношение %q, ожидалось Отрицательный", art.Sentiment)
+	}
+	if art.Objects[0].Affiliation == "" {
+		t.Fatal("текстовый ответ о связи с объектом не сохранен")
+	}
+}
+
+func TestAnalyzeContentUnrecognized(t *testing.T) {
+	var generated atomic.Int64
+	bot := testAnalysisBot(t, malformedLLM("lorem ipsum", &generated), false)
+
+	art := &domain.Article{Content: testArticleText}
+	if err := bot.analyzeContent(context.Background(), art, bot.settingsFor(nil), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if art.Sentiment != "Не определено" {
+		t.Fatalf("отношение %q, ожидалось Не определено", art.Sentiment)
+	}
+	if art.Title != "lorem ipsum" {
+		t.Fatalf("заголовок %q", art.Title)
+	}
+}
+
+func TestAnalyzeContentModelError(t *testing.T) {
+	llm := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"error":"model crashed"}`)
+	})
+	bot := testAnalysisBot(t, llm, true)
+
+	art := &domain.Article{Content: testArticleText}
+	if err := bot.analyzeContent(context.Background(), art, bot.settingsFor(nil), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ошибки отдельных запросов не прерывают анализ, а сохраняются в статье и ее происхождении
+	if len(art.Errors) == 0 {
+		t.Fatal("ошибки модели не сохранены")
+	}
+	if len(art.Provenance.Errors) != len(art.Errors) {
+		t.Fatalf("в происхождении %d ошибок из %d", len(art.Provenance.Errors), len(art.Errors))
+	}
+	for _, err := range art.Errors {
+		if !strings.Contains(err.Error(), "model crashed") {
+			t.Fatalf("неожиданная ошибка: %v", err)
+		}
+	}
+	if art.Sentiment != "" || art.Title != "" {
+		t.Fatalf("результат без ответа модели: %q, %q", art.Sentiment, art.Title)
+	}
+}
+
+func TestAnalyzeContentCanceled(t *testing.T) {
+	bot := testAnalysisBot(t, fakellm.NewServer().Handler(), true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	art := &domain.Article{Content: testArticleText}
+	if err := bot.analyzeContent(ctx, art, bot.settingsFor(nil), nil); err != context.Canceled {
+		t.Fatalf("ошибка %v, ожидалась отмена", err)
+	}
+}
@@ -0,0 +1,862 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+const (
+	apiDefaultLimit = 50
+	apiMaxLimit     = 500
+)
+
+// Результат анализа статьи по объекту в ответах API
+type apiObjectResult struct {
+	Object        string  `json:"object"`
+	Affiliation   string  `json:"affiliation"`
+	Sentiment     string  `json:"sentiment"`
+	Justification string  `json:"justification"`
+	Confidence    float64 `json:"confidence"`
+	Verified      bool    `json:"verified"`
+}
+
+// Статья в ответах API
+type apiArticle struct {
+	ID             int64              `json:"id"`
+	Title          string             `json:"title"`
+	SourceURL      string             `json:"source_url"`
+	Content        string             `json:"content,omitempty"`
+	CreatedAt      *time.Time         `json:"created_at,omitempty"`
+	PublishedAt    *time.Time         `json:"published_at,omitempty"`
+	Citations      int64              `json:"citations"`
+	Original       bool               `json:"original"`
+	SimilarURLs    []string           `json:"similar_urls"`
+	Affiliation    string             `json:"affiliation"`
+	Sentiment      string             `json:"sentiment"`
+	Justification  string             `json:"justification"`
+	Confidence     float64            `json:"confidence"`
+	StoryID        int64              `json:"story_id,omitempty"`
+	Irrelevant     bool               `json:"irrelevant"`
+	Objects        []apiObjectResult  `json:"objects"`
+	Similarity     float64            `json:"similarity,omitempty"`
+	TrueSimilarity float64            `json:"true_similarity,omitempty"`
+	TextSimilarity float64            `json:"text_similarity,omitempty"` // Почти дословная копия: оценка совпадения текста
+	Provenance     *domain.Provenance `json:"provenance,omitempty"`
+	VerifiedBy     string             `json:"verified_by,omitempty"`
+	VerifiedAt     *time.Time         `json:"verified_at,omitempty"`
+	History        []apiHistoryEntry  `json:"history,omitempty"`
+	Snippet        string             `json:"snippet,omitempty"`
+	Errors         []string           `json:"errors,omitempty"`
+}
+
+// Прежний результат анализа статьи
+type apiHistoryEntry struct {
+	Object        string             `json:"object"`
+	Affiliation   string             `json:"affiliation"`
+	Sentiment     string             `json:"sentiment"`
+	Justification string             `json:"justification"`
+	Confidence    float64            `json:"confidence"`
+	ReplacedAt    *time.Time         `json:"replaced_at"`
+	Provenance    *domain.Provenance `json:"provenance,omitempty"`
+}
+
+type apiSearchResults struct {
+	Query string       `json:"query"`
+	Items []apiArticle `json:"items"`
+}
+
+type apiSentimentRequest struct {
+	Sentiment string `json:"sentiment"`
+	Object    string `json:"object"` // Пусто - основной объект
+}
+
+type apiSentimentCorrection struct {
+	Article apiArticle `json:"article"`
+	Object  string     `json:"object,omitempty"`
+	Before  string     `json:"before"`
+	After   string     `json:"after"`
+}
+
+type apiDeleted struct {
+	ID      int64 `json:"id"`
+	Deleted bool  `json:"deleted"`
+}
+
+type apiArticlesPage struct {
+	Items  []apiArticle `json:"items"`
+	Total  int          `json:"total"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+}
+
+type apiAnalysis struct {
+	Article         apiArticle   `json:"article"`
+	Duplicate       *apiArticle  `json:"duplicate,omitempty"`
+	DuplicateReason string       `json:"duplicate_reason,omitempty"` // url или content
+	Similar         []apiArticle `json:"similar"`
+	Saved           bool         `json:"saved"`
+	SheetsPushed    bool         `json:"sheets_pushed"`
+	SheetsError     string       `json:"sheets_error,omitempty"`
+}
+
+type apiSimilarity struct {
+	Duplicate       *apiArticle  `json:"duplicate,omitempty"`
+	DuplicateReason string       `json:"duplicate_reason,omitempty"`
+	Candidates      int          `json:"candidates"`
+	Similar         []apiArticle `json:"similar"`
+}
+
+// Запись журнала в ответах API
+type apiAuditEntry struct {
+	ID        int64                 `json:"id"`
+	CreatedAt *time.Time            `json:"created_at"`
+	Platform  string                `json:"platform"`
+	UserID    string                `json:"user_id"`
+	UserName  string                `json:"user_name,omitempty"`
+	Command   string                `json:"command"`
+	Args      string                `json:"args"`
+	Error     string                `json:"error,omitempty"`
+	Changes   []domain.ConfigChange `json:"changes"`
+}
+
+type apiAuditPage struct {
+	Items  []apiAuditEntry `json:"items"`
+	Total  int             `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+}
+
+type apiPrompt struct {
+	Hash string `json:"hash"`
+	Text string `json:"text"`
+}
+
+type apiURLRequest struct {
+	URL string `json:"url"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func unixTime(timestamp int64) *time.Time {
+	if timestamp <= 0 {
+		return nil
+	}
+
+	t := time.Unix(timestamp, 0).UTC()
+	return &t
+}
+
+func newAPIArticle(art *domain.Article, withContent bool) apiArticle {
+	result := apiArticle{
+		ID:             art.ID,
+		Title:          art.Title,
+		SourceURL:      art.SourceURL,
+		CreatedAt:      unixTime(art.CreatedAt),
+		PublishedAt:    unixTime(art.PublishedAt),
+		Citations:      art.Citations,
+		Original:       art.Original,
+		SimilarURLs:    art.SimilarURLs,
+		Affiliation:    art.Affiliation,
+		Sentiment:      art.Sentiment,
+		Justification:  art.Justification,
+		Confidence:     art.Confidence,
+		StoryID:        art.StoryID,
+		Irrelevant:     art.Irrelevant,
+		Objects:        []apiObjectResult{},
+		Similarity:     art.Similarity,
+		TrueSimilarity: art.TrueSimilarity,
+		TextSimilarity: art.TextSimilarity,
+		Provenance:     art.Provenance,
+		VerifiedBy:     art.VerifiedBy,
+	}
+	if art.Verified() {
+		result.VerifiedAt = unixTime(art.VerifiedAt)
+	}
+	if withContent {
+		result.Content = art.Content
+	}
+	if result.SimilarURLs == nil {
+		result.SimilarURLs = []string{}
+	}
+
+	for _, object := range art.Objects {
+		result.Objects = append(result.Objects, apiObjectResult{
+			Object:        object.Object,
+			Affiliation:   object.Affiliation,
+			Sentiment:     object.Sentiment,
+			Justification: object.Justification,
+			Confidence:    object.Confidence,
+			Verified:      object.Verified,
+		})
+	}
+
+	result.Errors = errorStrings(art.Errors)
+	if len(result.Errors) == 0 && art.Provenance != nil {
+		result.Errors = art.Provenance.Errors
+	}
+
+	return result
+}
+
+func newAPIArticles(articles []domain.Article) []apiArticle {
+	result := make([]apiArticle, 0, len(articles))
+	for i := range articles {
+		result = append(result, newAPIArticle(&articles[i], false))
+	}
+
+	return result
+}
+
+func newAPIAuditEntry(entry domain.AuditEntry) apiAuditEntry {
+	result := apiAuditEntry{
+		ID:        entry.ID,
+		CreatedAt: unixTime(entry.CreatedAt),
+		Platform:  entry.Platform,
+		UserID:    entry.UserID,
+		UserName:  entry.UserName,
+		Command:   entry.Command,
+		Args:      entry.Args,
+		Error:     entry.Error,
+		Changes:   entry.Changes,
+	}
+	if result.Changes == nil {
+		result.Changes = []domain.ConfigChange{}
+	}
+
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Ошибка отправки ответа API: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{Error: message})
+}
+
+// Достает JWT из заголовка Authorization (Bearer) или из куки
+func tokenFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	if cookie, err := r.Cookie("auth_token"); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+// Проверяет JWT запроса и возвращает его утверждения
+func (ws *WebServer) authenticate(r *http.Request) (jwt.MapClaims, error) {
+	tokenString := tokenFromRequest(r)
+	if tokenString == "" {
+		return nil, errors.New("отсутствует токен")
+	}
+
+	token, err := ws.validateJWT(tokenString)
+	if err != nil || !token.Valid {
+		return nil, errors.New("недействительный токен")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("недействительный токен")
+	}
+
+	// Токен должен быть выдан этим сервером и не отозван
+	jti, _ := claims["jti"].(string)
+	session, err := ws.bot.conf.GetDB().GetWebSession(jti)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || !session.Active(time.Now().Unix()) {
+		return nil, errors.New("токен отозван или истек")
+	}
+
+	username, _ := claims["username"].(string)
+	if session.Username != username || ws.bot.webRole(username) == domain.RoleNone {
+		return nil, errors.New("недействительный токен")
+	}
+
+	return claims, nil
+}
+
+// Пользователь веб-интерфейса, от имени которого выполняется запрос API
+func (ws *WebServer) apiCaller(r *http.Request) Caller {
+	caller := Caller{Platform: domain.PlatformWeb}
+	if claims, err := ws.authenticate(r); err == nil {
+		caller.UserID, _ = claims["username"].(string)
+	}
+
+	return caller
+}
+
+// Проверяет JWT запроса и возвращает роль его пользователя
+func (ws *WebServer) authorize(r *http.Request) (domain.Role, error) {
+	claims, err := ws.authenticate(r)
+	if err != nil {
+		return domain.RoleNone, err
+	}
+
+	username, _ := claims["username"].(string)
+	return ws.bot.webRole(username), nil
+}
+
+func (ws *WebServer) requireRole(minRole domain.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, err := ws.authorize(r)
+		if err != nil {
+			writeAPIError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		if !role.Allows(minRole) {
+			writeAPIError(w, http.StatusForbidden, fmt.Sprintf("недостаточно прав: требуется роль %s", minRole))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (ws *WebServer) registerAPI(r *mux.Router) {
+	api := r.PathPrefix("/api/v1").Subrouter()
+
+	api.HandleFunc("/token", ws.handleAPIToken).Methods("POST")
+	api.HandleFunc("/articles", ws.requireRole(domain.RoleViewer, ws.handleAPIArticles)).Methods("GET")
+	api.HandleFunc("/articles/by-url", ws.requireRole(domain.RoleViewer, ws.handleAPIArticleByURL)).Methods("GET")
+	api.HandleFunc("/articles/{id:[0-9]+}", ws.requireRole(domain.RoleViewer, ws.handleAPIArticle)).Methods("GET")
+	api.HandleFunc("/articles/{id:[0-9]+}", ws.requireRole(domain.RoleAdmin, ws.handleAPIDeleteArticle)).Methods("DELETE")
+	api.HandleFunc("/articles/{id:[0-9]+}/sentiment", ws.requireRole(domain.RoleAnalyst, ws.handleAPISetSentiment)).Methods("PUT")
+	api.HandleFunc("/search", ws.requireRole(domain.RoleViewer, ws.handleAPISearch)).Methods("GET")
+	api.HandleFunc("/analyze", ws.requireRole(domain.RoleAnalyst, ws.handleAPIAnalyze)).Methods("POST")
+	api.HandleFunc("/prompts/{hash:[0-9a-f]+}", ws.requireRole(domain.RoleViewer, ws.handleAPIPrompt)).Methods("GET")
+	api.HandleFunc("/similar", ws.requireRole(domain.RoleAnalyst, ws.handleAPISimilar)).Methods("GET", "POST")
+	api.HandleFunc("/config", ws.requireRole(domain.RoleAdmin, ws.handleAPIConfig)).Methods("GET")
+	api.HandleFunc("/audit", ws.requireRole(domain.RoleAdmin, ws.handleAPIAudit)).Methods("GET")
+	api.HandleFunc("/audit/{id:[0-9]+}", ws.requireRole(domain.RoleAdmin, ws.handleAPIAuditEntry)).Methods("GET")
+}
+
+// Выдает токен для использования в заголовке Authorization
+func (ws *WebServer) handleAPIToken(w http.ResponseWriter, r *http.Request) {
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "неверный формат JSON")
+			return
+		}
+	} else {
+		credentials.Username = r.FormValue("username")
+		credentials.Password = r.FormValue("password")
+	}
+
+	user, err := ws.bot.authenticateWebUser(credentials.Username, credentials.Password)
+	if err != nil {
+		writeAPIError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	role := ws.bot.webRole(user.Username)
+	if role == domain.RoleNone {
+		writeAPIError(w, http.StatusForbidden, "у пользователя нет роли")
+		return
+	}
+
+	token, expiresAt, err := ws.generateJWT(user.Username, role)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "не удалось создать токен")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"token":      token,
+		"token_type": "Bearer",
+		"expires_at": expiresAt.UTC(),
+		"username":   user.Username,
+		"role":       role.String(),
+	})
+}
+
+// Разбирает параметры выборки статей из строки запроса
+func parseArticleFilter(r *http.Request) (domain.ArticleFilter, error) {
+	query := r.URL.Query()
+	filter := domain.ArticleFilter{
+		Limit:     apiDefaultLimit,
+		Search:    strings.TrimSpace(query.Get("q")),
+		Sentiment: strings.TrimSpace(query.Get("sentiment")),
+		Object:    strings.TrimSpace(query.Get("object")),
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return filter, errors.New("limit должен быть положительным числом")
+		}
+		if limit > apiMaxLimit {
+			limit = apiMaxLimit
+		}
+		filter.Limit = limit
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return filter, errors.New("offset должен быть неотрицательным числом")
+		}
+		filter.Offset = offset
+	}
+
+	for name, target := range map[string]*int64{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+
+		timestamp, err := parseAPITime(value)
+		if err != nil {
+			return filter, fmt.Errorf("%s: %w", name, err)
+		}
+		*target = timestamp
+	}
+
+	for name, target := range map[string]**bool{"original": &filter.Original, "irrelevant": &filter.Irrelevant, "verified": &filter.Verified} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("%s должен быть true или false", name)
+		}
+		*target = &flag
+	}
+
+	return filter, nil
+}
+
+// Принимает дату в формате RFC3339, YYYY-MM-DD или Unix timestamp
+func parseAPITime(value string) (int64, error) {
+	if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return timestamp, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), nil
+	}
+
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t.Unix(), nil
+	}
+
+	return 0, errors.New("неверный формат даты. Используйте RFC3339, YYYY-MM-DD или Unix timestamp")
+}
+
+func (ws *WebServer) handleAPIArticles(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseArticleFilter(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	articles, total, err := ws.bot.conf.GetDB().QueryArticles(filter)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "ошибка загрузки статей: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiArticlesPage{
+		Items:  newAPIArticles(articles),
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	})
+}
+
+func (ws *WebServer) handleAPIArticle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "неверный ID статьи")
+		return
+	}
+
+	article, err := ws.bot.conf.GetDB().GetArticle(id)
+	ws.writeArticleDetail(w, article, err)
+}
+
+func (ws *WebServer) handleAPIArticleByURL(w http.ResponseWriter, r *http.Request) {
+	articleURL := strings.TrimSpace(r.URL.Query().Get("url"))
+	if articleURL == "" {
+		writeAPIError(w, http.StatusBadRequest, "не указан url")
+		return
+	}
+
+	article, err := ws.bot.conf.GetDB().GetArticleByURL(r.Context(), articleURL)
+	ws.writeArticleDetail(w, article, err)
+}
+
+// Отвечает статьей вместе с текстом и прежними результатами анализа
+func (ws *WebServer) writeArticleDetail(w http.ResponseWriter, article *domain.Article, err error) {
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "ошибка загрузки статьи: "+err.Error())
+		return
+	}
+	if article == nil {
+		writeAPIError(w, http.StatusNotFound, "статья не найдена")
+		return
+	}
+
+	history, err := ws.bot.conf.GetDB().GetAnalysisHistory(article.ID)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "ошибка загрузки истории анализа: "+err.Error())
+		return
+	}
+
+	result := newAPIArticle(article, true)
+	for _, entry := range history {
+		result.History = append(result.History, apiHistoryEntry{
+			Object:        entry.Object,
+			Affiliation:   entry.Affiliation,
+			Sentiment:     entry.Sentiment,
+			Justification: entry.Justification,
+			Confidence:    entry.Confidence,
+			ReplacedAt:    unixTime(entry.ReplacedAt),
+			Provenance:    entry.Provenance,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (ws *WebServer) handleAPISearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeAPIError(w, http.StatusBadRequest, "не указан текст поиска q")
+		return
+	}
+
+	limit := apiDefaultLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "limit должен быть положительным числом")
+			return
+		}
+		if parsed > apiMaxLimit {
+			parsed = apiMaxLimit
+		}
+		limit = parsed
+	}
+
+	hits, err := ws.bot.conf.GetDB().SearchArticles(r.Context(), query, limit)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "ошибка поиска: "+err.Error())
+		return
+	}
+
+	results := apiSearchResults{
+		Query: query,
+		Items: make([]apiArticle, 0, len(hits)),
+	}
+	for i := range hits {
+		item := newAPIArticle(&hits[i].Article, false)
+		item.Snippet = hits[i].Snippet
+		results.Items = append(results.Items, item)
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (ws *WebServer) handleAPISetSentiment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "неверный ID статьи")
+		return
+	}
+
+	var body apiSentimentRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "неверный формат JSON")
+		return
+	}
+
+	caller := ws.apiCaller(r)
+	correction, err := ws.bot.correctSentiment(r.Context(), id, body.Sentiment, strings.TrimSpace(body.Object), caller)
+	ws.bot.recordAudit(caller, ws.bot.CommandByName("setsentiment"), strings.TrimSpace(fmt.Sprintf("%d %s %s", id, body.Sentiment, body.Object)), err, nil)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiSentimentCorrection{
+		Article: newAPIArticle(correction.Article, false),
+		Object:  correction.Object,
+		Before:  correction.Before,
+		After:   correction.After,
+	})
+}
+
+func (ws *WebServer) handleAPIDeleteArticle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "неверный ID статьи")
+		return
+	}
+
+	deleted, err := ws.bot.deleteArticle(r.Context(), id)
+	status := http.StatusInternalServerError
+	if err == nil && !deleted {
+		err = fmt.Errorf("статья #%d не найдена", id)
+		status = http.StatusNotFound
+	}
+	ws.bot.recordAudit(ws.apiCaller(r), ws.bot.CommandByName("rmarticle"), strconv.FormatInt(id, 10), err, nil)
+	if err != nil {
+		writeAPIError(w, status, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiDeleted{ID: id, Deleted: true})
+}
+
+func (ws *WebServer) handleAPIPrompt(w http.ResponseWriter, r *http.Request) {
+	hash, text, err := ws.bot.conf.GetDB().GetPrompt(mux.Vars(r)["hash"])
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "ошибка загрузки промпта: "+err.Error())
+		return
+	}
+	if hash == "" {
+		writeAPIError(w, http.StatusNotFound, "промпт не найден")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiPrompt{Hash: hash, Text: text})
+}
+
+// Достает URL статьи из JSON тела, формы или строки запроса
+func requestURL(r *http.Request) (string, error) {
+	if r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var body apiURLRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return "", errors.New("неверный формат JSON")
+		}
+		return strings.TrimSpace(body.URL), nil
+	}
+
+	return strings.TrimSpace(r.FormValue("url")), nil
+}
+
+func (ws *WebServer) handleAPIAnalyze(w http.ResponseWriter, r *http.Request) {
+	articleURL, err := requestURL(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Статья анализируется с настройками профиля пользователя, если он есть
+	caller := ws.apiCaller(r)
+	settings := ws.bot.settingsFor(ws.bot.profileByScope(caller.ProfileScope()))
+
+	// Анализ отменяется командой cancel или закрытием запроса
+	job := ws.bot.jobs.start(caller, "do", articleURL)
+	defer ws.bot.jobs.finish(job)
+	defer context.AfterFunc(r.Context(), job.cancel)()
+
+	outcome, err := ws.bot.processArticle(job.ctx, articleURL, settings, nil)
+	if err != nil && errors.Is(job.ctx.Err(), context.Canceled) {
+		err = errCanceled
+	}
+	ws.bot.recordAudit(caller, ws.bot.CommandByName("do"), articleURL, err, nil)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	response := apiAnalysis{
+		Article:      newAPIArticle(outcome.Article, false),
+		Similar:      newAPIArticles(outcome.Similar),
+		Saved:        outcome.Saved,
+		SheetsPushed: outcome.SheetsPushed,
+	}
+	if outcome.Duplicate != nil {
+		duplicate := newAPIArticle(outcome.Duplicate, false)
+		response.Duplicate = &duplicate
+		response.DuplicateReason = outcome.DuplicateReason
+	}
+	if outcome.SheetsError != nil {
+		response.SheetsError = outcome.SheetsError.Error()
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (ws *WebServer) handleAPISimilar(w http.ResponseWriter, r *http.Request) {
+	articleURL, err := requestURL(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	outcome, err := ws.bot.findSimilarArticles(r.Context(), articleURL)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	response := apiSimilarity{
+		Candidates: outcome.Candidates,
+		Similar:    newAPIArticles(outcome.Similar),
+	}
+	if outcome.Duplicate != nil {
+		duplicate := newAPIArticle(outcome.Duplicate, false)
+		response.Duplicate = &duplicate
+		response.DuplicateReason = outcome.DuplicateReason
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Конфигурация без секретов
+func (ws *WebServer) handleAPIConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, ws.bot.conf.Redacted())
+}
+
+// Разбирает параметры выборки журнала из строки запроса
+func parseAuditFilter(r *http.Request) (domain.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := domain.AuditFilter{
+		Limit:   apiDefaultLimit,
+		User:    strings.TrimSpace(query.Get("user")),
+		Command: strings.TrimSpace(query.Get("command")),
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return filter, errors.New("limit должен быть положительным числом")
+		}
+		if limit > apiMaxLimit {
+			limit = apiMaxLimit
+		}
+		filter.Limit = limit
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return filter, errors.New("offset должен быть неотрицательным числом")
+		}
+		filter.Offset = offset
+	}
+
+	for name, target := range map[string]*int64{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+
+		timestamp, err := parseAPITime(value)
+		if err != nil {
+			return filter, fmt.Errorf("%s: %w", name, err)
+		}
+		*target = timestamp
+	}
+
+	if value := query.Get("changes"); value != "" {
+		changesOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New("changes должен быть true или false")
+		}
+		filter.ChangesOnly = changesOnly
+	}
+
+	return filter, nil
+}
+
+func (ws *WebServer) handleAPIAudit(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, total, err := ws.bot.conf.GetDB().QueryAudit(filter)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "ошибка чтения журнала: "+err.Error())
+		return
+	}
+
+	page := apiAuditPage{
+		Items:  make([]apiAuditEntry, 0, len(entries)),
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}
+	for _, entry := range entries {
+		page.Items = append(page.Items, newAPIAuditEntry(entry))
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (ws *WebServer) handleAPIAuditEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "неверный ID записи")
+		return
+	}
+
+	entry, err := ws.bot.conf.GetDB().GetAuditEntry(id)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "ошибка чтения журнала: "+err.Error())
+		return
+	}
+	if entry == nil {
+		writeAPIError(w, http.StatusNotFound, "запись не найдена")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIAuditEntry(*entry))
+}
@@ -0,0 +1,306 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/db"
+	"Unbewohnte/ACASbot/internal/domain"
+	"Unbewohnte/ACASbot/internal/inference"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	searchResultsLimit  = 10 // Статей в ответе на search
+	articleHistoryLimit = 10 // Прежних результатов в ответе на article
+)
+
+// Разбирает ID статьи вида "12" или "#12"
+func parseArticleID(value string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(value), "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("неверный ID статьи \"%s\"", value)
+	}
+
+	return id, nil
+}
+
+// Находит статью по ID или URL источника
+func (bot *Bot) findArticle(ctx context.Context, ref string) (*domain.Article, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, errors.New("укажите ID или URL статьи")
+	}
+
+	var (
+		article *domain.Article
+		err     error
+	)
+	if strings.HasPrefix(ref, "http") {
+		// Статьи сохраняются с каноническими адресами, старые - с исходными
+		article, err = bot.conf.GetDB().GetArticleByURL(ctx, canonicalURL(ref))
+		if err == nil && article == nil {
+			article, err = bot.conf.GetDB().GetArticleByURL(ctx, ref)
+		}
+	} else {
+		id, parseErr := parseArticleID(ref)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		article, err = bot.conf.GetDB().GetArticle(id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить статью: %w", err)
+	}
+	if article == nil {
+		return nil, fmt.Errorf("статья %s не найдена", ref)
+	}
+
+	return article, nil
+}
+
+// Приводит метку отношения к одной из допустимых
+func parseSentimentLabel(value string) (string, error) {
+	for _, label := range inference.SentimentLabels {
+		if strings.EqualFold(strings.TrimSpace(value), label) {
+			return label, nil
+		}
+	}
+
+	return "", fmt.Errorf("неизвестное отношение \"%s\". Допустимые: %s", value, strings.Join(inference.SentimentLabels, ", "))
+}
+
+// Результат ручного исправления отношения
+type sentimentCorrection struct {
+	Article *domain.Article // Статья после исправления
+	Object  string          // Пусто для статей без результатов по объектам
+	Before  string
+	After   string
+}
+
+// Исправляет отношение статьи к объекту (пустой объект - к основному) и помечает
+// результат как проверенный человеком. Прежний результат сохраняется в истории
+func (bot *Bot) correctSentiment(ctx context.Context, id int64, label string, object string, caller Caller) (*sentimentCorrection, error) {
+	sentiment, err := parseSentimentLabel(label)
+	if err != nil {
+		return nil, err
+	}
+
+	article, err := bot.conf.GetDB().GetArticle(id)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить статью: %w", err)
+	}
+	if article == nil {
+		return nil, fmt.Errorf("статья #%d не найдена", id)
+	}
+
+	correction := &sentimentCorrection{After: sentiment}
+	primary := true
+	switch {
+	case object == "" && len(article.Objects) > 0:
+		correction.Object = article.Objects[0].Object
+		correction.Before = article.Objects[0].Sentiment
+	case object == "":
+		correction.Before = article.Sentiment
+	default:
+		index := -1
+		for i, result := range article.Objects {
+			if strings.EqualFold(result.Object, object) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("у статьи #%d нет результата по объекту \"%s\"", id, object)
+		}
+
+		correction.Object = article.Objects[index].Object
+		correction.Before = article.Objects[index].Sentiment
+		primary = index == 0
+	}
+
+	justification := fmt.Sprintf("Отношение исправлено вручную (%s)", caller)
+	err = bot.mirrorArticle(ctx, article.SourceURL, func(store db.ArticleStore, id int64) error {
+		return store.CorrectSentiment(ctx, id, correction.Object, primary, sentiment, justification, caller.String())
+	})
+	if err == nil {
+		err = bot.conf.GetDB().CorrectSentiment(ctx, id, correction.Object, primary, sentiment, justification, caller.String())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сохранить исправление: %w", err)
+	}
+
+	correction.Article, err = bot.conf.GetDB().GetArticle(id)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить статью: %w", err)
+	}
+
+	return correction, nil
+}
+
+// Подробности о статье: результаты анализа, происхождение, ручная проверка и история
+func (bot *Bot) ShowArticle(call *CallContext) (string, error) {
+	article, err := bot.findArticle(call.Context(), call.Args)
+	if err != nil {
+		return "", err
+	}
+
+	history, err := bot.conf.GetDB().GetAnalysisHistory(article.ID)
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить историю анализа: %w", err)
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("📰 *Статья #%d*\n", article.ID))
+	response.WriteString(fmt.Sprintf("*URL:* %s\n", article.SourceURL))
+	response.WriteString(fmt.Sprintf("*Добавлена:* %s\n", time.Unix(article.CreatedAt, 0).Format("2006-01-02 15:04")))
+	if article.Original {
+		response.WriteString(fmt.Sprintf("*Оригинал:* да, цитирований: %d\n", article.Citations))
+	} else {
+		response.WriteString(fmt.Sprintf("*Оригинал:* нет, цитирований: %d\n", article.Citations))
+	}
+	if article.Verified() {
+		response.WriteString(fmt.Sprintf("✅ *Проверено вручную:* %s, %s\n",
+			article.VerifiedBy,
+			time.Unix(article.VerifiedAt, 0).Format("2006-01-02 15:04"),
+		))
+	}
+	response.WriteString("\n")
+	response.WriteString(bot.formatAnalysisResult(article))
+
+	if len(history) > 0 {
+		response.WriteString(fmt.Sprintf("\n*Прежние результаты (%d):*\n", len(history)))
+		for i, entry := range history {
+			if i == articleHistoryLimit {
+				response.WriteString(fmt.Sprintf("...и еще %d\n", len(history)-articleHistoryLimit))
+				break
+			}
+
+			object := entry.Object
+			if object == "" {
+				object = "основной объект"
+			}
+			response.WriteString(fmt.Sprintf("- %s, %s: %s",
+				time.Unix(entry.ReplacedAt, 0).Format("2006-01-02 15:04"),
+				object,
+				entry.Sentiment,
+			))
+			if entry.Provenance != nil && entry.Provenance.Model != "" {
+				response.WriteString(fmt.Sprintf(" (`%s`)", entry.Provenance.Model))
+			}
+			response.WriteString("\n")
+		}
+	}
+
+	return response.String(), nil
+}
+
+// Полнотекстовый поиск по заголовкам, текстам и примечаниям сохраненных статей
+func (bot *Bot) SearchArticles(call *CallContext) (string, error) {
+	text := strings.TrimSpace(call.Args)
+	if text == "" {
+		return "", errors.New("укажите текст для поиска")
+	}
+
+	hits, err := bot.conf.GetDB().SearchArticles(call.Context(), text, searchResultsLimit)
+	if err != nil {
+		return "", fmt.Errorf("ошибка поиска: %w", err)
+	}
+	if len(hits) == 0 {
+		return fmt.Sprintf("По запросу \"%s\" ничего не найдено", text), nil
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("🔎 *Найдено по запросу \"%s\":*\n", text))
+	for i, hit := range hits {
+		title := hit.Article.Title
+		if title == "" {
+			title = hit.Article.SourceURL
+		}
+
+		response.WriteString(fmt.Sprintf("\n%d. #%d [%s](%s)", i+1, hit.Article.ID, title, hit.Article.SourceURL))
+		if hit.Article.Sentiment != "" {
+			response.WriteString(" - " + hit.Article.Sentiment)
+		}
+		if hit.Article.Verified() {
+			response.WriteString(" ✅")
+		}
+		response.WriteString("\n")
+
+		if hit.Snippet != "" {
+			response.WriteString(fmt.Sprintf("   %s\n", hit.Snippet))
+		}
+	}
+	response.WriteString("\nПодробнее: `article <id>`")
+
+	return response.String(), nil
+}
+
+// Ручное исправление отношения. Формат: "ID метка [объект]"
+func (bot *Bot) SetSentiment(call *CallContext) (string, error) {
+	fields := strings.Fields(call.Args)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("укажите ID статьи и отношение (%s), при необходимости - объект", strings.Join(inference.SentimentLabels, ", "))
+	}
+
+	id, err := parseArticleID(fields[0])
+	if err != nil {
+		return "", err
+	}
+
+	correction, err := bot.correctSentiment(call.Context(), id, fields[1], strings.Join(fields[2:], " "), call.Caller)
+	if err != nil {
+		return "", err
+	}
+
+	target := "статьи"
+	if correction.Object != "" {
+		target = fmt.Sprintf("статьи к \"%s\"", correction.Object)
+	}
+	before := correction.Before
+	if before == "" {
+		before = "не определено"
+	}
+
+	return fmt.Sprintf("✅ Отношение %s #%d исправлено: %s → %s. Результат помечен как проверенный вручную, прежний сохранен в истории",
+		target, id, before, correction.After,
+	), nil
+}
+
+// Удаляет статью из базы вместе с ее результатами и историей анализа
+func (bot *Bot) RemoveArticle(call *CallContext) (string, error) {
+	article, err := bot.findArticle(call.Context(), call.Args)
+	if err != nil {
+		return "", err
+	}
+
+	deleted, err := bot.deleteArticle(call.Context(), article.ID)
+	if err != nil {
+		return "", fmt.Errorf("не удалось удалить статью: %w", err)
+	}
+	if !deleted {
+		return "", fmt.Errorf("статья #%d не найдена", article.ID)
+	}
+
+	return fmt.Sprintf("Статья #%d \"%s\" удалена", article.ID, article.Title), nil
+}
@@ -0,0 +1,280 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/audit"
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Пользователь, вызвавший команду
+type Caller struct {
+	Platform string // telegram или web
+	UserID   string // ID в Telegram или имя пользователя веб-интерфейса
+	UserName string // Имя пользователя в Telegram
+	ChatID   int64  // Чат Telegram, из которого пришла команда
+}
+
+func (c Caller) String() string {
+	if c.UserName != "" && c.UserName != c.UserID {
+		return fmt.Sprintf("%s:%s (@%s)", c.Platform, c.UserID, c.UserName)
+	}
+
+	return fmt.Sprintf("%s:%s", c.Platform, c.UserID)
+}
+
+const auditValueLimit = 200
+
+// Аргументы команды в том виде, в котором они попадут в журнал
+func (command *Command) auditArgs(args string) string {
+	if !command.SecretArgs {
+		return args
+	}
+
+	// Оставляем только первый аргумент (имя пользователя), остальное может быть паролем
+	fields := strings.Fields(args)
+	if len(fields) <= 1 {
+		return args
+	}
+
+	return fields[0] + " ***"
+}
+
+// Текст сообщения для журнала приложения: аргументы секретных команд скрываются так же, как в аудите
+func (bot *Bot) loggableText(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return text
+	}
+
+	name := strings.ToLower(strings.TrimPrefix(fields[0], "/"))
+	for index := range bot.commands {
+		command := &bot.commands[index]
+		if command.SecretArgs && strings.HasPrefix(name, command.Name) {
+			return fields[0] + " " + command.auditArgs(strings.Join(fields[1:], " "))
+		}
+	}
+
+	return text
+}
+
+// Снимок конфигурации без секретов для сравнения до и после команды
+func (bot *Bot) configSnapshot() audit.Snapshot {
+	snapshot, err := audit.Take(bot.conf.Redacted())
+	if err != nil {
+		log.Printf("Не удалось сделать снимок конфигурации: %v", err)
+		return nil
+	}
+
+	return snapshot
+}
+
+// Снимок конфигурации вместе с профилем вызвавшего. Поля профиля хранятся с префиксом "profile."
+func (bot *Bot) callSnapshot(caller Caller) audit.Snapshot {
+	snapshot := bot.configSnapshot()
+	if snapshot == nil {
+		return nil
+	}
+
+	profile := bot.profileByScope(caller.ProfileScope())
+	if profile == nil {
+		return snapshot
+	}
+
+	profileSnapshot, err := audit.Take(profile)
+	if err != nil {
+		log.Printf("Не удалось сделать снимок профиля: %v", err)
+		return snapshot
+	}
+	for path, value := range profileSnapshot {
+		snapshot["profile."+path] = value
+	}
+
+	return snapshot
+}
+
+// Вызывает команду как отменяемое задание и записывает вызов в журнал. Для команд,
+// меняющих конфигурацию, записываются и изменения конфигурации и профиля: снимки
+// до и после делаются под bot.configMu, чтобы изменения, сделанные одновременно
+// другой командой, не приписывались этой
+func (bot *Bot) runCommand(call *CallContext, command *Command) (*Response, error) {
+	call.job = bot.jobs.start(call.Caller, command.Name, command.auditArgs(call.Args))
+	defer bot.jobs.finish(call.job)
+
+	var before audit.Snapshot
+	if command.ChangesConfig {
+		bot.configMu.Lock()
+		defer bot.configMu.Unlock()
+		before = bot.callSnapshot(call.Caller)
+	}
+
+	response, err := command.Call(call)
+	if err != nil && errors.Is(call.job.ctx.Err(), context.Canceled) {
+		err = errCanceled
+	}
+	bot.recordAudit(call.Caller, command, call.Args, err, before)
+
+	return response, err
+}
+
+// Записывает вызов команды в журнал. Если передан снимок конфигурации до вызова,
+// сохраняются и изменения конфигурации и профиля
+func (bot *Bot) recordAudit(caller Caller, command *Command, args string, callErr error, before audit.Snapshot) {
+	entry := domain.AuditEntry{
+		CreatedAt: time.Now().Unix(),
+		Platform:  caller.Platform,
+		UserID:    caller.UserID,
+		UserName:  caller.UserName,
+		Command:   command.Name,
+		Args:      command.auditArgs(args),
+	}
+	if callErr != nil {
+		entry.Error = callErr.Error()
+	}
+
+	if before != nil {
+		if after := bot.callSnapshot(caller); after != nil {
+			entry.Changes = audit.Diff(before, after)
+		}
+	}
+
+	if err := bot.conf.GetDB().SaveAuditEntry(&entry); err != nil {
+		log.Printf("Не удалось записать вызов %s в журнал: %v", command.Name, err)
+	}
+}
+
+func shortenAuditValue(value string) string {
+	if value == "" {
+		return "—"
+	}
+
+	runes := []rune(value)
+	if len(runes) > auditValueLimit {
+		return string(runes[:auditValueLimit]) + "..."
+	}
+
+	return value
+}
+
+func formatAuditEntry(entry domain.AuditEntry, detailed bool) string {
+	var response strings.Builder
+
+	caller := Caller{Platform: entry.Platform, UserID: entry.UserID, UserName: entry.UserName}
+	response.WriteString(fmt.Sprintf(
+		"\n*#%d* %s %s: `%s",
+		entry.ID, time.Unix(entry.CreatedAt, 0).Format("2006-01-02 15:04:05"), caller, entry.Command,
+	))
+	if entry.Args != "" {
+		response.WriteString(" " + strings.ReplaceAll(shortenAuditValue(entry.Args), "`", "'"))
+	}
+	response.WriteString("`\n")
+
+	if entry.Error != "" {
+		response.WriteString(fmt.Sprintf("- ❌ %s\n", entry.Error))
+	}
+
+	if len(entry.Changes) == 0 {
+		return response.String()
+	}
+
+	if !detailed {
+		paths := make([]string, 0, len(entry.Changes))
+		for _, change := range entry.Changes {
+			paths = append(paths, change.Path)
+		}
+		response.WriteString(fmt.Sprintf("- Изменено: `%s`\n", strings.Join(paths, "`, `")))
+		return response.String()
+	}
+
+	for _, change := range entry.Changes {
+		response.WriteString(fmt.Sprintf(
+			"- `%s`:\n  было: %s\n  стало: %s\n",
+			change.Path, shortenAuditValue(change.Before), shortenAuditValue(change.After),
+		))
+	}
+
+	return response.String()
+}
+
+// Формат: "audit [N] [changes] [команда|пользователь]" или "audit #ID" для подробностей записи
+func (bot *Bot) Audit(call *CallContext) (string, error) {
+	filter := domain.AuditFilter{Limit: 10}
+
+	for _, field := range strings.Fields(call.Args) {
+		if strings.HasPrefix(field, "#") {
+			id, err := strconv.ParseInt(strings.TrimPrefix(field, "#"), 10, 64)
+			if err != nil {
+				return "", errors.New("неверный номер записи")
+			}
+			return bot.auditEntryDetails(id)
+		}
+
+		// Числа больше 1000 скорее ID пользователей Telegram, чем количество записей
+		if limit, err := strconv.Atoi(field); err == nil && limit > 0 && limit <= 1000 {
+			filter.Limit = limit
+			continue
+		}
+
+		switch {
+		case strings.EqualFold(field, "changes"):
+			filter.ChangesOnly = true
+		case bot.CommandByName(strings.ToLower(field)) != nil:
+			filter.Command = strings.ToLower(field)
+		default:
+			filter.User = strings.TrimPrefix(strings.TrimPrefix(field, "telegram:"), "web:")
+		}
+	}
+
+	entries, total, err := bot.conf.GetDB().QueryAudit(filter)
+	if err != nil {
+		return "", fmt.Errorf("не удалось прочитать журнал: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return "Записей в журнале нет", nil
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("*Журнал (%d из %d):*\n", len(entries), total))
+	for _, entry := range entries {
+		response.WriteString(formatAuditEntry(entry, false))
+	}
+	response.WriteString("\nПодробности записи: `audit #ID`")
+
+	return response.String(), nil
+}
+
+func (bot *Bot) auditEntryDetails(id int64) (string, error) {
+	entry, err := bot.conf.GetDB().GetAuditEntry(id)
+	if err != nil {
+		return "", fmt.Errorf("не удалось прочитать журнал: %w", err)
+	}
+	if entry == nil {
+		return "", fmt.Errorf("записи #%d нет", id)
+	}
+
+	return formatAuditEntry(*entry, true), nil
+}
@@ -0,0 +1,821 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/db"
+	"Unbewohnte/ACASbot/internal/domain"
+	"Unbewohnte/ACASbot/internal/schedule"
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Имена файлов резервной копии и ее содержимого
+const (
+	backupPrefix    = "acasbot-backup-"
+	backupExtension = ".tar.gz"
+
+	backupManifestFile = "manifest.json"
+	backupDatabaseFile = "database.sqlite3"
+	backupConfigFile   = "config.json"
+	backupSettingsFile = "settings.json"
+
+	// Копия, которая будет восстановлена при следующем запуске бота (команда restore).
+	// Лежит рядом с конфигурационным файлом
+	pendingRestoreFile = "acasbot-restore-pending" + backupExtension
+
+	backupFormat = 1 // Версия формата архива
+)
+
+// Описание резервной копии
+type backupManifest struct {
+	Format          int       `json:"format"`
+	CreatedAt       time.Time `json:"created_at"`
+	SchemaVersion   int       `json:"schema_version"`
+	RedactedSecrets bool      `json:"redacted_secrets"`
+}
+
+// Настройки колонок XLSX, промптов и объектов анализа. В отличие от остальной
+// конфигурации их можно восстановить на работающем боте (restore settings)
+type backupSettings struct {
+	XLSXColumns []domain.XLSXColumn `json:"xlsx_columns"`
+	Prompts     Prompts             `json:"prompts"`
+	Objects     []TrackedObject     `json:"objects"`
+}
+
+// Созданная резервная копия
+type BackupResult struct {
+	Path          string
+	Size          int64
+	SchemaVersion int
+	Redacted      bool
+	Removed       []string // Старые копии, удаленные при ротации
+}
+
+// Создает резервную копию в каталоге из настроек и удаляет копии сверх заданного количества.
+// Копия - архив tar.gz с согласованной копией локальной базы (VACUUM INTO), config.json
+// (секреты скрываются при redact) и настройками колонок, промптов и объектов
+func (conf *Config) CreateBackup(ctx context.Context, redact bool) (*BackupResult, error) {
+	dir := conf.Backup.Dir
+	if dir == "" {
+		return nil, errors.New("не задан каталог резервных копий")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	workDir, err := os.MkdirTemp(dir, ".backup-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	databasePath := filepath.Join(workDir, backupDatabaseFile)
+	if err := conf.GetDB().BackupTo(ctx, databasePath); err != nil {
+		return nil, fmt.Errorf("не удалось скопировать базу: %w", err)
+	}
+
+	version, err := db.CheckBackup(databasePath)
+	if err != nil {
+		return nil, fmt.Errorf("копия базы не прошла проверку: %w", err)
+	}
+
+	snapshot := *conf
+	if redact {
+		snapshot = conf.Redacted()
+	}
+	snapshot.Sheets.Google.Config.CredentialsJSON = nil
+	configJSON, err := json.MarshalIndent(&snapshot, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	settingsJSON, err := json.MarshalIndent(backupSettings{
+		XLSXColumns: conf.Sheets.XLSXColumns,
+		Prompts:     conf.Ollama.Prompts,
+		Objects:     conf.Analysis.Objects,
+	}, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	manifestJSON, err := json.MarshalIndent(backupManifest{
+		Format:          backupFormat,
+		CreatedAt:       time.Now(),
+		SchemaVersion:   version,
+		RedactedSecrets: redact,
+	}, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := os.CreateTemp(workDir, "archive-*")
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	compressed := gzip.NewWriter(archive)
+	writer := tar.NewWriter(compressed)
+	err = writeTarData(writer, backupManifestFile, manifestJSON)
+	if err == nil {
+		err = writeTarFile(writer, backupDatabaseFile, databasePath)
+	}
+	if err == nil {
+		err = writeTarData(writer, backupConfigFile, configJSON)
+	}
+	if err == nil {
+		err = writeTarData(writer, backupSettingsFile, settingsJSON)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		err = compressed.Close()
+	}
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось записать архив: %w", err)
+	}
+
+	path, err := reserveBackupPath(dir, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(archive.Name(), path); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	result := &BackupResult{
+		Path:          path,
+		SchemaVersion: version,
+		Redacted:      redact,
+	}
+
+	if info, err := os.Stat(result.Path); err == nil {
+		result.Size = info.Size()
+	}
+
+	result.Removed, err = rotateBackups(dir, conf.Backup.Keep)
+	if err != nil {
+		log.Printf("Не удалось удалить старые резервные копии: %v", err)
+	}
+
+	return result, nil
+}
+
+func writeTarData(writer *tar.Writer, name string, data []byte) error {
+	err := writer.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(data)
+	return err
+}
+
+func writeTarFile(writer *tar.Writer, name string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = writer.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+// Занимает в каталоге имя name+extension, создавая пустой файл, только если его еще нет.
+// Если имя занято, к нему дописывается номер: name-2+extension и т.д.
+func reservePath(dir string, name string, extension string) (string, error) {
+	path := filepath.Join(dir, name+extension)
+	for i := 2; ; i++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			return path, file.Close()
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", name, i, extension))
+	}
+}
+
+// Занимает в каталоге имя для новой резервной копии. Копии по расписанию и по команде
+// могут создаваться одновременно, поэтому к времени с миллисекундами при совпадении
+// дописывается номер
+func reserveBackupPath(dir string, now time.Time) (string, error) {
+	return reservePath(dir, backupPrefix+now.Format("20060102-150405.000"), backupExtension)
+}
+
+// Удаляет самые старые резервные копии в каталоге, оставляя keep последних (0 - все).
+// Возвращает пути удаленных копий
+func rotateBackups(dir string, keep uint) ([]string, error) {
+	if keep == 0 {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Копии упорядочиваются по времени и номеру из имени: при сравнении строк
+	// ...000-2.tar.gz оказалась бы раньше ...000.tar.gz
+	type backupFile struct {
+		name    string
+		created time.Time
+		number  int
+	}
+	var backups []backupFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		created, number, ok := parseBackupName(entry.Name())
+		if ok {
+			backups = append(backups, backupFile{entry.Name(), created, number})
+		}
+	}
+	slices.SortFunc(backups, func(a, b backupFile) int {
+		if c := a.created.Compare(b.created); c != 0 {
+			return c
+		}
+		return a.number - b.number
+	})
+
+	var removed []string
+	for len(backups) > int(keep) {
+		path := filepath.Join(dir, backups[0].name)
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+		backups = backups[1:]
+	}
+
+	return removed, nil
+}
+
+// Время создания и номер копии из имени acasbot-backup-<время>[-номер].tar.gz. У копий,
+// созданных до появления миллисекунд в имени, время с точностью до секунды. Для чужих
+// файлов возвращает false
+func parseBackupName(name string) (time.Time, int, bool) {
+	if !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupExtension) {
+		return time.Time{}, 0, false
+	}
+	stem := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupExtension)
+
+	// Дефис есть и в самом времени, номер идет после него
+	number := 1
+	if i := strings.LastIndex(stem, "-"); i > len("20060102") {
+		n, err := strconv.Atoi(stem[i+1:])
+		if err != nil || n < 2 {
+			return time.Time{}, 0, false
+		}
+		number = n
+		stem = stem[:i]
+	}
+
+	for _, layout := range []string{"20060102-150405.000", "20060102-150405"} {
+		if created, err := time.ParseInLocation(layout, stem, time.Local); err == nil {
+			return created, number, true
+		}
+	}
+
+	return time.Time{}, 0, false
+}
+
+// Распакованная и проверенная резервная копия
+type backupContents struct {
+	dir          string
+	Manifest     backupManifest
+	DatabasePath string
+	Config       *Config
+	Settings     *backupSettings // nil - копия создана без settings.json
+}
+
+// Удаляет распакованные файлы
+func (contents *backupContents) Remove() {
+	os.RemoveAll(contents.dir)
+}
+
+// Распаковывает резервную копию во временный каталог и проверяет ее: формат архива,
+// целостность базы и версию ее схемы
+func readBackup(path string) (*backupContents, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	compressed, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, errors.New("файл не является архивом tar.gz")
+	}
+	defer compressed.Close()
+
+	dir, err := os.MkdirTemp("", "acasbot-restore-*")
+	if err != nil {
+		return nil, err
+	}
+	contents := &backupContents{dir: dir}
+
+	if err := extractBackup(tar.NewReader(compressed), dir); err != nil {
+		contents.Remove()
+		return nil, err
+	}
+
+	if err := contents.load(); err != nil {
+		contents.Remove()
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+// Извлекает из архива только известные файлы резервной копии
+func extractBackup(reader *tar.Reader, dir string) error {
+	known := []string{backupManifestFile, backupDatabaseFile, backupConfigFile, backupSettingsFile}
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать архив: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg || !slices.Contains(known, header.Name) {
+			continue
+		}
+
+		file, err := os.OpenFile(filepath.Join(dir, header.Name), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(file, reader)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("не удалось распаковать %s: %w", header.Name, err)
+		}
+	}
+}
+
+func (contents *backupContents) load() error {
+	manifestJSON, err := os.ReadFile(filepath.Join(contents.dir, backupManifestFile))
+	if err != nil {
+		return errors.New("в архиве нет описания резервной копии (manifest.json)")
+	}
+	if err := json.Unmarshal(manifestJSON, &contents.Manifest); err != nil {
+		return fmt.Errorf("неверное описание резервной копии: %w", err)
+	}
+	if contents.Manifest.Format > backupFormat {
+		return fmt.Errorf("формат резервной копии (%d) новее поддерживаемого (%d): обновите бота", contents.Manifest.Format, backupFormat)
+	}
+
+	// Схема проверяется до того, как что-либо будет заменено
+	contents.DatabasePath = filepath.Join(contents.dir, backupDatabaseFile)
+	version, err := db.CheckBackup(contents.DatabasePath)
+	if err != nil {
+		return fmt.Errorf("база в резервной копии не прошла проверку: %w", err)
+	}
+	if version != contents.Manifest.SchemaVersion {
+		return fmt.Errorf("версия схемы базы (%d) не совпадает с указанной в описании копии (%d)", version, contents.Manifest.SchemaVersion)
+	}
+
+	configJSON, err := os.ReadFile(filepath.Join(contents.dir, backupConfigFile))
+	if err != nil {
+		return errors.New("в архиве нет конфигурации (config.json)")
+	}
+	contents.Config, err = parseConfig(configJSON)
+	if err != nil {
+		return fmt.Errorf("неверная конфигурация в резервной копии: %w", err)
+	}
+
+	settingsJSON, err := os.ReadFile(filepath.Join(contents.dir, backupSettingsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	contents.Settings = &backupSettings{}
+	if err := json.Unmarshal(settingsJSON, contents.Settings); err != nil {
+		return fmt.Errorf("неверные настройки колонок и промптов: %w", err)
+	}
+
+	return nil
+}
+
+func copyFile(source string, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(destination, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Переносит существующий файл в сторону, дописывая к имени время восстановления.
+// Возвращает новый путь или пустую строку, если файла не было
+func moveAside(path string, now time.Time) (string, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "", nil
+	}
+
+	// Прежние сохраненные файлы не перезаписываются
+	aside := path + ".before-restore-" + now.Format("20060102-150405")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(aside); os.IsNotExist(err) {
+			break
+		}
+		aside = fmt.Sprintf("%s.before-restore-%s-%d", path, now.Format("20060102-150405"), i)
+	}
+	if err := os.Rename(path, aside); err != nil {
+		return "", err
+	}
+
+	return aside, nil
+}
+
+// Восстанавливает базу и конфигурацию из резервной копии до запуска бота. На работающем боте
+// база не заменяется: пакетная обработка, ленты, расписания и API продолжают с ней работать.
+// Секреты, скрытые в копии, берутся из текущей конфигурации configPath, если она есть.
+// Прежние база и конфигурация сохраняются рядом с суффиксом .before-restore-<время>
+func RestoreBackup(archivePath string, configPath string) (string, error) {
+	contents, err := readBackup(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer contents.Remove()
+
+	conf := contents.Config
+	if current, err := ConfigFrom(configPath); err == nil {
+		conf.restoreSecrets(current)
+	}
+
+	now := time.Now()
+	var report strings.Builder
+	report.WriteString(fmt.Sprintf("Резервная копия от %s (схема базы v%d) восстановлена\n",
+		contents.Manifest.CreatedAt.Format("2006-01-02 15:04"), contents.Manifest.SchemaVersion,
+	))
+
+	if dir := filepath.Dir(conf.DB.File); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", err
+		}
+	}
+	previousDB, err := moveAside(conf.DB.File, now)
+	if err != nil {
+		return "", fmt.Errorf("не удалось сохранить прежнюю базу: %w", err)
+	}
+	if err := copyFile(contents.DatabasePath, conf.DB.File); err != nil {
+		if previousDB != "" {
+			os.Rename(previousDB, conf.DB.File)
+		}
+		return "", fmt.Errorf("не удалось восстановить базу: %w", err)
+	}
+	report.WriteString(fmt.Sprintf("База: %s\n", conf.DB.File))
+	if previousDB != "" {
+		report.WriteString(fmt.Sprintf("Прежняя база: %s\n", previousDB))
+	}
+
+	previousConfig, err := moveAside(configPath, now)
+	if err != nil {
+		return "", fmt.Errorf("не удалось сохранить прежнюю конфигурацию: %w", err)
+	}
+	if err := conf.Save(configPath); err != nil {
+		return "", fmt.Errorf("не удалось сохранить конфигурацию: %w", err)
+	}
+	report.WriteString(fmt.Sprintf("Конфигурация: %s\n", configPath))
+	if previousConfig != "" {
+		report.WriteString(fmt.Sprintf("Прежняя конфигурация: %s\n", previousConfig))
+	}
+
+	if missing := conf.redactedSecrets(); len(missing) > 0 {
+		report.WriteString(fmt.Sprintf("ВНИМАНИЕ: секреты в копии были скрыты, впишите их в конфигурацию вручную: %s\n", strings.Join(missing, ", ")))
+	}
+
+	return report.String(), nil
+}
+
+func pendingRestorePath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), pendingRestoreFile)
+}
+
+// Выполняет восстановление, подготовленное командой restore. Вызывается при запуске
+// до открытия базы. Возвращает отчет или пустую строку, если восстанавливать нечего.
+// Копия, которую не удалось восстановить, переименовывается, чтобы бот запустился
+// с прежними базой и конфигурацией
+func ApplyPendingRestore(configPath string) (string, error) {
+	pending := pendingRestorePath(configPath)
+	if _, err := os.Stat(pending); os.IsNotExist(err) {
+		return "", nil
+	}
+
+	report, err := RestoreBackup(pending, configPath)
+	if err != nil {
+		failed := pending + ".failed"
+		if renameErr := os.Rename(pending, failed); renameErr != nil {
+			return "", fmt.Errorf("%w (не удалось убрать копию %s: %v)", err, pending, renameErr)
+		}
+		return "", fmt.Errorf("%w (копия перенесена в %s)", err, failed)
+	}
+
+	if err := os.Remove(pending); err != nil {
+		log.Printf("Не удалось удалить восстановленную копию %s: %v", pending, err)
+	}
+
+	return report, nil
+}
+
+// Кладет проверенную копию туда, где ее найдет ApplyPendingRestore. Ранее подготовленная
+// копия заменяется
+func stageRestore(archivePath string, pending string) error {
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(filepath.Dir(pending), ".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(out.Name(), pending)
+}
+
+// Путь к резервной копии из аргументов или вложения. Вложение сохраняется во временный
+// файл, который удаляет возвращаемая функция
+func (call *CallContext) backupArchive(path string) (string, func(), error) {
+	attachment := call.attachment(backupExtension, ".tgz")
+	if attachment == nil {
+		if len(call.Attachments) > 0 {
+			return "", nil, errors.New("формат файла должен быть .tar.gz")
+		}
+		if path == "" {
+			return "", nil, errors.New("прикрепите резервную копию к команде или укажите путь к ней")
+		}
+		return path, func() {}, nil
+	}
+
+	file, err := os.CreateTemp("", "acasbot-backup-*"+backupExtension)
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.Remove(file.Name()) }
+
+	_, err = file.Write(attachment.Data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return file.Name(), cleanup, nil
+}
+
+// Восстанавливает резервную копию (вложение или путь к файлу). Копия проверяется сразу,
+// а база и конфигурация заменяются при следующем запуске бота: на работающем боте с базой
+// одновременно работают пакетная обработка, ленты, расписания и API.
+// "restore settings" сразу применяет только настройки колонок XLSX, промптов и объектов,
+// "restore cancel" отменяет подготовленное восстановление
+func (bot *Bot) Restore(call *CallContext) (string, error) {
+	fields := strings.Fields(call.Args)
+	var mode string
+	if len(fields) > 0 && (strings.EqualFold(fields[0], "settings") || strings.EqualFold(fields[0], "cancel")) {
+		mode = strings.ToLower(fields[0])
+		fields = fields[1:]
+	}
+
+	pending := pendingRestorePath(CONFIG_PATH)
+	if mode == "cancel" {
+		err := os.Remove(pending)
+		if os.IsNotExist(err) {
+			return "Подготовленного восстановления нет", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return "Подготовленное восстановление отменено", nil
+	}
+
+	path, cleanup, err := call.backupArchive(strings.Join(fields, " "))
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	call.Progress("📦 Проверяю резервную копию...")
+	contents, err := readBackup(path)
+	if err != nil {
+		return "", err
+	}
+	defer contents.Remove()
+
+	created := contents.Manifest.CreatedAt.Format("2006-01-02 15:04")
+	if mode == "settings" {
+		settings := contents.Settings
+		if settings == nil {
+			return "", errors.New("в резервной копии нет настроек колонок и промптов (settings.json)")
+		}
+
+		bot.conf.Sheets.XLSXColumns = settings.XLSXColumns
+		bot.conf.Ollama.Prompts = settings.Prompts
+		bot.conf.Analysis.Objects = settings.Objects
+		if err := bot.conf.Update(); err != nil {
+			return "", fmt.Errorf("не удалось сохранить настройки: %w", err)
+		}
+
+		return fmt.Sprintf("♻️ Из резервной копии от %s восстановлены настройки: колонок XLSX - %d, объектов - %d, общие промпты",
+			created, len(settings.XLSXColumns), len(settings.Objects),
+		), nil
+	}
+
+	if err := stageRestore(path, pending); err != nil {
+		return "", fmt.Errorf("не удалось подготовить восстановление: %w", err)
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("♻️ Резервная копия от %s (схема базы v%d) проверена и будет восстановлена при следующем запуске бота\n",
+		created, contents.Manifest.SchemaVersion,
+	))
+	response.WriteString("Перезапустите бота. Прежние база и конфигурация сохранятся рядом с суффиксом `.before-restore-<время>`\n")
+	if contents.Manifest.RedactedSecrets {
+		response.WriteString("Секреты в копии скрыты, они будут взяты из текущей конфигурации\n")
+	}
+	response.WriteString("Отменить: `restore cancel`")
+
+	return response.String(), nil
+}
+
+func formatBackupSize(size int64) string {
+	return fmt.Sprintf("%.1f МБ", float64(size)/1024/1024)
+}
+
+// Создает резервную копию. С аргументом redact секреты скрываются независимо от настроек.
+// Копия со скрытыми секретами отправляется файлом
+func (bot *Bot) Backup(call *CallContext) (*Response, error) {
+	redact := bot.conf.Backup.RedactSecrets
+	switch strings.ToLower(strings.TrimSpace(call.Args)) {
+	case "":
+	case "redact":
+		redact = true
+	default:
+		return nil, errors.New("неизвестный аргумент. Используйте backup или backup redact")
+	}
+
+	call.Progress("💾 Создаю резервную копию...")
+	result, err := bot.conf.CreateBackup(call.Context(), redact)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("💾 Резервная копия создана: `%s` (%s, схема базы v%d)\n",
+		result.Path, formatBackupSize(result.Size), result.SchemaVersion,
+	))
+	if len(result.Removed) > 0 {
+		text.WriteString(fmt.Sprintf("Удалено старых копий: %d\n", len(result.Removed)))
+	}
+	if bot.conf.DB.shared != nil {
+		text.WriteString("Копия содержит только локальную базу SQLite, общее хранилище PostgreSQL копируется средствами PostgreSQL\n")
+	}
+
+	response := &Response{}
+	if result.Redacted {
+		text.WriteString("Секреты в config.json скрыты")
+		response.Files = []ResponseFile{{
+			Name:    filepath.Base(result.Path),
+			Caption: "💾 Резервная копия ACASbot",
+			Path:    result.Path,
+		}}
+	} else {
+		text.WriteString("Копия содержит секреты, поэтому в чат не отправляется. Для отправки: `backup redact`")
+	}
+	response.Text = text.String()
+
+	return response, nil
+}
+
+// Создает резервные копии по расписанию
+func (bot *Bot) StartBackupScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		var (
+			next    time.Time
+			planned string // Выражение, по которому рассчитан next
+		)
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if !bot.conf.Backup.Enabled {
+					next = time.Time{}
+					continue
+				}
+
+				cron, err := schedule.Parse(bot.conf.Backup.Cron)
+				if err != nil {
+					continue
+				}
+
+				if next.IsZero() || planned != bot.conf.Backup.Cron {
+					next = cron.Next(now)
+					planned = bot.conf.Backup.Cron
+					continue
+				}
+
+				if now.Before(next) {
+					continue
+				}
+				next = cron.Next(now)
+
+				result, err := bot.conf.CreateBackup(context.Background(), bot.conf.Backup.RedactSecrets)
+				if err != nil {
+					log.Printf("Не удалось создать резервную копию: %v", err)
+					continue
+				}
+				log.Printf("Резервная копия создана: %s (%s), удалено старых: %d", result.Path, formatBackupSize(result.Size), len(result.Removed))
+			}
+		}
+	}()
+}
@@ -0,0 +1,193 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRotateBackups(t *testing.T) {
+	dir := t.TempDir()
+
+	// В порядке создания: копия старого формата, затем копии с миллисекундами и номерами
+	names := []string{
+		"acasbot-backup-20250101-040000.tar.gz",
+		"acasbot-backup-20250101-040000.500.tar.gz",
+		"acasbot-backup-20250101-040000.500-2.tar.gz",
+		"acasbot-backup-20250101-040000.500-10.tar.gz",
+		"acasbot-backup-20250102-040000.000.tar.gz",
+	}
+	foreign := []string{"acasbot-backup-notes.tar.gz", "notes.txt"}
+	for _, name := range append(slices.Clone(names), foreign...) {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := rotateBackups(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var removedNames []string
+	for _, path := range removed {
+		removedNames = append(removedNames, filepath.Base(path))
+	}
+	if !slices.Equal(removedNames, names[:2]) {
+		t.Fatalf("удалены %v, ожидались %v", removedNames, names[:2])
+	}
+
+	for _, name := range append(slices.Clone(names[2:]), foreign...) {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s не должен был удаляться: %v", name, err)
+		}
+	}
+}
+
+func TestParseBackupName(t *testing.T) {
+	tests := []struct {
+		name   string
+		ok     bool
+		number int
+	}{
+		{"acasbot-backup-20250101-040000.tar.gz", true, 1},
+		{"acasbot-backup-20250101-040000.123.tar.gz", true, 1},
+		{"acasbot-backup-20250101-040000.123-3.tar.gz", true, 3},
+		{"acasbot-backup-20250101-040000.123-x.tar.gz", false, 0},
+		{"acasbot-backup-latest.tar.gz", false, 0},
+		{"backup-20250101-040000.tar.gz", false, 0},
+		{pendingRestoreFile, false, 0},
+	}
+
+	for _, test := range tests {
+		_, number, ok := parseBackupName(test.name)
+		if ok != test.ok || number != test.number {
+			t.Errorf("parseBackupName(%q) = %d, %v; ожидалось %d, %v", test.name, number, ok, test.number, test.ok)
+		}
+	}
+}
+
+// Открывает базу из конфигурации и дожидается построения индекса, иначе закрытие
+// базы пересечется с его построением
+func openTestDB(t *testing.T, conf *Config) {
+	t.Helper()
+
+	database, err := conf.OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for !database.IndexStats().Ready {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestApplyPendingRestore(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+
+	conf := DefaultConfig()
+	conf.DB.File = filepath.Join(dir, "database.sqlite3")
+	conf.Backup.Dir = filepath.Join(dir, "backups")
+	openTestDB(t, conf)
+
+	result, err := conf.CreateBackup(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Статья, добавленная после копии, после восстановления пропадает
+	article := &domain.Article{Content: "после копии", SourceURL: "https://example.com/after", CreatedAt: time.Now().Unix()}
+	if err := conf.GetDB().SaveArticle(context.Background(), article); err != nil {
+		t.Fatal(err)
+	}
+	conf.Analysis.Objects = nil
+	if err := conf.Save(configPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.GetDB().Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if report, err := ApplyPendingRestore(configPath); err != nil || report != "" {
+		t.Fatalf("без подготовленной копии: %q, %v", report, err)
+	}
+
+	pending := pendingRestorePath(configPath)
+	if err := stageRestore(result.Path, pending); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ApplyPendingRestore(configPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(pending); !os.IsNotExist(err) {
+		t.Error("восстановленная копия не удалена")
+	}
+
+	restored, err := ConfigFrom(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.Analysis.Objects) == 0 {
+		t.Error("конфигурация не восстановлена")
+	}
+
+	openTestDB(t, restored)
+	defer restored.GetDB().Close()
+	exists, err := restored.GetDB().HasArticleByURL(context.Background(), article.SourceURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("база не восстановлена: в ней статья, добавленная после копии")
+	}
+
+	previous, _ := filepath.Glob(conf.DB.File + ".before-restore-*")
+	if len(previous) != 1 {
+		t.Errorf("прежняя база не сохранена: %v", previous)
+	}
+}
+
+func TestApplyPendingRestoreFailure(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	if err := DefaultConfig().Save(configPath); err != nil {
+		t.Fatal(err)
+	}
+
+	pending := pendingRestorePath(configPath)
+	if err := os.WriteFile(pending, []byte("не архив"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ApplyPendingRestore(configPath); err == nil {
+		t.Fatal("поврежденная копия восстановлена без ошибки")
+	}
+	if _, err := os.Stat(pending + ".failed"); err != nil {
+		t.Errorf("поврежденная копия не отложена: %v", err)
+	}
+	if _, err := os.Stat(pending); !os.IsNotExist(err) {
+		t.Error("поврежденная копия осталась на месте и будет восстанавливаться при каждом запуске")
+	}
+}
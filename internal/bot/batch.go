@@ -0,0 +1,383 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var urlRegexp = regexp.MustCompile(`https?://[^\s"'<>,;]+`)
+
+// Извлекает уникальные URL из произвольного текста (сообщение, .txt, .csv)
+func extractURLs(text string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+
+	for _, match := range urlRegexp.FindAllString(text, -1) {
+		url := strings.TrimRight(match, ".)]}!?")
+		if seen[url] {
+			continue
+		}
+		seen[url] = true
+		urls = append(urls, url)
+	}
+
+	return urls
+}
+
+// Ставит найденные в тексте URL в очередь. Уже известные базе URL пропускаются.
+// Задания анализируются с настройками профиля profile, если он задан
+func (bot *Bot) enqueueBatch(text string, chatID int64, profile string) (*domain.Batch, int, int, error) {
+	urls := extractURLs(text)
+	if len(urls) == 0 {
+		return nil, 0, 0, errors.New("не найдено ни одного URL")
+	}
+
+	db := bot.conf.GetDB()
+
+	var queued []string
+	skipped := 0
+	for _, url := range urls {
+		exists, err := bot.knownArticleURL(context.Background(), url)
+		if err == nil && exists {
+			skipped++
+			continue
+		}
+		queued = append(queued, url)
+	}
+
+	if len(queued) == 0 {
+		return nil, 0, skipped, errors.New("все указанные URL уже есть в базе")
+	}
+
+	batch, err := db.CreateBatch(chatID, profile, queued)
+	if err != nil {
+		return nil, 0, skipped, fmt.Errorf("не удалось поставить задания в очередь: %w", err)
+	}
+
+	bot.wakeBatchWorkers()
+
+	return batch, len(queued), skipped, nil
+}
+
+func formatBatchQueued(batch *domain.Batch, queued int, skipped int) string {
+	message := fmt.Sprintf("📦 Пакет #%d: в очередь поставлено %d URL", batch.ID, queued)
+	if skipped > 0 {
+		message += fmt.Sprintf(" (пропущено уже известных: %d)", skipped)
+	}
+
+	return message
+}
+
+func formatBatchProgress(batch *domain.Batch, progress domain.BatchProgress) string {
+	return fmt.Sprintf("⏳ Пакет #%d: обработано %d из %d (ошибок: %d)",
+		batch.ID,
+		progress.Done+progress.Failed,
+		progress.Total,
+		progress.Failed,
+	)
+}
+
+func (bot *Bot) formatBatchSummary(batch *domain.Batch, progress domain.BatchProgress) string {
+	var summary strings.Builder
+
+	summary.WriteString(fmt.Sprintf("📦 *Пакет #%d завершен*\n\n", batch.ID))
+	summary.WriteString(fmt.Sprintf("*Всего:* %d\n", progress.Total))
+	summary.WriteString(fmt.Sprintf("*Успешно:* %d\n", progress.Done))
+	summary.WriteString(fmt.Sprintf("*С ошибками:* %d\n", progress.Failed))
+
+	finishedAt := time.Now()
+	if batch.FinishedAt != 0 {
+		finishedAt = time.Unix(batch.FinishedAt, 0)
+	}
+	summary.WriteString(fmt.Sprintf("*Длительность:* %s\n",
+		finishedAt.Sub(time.Unix(batch.CreatedAt, 0)).Round(time.Second),
+	))
+
+	if progress.Failed > 0 {
+		failed, err := bot.conf.GetDB().GetFailedJobs(batch.ID)
+		if err == nil && len(failed) > 0 {
+			summary.WriteString("\n❌ *Не удалось обработать:*\n")
+			for _, job := range failed {
+				summary.WriteString(fmt.Sprintf("- %s: %s\n", job.URL, job.Error))
+			}
+		}
+	}
+
+	return summary.String()
+}
+
+// Отправляет прогресс или итог пакета туда, откуда он был поставлен
+func (bot *Bot) reportBatch(batch *domain.Batch, text string, final bool) {
+	if batch.ChatID == 0 {
+		if final {
+			bot.server.SendResponse(text)
+		} else {
+			bot.server.SendLog(text)
+		}
+		return
+	}
+
+	if bot.api == nil {
+		return
+	}
+
+	if !final {
+		if batch.MessageID != 0 {
+			bot.api.Send(tgbotapi.NewEditMessageText(batch.ChatID, batch.MessageID, text))
+		}
+		return
+	}
+
+	bot.sendMessage(batch.ChatID, text, batch.MessageID)
+}
+
+func (bot *Bot) updateBatchProgress(batchID int64) {
+	db := bot.conf.GetDB()
+
+	batch, err := db.GetBatch(batchID)
+	if err != nil {
+		log.Printf("Не удалось получить пакет #%d: %v", batchID, err)
+		return
+	}
+
+	progress, err := db.GetBatchProgress(batchID)
+	if err != nil {
+		log.Printf("Не удалось получить прогресс пакета #%d: %v", batchID, err)
+		return
+	}
+
+	// Пакеты лент не сообщают о прогрессе и итоге
+	if batch.FeedID == 0 {
+		bot.reportBatch(batch, formatBatchProgress(batch, progress), false)
+	}
+
+	if !progress.Finished() {
+		return
+	}
+
+	// Итог отправляется только одним обработчиком
+	finished, err := db.FinishBatch(batchID)
+	if err != nil {
+		log.Printf("Не удалось завершить пакет #%d: %v", batchID, err)
+		return
+	}
+
+	if finished && batch.FeedID == 0 {
+		bot.reportBatch(batch, bot.formatBatchSummary(batch, progress), true)
+	}
+}
+
+func (bot *Bot) wakeBatchWorkers() {
+	select {
+	case bot.batchWake <- struct{}{}:
+	default:
+	}
+}
+
+func (bot *Bot) processJob(job *domain.Job) {
+	status := domain.JobDone
+	errText := ""
+
+	// Профиль мог быть удален после постановки пакета в очередь
+	call := &CallContext{Args: job.URL}
+	batch, err := bot.conf.GetDB().GetBatch(job.BatchID)
+	if err == nil {
+		call.Profile = bot.profileByScope(batch.Profile)
+	}
+
+	// Анализ виден среди выполняющихся команд и отменяется командой cancel
+	call.Caller = queueCaller(job.BatchID, batch)
+	call.job = bot.jobs.start(call.Caller, "do", job.URL)
+	defer bot.jobs.finish(call.job)
+
+	result, err := bot.Do(call)
+	if err != nil && errors.Is(call.job.ctx.Err(), context.Canceled) {
+		err = errCanceled
+	}
+	if err != nil {
+		status = domain.JobFailed
+		errText = err.Error()
+	}
+
+	// О записях лент сообщается по каждой статье, а не итогом пакета
+	if batch != nil && batch.FeedID != 0 {
+		bot.notifyFeedItem(batch.FeedID, job.URL, result, err)
+	}
+
+	if err := bot.conf.GetDB().FinishJob(job.ID, status, errText); err != nil {
+		log.Printf("Не удалось обновить статус задания #%d: %v", job.ID, err)
+	}
+
+	bot.updateBatchProgress(job.BatchID)
+}
+
+// Вызывающий для задания очереди: лента или пакет
+func queueCaller(batchID int64, batch *domain.Batch) Caller {
+	if batch != nil && batch.FeedID != 0 {
+		return Caller{Platform: platformFeed, UserID: strconv.FormatInt(batch.FeedID, 10)}
+	}
+
+	caller := Caller{Platform: platformBatch, UserID: strconv.FormatInt(batchID, 10)}
+	if batch != nil {
+		caller.ChatID = batch.ChatID
+	}
+
+	return caller
+}
+
+func (bot *Bot) batchWorker() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		bot.batchMu.Lock()
+		job, err := bot.conf.GetDB().ClaimNextJob()
+		bot.batchMu.Unlock()
+		if err != nil {
+			log.Printf("Ошибка получения задания из очереди: %v", err)
+		}
+
+		if job == nil {
+			select {
+			case <-bot.batchWake:
+			case <-ticker.C:
+			}
+			continue
+		}
+
+		// В очереди могут остаться задания - будим следующий обработчик
+		bot.wakeBatchWorkers()
+
+		bot.processJob(job)
+	}
+}
+
+// Запускает обработчики очереди, возвращая в нее прерванные задания
+func (bot *Bot) StartBatchWorkers() {
+	db := bot.conf.GetDB()
+
+	reset, err := db.ResetRunningJobs()
+	if err != nil {
+		log.Printf("Не удалось вернуть прерванные задания в очередь: %v", err)
+	} else if reset > 0 {
+		log.Printf("Возобновлено %d прерванных заданий", reset)
+	}
+
+	// Пакеты, обработка которых завершилась до отправки итога
+	batches, err := db.GetUnfinishedBatches()
+	if err == nil {
+		for _, batch := range batches {
+			progress, err := db.GetBatchProgress(batch.ID)
+			if err == nil && progress.Finished() {
+				bot.updateBatchProgress(batch.ID)
+			}
+		}
+	}
+
+	workers := bot.conf.Batch.Workers
+	if workers == 0 {
+		workers = 1
+	}
+
+	for i := uint(0); i < workers; i++ {
+		go bot.batchWorker()
+	}
+
+	bot.wakeBatchWorkers()
+}
+
+// Ставит URL из аргументов и прикрепленных .txt/.csv файлов в очередь.
+// В ответе возвращается созданный пакет, чтобы Telegram мог обновлять сообщение с прогрессом
+func (bot *Bot) Batch(call *CallContext) (*Response, error) {
+	text := call.Args
+	for _, attachment := range call.Attachments {
+		name := strings.ToLower(attachment.Name)
+		if !strings.HasSuffix(name, ".txt") && !strings.HasSuffix(name, ".csv") {
+			return nil, errors.New("формат файла должен быть .txt или .csv")
+		}
+		text += "\n" + string(attachment.Data)
+	}
+
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("не указаны URL")
+	}
+
+	batch, queued, skipped, err := bot.enqueueBatch(text, call.Caller.ChatID, call.profileScope())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{Text: formatBatchQueued(batch, queued, skipped), Data: batch}, nil
+}
+
+func (bot *Bot) QueueStatus(call *CallContext) (string, error) {
+	db := bot.conf.GetDB()
+
+	var batches []domain.Batch
+	if strings.TrimSpace(call.Args) != "" {
+		batchID, err := strconv.ParseInt(strings.TrimSpace(call.Args), 10, 64)
+		if err != nil {
+			return "", errors.New("неверный номер пакета")
+		}
+
+		batch, err := db.GetBatch(batchID)
+		if err != nil {
+			return "", fmt.Errorf("пакет #%d не найден", batchID)
+		}
+		batches = append(batches, *batch)
+	} else {
+		var err error
+		batches, err = db.GetUnfinishedBatches()
+		if err != nil {
+			return "", fmt.Errorf("не удалось получить список пакетов: %w", err)
+		}
+
+		if len(batches) == 0 {
+			return "Очередь пуста", nil
+		}
+	}
+
+	var response strings.Builder
+	for _, batch := range batches {
+		progress, err := db.GetBatchProgress(batch.ID)
+		if err != nil {
+			continue
+		}
+
+		if batch.FinishedAt != 0 {
+			response.WriteString(bot.formatBatchSummary(&batch, progress))
+		} else {
+			response.WriteString(formatBatchProgress(&batch, progress))
+		}
+		response.WriteString("\n")
+	}
+
+	return response.String(), nil
+}
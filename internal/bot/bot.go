@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"Unbewohnte/ACASbot/internal/domain"
 	"Unbewohnte/ACASbot/internal/inference"
 	"Unbewohnte/ACASbot/internal/spreadsheet"
 	"context"
@@ -8,8 +9,9 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -18,18 +20,23 @@ import (
 type Bot struct {
 	api      *tgbotapi.BotAPI
 	conf     *Config
-	model    *inference.Client
+	model    inference.Client
 	commands []Command
 	sheet    *spreadsheet.GoogleSheetsClient
 	server   *WebServer
+
+	batchWake chan struct{}
+	batchMu   sync.Mutex
+
+	objectEmbeddings embeddingCache
+	profileModels    modelCache
+	jobs             jobRegistry
+
+	configMu sync.Mutex // Вызовы команд, меняющих конфигурацию
 }
 
 func NewBot(config *Config) (*Bot, error) {
-	model, err := inference.NewClient(
-		config.Ollama.GeneralModel,
-		config.Ollama.EmbeddingModel,
-		config.Ollama.QueryTimeoutSeconds,
-	)
+	model, err := inference.NewClient(config.Ollama.ClientOptions())
 	if err != nil {
 		return nil, err
 	}
@@ -40,9 +47,10 @@ func NewBot(config *Config) (*Bot, error) {
 	}
 
 	bot := &Bot{
-		api:   api,
-		conf:  config,
-		model: model,
+		api:       api,
+		conf:      config,
+		model:     model,
+		batchWake: make(chan struct{}, 1),
 	}
 
 	bot.server = NewWebServer(bot)
@@ -72,19 +80,86 @@ func (bot *Bot) init() {
 		log.Panic(err)
 	}
 
+	if err := bot.seedRoles(); err != nil {
+		log.Panic(err)
+	}
+
+	if err := bot.migrateWebLogin(); err != nil {
+		log.Panic(err)
+	}
+
 	bot.NewCommand(Command{
 		Name:        "help",
 		Description: "Напечатать вспомогательное сообщение",
 		Group:       "Общее",
-		Call:        bot.Help,
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.Help),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "profiles",
+		Description: "Напечатать профили чатов и пользователей и то, что в них изменено",
+		Group:       "Доступ",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.ListProfiles),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "profile",
+		Description:   "Показать профиль текущего чата или пользователя. \"on\" создает профиль со своими объектом, метаданными, промптами, моделью и Google таблицей, \"off\" удаляет его. Незаданные в профиле настройки берутся из общей конфигурации",
+		Example:       "profile on",
+		Group:         "Общее",
+		MinRole:       domain.RoleAnalyst,
+		ChangesConfig: true,
+		Call:          textCommand(bot.Profile),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "changeobj",
+		Description:   "Изменить имя основного объекта, отношение к которому будет анализировано. Если создан профиль (`profile on`), меняется только он",
+		Example:       "changeobj Человечество",
+		Group:         "Анализ",
+		MinRole:       domain.RoleAnalyst,
+		ChangesConfig: true,
+		Call:          textCommand(bot.ChangeObj),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "changeobj",
-		Description: "Изменить имя объекта, отношение к которому будет анализировано.",
-		Example:     "changeobj Человечество",
+		Name:          "addobject",
+		Description:   "Добавить отслеживаемый объект. После \"|\" можно указать метаданные объекта",
+		Example:       "addobject Губернатор | Губернатор Ростовской области ...",
+		Group:         "Анализ",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.AddObject),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "rmobject",
+		Description:   "Перестать отслеживать объект",
+		Example:       "rmobject Губернатор",
+		Group:         "Анализ",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.RemoveObject),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "objects",
+		Description: "Напечатать список отслеживаемых объектов",
 		Group:       "Анализ",
-		Call:        bot.ChangeObj,
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.ListObjects),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "setobjectprompt",
+		Description:   "Задать объекту собственный промпт (affiliation, sentiment, title или structured). Промпт \"-\" возвращает общий",
+		Example:       "setobjectprompt Губернатор | sentiment | Определи отношение к {{OBJECT}} ... Текст: {{TEXT}}",
+		Group:         "LLM",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetObjectPrompt),
 	})
 
 	bot.NewCommand(Command{
@@ -92,83 +167,295 @@ func (bot *Bot) init() {
 		Description: "Анализировать статью",
 		Example:     "do https://example.com/article2",
 		Group:       "Анализ",
-		Call:        bot.Do,
+		MinRole:     domain.RoleAnalyst,
+		Call:        textCommand(bot.Do),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "batch",
+		Description: "Поставить в очередь на анализ список URL (через пробел или с новой строки, либо прикрепленным .txt/.csv файлом)",
+		Example:     "batch https://example.com/article1 https://example.com/article2",
+		Group:       "Анализ",
+		MinRole:     domain.RoleAnalyst,
+		Call:        bot.Batch,
+	})
+
+	bot.NewCommand(Command{
+		Name:        "queue",
+		Description: "Показать прогресс незавершенных пакетов или конкретного пакета по номеру",
+		Example:     "queue 12",
+		Group:       "Анализ",
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.QueueStatus),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "jobs",
+		Description: "Показать свои выполняющиеся команды. Администраторы видят команды всех пользователей и анализы статей из пакетов и лент",
+		Example:     "jobs",
+		Group:       "Анализ",
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.ListJobs),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "cancel",
+		Description: "Отменить свои выполняющиеся команды или команду по номеру. Администраторы могут отменять чужие команды",
+		Example:     "cancel 3",
+		Group:       "Анализ",
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.Cancel),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "toggleSaveSimilar",
-		Description: "Не сохранять|Сохранять похожие статьи",
+		Name:        "reanalyze",
+		Description: "Повторно проанализировать сохраненные статьи текущими промптами и моделью без повторной загрузки. Фильтры: all, from=, to=, host=, sentiment=, object=, ids=, limit=. Исправленные вручную статьи выбираются только с флагом verified. Прежние результаты сохраняются в истории",
+		Example:     "reanalyze from=2025-01-01 host=example.com sentiment=Отрицательный",
 		Group:       "Анализ",
-		Call:        bot.ToggleSaveSimilar,
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.Reanalyze),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "toggleSaveSimilar",
+		Description:   "Не сохранять|Сохранять похожие статьи",
+		Group:         "Анализ",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.ToggleSaveSimilar),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "togglerelevance",
+		Description:   "Включить|Выключить проверку релевантности статьи объекту перед анализом LLM",
+		Group:         "Анализ",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.ToggleRelevance),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "setkeywords",
+		Description:   "Указать ключевые слова (основы слов или фразы через запятую) для проверки релевантности. \"-\" очищает список",
+		Example:       "setkeywords ростов, донск, мэр города",
+		Group:         "Анализ",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetRelevanceKeywords),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "setminkeywords",
+		Description:   "Указать минимальное количество вхождений ключевых слов, чтобы статья считалась релевантной",
+		Example:       "setminkeywords 2",
+		Group:         "Анализ",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetMinKeywordHits),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "setrelevancethreshold",
+		Description:   "Указать порог векторного сходства статьи с описанием объекта (0 - не проверять)",
+		Example:       "setrelevancethreshold 0.45",
+		Group:         "Анализ",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetRelevanceThreshold),
 	})
 
 	bot.NewCommand(Command{
 		Name:        "about",
 		Description: "Напечатать информацию о боте",
 		Group:       "Общее",
-		Call:        bot.About,
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.About),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "togglepublic",
-		Description: "Включить или выключить публичный/приватный доступ к боту",
-		Group:       "Телеграм",
-		Call:        bot.TogglePublicity,
+		Name:          "togglepublic",
+		Description:   "Включить или выключить публичный/приватный доступ к боту",
+		Group:         "Телеграм",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.TogglePublicity),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "adduser",
-		Description: "Добавить доступ к боту определенному пользователю по ID (напишите боту @userinfobot для получения своего ID)",
-		Example:     "adduser 5293210034",
-		Group:       "Телеграм",
-		Call:        bot.AddUser,
+		Name:          "adduser",
+		Description:   "Добавить доступ к боту определенному пользователю по ID (напишите боту @userinfobot для получения своего ID). Можно сразу указать роль",
+		Example:       "adduser 5293210034 analyst",
+		Group:         "Телеграм",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.AddUser),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "rmuser",
-		Description: "Убрать доступ к боту определенному пользователю по ID",
-		Example:     "rmuser 5293210034",
-		Group:       "Телеграм",
-		Call:        bot.RemoveUser,
+		Name:          "rmuser",
+		Description:   "Убрать доступ к боту определенному пользователю по ID",
+		Example:       "rmuser 5293210034",
+		Group:         "Телеграм",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.RemoveUser),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "setmaxcontent",
-		Description: "Установить новый лимит символов, извлекаемых из текста статьи",
-		Example:     "setmaxcontent 340",
-		Group:       "Анализ",
-		Call:        bot.ChangeMaxContentSize,
+		Name:        "roles",
+		Description: "Напечатать назначенные роли пользователей Telegram и веб-интерфейса",
+		Group:       "Доступ",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.ListRoles),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "setrole",
+		Description: "Назначить роль пользователю: viewer (просмотр результатов), analyst (анализ статей) или admin (настройка бота). Пользователь веб-интерфейса указывается как web:имя",
+		Example:     "setrole 5293210034 analyst",
+		Group:       "Доступ",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.SetRole),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "rmrole",
+		Description: "Снять назначенную роль с пользователя. Пользователь Telegram снова получит роль по умолчанию, если у него есть доступ, а пользователь веб-интерфейса потеряет доступ",
+		Example:     "rmrole web:ivan",
+		Group:       "Доступ",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.RemoveRole),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "setdefaultrole",
+		Description:   "Изменить роль по умолчанию для пользователей Telegram без назначенной роли",
+		Example:       "setdefaultrole viewer",
+		Group:         "Доступ",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetDefaultRole),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "webusers",
+		Description: "Напечатать пользователей веб-интерфейса с ролями и временем последнего входа",
+		Group:       "Доступ",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.ListWebUsers),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "addwebuser",
+		Description: "Создать пользователя веб-интерфейса с ролью. Если пароль не указан, он будет сгенерирован",
+		Example:     "addwebuser ivan analyst",
+		Group:       "Доступ",
+		MinRole:     domain.RoleAdmin,
+		SecretArgs:  true,
+		Call:        textCommand(bot.AddWebUser),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "disablewebuser",
+		Description: "Отключить пользователя веб-интерфейса и отозвать все его сессии",
+		Example:     "disablewebuser ivan",
+		Group:       "Доступ",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.DisableWebUser),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "enablewebuser",
+		Description: "Снова разрешить пользователю веб-интерфейса входить",
+		Example:     "enablewebuser ivan",
+		Group:       "Доступ",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.EnableWebUser),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "resetwebpassword",
+		Description: "Сменить пароль пользователя веб-интерфейса и отозвать его сессии. Если пароль не указан, он будет сгенерирован",
+		Example:     "resetwebpassword ivan",
+		Group:       "Доступ",
+		MinRole:     domain.RoleAdmin,
+		SecretArgs:  true,
+		Call:        textCommand(bot.ResetWebPassword),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "websessions",
+		Description: "Напечатать действующие сессии (jti токенов) пользователя веб-интерфейса",
+		Example:     "websessions ivan",
+		Group:       "Доступ",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.ListWebSessions),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "revokesession",
+		Description: "Отозвать сессию веб-интерфейса по jti токена или все сессии пользователя по имени",
+		Example:     "revokesession ivan",
+		Group:       "Доступ",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.RevokeWebSession),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "audit",
+		Description: "Журнал вызовов команд и изменений конфигурации. Можно указать количество записей, команду, пользователя и \"changes\" для записей с изменениями. Подробности записи - audit #ID",
+		Example:     "audit 20 changes setpromptsent",
+		Group:       "Доступ",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.Audit),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "setmaxcontent",
+		Description:   "Установить новый лимит символов, извлекаемых из текста статьи",
+		Example:       "setmaxcontent 340",
+		Group:         "Анализ",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.ChangeMaxContentSize),
 	})
 
 	bot.NewCommand(Command{
 		Name:        "conf",
 		Description: "Написать текущую конфигурацию",
 		Group:       "Общее",
-		Call:        bot.PrintConfig,
+		MinRole:     domain.RoleAnalyst,
+		Call:        textCommand(bot.PrintConfig),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "setsheetname",
-		Description: "Изменить наименование листа таблицы",
-		Example:     "setsheetname Sheet 2",
-		Group:       "Таблицы",
-		Call:        bot.ChangeSheetName,
+		Name:          "setsheetname",
+		Description:   "Изменить наименование листа таблицы. Если создан профиль (`profile on`), меняется только он",
+		Example:       "setsheetname Sheet 2",
+		Group:         "Таблицы",
+		MinRole:       domain.RoleAnalyst,
+		ChangesConfig: true,
+		Call:          textCommand(bot.ChangeSheetName),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "setsheetid",
-		Description: "Изменить идентификатор таблицы",
-		Example:     "setsheetid s0m3_1d_l1k3_k4DGHJd1",
-		Group:       "Таблицы",
-		Call:        bot.ChangeSpreadsheetID,
+		Name:          "setsheetid",
+		Description:   "Изменить идентификатор таблицы. Если создан профиль (`profile on`), меняется только он",
+		Example:       "setsheetid s0m3_1d_l1k3_k4DGHJd1",
+		Group:         "Таблицы",
+		MinRole:       domain.RoleAnalyst,
+		ChangesConfig: true,
+		Call:          textCommand(bot.ChangeSpreadsheetID),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "setquerytimeout",
-		Description: "Изменить допустимое время запросов к LLM в секундах. Если запрос будет обрабатываться дольше допустимого, - запрос окончится досрочно.",
-		Example:     "setquerytimeout 120",
-		Group:       "LLM",
-		Call:        bot.ChangeQueryTimeout,
+		Name:          "setquerytimeout",
+		Description:   "Изменить допустимое время запросов к LLM в секундах. Если запрос будет обрабатываться дольше допустимого, - запрос окончится досрочно.",
+		Example:       "setquerytimeout 120",
+		Group:         "LLM",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.ChangeQueryTimeout),
 	})
 
 	bot.NewCommand(Command{
@@ -176,45 +463,83 @@ func (bot *Bot) init() {
 		Description: "Задать общий запрос модели",
 		Example:     "ask Как получить API token телеграм?",
 		Group:       "LLM",
-		Call:        bot.GeneralQuery,
+		MinRole:     domain.RoleAnalyst,
+		Call:        textCommand(bot.GeneralQuery),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "setobjectdata",
-		Description: "Указать метаданные об объекте",
-		Example:     "setobjectdata Ростов-на-Дону - город на юге России, включает в себя ...",
-		Group:       "Общее",
-		Call:        bot.SetObjectData,
+		Name:          "setobjectdata",
+		Description:   "Указать метаданные об основном объекте или, в формате \"Имя | данные\", о конкретном. Если создан профиль (`profile on`), меняется только он",
+		Example:       "setobjectdata Ростов-на-Дону | Ростов-на-Дону - город на юге России, включает в себя ...",
+		Group:         "Общее",
+		MinRole:       domain.RoleAnalyst,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetObjectData),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "setpromptaf",
-		Description: "Изменить промпт связи",
-		Example:     "setpromptaf При чем здесь {{OBJECT}}? Текст: {{TEXT}}",
-		Group:       "LLM",
-		Call:        bot.SetAffiliationPrompt,
+		Name:          "setpromptaf",
+		Description:   "Изменить промпт связи. Если создан профиль (`profile on`), меняется только он",
+		Example:       "setpromptaf При чем здесь {{OBJECT}}? Текст: {{TEXT}}",
+		Group:         "LLM",
+		MinRole:       domain.RoleAnalyst,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetAffiliationPrompt),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "setpromptti",
-		Description: "Изменить промпт нахождения заголовка",
-		Example:     "setpromptti Найди заголовок текста. Текст: {{TEXT}}",
-		Group:       "LLM",
-		Call:        bot.SetTitlePrompt,
+		Name:          "setpromptti",
+		Description:   "Изменить промпт нахождения заголовка. Если создан профиль (`profile on`), меняется только он",
+		Example:       "setpromptti Найди заголовок текста. Текст: {{TEXT}}",
+		Group:         "LLM",
+		MinRole:       domain.RoleAnalyst,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetTitlePrompt),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "setpromptsent",
-		Description: "Изменить промпт выявления отношения к объекту",
-		Example:     "setpromptses Определи отношение к {{OBJECT}} в следующем тексте. Ответь одним предложением. Текст: {{TEXT}}",
+		Name:          "setpromptsent",
+		Description:   "Изменить промпт выявления отношения к объекту. Если создан профиль (`profile on`), меняется только он",
+		Example:       "setpromptses Определи отношение к {{OBJECT}} в следующем тексте. Ответь одним предложением. Текст: {{TEXT}}",
+		Group:         "LLM",
+		MinRole:       domain.RoleAnalyst,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetSentimentPrompt),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "setpromptstruct",
+		Description:   "Изменить промпт единого структурированного запроса (ответ в JSON: title, affiliation, sentiment, confidence, justification). Если создан профиль (`profile on`), меняется только он",
+		Example:       "setpromptstruct Проанализируй отношение к {{OBJECT}} и верни JSON ... Текст: {{TEXT}}",
+		Group:         "LLM",
+		MinRole:       domain.RoleAnalyst,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetStructuredPrompt),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "prompt",
+		Description: "Показать полный текст промпта по хешу (или его началу) из происхождения результата анализа",
+		Example:     "prompt 3f2a9c1b7e4d",
 		Group:       "LLM",
-		Call:        bot.SetSentimentPrompt,
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.ShowPrompt),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "togglestructured",
+		Description:   "Выключить|Включить структурированный JSON ответ LLM (при выключении используются отдельные текстовые запросы)",
+		Group:         "LLM",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.ToggleStructuredOutput),
 	})
 
 	bot.NewCommand(Command{
 		Name:        "xlsx",
 		Description: "Сгенерировать файл XLSX таблицы с результатами анализов",
 		Group:       "Таблицы",
+		MinRole:     domain.RoleViewer,
 		Call:        bot.GenerateSpreadsheet,
 	})
 
@@ -222,23 +547,80 @@ func (bot *Bot) init() {
 		Name:        "findsimilar",
 		Description: "Определить уникальность статьи без проведения полного анализа. Если есть похожие - сообщить.",
 		Group:       "Анализ",
+		MinRole:     domain.RoleAnalyst,
 		Example:     "findsimilar https://example.com/article",
-		Call:        bot.FindSimilar,
+		Call:        textCommand(bot.FindSimilar),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "stories",
+		Description: "Показать последние сюжеты из нескольких статей: первое появление и распространение по ресурсам",
+		Example:     "stories 20",
+		Group:       "Анализ",
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.ListStories),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "story",
+		Description: "Показать сюжет: первое появление, ресурсы и статьи в порядке публикации",
+		Example:     "story 12",
+		Group:       "Анализ",
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.ShowStory),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "clusterstories",
+		Description: "Распределить по сюжетам сохраненные статьи, еще не отнесенные ни к одному",
+		Group:       "Анализ",
+		MinRole:     domain.RoleAnalyst,
+		Call:        textCommand(bot.ClusterStories),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "togglestories",
+		Description:   "Выключить|Включить распределение новых статей по сюжетам",
+		Group:         "Анализ",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.ToggleStories),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "setstorythreshold",
+		Description:   "Изменить порог общей схожести (0-1), при котором статья попадает в сюжет похожей статьи",
+		Example:       "setstorythreshold 0.55",
+		Group:         "Анализ",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetStoryThreshold),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "dbinfo",
+		Description: "Показать версию схемы базы данных, ее размер и количество строк в таблицах",
+		Group:       "База данных",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.DatabaseInfo),
 	})
 
 	bot.NewCommand(Command{
 		Name:        "models",
 		Description: "Напечатать доступные боту локальные LLM",
 		Group:       "LLM",
-		Call:        bot.ListModels,
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.ListModels),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "setmodel",
-		Description: "Указать имя новой локальной LLM, которая будет использоваться",
-		Example:     "setmodel gemma3:12b",
-		Group:       "LLM",
-		Call:        bot.SetModel,
+		Name:          "setmodel",
+		Description:   "Указать имя новой LLM, которая будет использоваться. Чтобы сменить бэкенд, укажите его перед именем модели (ollama или openai) и, при необходимости, адрес API после. Если создан профиль (`profile on`), меняется только он",
+		Example:       "setmodel openai qwen2.5-7b-instruct http://localhost:8000/v1",
+		Group:         "LLM",
+		MinRole:       domain.RoleAnalyst,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetModel),
 	})
 
 	bot.NewCommand(Command{
@@ -246,22 +628,99 @@ func (bot *Bot) init() {
 		Description: "Загрузить статьи из XLSX файла (без анализа)",
 		Example:     "loadxlsx [прикрепите файл]",
 		Group:       "База данных",
+		MinRole:     domain.RoleAnalyst,
 		Call:        bot.LoadXLSX,
 	})
 
+	bot.NewCommand(Command{
+		Name:        "article",
+		Description: "Показать статью по ID или URL: результаты анализа, происхождение, ручную проверку и прежние результаты",
+		Example:     "article 42",
+		Group:       "База данных",
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.ShowArticle),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "search",
+		Description: "Полнотекстовый поиск по заголовкам, текстам и примечаниям сохраненных статей. Слова ищутся по началу",
+		Example:     "search отключение электроэнергии",
+		Group:       "База данных",
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.SearchArticles),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "setsentiment",
+		Description: "Исправить отношение статьи вручную (к основному объекту или к указанному). Результат помечается как проверенный человеком, прежний сохраняется в истории",
+		Example:     "setsentiment 42 Отрицательный Жители, люди",
+		Group:       "База данных",
+		MinRole:     domain.RoleAnalyst,
+		Call:        textCommand(bot.SetSentiment),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "rmarticle",
+		Description: "Удалить статью по ID или URL вместе с ее результатами и историей анализа",
+		Example:     "rmarticle 42",
+		Group:       "База данных",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.RemoveArticle),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "forget",
+		Description: "Удалить статьи по дате публикации (from=, to=) и сайту (host=) или все (all). Сначала показывает, сколько статей будет удалено, и код подтверждения; удаление - повторной командой с confirm=код. Если задан каталог архивов, статьи сначала сохраняются в архив",
+		Example:     "forget to=2024-12-31 host=example.com",
+		Group:       "База данных",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.Forget),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "retention",
+		Description: "Показать, сколько статей затронут правила хранения (удаление векторов и текстов старых статей), или применить их сейчас (apply). Перед удалением статьи сохраняются в сжатый JSONL архив",
+		Example:     "retention apply",
+		Group:       "База данных",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.Retention),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "backup",
+		Description: "Создать резервную копию: согласованную копию базы, config.json и настройки колонок, промптов и объектов в одном архиве. Восстанавливается командой restore или запуском бота с -restore. Старые копии сверх заданного количества удаляются. С redact секреты в config.json скрываются; копия со скрытыми секретами отправляется файлом",
+		Example:     "backup redact",
+		Group:       "База данных",
+		MinRole:     domain.RoleAdmin,
+		Call:        bot.Backup,
+	})
+
+	bot.NewCommand(Command{
+		Name:          "restore",
+		Description:   "Восстановить резервную копию (прикрепите архив или укажите путь). Копия проверяется сразу, а база и конфигурация заменяются при следующем запуске бота. \"restore settings\" сразу восстанавливает только настройки колонок XLSX, промптов и объектов, \"restore cancel\" отменяет подготовленное восстановление",
+		Example:       "restore backups/acasbot-backup-20250101-040000.000.tar.gz",
+		Group:         "База данных",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.Restore),
+	})
+
 	bot.NewCommand(Command{
 		Name:        "getlogs",
 		Description: "Отправить файл логов",
 		Group:       "Общее",
+		MinRole:     domain.RoleAdmin,
 		Call:        bot.SendLogs,
 	})
 
 	bot.NewCommand(Command{
-		Name:        "setxlsxcolumns",
-		Description: "Установить конфигурацию колонок для XLSX-файла",
-		Example:     "setxlsxcolumns [{\"name\": \"Дата\", \"field\": \"published_at\"}, {\"name\": \"Заголовок\", \"llm_query\": \"Извлеки заголовок из текста: {{.Content}}\"}]",
-		Group:       "Таблицы",
-		Call:        bot.SetXLSXColumns,
+		Name:          "setxlsxcolumns",
+		Description:   "Установить конфигурацию колонок для XLSX-файла",
+		Example:       "setxlsxcolumns [{\"name\": \"Дата\", \"field\": \"published_at\"}, {\"name\": \"Заголовок\", \"llm_query\": \"Извлеки заголовок из текста: {{.Content}}\"}]",
+		Group:         "Таблицы",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetXLSXColumns),
 	})
 
 	bot.NewCommand(Command{
@@ -269,14 +728,119 @@ func (bot *Bot) init() {
 		Description: "Показать текущую конфигурацию колонок для XLSX-файла",
 		Example:     "showxlsxcolumns",
 		Group:       "Таблицы",
-		Call:        bot.ShowXLSXColumns,
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.ShowXLSXColumns),
 	})
 
 	bot.NewCommand(Command{
-		Name:        "togglepushtogoogle",
-		Description: "Не отправлять|Отправлять результаты анализа в гугл таблицу",
-		Group:       "Таблицы",
-		Call:        bot.TogglePushToGoogleSheets,
+		Name:        "addfeed",
+		Description: "Подписаться на RSS/Atom ленту. Новые статьи из нее будут анализироваться автоматически. Можно указать интервал проверки в минутах",
+		Example:     "addfeed https://example.com/rss.xml 30",
+		Group:       "Ленты",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.AddFeed),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "rmfeed",
+		Description: "Отписаться от ленты по номеру или URL",
+		Example:     "rmfeed 2",
+		Group:       "Ленты",
+		MinRole:     domain.RoleAdmin,
+		Call:        textCommand(bot.RemoveFeed),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "feeds",
+		Description: "Напечатать список лент",
+		Group:       "Ленты",
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.ListFeeds),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "setfeedchat",
+		Description:   "Указать ID чата Telegram, куда будут отправляться результаты анализа новых статей из лент (0 - только веб-интерфейс)",
+		Example:       "setfeedchat 5293210034",
+		Group:         "Ленты",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetFeedChat),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "togglefeeds",
+		Description:   "Выключить|Включить автоматическую проверку лент",
+		Group:         "Ленты",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.ToggleFeeds),
+	})
+
+	// "reports" регистрируется раньше "report", так как команды Telegram сопоставляются по префиксу
+	bot.NewCommand(Command{
+		Name:        "reports",
+		Description: "Напечатать расписание сводок и время следующей отправки",
+		Group:       "Сводки",
+		MinRole:     domain.RoleViewer,
+		Call:        textCommand(bot.ListReports),
+	})
+
+	bot.NewCommand(Command{
+		Name:        "report",
+		Description: "Сводка по статьям, добавленным за период: количество по отношению и ресурсам, самые цитируемые оригиналы и новые негативные статьи. Без аргументов - за последние сутки",
+		Example:     "report 2025-06-01 2025-06-07",
+		Group:       "Сводки",
+		MinRole:     domain.RoleViewer,
+		Call:        bot.Report,
+	})
+
+	bot.NewCommand(Command{
+		Name:          "addreport",
+		Description:   "Добавить сводку по расписанию в формате cron (минута час день месяц день_недели). Указывается период сводки в часах и название",
+		Example:       "addreport 0 9 * * 1 | 168 | Недельная сводка",
+		Group:         "Сводки",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.AddReport),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "rmreport",
+		Description:   "Удалить сводку из расписания по номеру",
+		Example:       "rmreport 2",
+		Group:         "Сводки",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.RemoveReport),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "setreportchat",
+		Description:   "Указать ID чата Telegram, куда будут отправляться сводки по расписанию (0 - только веб-интерфейс)",
+		Example:       "setreportchat 5293210034",
+		Group:         "Сводки",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.SetReportChat),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "togglereports",
+		Description:   "Выключить|Включить отправку сводок по расписанию",
+		Group:         "Сводки",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.ToggleReports),
+	})
+
+	bot.NewCommand(Command{
+		Name:          "togglepushtogoogle",
+		Description:   "Не отправлять|Отправлять результаты анализа в гугл таблицу",
+		Group:         "Таблицы",
+		MinRole:       domain.RoleAdmin,
+		ChangesConfig: true,
+		Call:          textCommand(bot.TogglePushToGoogleSheets),
 	})
 
 	if bot.conf.Sheets.PushToGoogleSheet {
@@ -294,6 +858,21 @@ func (bot *Bot) init() {
 	// Автоматически сохранять таблицу
 	bot.StartAutoSave(time.Hour * 1)
 
+	// Обрабатывать очередь пакетного анализа
+	bot.StartBatchWorkers()
+
+	// Проверять подписки на ленты
+	bot.StartFeedPoller(time.Minute * 1)
+
+	// Отправлять сводки по расписанию
+	bot.StartReportScheduler(time.Second * 30)
+
+	// Применять правила хранения по расписанию
+	bot.StartRetentionScheduler(time.Second * 30)
+
+	// Создавать резервные копии по расписанию
+	bot.StartBackupScheduler(time.Second * 30)
+
 	// Запустить веб-сервер
 	if bot.conf.Web.Enabled {
 		bot.server.Start()
@@ -314,7 +893,6 @@ func (bot *Bot) Start() error {
 
 		// Блокируем горутину, чтобы приложение не завершилось (веб-сервер работает в фоне)
 		select {}
-		return nil
 	}
 
 	log.Printf("Бот авторизован как %s", bot.api.Self.UserName)
@@ -326,37 +904,35 @@ func (bot *Bot) Start() error {
 		updates := bot.api.GetUpdatesChan(u)
 
 		for update := range updates {
+			if update.CallbackQuery != nil {
+				go bot.handleTelegramCallback(update.CallbackQuery)
+				continue
+			}
+
 			if update.Message == nil {
 				continue
 			}
 
 			go func(message *tgbotapi.Message) {
-				log.Printf("[%s] %s (cap: %s)", message.From.UserName, message.Text, message.Caption)
+				log.Printf(
+					"[%s] %s (cap: %s)",
+					message.From.UserName, bot.loggableText(message.Text), bot.loggableText(message.Caption),
+				)
 
 				// Проверка на возможность дальнейшего общения с данным пользователем
-				if !bot.conf.Telegram.Public {
-					var allowed bool = false
-					for _, allowedID := range bot.conf.Telegram.AllowedUserIDs {
-						if message.From.ID == allowedID {
-							allowed = true
-							break
-						}
-					}
-
-					if !allowed {
-						// Не пропускаем дальше
-						msg := tgbotapi.NewMessage(
-							message.Chat.ID,
-							"Вам не разрешено пользоваться этим ботом!",
-						)
-						bot.api.Send(msg)
-
-						if bot.conf.Debug {
-							log.Printf("Не допустили к общению пользователя %v", message.From.ID)
-						}
+				if bot.telegramRole(message.From.ID) == domain.RoleNone {
+					// Не пропускаем дальше
+					msg := tgbotapi.NewMessage(
+						message.Chat.ID,
+						"Вам не разрешено пользоваться этим ботом!",
+					)
+					bot.api.Send(msg)
 
-						return
+					if bot.conf.Debug {
+						log.Printf("Не допустили к общению пользователя %v", message.From.ID)
 					}
+
+					return
 				}
 
 				// Обработать команды
@@ -372,6 +948,16 @@ func (bot *Bot) Start() error {
 					}
 				}
 
+				// Несколько URL сразу отправляем пакетом
+				if len(extractURLs(message.Text)) > 1 {
+					batch := bot.CommandByName("batch")
+					if batch != nil {
+						message.Text = "batch " + message.Text
+						bot.handleTelegramCommand(batch, message)
+					}
+					return
+				}
+
 				// Проверим, URL ли это
 				if strings.HasPrefix(message.Text, "http") {
 					// Отправляем команде do
@@ -419,134 +1005,116 @@ func (bot *Bot) sendCommandSuggestions(chatID int64, input string) {
 }
 
 func (bot *Bot) handleTelegramCommand(command *Command, msg *tgbotapi.Message) {
-	var args string
+	if role := bot.telegramRole(msg.From.ID); !role.Allows(command.MinRole) {
+		bot.sendError(msg.Chat.ID, permissionDenied(command, role), msg.MessageID)
+		return
+	}
 
-	switch command.Name {
-	case "loadxlsx":
-		// Для команды loadxlsx обрабатываем прикрепленный файл
-		if msg.Document == nil {
-			bot.sendError(msg.Chat.ID, "Пожалуйста, прикрепите XLSX файл к команде", msg.MessageID)
-			return
-		}
+	caller := Caller{
+		Platform: domain.PlatformTelegram,
+		UserID:   strconv.FormatInt(msg.From.ID, 10),
+		UserName: msg.From.UserName,
+		ChatID:   msg.Chat.ID,
+	}
 
-		// Проверяем расширение файла
-		if !strings.HasSuffix(msg.Document.FileName, ".xlsx") {
-			bot.sendError(msg.Chat.ID, "Формат файла должен быть .xlsx", msg.MessageID)
-			return
-		}
+	// Убрать имя команды
+	var args string
+	parts := strings.Split(strings.TrimSpace(msg.Text), " ")
+	if len(parts) >= 2 {
+		args = strings.Join(parts[1:], " ")
+	}
+
+	call := bot.newCall(caller, args)
 
-		// Скачиваем файл
-		fileURL, err := bot.api.GetFileDirectURL(msg.Document.FileID)
+	// Прикрепленный файл передается команде вложением
+	if msg.Document != nil {
+		contents, err := bot.downloadTelegramFile(msg.Document.FileID)
 		if err != nil {
-			bot.sendError(msg.Chat.ID, "Ошибка получения файла", msg.MessageID)
+			bot.sendError(msg.Chat.ID, "Ошибка скачивания файла: "+err.Error(), msg.MessageID)
 			return
 		}
+		call.Attachments = append(call.Attachments, Attachment{Name: msg.Document.FileName, Data: contents})
+	}
 
-		// Индикатор загрузки
-		processingMsg := tgbotapi.NewMessage(msg.Chat.ID, "📥 Загружаю файл...")
-		sentMsg, _ := bot.api.Send(processingMsg)
-		defer func() {
-			deleteMsg := tgbotapi.NewDeleteMessage(msg.Chat.ID, sentMsg.MessageID)
-			bot.api.Send(deleteMsg)
-		}()
+	// Ход выполнения и ответ модели по частям показываются одним обновляемым сообщением,
+	// которое удаляется после ответа
+	status := newTelegramStatus(bot, msg.Chat.ID, msg.MessageID, call.JobID)
+	call.progress = status.progress
+	call.stream = status.stream
+	defer status.close()
 
-		// Создаем временный файл
-		tmpFile, err := os.CreateTemp("", "acasbot-*.xlsx")
-		if err != nil {
-			bot.sendError(msg.Chat.ID, "Ошибка создания временного файла", msg.MessageID)
-			return
-		}
-		defer os.Remove(tmpFile.Name())
-		defer tmpFile.Close()
+	response, err := bot.runCommand(call, command)
+	if err != nil {
+		bot.sendError(msg.Chat.ID, "Ошибка: "+err.Error(), msg.MessageID)
+		return
+	}
 
-		// Скачиваем содержимое
-		resp, err := http.Get(fileURL)
-		if err != nil {
-			bot.sendError(msg.Chat.ID, "Ошибка скачивания файла", msg.MessageID)
-			return
-		}
-		defer resp.Body.Close()
+	sent := bot.sendResponse(msg.Chat.ID, response, msg.MessageID)
 
-		if _, err := io.Copy(tmpFile, resp.Body); err != nil {
-			bot.sendError(msg.Chat.ID, "Ошибка сохранения файла", msg.MessageID)
-			return
+	// Сообщение о поставленном пакете обновляется по мере его обработки
+	if batch, ok := response.Data.(*domain.Batch); ok && sent != 0 {
+		if err := bot.conf.GetDB().SetBatchMessageID(batch.ID, sent); err != nil {
+			log.Printf("Не удалось запомнить сообщение прогресса пакета #%d: %v", batch.ID, err)
 		}
+	}
+}
 
-		// Устанавливаем args как путь к временному файлу
-		args = tmpFile.Name()
-	case "xlsx":
-		fileName := "ACASbot_Results.xlsx"
-		if _, err := os.Stat(fileName); err == nil {
-			// Файл существует, отправляем его
-			fileBytes, err := os.ReadFile(fileName)
-			if err != nil {
-				bot.sendError(msg.Chat.ID, "Ошибка чтения файла: "+err.Error(), msg.MessageID)
-				return
-			}
-
-			file := tgbotapi.FileBytes{
-				Name:  "ACASbot_Results.xlsx",
-				Bytes: fileBytes,
-			}
-			docMsg := tgbotapi.NewDocument(msg.Chat.ID, file)
-			docMsg.Caption = "📊 Сгенерированная таблица на основе базы данных"
-			docMsg.ReplyToMessageID = msg.MessageID
-			_, err = bot.api.Send(docMsg)
-			if err != nil {
-				bot.sendError(msg.Chat.ID, "Ошибка отправки файла: "+err.Error(), msg.MessageID)
-				return
-			}
+// Отправляет ответ команды в чат: текст, затем файлы. Возвращает ID сообщения с текстом или 0
+func (bot *Bot) sendResponse(chatID int64, response *Response, replyTo int) int {
+	var sentID int
+	if response.Text != "" {
+		msg := tgbotapi.NewMessage(chatID, response.Text)
+		msg.ReplyToMessageID = replyTo
+		msg.ParseMode = "Markdown"
+		if sent, err := bot.api.Send(msg); err == nil {
+			sentID = sent.MessageID
+		}
+	}
 
-			// Отправляем подтверждающее сообщение
-			bot.sendMessage(msg.Chat.ID, "Таблица успешно сгенерирована и отправлена", msg.MessageID)
-			return
+	for _, file := range response.Files {
+		// Telegram имеет ограничение на размер файла - 50MB
+		size, err := file.Size()
+		if err != nil {
+			bot.sendError(chatID, "Ошибка проверки размера файла: "+err.Error(), replyTo)
+			continue
 		}
-	case "getlogs":
-		// Проверяем, существует ли файл логов
-		if _, err := os.Stat(bot.conf.LogsFile); os.IsNotExist(err) {
-			bot.sendError(msg.Chat.ID, "Файл логов не найден", msg.MessageID)
-			return
+		if size > 50*1024*1024 {
+			bot.sendError(chatID, fmt.Sprintf("Файл %s слишком большой (максимум 50MB)", file.Name), replyTo)
+			continue
 		}
 
-		// Проверяем размер файла
-		fileInfo, err := os.Stat(bot.conf.LogsFile)
-		if err != nil {
-			bot.sendError(msg.Chat.ID, "Ошибка проверки размера файла: "+err.Error(), msg.MessageID)
-			return
+		var document tgbotapi.DocumentConfig
+		if file.Path != "" {
+			document = tgbotapi.NewDocument(chatID, tgbotapi.FilePath(file.Path))
+		} else {
+			document = tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: file.Name, Bytes: file.Data})
 		}
+		document.Caption = file.Caption
+		document.ReplyToMessageID = replyTo
 
-		// Telegram имеет ограничение на размер файла - 50MB
-		if fileInfo.Size() > 50*1024*1024 { // 50MB
-			bot.sendError(msg.Chat.ID, "Файл логов слишком большой (максимум 50MB)", msg.MessageID)
-			return
+		if _, err := bot.api.Send(document); err != nil {
+			bot.sendError(chatID, "Ошибка отправки файла: "+err.Error(), replyTo)
 		}
+	}
 
-		// Отправляем файл логов
-		file := tgbotapi.NewDocument(msg.Chat.ID, tgbotapi.FilePath(bot.conf.LogsFile))
-		file.Caption = "📄 Файл логов ACASbot"
-		file.ReplyToMessageID = msg.MessageID
+	return sentID
+}
 
-		_, err = bot.api.Send(file)
-		if err != nil {
-			bot.sendError(msg.Chat.ID, "Ошибка отправки файла логов: "+err.Error(), msg.MessageID)
-			return
-		}
-	default:
-		// Убрать имя команды
-		parts := strings.Split(strings.TrimSpace(msg.Text), " ")
-		if len(parts) < 2 {
-			// Это команда без аргументов
-			args = ""
-		} else {
-			args = strings.Join(parts[1:], " ")
-		}
+func (bot *Bot) downloadTelegramFile(fileID string) ([]byte, error) {
+	fileURL, err := bot.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
 	}
 
-	result, err := command.Call(args)
+	resp, err := http.Get(fileURL)
 	if err != nil {
-		bot.sendError(msg.Chat.ID, "Ошибка: "+err.Error(), msg.MessageID)
-		return
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("неожиданный ответ сервера: %s", resp.Status)
 	}
 
-	bot.sendMessage(msg.Chat.ID, result, msg.MessageID)
+	return io.ReadAll(resp.Body)
 }
@@ -0,0 +1,252 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Параметры запроса, которые добавляют рекламные и аналитические системы.
+// Параметры с префиксами utm_ и amp_ удаляются все
+var trackingParams = map[string]bool{
+	"fbclid":    true,
+	"gclid":     true,
+	"gclsrc":    true,
+	"dclid":     true,
+	"msclkid":   true,
+	"yclid":     true,
+	"ysclid":    true,
+	"igshid":    true,
+	"mc_cid":    true,
+	"mc_eid":    true,
+	"_openstat": true,
+	"_ga":       true,
+	"_gl":       true,
+	"amp":       true,
+	"usqp":      true,
+}
+
+// Поддомены мобильных и AMP версий сайтов
+var mobileHostPrefixes = []string{"m.", "mobile.", "amp.", "pda."}
+
+func isTrackingParam(name string, values []string) bool {
+	name = strings.ToLower(name)
+	if strings.HasPrefix(name, "utm_") || strings.HasPrefix(name, "amp_") || trackingParams[name] {
+		return true
+	}
+
+	// Встречается у сайтов, отдающих AMP версию по параметру
+	return name == "outputtype" && len(values) == 1 && strings.EqualFold(values[0], "amp")
+}
+
+// Убирает префиксы мобильных версий из хоста. Хост из двух частей не трогается
+func stripMobileHost(host string) string {
+	for {
+		stripped := false
+		for _, prefix := range mobileHostPrefixes {
+			if strings.HasPrefix(host, prefix) && strings.Count(host, ".") > 1 {
+				host = strings.TrimPrefix(host, prefix)
+				stripped = true
+			}
+		}
+		if !stripped {
+			return host
+		}
+	}
+}
+
+// Адрес оригинала для страниц из кэшей AMP (google.com/amp/s/..., *.cdn.ampproject.org/c/s/...)
+// или nil, если адрес не из кэша
+func unwrapAMPCache(u *url.URL) *url.URL {
+	host := strings.ToLower(u.Hostname())
+
+	var rest string
+	switch {
+	case (strings.HasPrefix(host, "google.") || strings.HasPrefix(host, "www.google.")) && strings.HasPrefix(u.Path, "/amp/"):
+		rest = strings.TrimPrefix(u.Path, "/amp/")
+	case strings.HasSuffix(host, ".cdn.ampproject.org"):
+		// Первый сегмент - тип содержимого: c, v, i, wp
+		parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+		if len(parts) < 2 {
+			return nil
+		}
+		rest = parts[1]
+	default:
+		return nil
+	}
+
+	scheme := "http"
+	if strings.HasPrefix(rest, "s/") {
+		scheme = "https"
+		rest = strings.TrimPrefix(rest, "s/")
+	}
+
+	original, err := url.Parse(scheme + "://" + rest)
+	if err != nil || original.Host == "" {
+		return nil
+	}
+	original.RawQuery = u.RawQuery
+
+	return original
+}
+
+// Убирает AMP сегменты пути: /amp/news/1, /news/1/amp, /news/1.amp, /news/1.amp.html
+func stripAMPPath(path string) string {
+	segments := strings.Split(path, "/")
+	kept := segments[:0]
+	for _, segment := range segments {
+		if strings.EqualFold(segment, "amp") {
+			continue
+		}
+		kept = append(kept, segment)
+	}
+
+	if last := len(kept) - 1; last >= 0 {
+		kept[last] = strings.Replace(kept[last], ".amp.html", ".html", 1)
+		kept[last] = strings.TrimSuffix(kept[last], ".amp")
+	}
+
+	return strings.Join(kept, "/")
+}
+
+// Приводит адрес статьи к каноническому виду, чтобы одна статья, открытая по разным
+// ссылкам, имела один адрес: без параметров отслеживания (utm_*, fbclid, ...) и якоря,
+// без AMP и мобильных версий, с хостом в нижнем регистре и отсортированными параметрами.
+// www не убирается: не у всех сайтов есть адрес без него. Некорректный адрес возвращается как есть
+func canonicalURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return raw
+	}
+
+	if original := unwrapAMPCache(u); original != nil {
+		u = original
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	host := stripMobileHost(strings.ToLower(u.Hostname()))
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	u.Path = strings.TrimSuffix(stripAMPPath(u.Path), "/")
+	u.RawPath = ""
+
+	query := u.Query()
+	for name, values := range query {
+		if isTrackingParam(name, values) {
+			query.Del(name)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// Хост без www и префиксов мобильных версий
+func siteHost(u *url.URL) string {
+	return stripMobileHost(strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."))
+}
+
+// Адреса относятся к одному сайту: хосты совпадают без учета www и мобильных
+// версий или один из них - поддомен другого
+func sameSite(a, b *url.URL) bool {
+	hostA, hostB := siteHost(a), siteHost(b)
+	return hostA == hostB ||
+		strings.HasSuffix(hostA, "."+hostB) ||
+		strings.HasSuffix(hostB, "."+hostA)
+}
+
+// Канонический адрес статьи с учетом <link rel="canonical"> (или og:url) страницы.
+// Адрес страницы принимается, только если он ведет на тот же сайт и не на главную:
+// некоторые сайты указывают главную канонической для всех страниц
+func pageCanonicalURL(doc *goquery.Document, pageURL string) string {
+	fallback := canonicalURL(pageURL)
+	if doc == nil {
+		return fallback
+	}
+
+	base, err := url.Parse(fallback)
+	if err != nil {
+		return fallback
+	}
+
+	href, _ := doc.Find(`link[rel="canonical"]`).First().Attr("href")
+	if strings.TrimSpace(href) == "" {
+		href, _ = doc.Find(`meta[property="og:url"]`).First().Attr("content")
+	}
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return fallback
+	}
+
+	declared, err := base.Parse(href)
+	if err != nil || declared.Host == "" || !sameSite(base, declared) {
+		return fallback
+	}
+
+	canonical, err := url.Parse(canonicalURL(declared.String()))
+	if err != nil || (canonical.Path == "" && base.Path != "") {
+		return fallback
+	}
+
+	return canonical.String()
+}
+
+// Сохраненная статья с тем же адресом или nil. Статьи, сохраненные до появления
+// канонических адресов, ищутся и по адресу в исходном виде
+func (bot *Bot) storedArticleByURL(ctx context.Context, rawURL string) (*domain.Article, error) {
+	store := bot.conf.GetArticleStore()
+
+	canonical := canonicalURL(rawURL)
+	article, err := store.GetArticleByURL(ctx, canonical)
+	if err != nil || article != nil || canonical == rawURL {
+		return article, err
+	}
+
+	return store.GetArticleByURL(ctx, rawURL)
+}
+
+// Статья с тем же адресом уже сохранена
+func (bot *Bot) knownArticleURL(ctx context.Context, rawURL string) (bool, error) {
+	store := bot.conf.GetArticleStore()
+
+	canonical := canonicalURL(rawURL)
+	exists, err := store.HasArticleByURL(ctx, canonical)
+	if err != nil || exists || canonical == rawURL {
+		return exists, err
+	}
+
+	return store.HasArticleByURL(ctx, rawURL)
+}
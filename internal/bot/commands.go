@@ -2,8 +2,10 @@ package bot
 
 import (
 	"Unbewohnte/ACASbot/internal/domain"
+	"Unbewohnte/ACASbot/internal/inference"
 	"Unbewohnte/ACASbot/internal/similarity"
 	"Unbewohnte/ACASbot/internal/spreadsheet"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -22,10 +24,33 @@ type Command struct {
 	Description string
 	Example     string
 	Group       string
-	Call        func(string) (string, error)
+	MinRole     domain.Role // Минимальная роль для вызова
+	SecretArgs  bool        // В журнал попадает только первый аргумент
+	// Команда меняет конфигурацию или профиль: такие вызовы выполняются по одному,
+	// и в журнал попадают изменения, сделанные ими
+	ChangesConfig bool
+	Call          func(call *CallContext) (*Response, error)
+}
+
+// Контекст вызова команды: кто вызвал, с какими аргументами и вложениями и с каким профилем настроек
+type CallContext struct {
+	Caller      Caller
+	Role        domain.Role
+	Args        string
+	Attachments []Attachment
+	Profile     *domain.Profile // nil - используется общая конфигурация
+
+	progress func(text string)  // Задается интерфейсом, из которого вызвана команда
+	stream   func(chunk string) // Получатель частей ответа модели, задается так же
+	job      *runningJob        // Задается в runCommand
 }
 
 func (bot *Bot) NewCommand(cmd Command) {
+	// Команды без указанной роли доступны только администраторам
+	if cmd.MinRole == domain.RoleNone {
+		cmd.MinRole = domain.RoleAdmin
+	}
+
 	bot.commands = append(bot.commands, cmd)
 }
 
@@ -45,14 +70,17 @@ func constructCommandHelpMessage(command Command) string {
 	if command.Example != "" {
 		commandHelp += fmt.Sprintf("*Пример:* `%s`\n", command.Example)
 	}
+	if command.MinRole > domain.RoleViewer {
+		commandHelp += fmt.Sprintf("*Роль:* %s\n", command.MinRole)
+	}
 
 	return commandHelp
 }
 
-func (bot *Bot) Help(args string) (string, error) {
-	if strings.TrimSpace(args) != "" {
+func (bot *Bot) Help(call *CallContext) (string, error) {
+	if strings.TrimSpace(call.Args) != "" {
 		// Ответить лишь по конкретной команде
-		command := bot.CommandByName(args)
+		command := bot.CommandByName(call.Args)
 		if command != nil {
 			return constructCommandHelpMessage(*command), nil
 		}
@@ -81,17 +109,37 @@ func (bot *Bot) Help(args string) (string, error) {
 	return helpMessage, nil
 }
 
-func (bot *Bot) ChangeObj(args string) (string, error) {
-	if args == "" {
+func (bot *Bot) ChangeObj(call *CallContext) (string, error) {
+	call.Args = strings.TrimSpace(call.Args)
+	if call.Args == "" {
 		return "", errors.New("имя объекта не указано")
 	}
 
-	bot.conf.Analysis.Object = args
+	if call.Profile != nil {
+		call.Profile.Object = call.Args
+		if err := bot.saveProfile(call); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Объект профиля сменен на \"%s\"", call.Profile.Object), nil
+	}
+
+	if err := call.requireGlobalAdmin(); err != nil {
+		return "", err
+	}
+
+	if existing := bot.conf.Analysis.ObjectByName(call.Args); existing != nil && existing != bot.primaryObject() {
+		return "", fmt.Errorf("объект \"%s\" уже отслеживается", existing.Name)
+	}
+
+	if len(bot.conf.Analysis.Objects) == 0 {
+		bot.conf.Analysis.Objects = append(bot.conf.Analysis.Objects, TrackedObject{})
+	}
+	bot.conf.Analysis.Objects[0].Name = call.Args
 
 	// Обновляем конфигурационный файл
 	bot.conf.Update()
 
-	return fmt.Sprintf("Объект сменен на \"%s\"", bot.conf.Analysis.Object), nil
+	return fmt.Sprintf("Объект сменен на \"%s\"", bot.conf.Analysis.Objects[0].Name), nil
 }
 
 func (bot *Bot) formatAnalysisResult(art *domain.Article) string {
@@ -100,6 +148,10 @@ func (bot *Bot) formatAnalysisResult(art *domain.Article) string {
 	// Добавляем заголовок
 	response.WriteString(fmt.Sprintf("*Заголовок:* %s\n\n", art.Title))
 
+	if art.Irrelevant {
+		response.WriteString("🚫 *Статья не прошла проверку релевантности, анализ LLM не проводился*\n\n")
+	}
+
 	// Дата публикации
 	if art.PublishedAt != 0 {
 		pubDate := time.Unix(art.PublishedAt, 0)
@@ -114,129 +166,282 @@ func (bot *Bot) formatAnalysisResult(art *domain.Article) string {
 		)
 	}
 
-	response.WriteString(fmt.Sprintf("*Связь с \"%s\":* %s\n\n", bot.conf.Analysis.Object, art.Affiliation))
+	if art.Irrelevant || len(art.Objects) == 0 {
+		if art.Affiliation != "" {
+			response.WriteString(fmt.Sprintf("*Примечание:* %s\n\n", art.Affiliation))
+		}
+		if !art.Irrelevant && art.Sentiment != "" {
+			response.WriteString(fmt.Sprintf("*Отношение:* %s\n\n", art.Sentiment))
+		}
+	}
+
+	for _, object := range art.Objects {
+		response.WriteString(fmt.Sprintf("*Связь с \"%s\":* %s\n", object.Object, object.Affiliation))
 
-	// Добавляем отношение
-	if art.Sentiment != "" {
-		response.WriteString(fmt.Sprintf("*Отношение:* %s\n", art.Sentiment))
-		if art.Justification != "" {
-			response.WriteString(fmt.Sprintf("*Обоснование:* %s\n", art.Justification))
+		// Добавляем отношение
+		if object.Sentiment != "" {
+			if object.Verified {
+				response.WriteString(fmt.Sprintf("*Отношение:* %s ✅ (проверено вручную)\n", object.Sentiment))
+			} else if object.Confidence > 0 {
+				response.WriteString(fmt.Sprintf("*Отношение:* %s (уверенность %.0f%%)\n", object.Sentiment, object.Confidence*100))
+			} else {
+				response.WriteString(fmt.Sprintf("*Отношение:* %s\n", object.Sentiment))
+			}
+			if object.Justification != "" {
+				response.WriteString(fmt.Sprintf("*Обоснование:* %s\n", object.Justification))
+			}
 		}
+		response.WriteString("\n")
+	}
+
+	if art.StoryID != 0 {
+		response.WriteString(fmt.Sprintf("*Сюжет:* #%d (`story %d`)\n", art.StoryID, art.StoryID))
+	}
+
+	if provenance := formatProvenance(art.Provenance); provenance != "" {
+		response.WriteString("\n" + provenance)
 	}
 
-	// Добавляем ошибки (если есть)
-	if len(art.Errors) > 0 {
+	// Добавляем ошибки (если есть). У статей из базы они сохранены в происхождении
+	errs := errorStrings(art.Errors)
+	if len(errs) == 0 && art.Provenance != nil {
+		errs = art.Provenance.Errors
+	}
+	if len(errs) > 0 {
 		response.WriteString("\n⚠️ *Ошибки при анализе:*\n")
-		for _, err := range art.Errors {
-			response.WriteString(fmt.Sprintf("- %s\n", err.Error()))
+		for _, err := range errs {
+			response.WriteString(fmt.Sprintf("- %s\n", err))
 		}
 	}
 
 	return response.String()
 }
 
-func (bot *Bot) Do(args string) (string, error) {
-	if args == "" {
-		return "", errors.New("вы не указали URL")
+// Результат полной обработки статьи
+type analysisOutcome struct {
+	Article         *domain.Article  // При дубликате статья не анализируется
+	Duplicate       *domain.Article  // Уже сохраненная статья с тем же адресом или текстом
+	DuplicateReason string           // duplicateByURL или duplicateByContent
+	Similar         []domain.Article // Почти дословные копии и подтвержденные похожие статьи
+	Saved           bool
+	SheetsPushed    bool
+	SheetsError     error
+}
+
+// Причины, по которым статья считается уже сохраненной
+const (
+	duplicateByURL     = "url"     // Совпал канонический адрес
+	duplicateByContent = "content" // Совпал нормализованный текст
+)
+
+// Находит уже сохраненную статью с тем же адресом или текстом, что и извлеченная art.
+// Возвращает статью и причину совпадения или nil
+func (bot *Bot) findDuplicate(ctx context.Context, art *domain.Article, requestedURL string) (*domain.Article, string, error) {
+	// Страница могла указать канонический адрес, отличный от запрошенного
+	if art.SourceURL != canonicalURL(requestedURL) {
+		existing, err := bot.storedArticleByURL(ctx, art.SourceURL)
+		if err != nil || existing != nil {
+			return existing, duplicateByURL, err
+		}
 	}
 
-	if !strings.HasPrefix(args, "http") {
-		return "", errors.New("пожалуйста, отправьте действительный URL, начинающийся с http/https")
+	existing, err := bot.conf.GetArticleStore().GetExactDuplicate(ctx, art.Content)
+	if err != nil || existing != nil {
+		return existing, duplicateByContent, err
 	}
 
-	url := args
+	return nil, "", nil
+}
 
-	// Анализируем статью
-	art, err := bot.analyzeArticle(url)
+// Похожие статьи: почти дословные копии (по отпечаткам текста, без порога композитного
+// сходства) и статьи, прошедшие оба порога сходства. Возвращает подтвержденные статьи
+// и общее число кандидатов
+func (bot *Bot) matchSimilar(ctx context.Context, art *domain.Article, embedding []float64) ([]domain.Article, int, error) {
+	store := bot.conf.GetArticleStore()
+	composite := similarity.NewCompositeSimilarity(bot.conf.Analysis.CompositeVectorWeight)
+
+	near, err := store.FindNearDuplicates(
+		ctx,
+		art.Content,
+		bot.conf.Analysis.NearDuplicateThreshold,
+		uint(bot.conf.Analysis.DaysLookback),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var matched []domain.Article
+	seen := make(map[int64]bool, len(near))
+	for _, candidate := range near {
+		seen[candidate.ID] = true
+
+		candidate.TrueSimilarity = candidate.TextSimilarity
+		if vectorSimilarity, err := similarity.SemanticSimilarity(embedding, candidate.Embedding); err == nil {
+			candidate.Similarity = vectorSimilarity
+		}
+		if score, err := composite.Compare(art.Content, candidate.Content, embedding, candidate.Embedding); err == nil && score > candidate.TrueSimilarity {
+			candidate.TrueSimilarity = score
+		}
+		matched = append(matched, candidate)
+	}
+
+	similar, err := store.FindSimilar(
+		ctx,
+		embedding,
+		bot.conf.Analysis.VectorSimilarityThreshold,
+		uint(bot.conf.Analysis.DaysLookback),
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	candidates := len(near)
+	for _, candidate := range similar {
+		if seen[candidate.ID] {
+			continue
+		}
+		candidates++
+
+		score, err := composite.Compare(
+			art.Content,
+			candidate.Content,
+			embedding,
+			candidate.Embedding,
+		)
+		if err == nil && score >= bot.conf.Analysis.FinalSimilarityThreshold {
+			candidate.TrueSimilarity = score
+			matched = append(matched, candidate)
+		}
+	}
+
+	return matched, candidates, nil
+}
+
+// Анализирует статью, ищет похожие, сохраняет результат и отправляет его в Google таблицу.
+// Объекты, промпты, модель и таблица берутся из настроек settings, о каждом этапе сообщается в progress.
+// Уже сохраненные статьи (по каноническому адресу или тексту) не анализируются повторно
+func (bot *Bot) processArticle(ctx context.Context, url string, settings *analysisSettings, progress progressFunc) (*analysisOutcome, error) {
+	if url == "" {
+		return nil, errors.New("вы не указали URL")
+	}
+
+	if !strings.HasPrefix(url, "http") {
+		return nil, errors.New("пожалуйста, отправьте действительный URL, начинающийся с http/https")
+	}
+
+	// Статью с тем же адресом незачем даже загружать
+	if existing, err := bot.storedArticleByURL(ctx, url); err == nil && existing != nil {
+		return &analysisOutcome{
+			Article:         &domain.Article{SourceURL: canonicalURL(url)},
+			Duplicate:       existing,
+			DuplicateReason: duplicateByURL,
+		}, nil
+	}
+
+	art, err := bot.getArticle(ctx, url, progress)
 	if err != nil {
-		return "", fmt.Errorf("ошибка обработки страницы: %w", err)
+		return nil, fmt.Errorf("ошибка обработки страницы: %w", err)
+	}
+	if art.SourceURL == "" {
+		art.SourceURL = canonicalURL(url)
 	}
 	if art.PublishedAt == 0 {
 		now := time.Now()
 		art.PublishedAt = now.Unix()
 	}
 
-	// Проверка точного дубликата
-	if existingArticle, err := bot.conf.GetDB().GetExactDuplicate(art.Content); err == nil && existingArticle != nil {
-		return bot.notifyExactDuplicate(existingArticle), nil
+	outcome := &analysisOutcome{
+		Article: art,
 	}
 
-	// Получение вектора
-	embedding, err := bot.model.GetEmbedding(art.Content)
-	if err != nil {
-		return "", errors.New("ошибка векторизации")
+	// Проверка дубликата до запросов к LLM
+	if existing, reason, err := bot.findDuplicate(ctx, art, url); err == nil && existing != nil {
+		outcome.Duplicate = existing
+		outcome.DuplicateReason = reason
+		return outcome, nil
+	}
+
+	// Анализируем статью
+	if err := bot.analyzeArticle(ctx, art, settings, progress); err != nil {
+		return nil, fmt.Errorf("ошибка обработки страницы: %w", err)
+	}
+
+	// Получение вектора (мог быть получен при проверке релевантности)
+	embedding := art.Embedding
+	if len(embedding) == 0 {
+		progress.report("🧮 Векторизация текста...")
+		embedding, err = bot.model.GetEmbedding(ctx, art.Content)
+		if err != nil {
+			return nil, errors.New("ошибка векторизации")
+		}
 	}
 
 	// Поиск схожих статей
-	similar, err := bot.conf.GetDB().FindSimilar(
-		embedding,
-		bot.conf.Analysis.VectorSimilarityThreshold,
-		uint(bot.conf.Analysis.DaysLookback),
-	)
+	progress.report("🔍 Поиск похожих статей...")
+	outcome.Similar, _, err = bot.matchSimilar(ctx, art, embedding)
 	if err != nil {
-		return "", errors.New("ошибка нахождения схожих статей")
-	}
-
-	var verified []domain.Article
-	if len(similar) > 0 {
-		composite := similarity.NewCompositeSimilarity(bot.conf.Analysis.CompositeVectorWeight)
-		for _, candidate := range similar {
-			score, err := composite.Compare(
-				art.Content,
-				candidate.Content,
-				embedding,
-				candidate.Embedding,
-			)
-			if err == nil && score >= bot.conf.Analysis.FinalSimilarityThreshold {
-				candidate.TrueSimilarity = score
-				verified = append(verified, candidate)
-
-				// Добавляем ссылку на текущую статью в оригинальную
-				if err := bot.conf.GetDB().AddSimilarURL(candidate.ID, url); err != nil {
-					log.Printf("ошибка добавления URL в оригинальную статью: %v", err)
-				}
+		return nil, errors.New("ошибка нахождения схожих статей")
+	}
 
-				// Инкремент цитирований
-				if err := bot.conf.GetDB().IncrementCitation(candidate.ID); err != nil {
-					log.Printf("ошибка инкремента количества цитирований: %v", err)
-				}
-			}
-		}
+	for _, candidate := range outcome.Similar {
+		bot.recordCitation(ctx, candidate, art.SourceURL)
 	}
 
 	// Устанавливаем флаг оригинальности для новой статьи
-	art.Original = len(verified) == 0
+	art.Original = len(outcome.Similar) == 0
 
 	// Сохранение статьи в базу
-	if len(verified) == 0 || bot.conf.Analysis.SaveSimilarArticles {
-		if err := bot.saveNewArticle(art, embedding, url); err != nil {
-			return "", errors.New("ошибка сохранения")
+	if len(outcome.Similar) == 0 || bot.conf.Analysis.SaveSimilarArticles {
+		progress.report("💾 Сохраняю результат...")
+		if err := bot.saveNewArticle(ctx, art, embedding, art.SourceURL); err != nil {
+			return nil, errors.New("ошибка сохранения")
 		}
+		outcome.Saved = true
+	}
+
+	// Обработка Google Sheets (нерелевантные статьи в онлайн таблицу не попадают)
+	if bot.conf.Sheets.PushToGoogleSheet && !art.Irrelevant {
+		progress.report("📤 Отправляю в онлайн таблицу...")
+		if err := settings.sheet.AddAnalysisResultWithRetry(art, 3); err != nil {
+			log.Printf("ошибка добавления в Google Sheet: %v", err)
+			outcome.SheetsError = err
+		} else {
+			outcome.SheetsPushed = true
+		}
+	}
+
+	return outcome, nil
+}
+
+func (bot *Bot) Do(call *CallContext) (string, error) {
+	outcome, err := bot.processArticle(call.Context(), call.Args, bot.settingsFor(call.Profile), call.Progress)
+	if err != nil {
+		return "", err
 	}
 
-	duplicatesText := bot.generateDuplicatesMessage(verified, *art)
+	if outcome.Duplicate != nil {
+		return bot.notifyExactDuplicate(outcome.Duplicate, outcome.DuplicateReason), nil
+	}
+
+	duplicatesText := bot.generateDuplicatesMessage(outcome.Similar, *outcome.Article)
 
 	// Формирование итогового сообщения
-	responseText := bot.formatAnalysisResult(art)
+	responseText := bot.formatAnalysisResult(outcome.Article)
 	fullMessage := "📋 *Результаты анализа*\n" + responseText
 	if duplicatesText != "" {
 		fullMessage += "\n\n" + duplicatesText
 	}
 
-	// Обработка Google Sheets
-	if bot.conf.Sheets.PushToGoogleSheet {
-		if err := bot.sheet.AddAnalysisResultWithRetry(art, 3); err != nil {
-			log.Printf("ошибка добавления в Google Sheet: %v", err)
-			fullMessage += "\n\n❌ ошибка внесения изменений в онлайн таблицу: " + err.Error()
-		} else {
-			fullMessage += "\n\n💾 запись успешно добавлена в онлайн таблицу!"
-		}
+	if outcome.SheetsError != nil {
+		fullMessage += "\n\n❌ ошибка внесения изменений в онлайн таблицу: " + outcome.SheetsError.Error()
+	} else if outcome.SheetsPushed {
+		fullMessage += "\n\n💾 запись успешно добавлена в онлайн таблицу!"
 	}
 
 	return fullMessage, nil
 }
 
-func (bot *Bot) About(args string) (string, error) {
+func (bot *Bot) About(call *CallContext) (string, error) {
 	return `ACAS bot (Article Context And Sentiment bot).
 
 Бот для анализа статей на отношение к определенной объекта/личности, а также получения некоторых метаданных: заголовка и краткого описания.
@@ -247,16 +452,29 @@ Source: https://github.com/Unbewohnte/ACASbot
 `, nil
 }
 
-func (bot *Bot) AddUser(args string) (string, error) {
-	if args == "" {
+func (bot *Bot) AddUser(call *CallContext) (string, error) {
+	parts := strings.Fields(call.Args)
+	if len(parts) == 0 {
 		return "", errors.New("ID пользователя не указан")
 	}
 
-	id, err := strconv.ParseInt(args, 10, 64)
+	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
 		return "", errors.New("неверный ID пользователя")
 	}
 
+	// Сразу назначить роль, если она указана
+	if len(parts) > 1 {
+		role, err := domain.ParseRole(parts[1])
+		if err != nil {
+			return "", err
+		}
+
+		if err := bot.conf.GetDB().SetRole(domain.PlatformTelegram, parts[0], role); err != nil {
+			return "", fmt.Errorf("не удалось назначить роль: %w", err)
+		}
+	}
+
 	for _, allowedID := range bot.conf.Telegram.AllowedUserIDs {
 		if id == allowedID {
 			return "Этот пользователь уже есть в списке разрешенных.", nil
@@ -271,7 +489,7 @@ func (bot *Bot) AddUser(args string) (string, error) {
 	return "Пользователь успешно добавлен", nil
 }
 
-func (bot *Bot) TogglePublicity(args string) (string, error) {
+func (bot *Bot) TogglePublicity(call *CallContext) (string, error) {
 	if bot.conf.Telegram.Public {
 		bot.conf.Telegram.Public = false
 		bot.conf.Update()
@@ -282,12 +500,12 @@ func (bot *Bot) TogglePublicity(args string) (string, error) {
 		return "Доступ к боту теперь у всех.", nil
 	}
 }
-func (bot *Bot) RemoveUser(args string) (string, error) {
-	if args == "" {
+func (bot *Bot) RemoveUser(call *CallContext) (string, error) {
+	if call.Args == "" {
 		return "", errors.New("ID пользователя не указан")
 	}
 
-	id, err := strconv.ParseInt(args, 10, 64)
+	id, err := strconv.ParseInt(call.Args, 10, 64)
 	if err != nil {
 		return "", errors.New("неверный ID пользователя")
 	}
@@ -302,7 +520,13 @@ func (bot *Bot) RemoveUser(args string) (string, error) {
 		newAllowedUserIDs = append(newAllowedUserIDs, allowedID)
 	}
 
-	if !found {
+	// Назначенная роль тоже дает доступ, поэтому снимаем и ее
+	removedRole, err := bot.conf.GetDB().RemoveRole(domain.PlatformTelegram, strconv.FormatInt(id, 10))
+	if err != nil {
+		return "", fmt.Errorf("не удалось снять роль пользователя: %w", err)
+	}
+
+	if !found && !removedRole {
 		return "", errors.New("пользователь не найден в списке разрешенных")
 	}
 
@@ -312,12 +536,12 @@ func (bot *Bot) RemoveUser(args string) (string, error) {
 	return "Пользователь успешно удален!", nil
 }
 
-func (bot *Bot) ChangeMaxContentSize(args string) (string, error) {
-	if args == "" {
+func (bot *Bot) ChangeMaxContentSize(call *CallContext) (string, error) {
+	if call.Args == "" {
 		return "", errors.New("не указано новое значение")
 	}
 
-	newMaxContentSize, err := strconv.ParseUint(args, 10, 64)
+	newMaxContentSize, err := strconv.ParseUint(call.Args, 10, 64)
 	if err != nil {
 		return "", errors.New("указано некорректное значение")
 	}
@@ -333,7 +557,7 @@ func (bot *Bot) ChangeMaxContentSize(args string) (string, error) {
 		strconv.FormatUint(newMaxContentSize, 10) + " символов.", nil
 }
 
-func (bot *Bot) PrintConfig(args string) (string, error) {
+func (bot *Bot) PrintConfig(call *CallContext) (string, error) {
 	var response strings.Builder
 
 	response.WriteString("*Нынешняя конфигурация*: \n")
@@ -350,13 +574,51 @@ func (bot *Bot) PrintConfig(args string) (string, error) {
 	response.WriteString(fmt.Sprintf("*Конечный порог сходства*: `%v` (%v%%)\n",
 		bot.conf.Analysis.FinalSimilarityThreshold,
 		bot.conf.Analysis.FinalSimilarityThreshold*100.0))
-	response.WriteString(fmt.Sprintf("*Объект*: `%v`\n", bot.conf.Analysis.Object))
-	response.WriteString(fmt.Sprintf("*Метаданные объекта*: `%v`\n", bot.conf.Analysis.ObjectMetadata))
+	response.WriteString(fmt.Sprintf("*Порог почти дословных копий*: `%v` (%v%%)\n",
+		bot.conf.Analysis.NearDuplicateThreshold,
+		bot.conf.Analysis.NearDuplicateThreshold*100.0))
+	for _, object := range bot.conf.Analysis.Objects {
+		response.WriteString(fmt.Sprintf("*Объект*: `%v`\n", object.Name))
+		response.WriteString(fmt.Sprintf("- *Метаданные объекта*: `%v`\n", object.Metadata))
+	}
 	response.WriteString(fmt.Sprintf("*Сохранять похожие статьи*: `%v`\n", bot.conf.Analysis.SaveSimilarArticles))
 
+	response.WriteString("\n*[РЕЛЕВАНТНОСТЬ]*:\n")
+	response.WriteString(fmt.Sprintf("*Проверять релевантность?*: `%v`\n", bot.conf.Analysis.Relevance.Enabled))
+	response.WriteString(fmt.Sprintf("*Ключевые слова*: `%v`\n", strings.Join(bot.conf.Analysis.Relevance.Keywords, ", ")))
+	response.WriteString(fmt.Sprintf("*Минимум вхождений ключевых слов*: `%v`\n", bot.conf.Analysis.Relevance.MinKeywordHits))
+	response.WriteString(fmt.Sprintf("*Проверять векторное сходство с объектом?*: `%v`\n", bot.conf.Analysis.Relevance.UseEmbedding))
+	response.WriteString(fmt.Sprintf("*Порог сходства с объектом*: `%v` (%v%%)\n",
+		bot.conf.Analysis.Relevance.EmbeddingThreshold,
+		bot.conf.Analysis.Relevance.EmbeddingThreshold*100.0))
+
+	response.WriteString("\n*[СЮЖЕТЫ]*:\n")
+	response.WriteString(fmt.Sprintf("*Распределять по сюжетам?*: `%v`\n", bot.conf.Analysis.Stories.Enabled))
+	response.WriteString(fmt.Sprintf("*Порог векторного сходства*: `%v`\n", bot.conf.Analysis.Stories.VectorThreshold))
+	response.WriteString(fmt.Sprintf("*Порог общей схожести*: `%v`\n", bot.conf.Analysis.Stories.CompositeThreshold))
+	response.WriteString(fmt.Sprintf("*Окно сюжета (дней)*: `%v`\n", bot.conf.Analysis.Stories.WindowDays))
+
+	response.WriteString("\n*[СВОДКИ]*:\n")
+	response.WriteString(fmt.Sprintf("*Отправлять по расписанию?*: `%v`\n", bot.conf.Reports.Enabled))
+	response.WriteString(fmt.Sprintf("*Чат для сводок*: `%v`\n", bot.conf.Reports.ChatID))
+	response.WriteString(fmt.Sprintf("*Расписаний*: `%v`\n", len(bot.conf.Reports.Schedules)))
+
+	response.WriteString("\n*[ХРАНЕНИЕ]*:\n")
+	response.WriteString(fmt.Sprintf("*Применять по расписанию?*: `%v` (`%v`)\n", bot.conf.Retention.Enabled, bot.conf.Retention.Cron))
+	response.WriteString(fmt.Sprintf("*Удалять векторы через*: `%v` дней\n", bot.conf.Retention.DropEmbeddingsDays))
+	response.WriteString(fmt.Sprintf("*Удалять тексты через*: `%v` дней\n", bot.conf.Retention.DropContentDays))
+	response.WriteString(fmt.Sprintf("*Каталог архивов*: `%v`\n", bot.conf.Retention.ArchiveDir))
+
+	response.WriteString("\n*[РЕЗЕРВНЫЕ КОПИИ]*:\n")
+	response.WriteString(fmt.Sprintf("*Создавать по расписанию?*: `%v` (`%v`)\n", bot.conf.Backup.Enabled, bot.conf.Backup.Cron))
+	response.WriteString(fmt.Sprintf("*Каталог копий*: `%v`\n", bot.conf.Backup.Dir))
+	response.WriteString(fmt.Sprintf("*Хранить копий*: `%v`\n", bot.conf.Backup.Keep))
+	response.WriteString(fmt.Sprintf("*Скрывать секреты?*: `%v`\n", bot.conf.Backup.RedactSecrets))
+
 	response.WriteString("\n*[ОБЩЕЕ]*:\n")
 	response.WriteString(fmt.Sprintf("*Общедоступный?*: `%v`\n", bot.conf.Telegram.Public))
 	response.WriteString(fmt.Sprintf("*Разрешенные пользователи*: `%+v`\n", bot.conf.Telegram.AllowedUserIDs))
+	response.WriteString(fmt.Sprintf("*Роль по умолчанию*: `%v`\n", bot.conf.Telegram.DefaultRole))
 
 	response.WriteString("\n*[LLM]*:\n")
 	response.WriteString(fmt.Sprintf("*LLM*: `%v`\n", bot.conf.Ollama.GeneralModel))
@@ -365,83 +627,152 @@ func (bot *Bot) PrintConfig(args string) (string, error) {
 	response.WriteString(fmt.Sprintf("*Промпт заголовка*: `%v`\n", bot.conf.Ollama.Prompts.Title))
 	response.WriteString(fmt.Sprintf("*Промпт связи с объектом*: `%v`\n", bot.conf.Ollama.Prompts.Affiliation))
 	response.WriteString(fmt.Sprintf("*Промпт отношения к объекту*: `%v`\n", bot.conf.Ollama.Prompts.Sentiment))
+	response.WriteString(fmt.Sprintf("*Бэкенд LLM*: `%v`\n", bot.model.Backend()))
+	if bot.conf.Ollama.BaseURL != "" {
+		response.WriteString(fmt.Sprintf("*Адрес LLM API*: `%v`\n", bot.conf.Ollama.BaseURL))
+	}
+	response.WriteString(fmt.Sprintf("*Структурированный JSON ответ*: `%v` (повторов: %v)\n", bot.conf.Ollama.StructuredOutput, bot.conf.Ollama.StructuredRetries))
+	response.WriteString(fmt.Sprintf("*Структурированный промпт*: `%v`\n", bot.conf.Ollama.Prompts.Structured))
+
+	response.WriteString("\n*[ЛЕНТЫ]*:\n")
+	response.WriteString(fmt.Sprintf("*Проверять ленты?*: `%v`\n", bot.conf.Feeds.Enabled))
+	response.WriteString(fmt.Sprintf("*Чат для результатов*: `%v`\n", bot.conf.Feeds.ChatID))
+	response.WriteString(fmt.Sprintf("*Интервал проверки по умолчанию*: `%v` минут\n", bot.conf.Feeds.DefaultIntervalMinutes))
 
 	response.WriteString("\n*[ТАБЛИЦЫ]*:\n")
 	response.WriteString(fmt.Sprintf("*Отправлять результат анализа в Google таблицу?*: `%v`\n", bot.conf.Sheets.PushToGoogleSheet))
 	response.WriteString(fmt.Sprintf("*Наименование листа таблицы*: `%v`\n", bot.conf.Sheets.Google.Config.SheetName))
 	response.WriteString(fmt.Sprintf("*ID Google таблицы*: `%v`\n", bot.conf.Sheets.Google.Config.SpreadsheetID))
 
+	if call.Profile != nil {
+		response.WriteString("\n*[ПРОФИЛЬ]*:\n")
+		response.WriteString(bot.formatProfile(call.Profile))
+	}
+
 	return response.String(), nil
 }
 
-func (bot *Bot) ChangeSpreadsheetID(args string) (string, error) {
-	if args == "" {
+func (bot *Bot) ChangeSpreadsheetID(call *CallContext) (string, error) {
+	if call.Args == "" {
 		return "", errors.New("не указано новое значение")
 	}
 
-	bot.conf.Sheets.Google.Config.SpreadsheetID = args
+	if call.Profile != nil {
+		call.Profile.SpreadsheetID = call.Args
+		if err := bot.saveProfile(call); err != nil {
+			return "", err
+		}
+		return "ID Google таблицы профиля успешно изменен на: " + call.Args, nil
+	}
+
+	if err := call.requireGlobalAdmin(); err != nil {
+		return "", err
+	}
+
+	bot.conf.Sheets.Google.Config.SpreadsheetID = call.Args
 	if bot.sheet != nil {
 		bot.sheet.SpreadsheetID = bot.conf.Sheets.Google.Config.SpreadsheetID
 	}
 
 	bot.conf.Update()
 
-	return "ID Google таблицы успешно изменен на: " + args, nil
+	return "ID Google таблицы успешно изменен на: " + call.Args, nil
 }
 
-func (bot *Bot) ChangeSheetName(args string) (string, error) {
-	if args == "" {
+func (bot *Bot) ChangeSheetName(call *CallContext) (string, error) {
+	if call.Args == "" {
 		return "", errors.New("не указано новое имя")
 	}
 
-	bot.conf.Sheets.Google.Config.SheetName = args
+	if call.Profile != nil {
+		call.Profile.SheetName = call.Args
+		if err := bot.saveProfile(call); err != nil {
+			return "", err
+		}
+		return "Имя листа Google таблицы профиля успешно изменено на: " + call.Args, nil
+	}
+
+	if err := call.requireGlobalAdmin(); err != nil {
+		return "", err
+	}
+
+	bot.conf.Sheets.Google.Config.SheetName = call.Args
 	if bot.sheet != nil {
 		bot.sheet.SheetName = bot.conf.Sheets.Google.Config.SheetName
 	}
 
 	bot.conf.Update()
 
-	return "Имя листа Google таблицы успешно изменено на: " + args, nil
+	return "Имя листа Google таблицы успешно изменено на: " + call.Args, nil
 }
 
-func (bot *Bot) ChangeQueryTimeout(args string) (string, error) {
-	if args == "" {
+func (bot *Bot) ChangeQueryTimeout(call *CallContext) (string, error) {
+	if call.Args == "" {
 		return "", errors.New("не указано количество секунд")
 	}
 
-	timeoutSeconds, err := strconv.ParseUint(args, 10, 64)
+	timeoutSeconds, err := strconv.ParseUint(call.Args, 10, 64)
 	if err != nil {
 		return "", errors.New("неверное значение количества секунд")
 	}
 
 	bot.conf.Ollama.QueryTimeoutSeconds = uint(timeoutSeconds)
-	bot.model.TimeoutSeconds = bot.conf.Ollama.QueryTimeoutSeconds
+	bot.model.SetTimeout(bot.conf.Ollama.QueryTimeoutSeconds)
+	bot.profileModels.reset()
 
 	bot.conf.Update()
 
 	return fmt.Sprintf("Время таймаута запросов к LLM успешно изменено на %d секунд", timeoutSeconds), nil
 }
-func (bot *Bot) GeneralQuery(args string) (string, error) {
-	if args == "" {
+func (bot *Bot) GeneralQuery(call *CallContext) (string, error) {
+	if call.Args == "" {
 		return "", errors.New("не указан запрос")
 	}
 
-	answer, err := bot.model.Query(args)
+	// Ответ показывается по мере генерации, итоговый текст приходит обычным ответом
+	answer, err := bot.settingsFor(call.Profile).model.QueryStream(call.Context(), call.Args, call.Stream)
 	if err != nil {
 		return "", fmt.Errorf("не удалось ответить на запрос: %w", err)
 	}
 
 	return answer, nil
 }
-func (bot *Bot) SetObjectData(args string) (string, error) {
-	if args == "" {
+func (bot *Bot) SetObjectData(call *CallContext) (string, error) {
+	if call.Args == "" {
 		return "", errors.New("не указана дополнительная информация об объекте")
 	}
 
-	bot.conf.Analysis.ObjectMetadata = strings.TrimSpace(args)
+	// Профиль хранит метаданные только своего основного объекта
+	if call.Profile != nil && !strings.Contains(call.Args, "|") {
+		call.Profile.Metadata = strings.TrimSpace(call.Args)
+		if err := bot.saveProfile(call); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Информация об объекте профиля \"%s\" успешно обновлена", bot.settingsFor(call.Profile).primaryObject().Name), nil
+	}
+
+	if err := call.requireGlobalAdmin(); err != nil {
+		return "", err
+	}
+
+	if len(bot.conf.Analysis.Objects) == 0 {
+		return "", errors.New("нет отслеживаемых объектов")
+	}
+
+	// Формат "Имя | данные" указывает объект, иначе меняется основной
+	object := bot.primaryObject()
+	if name, data, found := strings.Cut(call.Args, "|"); found {
+		object = bot.conf.Analysis.ObjectByName(name)
+		if object == nil {
+			return "", fmt.Errorf("объект \"%s\" не найден", strings.TrimSpace(name))
+		}
+		call.Args = data
+	}
+
+	object.Metadata = strings.TrimSpace(call.Args)
 	bot.conf.Update()
 
-	return "Информация об объекте успешно обновлена", nil
+	return fmt.Sprintf("Информация об объекте \"%s\" успешно обновлена", object.Name), nil
 }
 
 type promptType string
@@ -450,13 +781,39 @@ const (
 	PROMPT_AFFILIATION promptType = "affiliation"
 	PROMPT_TITLE       promptType = "title"
 	PROMPT_SENTIMENT   promptType = "sentiment"
+	PROMPT_STRUCTURED  promptType = "structured"
 )
 
-func (bot *Bot) setPrompt(args string, promptType promptType) (string, error) {
+func (bot *Bot) setPrompt(call *CallContext, promptType promptType) (string, error) {
+	args := call.Args
 	if args == "" {
 		return "", errors.New("не указан новый промпт")
 	}
 
+	if call.Profile != nil {
+		switch promptType {
+		case PROMPT_TITLE:
+			call.Profile.PromptTitle = args
+		case PROMPT_AFFILIATION:
+			call.Profile.PromptAffiliation = args
+		case PROMPT_SENTIMENT:
+			call.Profile.PromptSentiment = args
+		case PROMPT_STRUCTURED:
+			call.Profile.PromptStructured = args
+		default:
+			return "", errors.New("неизвестный тип промпта")
+		}
+
+		if err := bot.saveProfile(call); err != nil {
+			return "", err
+		}
+		return "Новый промпт применен в профиле", nil
+	}
+
+	if err := call.requireGlobalAdmin(); err != nil {
+		return "", err
+	}
+
 	switch promptType {
 	case PROMPT_TITLE:
 		bot.conf.Ollama.Prompts.Title = args
@@ -464,6 +821,8 @@ func (bot *Bot) setPrompt(args string, promptType promptType) (string, error) {
 		bot.conf.Ollama.Prompts.Affiliation = args
 	case PROMPT_SENTIMENT:
 		bot.conf.Ollama.Prompts.Sentiment = args
+	case PROMPT_STRUCTURED:
+		bot.conf.Ollama.Prompts.Structured = args
 	default:
 		return "", errors.New("неизвестный тип промпта")
 	}
@@ -473,59 +832,135 @@ func (bot *Bot) setPrompt(args string, promptType promptType) (string, error) {
 	return "Новый промпт успешно применен", nil
 }
 
-func (bot *Bot) SetAffiliationPrompt(args string) (string, error) {
-	return bot.setPrompt(args, PROMPT_AFFILIATION)
+func (bot *Bot) SetAffiliationPrompt(call *CallContext) (string, error) {
+	return bot.setPrompt(call, PROMPT_AFFILIATION)
 }
 
-func (bot *Bot) SetTitlePrompt(args string) (string, error) {
-	return bot.setPrompt(args, PROMPT_TITLE)
+func (bot *Bot) SetTitlePrompt(call *CallContext) (string, error) {
+	return bot.setPrompt(call, PROMPT_TITLE)
 }
 
-func (bot *Bot) SetSentimentPrompt(args string) (string, error) {
-	return bot.setPrompt(args, PROMPT_SENTIMENT)
+func (bot *Bot) SetSentimentPrompt(call *CallContext) (string, error) {
+	return bot.setPrompt(call, PROMPT_SENTIMENT)
+}
+
+func (bot *Bot) SetStructuredPrompt(call *CallContext) (string, error) {
+	return bot.setPrompt(call, PROMPT_STRUCTURED)
+}
+
+func (bot *Bot) ToggleStructuredOutput(call *CallContext) (string, error) {
+	bot.conf.Ollama.StructuredOutput = !bot.conf.Ollama.StructuredOutput
+	bot.conf.Update()
+
+	if bot.conf.Ollama.StructuredOutput {
+		return "Структурированный JSON ответ LLM включен.", nil
+	} else {
+		return "Структурированный JSON ответ LLM выключен, используются отдельные текстовые запросы.", nil
+	}
 }
-func (bot *Bot) ListModels(args string) (string, error) {
-	models, err := bot.model.ListModels()
+func (bot *Bot) ListModels(call *CallContext) (string, error) {
+	models, err := bot.model.ListModels(call.Context())
 	if err != nil {
-		return "", fmt.Errorf("не удалось получить список локальных моделей: %w", err)
+		return "", fmt.Errorf("не удалось получить список моделей: %w", err)
 	}
 
-	response := "Доступные модели:\n"
+	response := fmt.Sprintf("Доступные модели (%s):\n", bot.model.Backend())
 	for _, model := range models {
+		if model.ParameterSize == "" && model.Quantization == "" {
+			response += fmt.Sprintf("`%s`\n", model.Name)
+			continue
+		}
+
 		response += fmt.Sprintf("`%s` (%s, %s)\n",
 			model.Name,
-			model.Details.ParameterSize,
-			model.Details.QuantizationLevel,
+			model.ParameterSize,
+			model.Quantization,
 		)
 	}
-	response += fmt.Sprintf("\nТекущая:\n `%s`\n", bot.model.ModelName)
+	response += fmt.Sprintf("\nТекущая:\n `%s` (%s)\n", bot.model.Model(), bot.model.Backend())
 
 	return response, nil
 }
-func (bot *Bot) SetModel(args string) (string, error) {
-	if args == "" {
+
+// Смена модели. Формат: "модель" для текущего бэкенда или
+// "бэкенд модель [адрес]" для переключения на другой бэкенд (ollama, openai).
+// В профиле меняется только модель, бэкенд остается общим
+func (bot *Bot) SetModel(call *CallContext) (string, error) {
+	if call.Args == "" {
 		return "", errors.New("не указано имя модели")
 	}
 
-	newModel := strings.TrimSpace(args)
-	availableModels, err := bot.model.ListModels()
+	options := bot.conf.Ollama.ClientOptions()
+	options.Backend = bot.model.Backend()
+
+	fields := strings.Fields(call.Args)
+	if call.Profile != nil {
+		if len(fields) != 1 {
+			return "", errors.New("в профиле меняется только модель текущего бэкенда. Укажите одно имя модели")
+		}
+
+		availableModels, err := bot.model.ListModels(call.Context())
+		if err != nil {
+			return "", fmt.Errorf("не удалось получить список моделей: %w", err)
+		}
+
+		for _, availableModel := range availableModels {
+			if availableModel.Name == fields[0] {
+				call.Profile.Model = fields[0]
+				if err := bot.saveProfile(call); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Модель профиля успешно сменена на \"%s\" (%s)", fields[0], bot.model.Backend()), nil
+			}
+		}
+
+		return "Такой модели не существует, модель профиля не изменена", nil
+	}
+
+	if err := call.requireGlobalAdmin(); err != nil {
+		return "", err
+	}
+
+	if len(fields) >= 2 && inference.IsBackend(fields[0]) {
+		options.Backend = strings.ToLower(fields[0])
+		options.Model = fields[1]
+		if options.Backend != bot.conf.Ollama.Backend {
+			// Адрес прежнего бэкенда новому не подходит
+			options.BaseURL = ""
+		}
+		if len(fields) > 2 {
+			options.BaseURL = fields[2]
+		}
+	} else {
+		options.Model = strings.TrimSpace(call.Args)
+	}
+
+	client, err := inference.NewClient(options)
+	if err != nil {
+		return "", err
+	}
+
+	availableModels, err := client.ListModels(call.Context())
 	if err != nil {
-		return "", fmt.Errorf("не удалось получить список локальных моделей: %w", err)
+		return "", fmt.Errorf("не удалось получить список моделей: %w", err)
 	}
 
 	for _, availableModel := range availableModels {
-		if availableModel.Name == newModel {
-			bot.model.ModelName = newModel
-			bot.conf.Ollama.GeneralModel = newModel
+		if availableModel.Name == options.Model {
+			bot.model = client
+			bot.profileModels.reset()
+			bot.conf.Ollama.Backend = options.Backend
+			bot.conf.Ollama.BaseURL = options.BaseURL
+			bot.conf.Ollama.GeneralModel = options.Model
 			bot.conf.Update()
-			return fmt.Sprintf("Модель успешно сменена на \"%s\"", bot.model.ModelName), nil
+			return fmt.Sprintf("Модель успешно сменена на \"%s\" (%s)", bot.model.Model(), bot.model.Backend()), nil
 		}
 	}
 
-	return fmt.Sprintf("Такой модели не существует, оставлена \"%s\"", bot.model.ModelName), nil
+	return fmt.Sprintf("Такой модели не существует, оставлена \"%s\" (%s)", bot.model.Model(), bot.model.Backend()), nil
 }
 
-func (bot *Bot) ToggleSaveSimilar(args string) (string, error) {
+func (bot *Bot) ToggleSaveSimilar(call *CallContext) (string, error) {
 	if bot.conf.Analysis.SaveSimilarArticles {
 		bot.conf.Analysis.SaveSimilarArticles = false
 		bot.conf.Update()
@@ -612,50 +1047,58 @@ func (bot *Bot) ChangeCompositeWeights(args string) (string, error) {
 		newWeight, newWeight*100.0, (1.0-newWeight)*100.0), nil
 }
 
-func (bot *Bot) ForgetArticles(args string) (string, error) {
-	err := bot.conf.GetDB().DeleteAllArticles()
+func (bot *Bot) GenerateSpreadsheet(call *CallContext) (*Response, error) {
+	articles, err := bot.conf.GetArticleStore().GetAllArticles(call.Context())
 	if err != nil {
-		return "", fmt.Errorf("не удалось удалить статьи: %w", err)
+		return nil, fmt.Errorf("ошибка загрузки статей: %w", err)
 	}
 
-	return "Все статьи успешно \"забыты\"", nil
-}
-
-func (bot *Bot) GenerateSpreadsheet(args string) (string, error) {
-	articles, err := bot.conf.GetDB().GetAllArticles()
+	prompts, err := bot.conf.GetDB().GetArticlesPrompts(articles)
 	if err != nil {
-		return "", fmt.Errorf("ошибка загрузки статей: %w", err)
+		return nil, fmt.Errorf("ошибка загрузки промптов: %w", err)
 	}
 
 	// Генерируем Excel в памяти
-	fileBuffer, err := spreadsheet.GenerateFromDatabase(articles)
+	fileBuffer, err := spreadsheet.GenerateFromDatabase(articles, bot.objectNames(), prompts)
 	if err != nil {
-		return "", fmt.Errorf("ошибка генерации файла: %w", err)
+		return nil, fmt.Errorf("ошибка генерации файла: %w", err)
 	}
 
 	// Сохраняем как файл
 	fileName := "ACASbot_Results.xlsx"
 	file, err := os.Create(fileName)
 	if err != nil {
-		return "", fmt.Errorf("ошибка сохранения файла: %w", err)
+		return nil, fmt.Errorf("ошибка сохранения файла: %w", err)
 	}
 	defer file.Close()
 	_, err = file.Write(fileBuffer.Bytes())
 	if err != nil {
-		return "", fmt.Errorf("ошибка записи файла: %w", err)
+		return nil, fmt.Errorf("ошибка записи файла: %w", err)
 	}
 
-	return fmt.Sprintf("Таблица успешно сгенерирована и сохранена как %s", fileName), nil
+	return &Response{
+		Text: "Таблица успешно сгенерирована",
+		Files: []ResponseFile{{
+			Name:    fileName,
+			Caption: "📊 Сгенерированная таблица на основе базы данных",
+			Data:    fileBuffer.Bytes(),
+		}},
+	}, nil
 }
 
 func (bot *Bot) SaveLocalSpreadsheet(args string) (string, error) {
-	articles, err := bot.conf.GetDB().GetAllArticles()
+	articles, err := bot.conf.GetArticleStore().GetAllArticles(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	prompts, err := bot.conf.GetDB().GetArticlesPrompts(articles)
 	if err != nil {
 		return "", err
 	}
 
 	// Генерируем Excel в памяти
-	fileBuffer, err := spreadsheet.GenerateFromDatabase(articles)
+	fileBuffer, err := spreadsheet.GenerateFromDatabase(articles, bot.objectNames(), prompts)
 	if err != nil {
 		return "", err
 	}
@@ -675,77 +1118,110 @@ func (bot *Bot) SaveLocalSpreadsheet(args string) (string, error) {
 	return fmt.Sprintf("Локальная таблица успешно сохранена как %s", fileName), nil
 }
 
-func (bot *Bot) FindSimilar(args string) (string, error) {
-	parts := strings.Fields(args)
-	if len(parts) == 0 {
-		return "", errors.New("вы не указали URL")
+// Результат поиска похожих статей без анализа
+type similarityOutcome struct {
+	Article         *domain.Article
+	Duplicate       *domain.Article  // Сохраненная статья с тем же адресом или текстом
+	DuplicateReason string           // duplicateByURL или duplicateByContent
+	Candidates      int              // Кандидатов по отпечаткам текста и векторному сходству
+	Similar         []domain.Article // Почти дословные копии и подтвержденные композитным сходством
+}
+
+func (bot *Bot) findSimilarArticles(ctx context.Context, url string) (*similarityOutcome, error) {
+	if url == "" {
+		return nil, errors.New("вы не указали URL")
 	}
 
-	url := parts[0]
 	if !strings.HasPrefix(url, "http") {
-		return "", errors.New("пожалуйста, отправьте действительный URL, начинающийся с http/https")
+		return nil, errors.New("пожалуйста, отправьте действительный URL, начинающийся с http/https")
+	}
+
+	// Статья с тем же адресом уже сохранена
+	if existing, err := bot.storedArticleByURL(ctx, url); err == nil && existing != nil {
+		return &similarityOutcome{
+			Article:         &domain.Article{SourceURL: canonicalURL(url)},
+			Duplicate:       existing,
+			DuplicateReason: duplicateByURL,
+		}, nil
 	}
 
 	// Извлекаем содержимое статьи
-	art, err := bot.getArticle(url)
+	art, err := bot.getArticle(ctx, url, nil)
 	if err != nil {
-		return "", fmt.Errorf("ошибка загрузки статьи: %w", err)
+		return nil, fmt.Errorf("ошибка загрузки статьи: %w", err)
+	}
+	if art.SourceURL == "" {
+		art.SourceURL = canonicalURL(url)
+	}
+
+	outcome := &similarityOutcome{
+		Article: art,
+	}
+
+	// Проверка точных дубликатов
+	if existing, reason, err := bot.findDuplicate(ctx, art, url); err == nil && existing != nil {
+		outcome.Duplicate = existing
+		outcome.DuplicateReason = reason
+		return outcome, nil
 	}
 
 	// Получаем эмбеддинг
-	embedding, err := bot.model.GetEmbedding(art.Content)
+	embedding, err := bot.model.GetEmbedding(ctx, art.Content)
 	if err != nil {
-		return "", errors.New("ошибка векторизации")
+		return nil, errors.New("ошибка векторизации")
 	}
 
-	// Ищем похожие статьи
-	similar, err := bot.conf.GetDB().FindSimilar(
-		embedding,
-		bot.conf.Analysis.VectorSimilarityThreshold,
-		uint(bot.conf.Analysis.DaysLookback),
-	)
+	// Ищем почти дословные копии и похожие статьи
+	outcome.Similar, outcome.Candidates, err = bot.matchSimilar(ctx, art, embedding)
 	if err != nil {
-		return "", errors.New("ошибка поиска похожих статей")
+		return nil, errors.New("ошибка поиска похожих статей")
 	}
 
-	// Формируем результат
-	if len(similar) == 0 {
-		return "✅ Похожие статьи не найдены", nil
+	return outcome, nil
+}
+
+func (bot *Bot) FindSimilar(call *CallContext) (string, error) {
+	parts := strings.Fields(call.Args)
+	if len(parts) == 0 {
+		return "", errors.New("вы не указали URL")
 	}
 
-	// Проверка точных дубликатов
-	if existing, err := bot.conf.GetDB().GetExactDuplicate(art.Content); err == nil && existing != nil {
-		return fmt.Sprintf("⚠️ Найден точный дубликат: %s\nURL: %s", existing.Title, existing.SourceURL), nil
+	outcome, err := bot.findSimilarArticles(call.Context(), parts[0])
+	if err != nil {
+		return "", err
 	}
 
-	// Проверка с использованием композитного сходства
-	composite := similarity.NewCompositeSimilarity(bot.conf.Analysis.CompositeVectorWeight)
-	var verified []domain.Article
-	for _, candidate := range similar {
-		score, err := composite.Compare(
-			art.Content,
-			candidate.Content,
-			embedding,
-			candidate.Embedding,
-		)
-		if err == nil && score >= bot.conf.Analysis.FinalSimilarityThreshold {
-			candidate.TrueSimilarity = score
-			verified = append(verified, candidate)
-		}
+	if outcome.Duplicate != nil {
+		return fmt.Sprintf(
+			"⚠️ Найден точный дубликат (%s): %s\nURL: %s\nАнализ: %s",
+			duplicateReasonText(outcome.DuplicateReason),
+			outcome.Duplicate.Title,
+			outcome.Duplicate.SourceURL,
+			provenanceSummary(outcome.Duplicate.Provenance),
+		), nil
+	}
+
+	// Формируем результат
+	if outcome.Candidates == 0 {
+		return "✅ Похожие статьи не найдены", nil
 	}
 
 	// Формируем сообщение с результатами
-	if len(verified) == 0 {
+	if len(outcome.Similar) == 0 {
 		return "✅ Похожие статьи не найдены (после применения композитного сходства)", nil
 	}
 
 	var result strings.Builder
-	result.WriteString(fmt.Sprintf("🔍 Найдено %d похожих статей:\n\n", len(verified)))
+	result.WriteString(fmt.Sprintf("🔍 Найдено %d похожих статей:\n\n", len(outcome.Similar)))
 
-	for i, article := range verified {
+	for i, article := range outcome.Similar {
 		result.WriteString(fmt.Sprintf("%d. *%s*\n", i+1, article.Title))
 		result.WriteString(fmt.Sprintf("   🔗 [Источник](%s)\n", article.SourceURL))
-		result.WriteString(fmt.Sprintf("   💡 Сходство: %.2f%%\n\n", article.TrueSimilarity*100))
+		result.WriteString(fmt.Sprintf("   💡 Сходство: %.2f%%\n", article.TrueSimilarity*100))
+		if article.TextSimilarity > 0 {
+			result.WriteString(fmt.Sprintf("   📑 Почти дословная копия: совпадение текста %.0f%%\n", article.TextSimilarity*100))
+		}
+		result.WriteString(fmt.Sprintf("   🧾 Анализ: %s\n\n", provenanceSummary(article.Provenance)))
 	}
 
 	return result.String(), nil
@@ -830,35 +1306,52 @@ func ParseExcelDate(cellValue string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("unrecognized date format: %s", cellValue)
 }
 
-func (bot *Bot) LoadXLSX(args string) (string, error) {
-	// В новой системе args должен содержать путь к XLSX-файлу
-	if args == "" {
-		return "", errors.New("укажите путь к XLSX файлу")
-	}
+// Загружает статьи из прикрепленного XLSX файла или, если вложения нет, из файла на сервере по пути в аргументах
+func (bot *Bot) LoadXLSX(call *CallContext) (*Response, error) {
+	var (
+		xlFile *xlsx.File
+		err    error
+	)
 
-	// Проверяем расширение файла
-	if !strings.HasSuffix(args, ".xlsx") {
-		return "", errors.New("формат файла должен быть .xlsx")
-	}
+	if len(call.Attachments) > 0 {
+		attachment := call.attachment(".xlsx")
+		if attachment == nil {
+			return nil, errors.New("формат файла должен быть .xlsx")
+		}
 
-	// Проверяем существование файла
-	if _, err := os.Stat(args); os.IsNotExist(err) {
-		return "", fmt.Errorf("файл %s не найден", args)
-	}
+		call.Progress("📥 Загружаю файл...")
+		xlFile, err = xlsx.OpenBinary(attachment.Data)
+	} else {
+		if call.Args == "" {
+			return nil, errors.New("прикрепите XLSX файл к команде или укажите путь к нему")
+		}
 
-	// Парсим XLSX
-	xlFile, err := xlsx.OpenFile(args)
+		// Проверяем расширение файла
+		if !strings.HasSuffix(call.Args, ".xlsx") {
+			return nil, errors.New("формат файла должен быть .xlsx")
+		}
+
+		// Проверяем существование файла
+		if _, err := os.Stat(call.Args); os.IsNotExist(err) {
+			return nil, fmt.Errorf("файл %s не найден", call.Args)
+		}
+
+		xlFile, err = xlsx.OpenFile(call.Args)
+	}
 	if err != nil {
-		return "", fmt.Errorf("ошибка чтения XLSX файла: %w", err)
+		return nil, fmt.Errorf("ошибка чтения XLSX файла: %w", err)
 	}
 
 	// Обрабатываем данные
 	successCount := 0
 	skipCount := 0
-	db := bot.conf.GetDB()
 
 	for _, sheet := range xlFile.Sheets {
 		for i, row := range sheet.Rows {
+			if err := call.Context().Err(); err != nil {
+				return nil, fmt.Errorf("загружено статей до отмены: %d: %w", successCount, err)
+			}
+
 			// Пропускаем заголовок
 			if i == 0 || len(row.Cells) == 0 {
 				continue
@@ -891,21 +1384,21 @@ func (bot *Bot) LoadXLSX(args string) (string, error) {
 				Affiliation: cells[4].String(),
 				Sentiment:   cells[5].String(),
 				Title:       cells[2].String(),
-				SourceURL:   cells[3].String(),
+				SourceURL:   canonicalURL(cells[3].String()),
 				CreatedAt:   time.Now().Unix(),
 				SimilarURLs: []string{},
 				Embedding:   []float64{},
 			}
 
 			// Проверяем дубликат по URL
-			exists, err := db.HasArticleByURL(art.SourceURL)
+			exists, err := bot.knownArticleURL(call.Context(), cells[3].String())
 			if err != nil || exists {
 				skipCount++
 				continue
 			}
 
 			// Сохраняем в БД
-			if err := db.SaveArticle(art); err != nil {
+			if err := bot.storeArticle(call.Context(), art); err != nil {
 				log.Printf("Ошибка сохранения в базу: %v", err)
 				skipCount++
 			} else {
@@ -914,40 +1407,34 @@ func (bot *Bot) LoadXLSX(args string) (string, error) {
 		}
 	}
 
-	return fmt.Sprintf(
+	return &Response{Text: fmt.Sprintf(
 		"✅ Успешно загружено: %d статей\n🚫 Пропущено (дубликаты/ошибки): %d",
 		successCount, skipCount,
-	), nil
+	)}, nil
 }
 
-func (bot *Bot) SendLogs(args string) (string, error) {
+func (bot *Bot) SendLogs(call *CallContext) (*Response, error) {
 	// Проверяем, существует ли файл логов
 	if _, err := os.Stat(bot.conf.LogsFile); os.IsNotExist(err) {
-		return "", errors.New("файл логов не найден")
-	}
-
-	// Читаем лог-файл
-	logContent, err := os.ReadFile(bot.conf.LogsFile)
-	if err != nil {
-		return "", fmt.Errorf("ошибка чтения файла логов: %w", err)
+		return nil, errors.New("файл логов не найден")
 	}
 
-	// Проверяем размер файла
-	if len(logContent) > 50*1024*1024 { // 50MB
-		return "", errors.New("файл логов слишком большой (максимум 50MB)")
-	}
-
-	// Для веб-интерфейса возвращаем содержимое логов
-	return string(logContent), nil
+	return &Response{
+		Files: []ResponseFile{{
+			Name:    "acasbot_logs.txt",
+			Caption: "📄 Файл логов ACASbot",
+			Path:    bot.conf.LogsFile,
+		}},
+	}, nil
 }
 
-func (bot *Bot) SetXLSXColumns(args string) (string, error) {
-	if args == "" {
+func (bot *Bot) SetXLSXColumns(call *CallContext) (string, error) {
+	if call.Args == "" {
 		return "", errors.New("укажите JSON с настройкой колонок")
 	}
 
 	var columns []domain.XLSXColumn
-	if err := json.Unmarshal([]byte(args), &columns); err != nil {
+	if err := json.Unmarshal([]byte(call.Args), &columns); err != nil {
 		return "", errors.New("неверный формат JSON")
 	}
 
@@ -958,7 +1445,7 @@ func (bot *Bot) SetXLSXColumns(args string) (string, error) {
 	return "Конфиг колонок XLSX обновлен", nil
 }
 
-func (bot *Bot) ShowXLSXColumns(args string) (string, error) {
+func (bot *Bot) ShowXLSXColumns(call *CallContext) (string, error) {
 	columnsJSON, err := json.MarshalIndent(bot.conf.Sheets.XLSXColumns, "", "  ")
 	if err != nil {
 		return "", errors.New("ошибка форматирования конфигурации")
@@ -967,7 +1454,7 @@ func (bot *Bot) ShowXLSXColumns(args string) (string, error) {
 	return fmt.Sprintf("Текущие колонки XLSX:\n```json\n%s\n```", string(columnsJSON)), nil
 }
 
-func (bot *Bot) TogglePushToGoogleSheets(args string) (string, error) {
+func (bot *Bot) TogglePushToGoogleSheets(call *CallContext) (string, error) {
 	bot.conf.Sheets.PushToGoogleSheet = !bot.conf.Sheets.PushToGoogleSheet
 	bot.conf.Update()
 
@@ -977,3 +1464,38 @@ func (bot *Bot) TogglePushToGoogleSheets(args string) (string, error) {
 		return "Добавление данных в гугл таблицу отключено.", nil
 	}
 }
+
+// Версия схемы, размер базы данных и количество строк в таблицах
+func (bot *Bot) DatabaseInfo(call *CallContext) (string, error) {
+	info, err := bot.conf.GetDB().Info(call.Context())
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить сведения о базе данных: %w", err)
+	}
+
+	var response strings.Builder
+	response.WriteString("🗄 *База данных*\n")
+	response.WriteString(fmt.Sprintf("*Файл:* `%s`, %.1f МБ\n", bot.conf.DB.File, float64(info.SizeBytes)/(1024*1024)))
+	response.WriteString(fmt.Sprintf("*Версия схемы:* %d (последняя %d)\n", info.SchemaVersion, info.LatestVersion))
+	if len(info.Migrations) > 0 {
+		last := info.Migrations[len(info.Migrations)-1]
+		response.WriteString(fmt.Sprintf("*Последняя миграция:* %d \"%s\", %s\n",
+			last.Version,
+			last.Description,
+			time.Unix(last.AppliedAt, 0).Format("2006-01-02 15:04"),
+		))
+	}
+
+	response.WriteString("\n*Строк в таблицах:*\n")
+	for _, table := range info.Tables {
+		response.WriteString(fmt.Sprintf("- %s: %d\n", table.Table, table.Rows))
+	}
+
+	stats := bot.conf.GetDB().IndexStats()
+	if stats.Ready {
+		response.WriteString(fmt.Sprintf("\n*Индекс векторов:* %d статей, размерность %d, построен за %v\n", stats.Vectors, stats.Dimensions, stats.BuildTime.Round(time.Millisecond)))
+	} else {
+		response.WriteString("\n*Индекс векторов:* строится\n")
+	}
+
+	return response.String(), nil
+}
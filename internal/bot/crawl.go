@@ -30,6 +30,7 @@ import (
 	"math/rand"
 	"net/http"
 	"net/http/cookiejar"
+	"os/exec"
 	"regexp"
 	"strings"
 	"sync"
@@ -45,6 +46,16 @@ import (
 	trafilatura "github.com/markusmobius/go-trafilatura"
 )
 
+// Получатель сообщений о ходе обработки статьи. Может вызываться из нескольких
+// горутин одновременно. nil - сообщения никому не нужны
+type progressFunc func(text string)
+
+func (progress progressFunc) report(format string, args ...any) {
+	if progress != nil {
+		progress(fmt.Sprintf(format, args...))
+	}
+}
+
 type ArticleContent struct {
 	Title   string
 	Content string
@@ -62,15 +73,30 @@ type ArticleAnalysis struct {
 	Errors         []error
 }
 
-func (bot *Bot) ExtractWebContent(articleURL string) (*domain.Article, error) {
+// Загружает страницу и извлекает из нее статью. Способ загрузки, извлечения
+// и затраченное время записываются в происхождение статьи
+func (bot *Bot) ExtractWebContent(ctx context.Context, articleURL string, progress progressFunc) (*domain.Article, error) {
 	var htmlData []byte
 	var err error
 
-	htmlData, err = bot.extractWithHeadlessBrowser(articleURL)
+	started := time.Now()
+	provenance := &domain.Provenance{
+		Fetcher: domain.FetcherHeadless,
+	}
+
+	progress.report("🌐 Загружаю страницу...")
+	htmlData, err = bot.extractWithHeadlessBrowser(ctx, articleURL, progress)
 	if err != nil {
+		// После отмены обычный запрос уже не нужен
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
 		log.Printf("Не получилось получить данные при помощи headless браузера: %s. Откат к обычному запросу...", err)
+		progress.report("🌐 Браузер не справился, пробую обычный запрос...")
 
-		htmlData, err = bot.extractWithoutHeadless(articleURL)
+		provenance.Fetcher = domain.FetcherPlain
+		htmlData, err = bot.extractWithoutHeadless(ctx, articleURL)
 		if err != nil {
 			log.Printf("Не получилось получить данные при помощи обычного запроса: %s", err)
 			return nil, err
@@ -79,6 +105,10 @@ func (bot *Bot) ExtractWebContent(articleURL string) (*domain.Article, error) {
 
 	html := string(htmlData)
 
+	// Адрес статьи приводится к каноническому с учетом того, что указала сама страница
+	queryDoc, parseErr := goquery.NewDocumentFromReader(strings.NewReader(html))
+	sourceURL := pageCanonicalURL(queryDoc, articleURL)
+
 	// Используем trafilatura вместо readability
 	parseOpts := trafilatura.Options{
 		ExcludeTables:   false,
@@ -101,21 +131,34 @@ func (bot *Bot) ExtractWebContent(articleURL string) (*domain.Article, error) {
 			pubTime = time.Now()
 		}
 
+		provenance.Extractor = domain.ExtractorTrafilatura
+		provenance.FetchMillis = time.Since(started).Milliseconds()
+
 		return &domain.Article{
 			Title:       doc.Metadata.Title,
 			Content:     doc.ContentText,
 			PublishedAt: pubTime.Unix(),
-			SourceURL:   articleURL,
+			SourceURL:   sourceURL,
+			Provenance:  provenance,
 		}, nil
 	}
 
 	// Пробуем кастомный парсер
-	queryDoc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if parseErr != nil {
+		return nil, fmt.Errorf("ошибка парсинга HTML: %w", parseErr)
+	}
+
+	art, err := bot.extractCustomContent(queryDoc)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка парсинга HTML: %w", err)
+		return nil, err
 	}
 
-	return bot.extractCustomContent(queryDoc)
+	provenance.Extractor = domain.ExtractorCustom
+	provenance.FetchMillis = time.Since(started).Milliseconds()
+	art.Provenance = provenance
+	art.SourceURL = sourceURL
+
+	return art, nil
 }
 
 var userAgents = []string{
@@ -143,8 +186,8 @@ func (bot *Bot) getRandomUserAgent() string {
 	return userAgents[rand.Intn(len(userAgents))]
 }
 
-func (bot *Bot) extractWithHeadlessBrowser(articleURL string) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+func (bot *Bot) extractWithHeadlessBrowser(ctx context.Context, articleURL string, progress progressFunc) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
@@ -204,7 +247,20 @@ func (bot *Bot) extractWithHeadlessBrowser(articleURL string) ([]byte, error) {
 			break
 		}
 
-		time.Sleep(time.Duration(attempt*2) * time.Second)
+		// Без установленного браузера повторять незачем
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("headless браузер не найден: %w", err)
+		}
+
+		if attempt < 3 {
+			progress.report("🌐 Попытка %d не удалась, повторяю загрузку...", attempt)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(attempt*2) * time.Second):
+		}
 	}
 
 	if err != nil {
@@ -231,7 +287,7 @@ func shouldBlockRequest(url string) bool {
 	return false
 }
 
-func (bot *Bot) extractWithoutHeadless(articleURL string) ([]byte, error) {
+func (bot *Bot) extractWithoutHeadless(ctx context.Context, articleURL string) ([]byte, error) {
 	jar, err := cookiejar.New(nil)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания cookie jar: %w", err)
@@ -246,7 +302,7 @@ func (bot *Bot) extractWithoutHeadless(articleURL string) ([]byte, error) {
 		},
 	}
 
-	req, err := http.NewRequest("GET", articleURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", articleURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
 	}
@@ -434,13 +490,74 @@ func cleanContent(content string) string {
 	return cleaned
 }
 
-type QueryResult struct {
-	Type    string
-	Content string
+// Анализ статьи относительно одного объекта. Сначала используется структурированный ответ,
+// при его неудаче - отдельные текстовые запросы с разбором отношения по ключевым словам.
+// Возвращает результат, заголовок из структурированного ответа (если есть) и ошибки
+func (bot *Bot) analyzeObject(ctx context.Context, settings *analysisSettings, content string, object *TrackedObject, used *promptLog, progress progressFunc) (domain.ObjectAnalysis, string, []error) {
+	result := domain.ObjectAnalysis{
+		Object: object.Name,
+	}
+
+	if bot.conf.Ollama.StructuredOutput {
+		progress.report("🤖 LLM: анализ статьи относительно \"%s\"...", object.Name)
+		analysis, err := bot.queryStructured(ctx, settings, content, object, used)
+		if err == nil {
+			result.Affiliation = analysis.Affiliation
+			result.Sentiment = analysis.Sentiment
+			result.Confidence = analysis.Confidence
+			result.Justification = analysis.Justification
+			return result, analysis.Title, nil
+		}
+
+		log.Printf("Структурированный ответ для объекта \"%s\" не получен (%v), используем текстовые запросы", object.Name, err)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		errsMu sync.Mutex
+		errs   []error
+	)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		progress.report("🤖 LLM: связь статьи с \"%s\"...", object.Name)
+		response, err := bot.queryAffiliation(ctx, settings, content, object, used)
+		if err != nil {
+			errsMu.Lock()
+			errs = append(errs, fmt.Errorf("тема (%s): %w", object.Name, err))
+			errsMu.Unlock()
+			return
+		}
+		result.Affiliation = response
+	}()
+	go func() {
+		defer wg.Done()
+		progress.report("🤖 LLM: отношение к \"%s\"...", object.Name)
+		response, err := bot.querySentiment(ctx, settings, content, object, used)
+		if err != nil {
+			errsMu.Lock()
+			errs = append(errs, fmt.Errorf("отношение (%s): %w", object.Name, err))
+			errsMu.Unlock()
+			return
+		}
+
+		// Парсим структурированный ответ
+		parts := strings.SplitN(response, "\n", 2)
+		if len(parts) > 0 {
+			result.Sentiment = extractSentiment(strings.TrimSpace(parts[0]))
+		}
+		if len(parts) > 1 {
+			result.Justification = strings.TrimSpace(parts[1])
+		}
+	}()
+	wg.Wait()
+
+	return result, "", errs
 }
 
-func (bot *Bot) getArticle(url string) (*domain.Article, error) {
-	art, err := bot.ExtractWebContent(url)
+func (bot *Bot) getArticle(ctx context.Context, url string, progress progressFunc) (*domain.Article, error) {
+	art, err := bot.ExtractWebContent(ctx, url, progress)
 	if err != nil {
 		return nil, err
 	}
@@ -454,98 +571,129 @@ func (bot *Bot) getArticle(url string) (*domain.Article, error) {
 		)
 	}
 
+	if art.Provenance == nil {
+		art.Provenance = &domain.Provenance{}
+	}
+	art.Provenance.MaxContentSize = bot.conf.Analysis.MaxContentSize
+
 	// Ограничение размера контента
 	if uint(len([]rune(art.Content))) > bot.conf.Analysis.MaxContentSize {
 		art.Content = string([]rune(art.Content)[:bot.conf.Analysis.MaxContentSize])
+		art.Provenance.Truncated = true
 		if bot.conf.Debug {
 			log.Printf("Урезано до: %s\n", art.Content)
 		}
 	}
 
+	progress.report("📄 Текст извлечен: %d символов", utf8.RuneCountInString(art.Content))
+
 	return art, nil
 }
 
-func (bot *Bot) analyzeArticle(url string) (*domain.Article, error) {
-	art, err := bot.getArticle(url)
-	if err != nil {
-		return nil, err
+// Проверка релевантности и анализ уже извлеченной статьи
+func (bot *Bot) analyzeArticle(ctx context.Context, art *domain.Article, settings *analysisSettings, progress progressFunc) error {
+	// Нерелевантные статьи сохраняем без запросов к LLM
+	if bot.conf.Analysis.Relevance.Enabled {
+		progress.report("🎯 Проверяю релевантность...")
+	}
+	relevance := bot.checkRelevance(ctx, art, settings.objects)
+	if !relevance.Relevant {
+		art.Irrelevant = true
+		art.Affiliation = "Не относится к объекту: " + relevance.Reason
+		if bot.conf.Debug {
+			log.Printf("Статья %s отброшена фильтром релевантности: %s", art.SourceURL, relevance.Reason)
+		}
+		return nil
 	}
 
-	var wg sync.WaitGroup
-	results := make(chan QueryResult, 3)
-	errors := make(chan error, 3)
+	return bot.analyzeContent(ctx, art, settings, progress)
+}
 
-	// Типы запросов
-	const (
-		QueryTitle       = "title"
-		QueryAffiliation = "affiliation"
-		QuerySentiment   = "sentiment"
+// Запросы к LLM по уже извлеченному тексту статьи: результаты по каждому объекту
+// и заголовок, если его нет. Ошибки отдельных запросов сохраняются в art.Errors,
+// модель, промпты и время анализа - в происхождении статьи
+func (bot *Bot) analyzeContent(ctx context.Context, art *domain.Article, settings *analysisSettings, progress progressFunc) error {
+	objects := settings.objects
+	art.Objects = make([]domain.ObjectAnalysis, len(objects))
+	titles := make([]string, len(objects))
+
+	started := time.Now()
+	used := &promptLog{}
+
+	var (
+		wg       sync.WaitGroup
+		errorsMu sync.Mutex
 	)
 
-	needTitle := art.Title == ""
-	if needTitle {
+	// Без структурированного ответа заголовок запрашивается отдельно, параллельно с объектами
+	if art.Title == "" && !bot.conf.Ollama.StructuredOutput {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			response, err := bot.queryTitle(art.Content)
+			progress.report("🤖 LLM: заголовок...")
+			title, err := bot.queryTitle(ctx, settings, art.Content, used)
 			if err != nil {
-				errors <- fmt.Errorf("заголовок: %w", err)
+				errorsMu.Lock()
+				art.Errors = append(art.Errors, fmt.Errorf("заголовок: %w", err))
+				errorsMu.Unlock()
 				return
 			}
-			results <- QueryResult{Type: QueryTitle, Content: response}
+			art.Title = title
 		}()
 	}
 
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		response, err := bot.queryAffiliation(art.Content)
-		if err != nil {
-			errors <- fmt.Errorf("тема: %w", err)
-			return
-		}
-		results <- QueryResult{Type: QueryAffiliation, Content: response}
-	}()
-	go func() {
-		defer wg.Done()
-		response, err := bot.querySentiment(art.Content)
-		if err != nil {
-			errors <- fmt.Errorf("отношение: %w", err)
-			return
-		}
-		results <- QueryResult{Type: QuerySentiment, Content: response}
-	}()
+	for i := range objects {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
 
-	// Обработка результатов
-	go func() {
-		wg.Wait()
-		close(results)
-		close(errors)
-	}()
+			result, title, errs := bot.analyzeObject(ctx, settings, art.Content, &objects[index], used, progress)
+			art.Objects[index] = result
+			titles[index] = title
 
-	// Собираем результаты
-	for res := range results {
-		switch res.Type {
-		case QueryTitle:
-			art.Title = res.Content
-		case QueryAffiliation:
-			art.Affiliation = res.Content
-		case QuerySentiment:
-			// Парсим структурированный ответ
-			parts := strings.SplitN(res.Content, "\n", 2)
-			if len(parts) > 0 {
-				art.Sentiment = extractSentiment(strings.TrimSpace(parts[0]))
-			}
-			if len(parts) > 1 {
-				art.Justification = strings.TrimSpace(parts[1])
-			}
+			errorsMu.Lock()
+			art.Errors = append(art.Errors, errs...)
+			errorsMu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	// Ответы, прерванные отменой, неполны - сохранять их нельзя
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Заголовок берется из структурированного ответа по основному объекту, иначе запрашивается отдельно
+	if art.Title == "" && len(titles) > 0 {
+		art.Title = titles[0]
+	}
+	if art.Title == "" && bot.conf.Ollama.StructuredOutput {
+		progress.report("🤖 LLM: заголовок...")
+		title, err := bot.queryTitle(ctx, settings, art.Content, used)
+		if err != nil {
+			art.Errors = append(art.Errors, fmt.Errorf("заголовок: %w", err))
+		} else {
+			art.Title = title
 		}
 	}
 
-	// Собираем ошибки
-	for err := range errors {
-		art.Errors = append(art.Errors, err)
+	// Результат по основному объекту хранится и в самой статье
+	if len(art.Objects) > 0 {
+		art.Affiliation = art.Objects[0].Affiliation
+		art.Sentiment = art.Objects[0].Sentiment
+		art.Justification = art.Objects[0].Justification
+		art.Confidence = art.Objects[0].Confidence
 	}
 
-	return art, nil
+	if art.Provenance == nil {
+		art.Provenance = &domain.Provenance{}
+	}
+	art.Provenance.Backend = settings.model.Backend()
+	art.Provenance.Model = settings.model.Model()
+	art.Provenance.Prompts = used.prompts()
+	art.Provenance.AnalysisMillis = time.Since(started).Milliseconds()
+	art.Provenance.AnalyzedAt = time.Now().Unix()
+	art.Provenance.Errors = errorStrings(art.Errors)
+
+	return nil
 }
@@ -0,0 +1,304 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"Unbewohnte/ACASbot/internal/feed"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const feedFetchTimeout = 30 * time.Second
+
+func feedName(f *domain.Feed) string {
+	if f.Title != "" {
+		return f.Title
+	}
+
+	return f.URL
+}
+
+// Отправляет результат обработки записи ленты в настроенный чат и веб-интерфейс
+func (bot *Bot) notifyFeedResult(message string) {
+	if bot.conf.Feeds.ChatID != 0 {
+		bot.sendMessage(bot.conf.Feeds.ChatID, message, 0)
+	}
+
+	bot.server.SendResponse(message)
+}
+
+func (bot *Bot) pollFeed(f *domain.Feed) {
+	db := bot.conf.GetDB()
+
+	parsed, err := feed.Fetch(f.URL, feedFetchTimeout)
+	if err != nil {
+		log.Printf("Не удалось проверить ленту %s: %v", f.URL, err)
+		db.UpdateFeedChecked(f.ID, time.Now().Unix(), err.Error())
+		return
+	}
+
+	// Отмечаем проверку сразу, чтобы долгий анализ не сдвигал расписание
+	if err := db.UpdateFeedChecked(f.ID, time.Now().Unix(), ""); err != nil {
+		log.Printf("Не удалось обновить время проверки ленты %s: %v", f.URL, err)
+	}
+
+	// Ленты обычно идут от новых к старым - ставим в очередь в хронологическом порядке
+	var links []string
+	for i := len(parsed.Items) - 1; i >= 0; i-- {
+		item := parsed.Items[i]
+
+		seen, err := db.IsFeedItemSeen(f.ID, item.Link)
+		if err != nil || seen {
+			continue
+		}
+
+		known, err := bot.knownArticleURL(context.Background(), item.Link)
+		if err != nil {
+			continue
+		}
+		if known {
+			if err := db.MarkFeedItemSeen(f.ID, item.Link); err != nil {
+				log.Printf("Не удалось запомнить запись ленты %s: %v", item.Link, err)
+			}
+			continue
+		}
+
+		if bot.conf.Debug {
+			log.Printf("Новая запись ленты %s: %s", f.URL, item.Link)
+		}
+		links = append(links, item.Link)
+	}
+
+	if len(links) == 0 {
+		return
+	}
+
+	// Записи анализируются через очередь пакетов, поэтому не теряются при недоступной
+	// модели или перезапуске. Просмотренными они отмечаются только после постановки в очередь
+	if _, err := db.CreateFeedBatch(f.ID, links); err != nil {
+		log.Printf("Не удалось поставить записи ленты %s в очередь: %v", f.URL, err)
+		return
+	}
+	for _, link := range links {
+		if err := db.MarkFeedItemSeen(f.ID, link); err != nil {
+			log.Printf("Не удалось запомнить запись ленты %s: %v", link, err)
+		}
+	}
+
+	bot.wakeBatchWorkers()
+}
+
+// Сообщает о результате анализа записи ленты, обработанной очередью
+func (bot *Bot) notifyFeedItem(feedID int64, link string, result string, err error) {
+	name := link
+	if f, feedErr := bot.conf.GetDB().GetFeed(feedID); feedErr == nil {
+		name = feedName(f)
+	}
+
+	if err != nil {
+		bot.notifyFeedResult(fmt.Sprintf(
+			"❌ Не удалось обработать статью из ленты \"%s\": %s\n%s",
+			name, err.Error(), link,
+		))
+		return
+	}
+
+	bot.notifyFeedResult(fmt.Sprintf(
+		"📰 *Новая статья из ленты \"%s\"*\n%s\n\n%s",
+		name, link, result,
+	))
+}
+
+func (bot *Bot) pollFeeds() {
+	feeds, err := bot.conf.GetDB().GetFeeds()
+	if err != nil {
+		log.Printf("Не удалось получить список лент: %v", err)
+		return
+	}
+
+	now := time.Now().Unix()
+	for i := range feeds {
+		if feeds[i].Due(now) {
+			bot.pollFeed(&feeds[i])
+		}
+	}
+}
+
+// Периодически проверяет ленты, у которых подошел срок
+func (bot *Bot) StartFeedPoller(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if bot.conf.Feeds.Enabled {
+					bot.pollFeeds()
+				}
+			}
+		}
+	}()
+}
+
+func (bot *Bot) AddFeed(call *CallContext) (string, error) {
+	parts := strings.Fields(call.Args)
+	if len(parts) == 0 {
+		return "", errors.New("не указан URL ленты")
+	}
+
+	feedURL := parts[0]
+	if !strings.HasPrefix(feedURL, "http") {
+		return "", errors.New("пожалуйста, укажите действительный URL, начинающийся с http/https")
+	}
+
+	interval := bot.conf.Feeds.DefaultIntervalMinutes
+	if len(parts) > 1 {
+		minutes, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil || minutes == 0 {
+			return "", errors.New("неверный интервал проверки. Укажите число минут > 0")
+		}
+		interval = uint(minutes)
+	}
+	if interval == 0 {
+		interval = 30
+	}
+
+	db := bot.conf.GetDB()
+	if existing, err := db.GetFeedByURL(feedURL); err == nil && existing != nil {
+		return "", fmt.Errorf("лента уже добавлена под номером %d", existing.ID)
+	}
+
+	// Проверяем, что по ссылке действительно лента
+	parsed, err := feed.Fetch(feedURL, feedFetchTimeout)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := db.AddFeed(feedURL, parsed.Title, interval)
+	if err != nil {
+		return "", fmt.Errorf("не удалось сохранить ленту: %w", err)
+	}
+
+	// Уже опубликованные записи не анализируем - только новые
+	for _, item := range parsed.Items {
+		if err := db.MarkFeedItemSeen(f.ID, item.Link); err != nil {
+			log.Printf("Не удалось запомнить запись ленты %s: %v", item.Link, err)
+		}
+	}
+
+	return fmt.Sprintf(
+		"Лента \"%s\" добавлена под номером %d. Проверка каждые %d мин. Текущих записей: %d (анализироваться будут только новые)",
+		feedName(f), f.ID, f.IntervalMinutes, len(parsed.Items),
+	), nil
+}
+
+func (bot *Bot) RemoveFeed(call *CallContext) (string, error) {
+	call.Args = strings.TrimSpace(call.Args)
+	if call.Args == "" {
+		return "", errors.New("не указан номер или URL ленты")
+	}
+
+	db := bot.conf.GetDB()
+
+	feedID, err := strconv.ParseInt(call.Args, 10, 64)
+	if err != nil {
+		f, err := db.GetFeedByURL(call.Args)
+		if err != nil {
+			return "", errors.New("лента не найдена")
+		}
+		feedID = f.ID
+	}
+
+	removed, err := db.RemoveFeed(feedID)
+	if err != nil {
+		return "", fmt.Errorf("не удалось удалить ленту: %w", err)
+	}
+	if !removed {
+		return "", errors.New("лента не найдена")
+	}
+
+	return fmt.Sprintf("Лента %d удалена", feedID), nil
+}
+
+func (bot *Bot) ListFeeds(call *CallContext) (string, error) {
+	feeds, err := bot.conf.GetDB().GetFeeds()
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить список лент: %w", err)
+	}
+
+	if len(feeds) == 0 {
+		return "Лент нет. Добавьте новую командой `addfeed`", nil
+	}
+
+	var response strings.Builder
+	response.WriteString("*Ленты:*\n")
+	for _, f := range feeds {
+		response.WriteString(fmt.Sprintf("\n*%d.* %s\n", f.ID, feedName(&f)))
+		response.WriteString(fmt.Sprintf("- URL: %s\n", f.URL))
+		response.WriteString(fmt.Sprintf("- Проверка каждые %d мин., последняя: %s\n",
+			f.IntervalMinutes,
+			time.Unix(f.LastChecked, 0).Format("2006-01-02 15:04"),
+		))
+		if f.LastError != "" {
+			response.WriteString(fmt.Sprintf("- ⚠️ Ошибка: %s\n", f.LastError))
+		}
+	}
+
+	if !bot.conf.Feeds.Enabled {
+		response.WriteString("\n⚠️ Проверка лент выключена (`togglefeeds`)")
+	}
+
+	return response.String(), nil
+}
+
+func (bot *Bot) SetFeedChat(call *CallContext) (string, error) {
+	if call.Args == "" {
+		return "", errors.New("не указан ID чата")
+	}
+
+	chatID, err := strconv.ParseInt(strings.TrimSpace(call.Args), 10, 64)
+	if err != nil {
+		return "", errors.New("неверный ID чата")
+	}
+
+	bot.conf.Feeds.ChatID = chatID
+	bot.conf.Update()
+
+	if chatID == 0 {
+		return "Результаты анализа лент будут отправляться только в веб-интерфейс", nil
+	}
+
+	return fmt.Sprintf("Результаты анализа лент будут отправляться в чат %d", chatID), nil
+}
+
+func (bot *Bot) ToggleFeeds(call *CallContext) (string, error) {
+	bot.conf.Feeds.Enabled = !bot.conf.Feeds.Enabled
+	bot.conf.Update()
+
+	if bot.conf.Feeds.Enabled {
+		return "Проверка лент включена.", nil
+	} else {
+		return "Проверка лент выключена.", nil
+	}
+}
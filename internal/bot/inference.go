@@ -19,6 +19,8 @@
 package bot
 
 import (
+	"Unbewohnte/ACASbot/internal/inference"
+	"context"
 	"log"
 	"strings"
 )
@@ -29,10 +31,15 @@ const (
 	TEMPLATE_METADATA = "{{METADATA}}"
 )
 
-func (bot *Bot) preparePrompt(template string, text string) string {
+// Подставляет в шаблон текст, объект и его метаданные
+func fillPrompt(template string, text string, object *TrackedObject) string {
 	prompt := strings.ReplaceAll(template, TEMPLATE_TEXT, text)
-	prompt = strings.ReplaceAll(prompt, TEMPLATE_METADATA, bot.conf.Analysis.ObjectMetadata)
-	prompt = strings.ReplaceAll(prompt, TEMPLATE_OBJECT, bot.conf.Analysis.Object)
+	prompt = strings.ReplaceAll(prompt, TEMPLATE_METADATA, object.Metadata)
+	return strings.ReplaceAll(prompt, TEMPLATE_OBJECT, object.Name)
+}
+
+func (bot *Bot) preparePrompt(template string, text string, object *TrackedObject) string {
+	prompt := fillPrompt(template, text, object)
 
 	if bot.conf.Debug {
 		log.Printf("Подготовленный промпт: %s", prompt)
@@ -41,33 +48,59 @@ func (bot *Bot) preparePrompt(template string, text string) string {
 	return prompt
 }
 
+// Основной (первый) отслеживаемый объект общей конфигурации
+func (bot *Bot) primaryObject() *TrackedObject {
+	if len(bot.conf.Analysis.Objects) == 0 {
+		return &TrackedObject{}
+	}
+
+	return &bot.conf.Analysis.Objects[0]
+}
+
 // Запрос для извлечения заголовка
-func (bot *Bot) queryTitle(content string) (string, error) {
-	return bot.model.Query(
-		bot.preparePrompt(
-			bot.conf.Ollama.Prompts.Title,
-			content,
-		),
+func (bot *Bot) queryTitle(ctx context.Context, settings *analysisSettings, content string, used *promptLog) (string, error) {
+	object := settings.primaryObject()
+	template := object.ResolvePrompts(settings.prompts).Title
+	used.record(PROMPT_TITLE, template, object)
+
+	return settings.model.Query(
+		ctx,
+		bot.preparePrompt(template, content, object),
 	)
 }
 
 // Запрос для определения связи
-func (bot *Bot) queryAffiliation(content string) (string, error) {
-	return bot.model.Query(
-		bot.preparePrompt(
-			bot.conf.Ollama.Prompts.Affiliation,
-			content,
-		),
+func (bot *Bot) queryAffiliation(ctx context.Context, settings *analysisSettings, content string, object *TrackedObject, used *promptLog) (string, error) {
+	template := object.ResolvePrompts(settings.prompts).Affiliation
+	used.record(PROMPT_AFFILIATION, template, object)
+
+	return settings.model.Query(
+		ctx,
+		bot.preparePrompt(template, content, object),
 	)
 }
 
 // Запрос для определения отношения к организации
-func (bot *Bot) querySentiment(content string) (string, error) {
-	return bot.model.Query(
-		bot.preparePrompt(
-			bot.conf.Ollama.Prompts.Sentiment,
-			content,
-		),
+func (bot *Bot) querySentiment(ctx context.Context, settings *analysisSettings, content string, object *TrackedObject, used *promptLog) (string, error) {
+	template := object.ResolvePrompts(settings.prompts).Sentiment
+	used.record(PROMPT_SENTIMENT, template, object)
+
+	return settings.model.Query(
+		ctx,
+		bot.preparePrompt(template, content, object),
+	)
+}
+
+// Единый структурированный запрос: заголовок, связь, отношение, уверенность и обоснование
+func (bot *Bot) queryStructured(ctx context.Context, settings *analysisSettings, content string, object *TrackedObject, used *promptLog) (*inference.StructuredAnalysis, error) {
+	template := object.ResolvePrompts(settings.prompts).Structured
+	used.record(PROMPT_STRUCTURED, template, object)
+
+	return inference.QueryAnalysis(
+		ctx,
+		settings.model,
+		bot.preparePrompt(template, content, object),
+		bot.conf.Ollama.StructuredRetries,
 	)
 }
 
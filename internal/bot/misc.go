@@ -19,8 +19,10 @@
 package bot
 
 import (
+	"Unbewohnte/ACASbot/internal/db"
 	"Unbewohnte/ACASbot/internal/domain"
 	"Unbewohnte/ACASbot/internal/similarity"
+	"context"
 	"fmt"
 	"log"
 	"sort"
@@ -90,7 +92,108 @@ func (bot *Bot) findSimilarCommands(input string) []string {
 	return suggestions
 }
 
-func (bot *Bot) saveNewArticle(art *domain.Article, embedding []float64, sourceURL string) error {
+// Сохраняет статью в хранилище статей. При общем хранилище статья сначала сохраняется в локальную
+// базу, с которой работают сюжеты, поиск и повторный анализ, затем в общее. Если общее хранилище
+// статью не приняло, локальная запись удаляется, чтобы хранилища не расходились. ID статьи - локальный
+func (bot *Bot) storeArticle(ctx context.Context, article *domain.Article) error {
+	if err := bot.conf.GetDB().SaveArticle(ctx, article); err != nil {
+		return err
+	}
+
+	shared := bot.conf.DB.shared
+	if shared == nil {
+		return nil
+	}
+
+	copied := *article
+	if err := shared.SaveArticle(ctx, &copied); err != nil {
+		// Контекст мог быть отменен, а локальную запись нужно убрать в любом случае
+		if _, undoErr := bot.conf.GetDB().DeleteArticle(context.Background(), article.ID); undoErr != nil {
+			log.Printf("Не удалось удалить статью %d из локальной базы: %v", article.ID, undoErr)
+		}
+		article.ID = 0
+
+		return fmt.Errorf("не удалось сохранить статью в общее хранилище: %w", err)
+	}
+
+	return nil
+}
+
+// Учитывает цитирование оригинальной статьи. При общем хранилище оригинал найден в нем,
+// а в локальной базе обновляется та же статья, если ее сохранял этот экземпляр бота
+func (bot *Bot) recordCitation(ctx context.Context, original domain.Article, citingURL string) {
+	citeArticle(ctx, bot.conf.GetArticleStore(), original.ID, citingURL)
+
+	if bot.conf.DB.shared == nil {
+		return
+	}
+
+	local, err := bot.conf.GetDB().GetArticleByURL(ctx, original.SourceURL)
+	if err != nil {
+		log.Printf("Не удалось найти статью %s в локальной базе: %v", original.SourceURL, err)
+		return
+	}
+	if local != nil && local.Original {
+		citeArticle(ctx, bot.conf.GetDB(), local.ID, citingURL)
+	}
+}
+
+// Повторяет изменение статьи в общем хранилище, если оно используется. Статья находится
+// по URL источника, так как ID в хранилищах разные; статьи, которой в общем хранилище нет,
+// изменение не касается. Где возможно, вызывается до изменения локальной базы, чтобы при
+// ошибке общего хранилища обе базы остались прежними
+func (bot *Bot) mirrorArticle(ctx context.Context, sourceURL string, change func(store db.ArticleStore, id int64) error) error {
+	shared := bot.conf.DB.shared
+	if shared == nil || sourceURL == "" {
+		return nil
+	}
+
+	article, err := shared.GetArticleByURL(ctx, sourceURL)
+	if err != nil {
+		return fmt.Errorf("не удалось найти статью в общем хранилище: %w", err)
+	}
+	if article == nil {
+		return nil
+	}
+
+	if err := change(shared, article.ID); err != nil {
+		return fmt.Errorf("не удалось изменить статью в общем хранилище: %w", err)
+	}
+
+	return nil
+}
+
+// Удаляет статью из хранилищ. Возвращает false, если статьи не было
+func (bot *Bot) deleteArticle(ctx context.Context, id int64) (bool, error) {
+	article, err := bot.conf.GetDB().GetArticle(id)
+	if err != nil || article == nil {
+		return false, err
+	}
+
+	err = bot.mirrorArticle(ctx, article.SourceURL, func(store db.ArticleStore, id int64) error {
+		_, err := store.DeleteArticle(ctx, id)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return bot.conf.GetDB().DeleteArticle(ctx, id)
+}
+
+func citeArticle(ctx context.Context, store db.ArticleStore, articleID int64, citingURL string) {
+	// Добавляем ссылку на текущую статью в оригинальную
+	if err := store.AddSimilarURL(ctx, articleID, citingURL); err != nil {
+		log.Printf("ошибка добавления URL в оригинальную статью: %v", err)
+	}
+
+	// Инкремент цитирований
+	if err := store.IncrementCitation(ctx, articleID); err != nil {
+		log.Printf("ошибка инкремента количества цитирований: %v", err)
+	}
+}
+
+func (bot *Bot) saveNewArticle(ctx context.Context, art *domain.Article, embedding []float64, sourceURL string) error {
 	newArticle := &domain.Article{
 		Content:       art.Content,
 		Title:         art.Title,
@@ -103,9 +206,25 @@ func (bot *Bot) saveNewArticle(art *domain.Article, embedding []float64, sourceU
 		Affiliation:   art.Affiliation,
 		Sentiment:     art.Sentiment,
 		Justification: art.Justification,
+		Irrelevant:    art.Irrelevant,
+		Objects:       art.Objects,
+		Provenance:    art.Provenance,
+	}
+
+	if err := bot.storeArticle(ctx, newArticle); err != nil {
+		return err
+	}
+
+	art.ID = newArticle.ID
+	art.CreatedAt = newArticle.CreatedAt
+	art.SourceURL = newArticle.SourceURL
+
+	if _, err := bot.assignStory(ctx, newArticle); err != nil {
+		log.Printf("Не удалось определить сюжет статьи %s: %v", sourceURL, err)
 	}
+	art.StoryID = newArticle.StoryID
 
-	return bot.conf.GetDB().SaveArticle(newArticle)
+	return nil
 }
 
 func (bot *Bot) generateDuplicatesMessage(similar []domain.Article, original domain.Article) string {
@@ -117,6 +236,9 @@ func (bot *Bot) generateDuplicatesMessage(similar []domain.Article, original dom
 	for i, art := range similar {
 		msgText += fmt.Sprintf("%d. [\"%s\"](%s)\n", i+1, art.Title, art.SourceURL)
 		msgText += fmt.Sprintf("- Добавлена: %s\n", time.Unix(art.CreatedAt, 0).Format("2006-01-02 15:04"))
+		if art.TextSimilarity > 0 {
+			msgText += fmt.Sprintf("- Почти дословная копия: совпадение текста %.0f%%\n", art.TextSimilarity*100)
+		}
 		msgText += fmt.Sprintf("- Общая схожесть: %.0f%%\n", art.TrueSimilarity*100)
 		msgText += fmt.Sprintf("-- Схожесть текста: %.0f%%\n", similarity.CalculateEnhancedTextSimilarity(original.Content, art.Content)*100)
 		msgText += fmt.Sprintf("-- Схожесть векторов: %.0f%%\n", art.Similarity*100)
@@ -146,21 +268,33 @@ func (bot *Bot) sendMessage(chatID int64, text string, replyTo int) {
 	bot.api.Send(msg)
 }
 
-func (bot *Bot) notifyExactDuplicate(existingArticle *domain.Article) string {
+// Описание причины, по которой статья считается дубликатом
+func duplicateReasonText(reason string) string {
+	if reason == duplicateByURL {
+		return "тот же адрес"
+	}
+
+	return "тот же текст"
+}
+
+func (bot *Bot) notifyExactDuplicate(existingArticle *domain.Article, reason string) string {
 	msgText := fmt.Sprintf(`
-❌ Точный дубликат уже существует!
+❌ Точный дубликат уже существует (%s)!
 
 Оригинал:
 - *Заголовок:* %s
 - *URL:* [%s](%s)
 - *Добавлен:* %s
 - *Цитирований:* %d
+- *Анализ:* %s
 `,
+		duplicateReasonText(reason),
 		existingArticle.Title,
 		existingArticle.SourceURL,
 		existingArticle.SourceURL,
 		time.Unix(existingArticle.CreatedAt, 0).Format("2006-01-02 15:04"),
 		existingArticle.Citations,
+		provenanceSummary(existingArticle.Provenance),
 	)
 
 	return msgText
@@ -0,0 +1,173 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Имена отслеживаемых объектов в порядке конфигурации
+func (bot *Bot) objectNames() []string {
+	names := make([]string, 0, len(bot.conf.Analysis.Objects))
+	for _, object := range bot.conf.Analysis.Objects {
+		names = append(names, object.Name)
+	}
+
+	return names
+}
+
+// Разбивает аргументы команды по разделителю "|"
+func splitArgs(args string, n int) []string {
+	parts := strings.SplitN(args, "|", n)
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	return parts
+}
+
+func (bot *Bot) AddObject(call *CallContext) (string, error) {
+	parts := splitArgs(call.Args, 2)
+	name := parts[0]
+	if name == "" {
+		return "", errors.New("имя объекта не указано")
+	}
+
+	if existing := bot.conf.Analysis.ObjectByName(name); existing != nil {
+		return "", fmt.Errorf("объект \"%s\" уже отслеживается", existing.Name)
+	}
+
+	object := TrackedObject{
+		Name: name,
+	}
+	if len(parts) > 1 {
+		object.Metadata = parts[1]
+	}
+
+	bot.conf.Analysis.Objects = append(bot.conf.Analysis.Objects, object)
+	bot.conf.Update()
+
+	return fmt.Sprintf("Объект \"%s\" добавлен. Отслеживается объектов: %d", name, len(bot.conf.Analysis.Objects)), nil
+}
+
+func (bot *Bot) RemoveObject(call *CallContext) (string, error) {
+	name := strings.TrimSpace(call.Args)
+	if name == "" {
+		return "", errors.New("имя объекта не указано")
+	}
+
+	if len(bot.conf.Analysis.Objects) <= 1 {
+		return "", errors.New("нельзя удалить единственный объект. Используйте `changeobj`, чтобы его изменить")
+	}
+
+	var remaining []TrackedObject
+	var removed string
+	for _, object := range bot.conf.Analysis.Objects {
+		if removed == "" && strings.EqualFold(object.Name, name) {
+			removed = object.Name
+			continue
+		}
+		remaining = append(remaining, object)
+	}
+
+	if removed == "" {
+		return "", fmt.Errorf("объект \"%s\" не найден", name)
+	}
+
+	bot.conf.Analysis.Objects = remaining
+	bot.conf.Update()
+
+	return fmt.Sprintf("Объект \"%s\" больше не отслеживается. Ранее полученные результаты сохранены в базе", removed), nil
+}
+
+func (bot *Bot) ListObjects(call *CallContext) (string, error) {
+	if len(bot.conf.Analysis.Objects) == 0 {
+		return "Отслеживаемых объектов нет. Добавьте новый командой `addobject`", nil
+	}
+
+	var response strings.Builder
+	response.WriteString("*Отслеживаемые объекты:*\n")
+	for i, object := range bot.conf.Analysis.Objects {
+		response.WriteString(fmt.Sprintf("\n*%d. %s*", i+1, object.Name))
+		if i == 0 {
+			response.WriteString(" (основной)")
+		}
+		response.WriteString("\n")
+
+		if object.Metadata != "" {
+			response.WriteString(fmt.Sprintf("- Метаданные: %s\n", object.Metadata))
+		}
+		if object.Prompts.Affiliation != "" {
+			response.WriteString(fmt.Sprintf("- Промпт связи: `%s`\n", object.Prompts.Affiliation))
+		}
+		if object.Prompts.Sentiment != "" {
+			response.WriteString(fmt.Sprintf("- Промпт отношения: `%s`\n", object.Prompts.Sentiment))
+		}
+		if object.Prompts.Title != "" {
+			response.WriteString(fmt.Sprintf("- Промпт заголовка: `%s`\n", object.Prompts.Title))
+		}
+		if object.Prompts.Structured != "" {
+			response.WriteString(fmt.Sprintf("- Структурированный промпт: `%s`\n", object.Prompts.Structured))
+		}
+	}
+
+	return response.String(), nil
+}
+
+// Переопределяет промпт для конкретного объекта. Формат: "Имя | тип | промпт",
+// где тип - affiliation, sentiment, title или structured. Промпт "-" возвращает общий
+func (bot *Bot) SetObjectPrompt(call *CallContext) (string, error) {
+	parts := splitArgs(call.Args, 3)
+	if len(parts) < 3 || parts[0] == "" || parts[2] == "" {
+		return "", errors.New("укажите аргументы в формате \"объект | affiliation|sentiment|title|structured | промпт\"")
+	}
+
+	object := bot.conf.Analysis.ObjectByName(parts[0])
+	if object == nil {
+		return "", fmt.Errorf("объект \"%s\" не найден", parts[0])
+	}
+
+	prompt := parts[2]
+	if prompt == "-" {
+		prompt = ""
+	}
+
+	switch promptType(strings.ToLower(parts[1])) {
+	case PROMPT_AFFILIATION:
+		object.Prompts.Affiliation = prompt
+	case PROMPT_SENTIMENT:
+		object.Prompts.Sentiment = prompt
+	case PROMPT_TITLE:
+		object.Prompts.Title = prompt
+	case PROMPT_STRUCTURED:
+		object.Prompts.Structured = prompt
+	default:
+		return "", errors.New("неизвестный тип промпта. Допустимые: affiliation, sentiment, title, structured")
+	}
+
+	bot.conf.Update()
+
+	if prompt == "" {
+		return fmt.Sprintf("Для объекта \"%s\" снова используется общий промпт", object.Name), nil
+	}
+
+	return fmt.Sprintf("Промпт для объекта \"%s\" успешно применен", object.Name), nil
+}
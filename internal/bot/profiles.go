@@ -0,0 +1,359 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"Unbewohnte/ACASbot/internal/inference"
+	"Unbewohnte/ACASbot/internal/spreadsheet"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Область действия профиля вызвавшего: чат Telegram или пользователь веб-интерфейса
+func (c Caller) ProfileScope() string {
+	switch c.Platform {
+	case domain.PlatformTelegram:
+		if c.ChatID == 0 {
+			return ""
+		}
+		return domain.ProfileScope(domain.PlatformTelegram, strconv.FormatInt(c.ChatID, 10))
+	case domain.PlatformWeb:
+		if c.UserID == "" {
+			return ""
+		}
+		return domain.ProfileScope(domain.PlatformWeb, c.UserID)
+	default:
+		return ""
+	}
+}
+
+// Роль вызвавшего. У внутренних вызовов (ленты, очередь) роли нет
+func (bot *Bot) callerRole(caller Caller) domain.Role {
+	switch caller.Platform {
+	case domain.PlatformTelegram:
+		userID, err := strconv.ParseInt(caller.UserID, 10, 64)
+		if err != nil {
+			return domain.RoleNone
+		}
+		return bot.telegramRole(userID)
+	case domain.PlatformWeb:
+		return bot.webRole(caller.UserID)
+	default:
+		return domain.RoleNone
+	}
+}
+
+// Профиль по области действия или nil, если его нет
+func (bot *Bot) profileByScope(scope string) *domain.Profile {
+	if scope == "" {
+		return nil
+	}
+
+	profile, err := bot.conf.GetDB().GetProfile(scope)
+	if err != nil {
+		log.Printf("Не удалось получить профиль %s: %v", scope, err)
+		return nil
+	}
+
+	return profile
+}
+
+// Собирает контекст вызова команды: роль и профиль вызвавшего
+func (bot *Bot) newCall(caller Caller, args string) *CallContext {
+	return &CallContext{
+		Caller:  caller,
+		Role:    bot.callerRole(caller),
+		Args:    args,
+		Profile: bot.profileByScope(caller.ProfileScope()),
+	}
+}
+
+// Область действия профиля вызова, пустая при работе с общей конфигурацией
+func (call *CallContext) profileScope() string {
+	if call.Profile == nil {
+		return ""
+	}
+
+	return call.Profile.Scope
+}
+
+// Без профиля команда меняет общую конфигурацию, что доступно только администраторам
+func (call *CallContext) requireGlobalAdmin() error {
+	if call.Role.Allows(domain.RoleAdmin) {
+		return nil
+	}
+
+	return errors.New("изменение общей конфигурации доступно только администраторам. Создайте свой профиль командой `profile on`")
+}
+
+// Сохраняет измененный профиль вызова
+func (bot *Bot) saveProfile(call *CallContext) error {
+	if err := bot.conf.GetDB().SaveProfile(call.Profile); err != nil {
+		return fmt.Errorf("не удалось сохранить профиль: %w", err)
+	}
+
+	return nil
+}
+
+// Клиенты моделей, выбранных в профилях
+type modelCache struct {
+	mu      sync.Mutex
+	clients map[string]inference.Client
+}
+
+func (c *modelCache) get(options inference.Options) (inference.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := options.Backend + "|" + options.BaseURL + "|" + options.Model
+	if client, ok := c.clients[key]; ok {
+		return client, nil
+	}
+
+	client, err := inference.NewClient(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.clients == nil {
+		c.clients = make(map[string]inference.Client)
+	}
+	c.clients[key] = client
+
+	return client, nil
+}
+
+// Забывает созданные клиенты, например после смены бэкенда или таймаута
+func (c *modelCache) reset() {
+	c.mu.Lock()
+	c.clients = nil
+	c.mu.Unlock()
+}
+
+// Настройки анализа с учетом профиля: объекты, промпты, модель и Google таблица
+type analysisSettings struct {
+	objects []TrackedObject
+	prompts Prompts
+	model   inference.Client
+	sheet   *spreadsheet.GoogleSheetsClient
+}
+
+// Основной (первый) объект анализа
+func (s *analysisSettings) primaryObject() *TrackedObject {
+	if len(s.objects) == 0 {
+		return &TrackedObject{}
+	}
+
+	return &s.objects[0]
+}
+
+// Промпты профиля, дополненные общими там, где свои не заданы
+func profilePrompts(profile *domain.Profile, general Prompts) Prompts {
+	resolved := general
+	if profile.PromptAffiliation != "" {
+		resolved.Affiliation = profile.PromptAffiliation
+	}
+	if profile.PromptSentiment != "" {
+		resolved.Sentiment = profile.PromptSentiment
+	}
+	if profile.PromptTitle != "" {
+		resolved.Title = profile.PromptTitle
+	}
+	if profile.PromptStructured != "" {
+		resolved.Structured = profile.PromptStructured
+	}
+
+	return resolved
+}
+
+// Действующие настройки анализа. Без профиля используется общая конфигурация.
+// Объект профиля заменяет основной объект, остальные отслеживаемые объекты сохраняются
+func (bot *Bot) settingsFor(profile *domain.Profile) *analysisSettings {
+	// Копия списка, чтобы изменения конфигурации во время анализа не влияли на результат
+	settings := &analysisSettings{
+		objects: append([]TrackedObject(nil), bot.conf.Analysis.Objects...),
+		prompts: bot.conf.Ollama.Prompts,
+		model:   bot.model,
+		sheet:   bot.sheet,
+	}
+
+	if profile == nil {
+		return settings
+	}
+
+	settings.prompts = profilePrompts(profile, settings.prompts)
+
+	if profile.Object != "" || profile.Metadata != "" {
+		if len(settings.objects) == 0 {
+			settings.objects = append(settings.objects, TrackedObject{})
+		}
+
+		primary := settings.objects[0]
+		if profile.Object != "" && !strings.EqualFold(profile.Object, primary.Name) {
+			// Свои промпты прежнего объекта к другому объекту не относятся
+			primary = TrackedObject{Name: profile.Object}
+		}
+		if profile.Metadata != "" {
+			primary.Metadata = profile.Metadata
+		}
+		settings.objects[0] = primary
+	}
+
+	if profile.Model != "" && profile.Model != bot.model.Model() {
+		options := bot.conf.Ollama.ClientOptions()
+		options.Backend = bot.model.Backend()
+		options.Model = profile.Model
+
+		client, err := bot.profileModels.get(options)
+		if err != nil {
+			log.Printf("Не удалось подключить модель профиля %s (%s), используется общая: %v", profile.Scope, profile.Model, err)
+		} else {
+			settings.model = client
+		}
+	}
+
+	if bot.sheet != nil && (profile.SpreadsheetID != "" || profile.SheetName != "") {
+		sheet := *bot.sheet
+		if profile.SpreadsheetID != "" {
+			sheet.SpreadsheetID = profile.SpreadsheetID
+		}
+		if profile.SheetName != "" {
+			sheet.SheetName = profile.SheetName
+		}
+		settings.sheet = &sheet
+	}
+
+	return settings
+}
+
+// Значение из профиля или пометка о том, что используется общее. Пустой fallback не печатается
+func describeProfileValue(value string, fallback string) string {
+	if value == "" && fallback == "" {
+		return "— (общее)"
+	}
+	if value == "" {
+		return fmt.Sprintf("— (общее: `%s`)", fallback)
+	}
+
+	return fmt.Sprintf("`%s`", value)
+}
+
+func (bot *Bot) formatProfile(profile *domain.Profile) string {
+	var response strings.Builder
+
+	primary := bot.primaryObject()
+
+	response.WriteString(fmt.Sprintf("*Профиль %s*\n", profile.Scope))
+	response.WriteString(fmt.Sprintf("*Объект*: %s\n", describeProfileValue(profile.Object, primary.Name)))
+	response.WriteString(fmt.Sprintf("*Метаданные объекта*: %s\n", describeProfileValue(profile.Metadata, primary.Metadata)))
+	response.WriteString(fmt.Sprintf("*Модель*: %s\n", describeProfileValue(profile.Model, bot.model.Model())))
+	response.WriteString(fmt.Sprintf("*Промпт заголовка*: %s\n", describeProfileValue(profile.PromptTitle, "")))
+	response.WriteString(fmt.Sprintf("*Промпт связи с объектом*: %s\n", describeProfileValue(profile.PromptAffiliation, "")))
+	response.WriteString(fmt.Sprintf("*Промпт отношения к объекту*: %s\n", describeProfileValue(profile.PromptSentiment, "")))
+	response.WriteString(fmt.Sprintf("*Структурированный промпт*: %s\n", describeProfileValue(profile.PromptStructured, "")))
+	response.WriteString(fmt.Sprintf("*ID Google таблицы*: %s\n", describeProfileValue(profile.SpreadsheetID, bot.conf.Sheets.Google.Config.SpreadsheetID)))
+	response.WriteString(fmt.Sprintf("*Наименование листа таблицы*: %s\n", describeProfileValue(profile.SheetName, bot.conf.Sheets.Google.Config.SheetName)))
+
+	return response.String()
+}
+
+// Профиль текущего чата или пользователя. Без аргументов показывает профиль,
+// "on" создает пустой профиль, "off" удаляет его и возвращает общую конфигурацию
+func (bot *Bot) Profile(call *CallContext) (string, error) {
+	scope := call.Caller.ProfileScope()
+	if scope == "" {
+		return "", errors.New("профиль недоступен для этого вызова")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(call.Args)) {
+	case "":
+		if call.Profile == nil {
+			return "Профиль не создан, используется общая конфигурация. Создайте профиль командой `profile on`", nil
+		}
+		return bot.formatProfile(call.Profile), nil
+	case "on":
+		if call.Profile != nil {
+			return "Профиль уже создан\n\n" + bot.formatProfile(call.Profile), nil
+		}
+
+		call.Profile = &domain.Profile{Scope: scope}
+		if err := bot.saveProfile(call); err != nil {
+			return "", err
+		}
+		return "Профиль создан. Команды `changeobj`, `setobjectdata`, `setprompt*`, `setmodel`, `setsheetid` и `setsheetname` теперь меняют только его\n\n" +
+			bot.formatProfile(call.Profile), nil
+	case "off":
+		removed, err := bot.conf.GetDB().RemoveProfile(scope)
+		if err != nil {
+			return "", fmt.Errorf("не удалось удалить профиль: %w", err)
+		}
+		if !removed {
+			return "Профиль не был создан", nil
+		}
+		call.Profile = nil
+		return "Профиль удален, используется общая конфигурация", nil
+	default:
+		return "", errors.New("неизвестный аргумент. Допустимые: on, off или ничего")
+	}
+}
+
+func (bot *Bot) ListProfiles(call *CallContext) (string, error) {
+	profiles, err := bot.conf.GetDB().GetProfiles()
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить профили: %w", err)
+	}
+
+	if len(profiles) == 0 {
+		return "Профилей нет, все используют общую конфигурацию", nil
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("*Профили (%d):*\n", len(profiles)))
+	for _, profile := range profiles {
+		var overrides []string
+		if profile.Object != "" {
+			overrides = append(overrides, "объект "+profile.Object)
+		}
+		if profile.Metadata != "" {
+			overrides = append(overrides, "метаданные")
+		}
+		if profile.Model != "" {
+			overrides = append(overrides, "модель "+profile.Model)
+		}
+		if profile.PromptAffiliation != "" || profile.PromptSentiment != "" || profile.PromptTitle != "" || profile.PromptStructured != "" {
+			overrides = append(overrides, "промпты")
+		}
+		if profile.SpreadsheetID != "" || profile.SheetName != "" {
+			overrides = append(overrides, "Google таблица")
+		}
+
+		if len(overrides) == 0 {
+			response.WriteString(fmt.Sprintf("\n`%s`: без изменений", profile.Scope))
+		} else {
+			response.WriteString(fmt.Sprintf("\n`%s`: %s", profile.Scope, strings.Join(overrides, ", ")))
+		}
+	}
+
+	return response.String(), nil
+}
@@ -0,0 +1,190 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Промпты, отправленные модели при анализе одной статьи. Безопасен для
+// использования из нескольких горутин, nil - запись не нужна
+type promptLog struct {
+	mu   sync.Mutex
+	refs []domain.PromptRef
+}
+
+// Запоминает промпт, подготовленный из template для объекта. Текст статьи в хеш
+// не входит, поэтому одинаковые настройки дают одинаковый хеш для всех статей
+func (used *promptLog) record(kind promptType, template string, object *TrackedObject) {
+	if used == nil {
+		return
+	}
+
+	text := fillPrompt(template, TEMPLATE_TEXT, object)
+	sum := sha256.Sum256([]byte(text))
+	ref := domain.PromptRef{
+		Kind:   string(kind),
+		Object: object.Name,
+		Hash:   hex.EncodeToString(sum[:]),
+		Text:   text,
+	}
+
+	used.mu.Lock()
+	defer used.mu.Unlock()
+
+	for _, existing := range used.refs {
+		if existing.Kind == ref.Kind && existing.Object == ref.Object && existing.Hash == ref.Hash {
+			return
+		}
+	}
+	used.refs = append(used.refs, ref)
+}
+
+func (used *promptLog) prompts() []domain.PromptRef {
+	if used == nil {
+		return nil
+	}
+
+	used.mu.Lock()
+	defer used.mu.Unlock()
+
+	return append([]domain.PromptRef(nil), used.refs...)
+}
+
+// Тексты ошибок анализа для сохранения вместе со статьей
+func errorStrings(errs []error) []string {
+	var result []string
+	for _, err := range errs {
+		if err != nil {
+			result = append(result, err.Error())
+		}
+	}
+
+	return result
+}
+
+func formatSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.1f с", duration.Seconds())
+}
+
+// Подробное описание происхождения результата анализа
+func formatProvenance(provenance *domain.Provenance) string {
+	if provenance == nil {
+		return ""
+	}
+
+	var response strings.Builder
+	response.WriteString("*Происхождение:*\n")
+
+	if provenance.Model != "" {
+		response.WriteString(fmt.Sprintf("- Модель: `%s` (%s)\n", provenance.Model, provenance.Backend))
+	}
+
+	if method := provenance.ExtractionMethod(); method != "" {
+		response.WriteString(fmt.Sprintf("- Извлечение текста: %s\n", method))
+	}
+
+	if provenance.MaxContentSize > 0 {
+		if provenance.Truncated {
+			response.WriteString(fmt.Sprintf("- Лимит текста: %d символов (текст урезан)\n", provenance.MaxContentSize))
+		} else {
+			response.WriteString(fmt.Sprintf("- Лимит текста: %d символов\n", provenance.MaxContentSize))
+		}
+	}
+
+	var timings []string
+	if provenance.FetchMillis > 0 {
+		timings = append(timings, "загрузка "+formatSeconds(provenance.FetchDuration()))
+	}
+	if provenance.AnalysisMillis > 0 {
+		timings = append(timings, "LLM "+formatSeconds(provenance.AnalysisDuration()))
+	}
+	if len(timings) > 0 {
+		response.WriteString(fmt.Sprintf("- Время: %s\n", strings.Join(timings, ", ")))
+	}
+
+	for _, ref := range provenance.Prompts {
+		if ref.Kind == string(PROMPT_TITLE) || ref.Object == "" {
+			response.WriteString(fmt.Sprintf("- Промпт %s: `%s`\n", ref.Kind, ref.ShortHash()))
+		} else {
+			response.WriteString(fmt.Sprintf("- Промпт %s (%s): `%s`\n", ref.Kind, ref.Object, ref.ShortHash()))
+		}
+	}
+
+	if provenance.AnalyzedAt > 0 {
+		response.WriteString(fmt.Sprintf("- Проанализирована: %s\n", time.Unix(provenance.AnalyzedAt, 0).Format("2006-01-02 15:04")))
+	}
+
+	return response.String()
+}
+
+// Краткое описание происхождения в одну строку для списков статей
+func provenanceSummary(provenance *domain.Provenance) string {
+	if provenance == nil {
+		return "происхождение неизвестно"
+	}
+
+	var parts []string
+	if provenance.Model != "" {
+		parts = append(parts, fmt.Sprintf("`%s`", provenance.Model))
+	}
+	if method := provenance.ExtractionMethod(); method != "" {
+		parts = append(parts, method)
+	}
+	if provenance.MaxContentSize > 0 {
+		parts = append(parts, fmt.Sprintf("лимит %d", provenance.MaxContentSize))
+	}
+	if hashes := provenance.PromptHashes(); hashes != "" {
+		parts = append(parts, "промпты "+hashes)
+	}
+	if len(provenance.Errors) > 0 {
+		parts = append(parts, fmt.Sprintf("ошибок: %d", len(provenance.Errors)))
+	}
+
+	if len(parts) == 0 {
+		return "происхождение неизвестно"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Показывает полный текст промпта по хешу (или его началу) из происхождения статьи
+func (bot *Bot) ShowPrompt(call *CallContext) (string, error) {
+	hash := strings.TrimSpace(call.Args)
+	if len(hash) < 6 {
+		return "", errors.New("укажите хеш промпта (не менее 6 символов)")
+	}
+
+	fullHash, text, err := bot.conf.GetDB().GetPrompt(hash)
+	if err != nil {
+		return "", fmt.Errorf("не удалось найти промпт: %w", err)
+	}
+	if fullHash == "" {
+		return "", fmt.Errorf("промпт с хешем \"%s\" не найден", hash)
+	}
+
+	return fmt.Sprintf("*Промпт* `%s`:\n\n```\n%s\n```", fullHash, text), nil
+}
@@ -0,0 +1,256 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/db"
+	"Unbewohnte/ACASbot/internal/domain"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Сколько изменившихся результатов перечисляется в ответе
+const reanalyzeListedChanges = 10
+
+// Разбирает фильтры повторного анализа: all или поля вида ключ=значение.
+// Нерелевантные статьи не анализировались моделью, поэтому не выбираются.
+// Исправленные вручную статьи выбираются только с флагом verified
+func parseReanalyzeFilter(args string) (domain.ArticleFilter, error) {
+	irrelevant, verified := false, false
+	filter := domain.ArticleFilter{Irrelevant: &irrelevant, Verified: &verified}
+
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return filter, errors.New("укажите фильтры (from=, to=, host=, sentiment=, object=, ids=, limit=, verified) или all для всех статей")
+	}
+
+	for _, field := range fields {
+		if strings.EqualFold(field, "all") {
+			continue
+		}
+		if strings.EqualFold(field, "verified") {
+			filter.Verified = nil
+			continue
+		}
+
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return filter, fmt.Errorf("неверный фильтр \"%s\", ожидается ключ=значение", field)
+		}
+
+		switch strings.ToLower(key) {
+		case "from", "to":
+			end := strings.EqualFold(key, "to")
+			timestamp, err := parseFilterTime(value, end)
+			if err != nil {
+				return filter, fmt.Errorf("%s: %w", key, err)
+			}
+			if end {
+				filter.To = timestamp
+			} else {
+				filter.From = timestamp
+			}
+		case "host":
+			filter.Host = normalizeHost(value)
+		case "sentiment":
+			filter.Sentiment = value
+		case "object":
+			filter.Object = value
+		case "ids":
+			for _, part := range strings.Split(value, ",") {
+				id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(part), "#"), 10, 64)
+				if err != nil || id <= 0 {
+					return filter, fmt.Errorf("неверный ID статьи \"%s\"", part)
+				}
+				filter.IDs = append(filter.IDs, id)
+			}
+		case "limit":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit <= 0 {
+				return filter, errors.New("limit должен быть положительным числом")
+			}
+			filter.Limit = limit
+		default:
+			return filter, fmt.Errorf("неизвестный фильтр \"%s\"", key)
+		}
+	}
+
+	return filter, nil
+}
+
+// Имя сайта без схемы, пути и www
+func normalizeHost(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if parsed, err := url.Parse(value); err == nil && parsed.Host != "" {
+		value = parsed.Host
+	}
+	value, _, _ = strings.Cut(value, "/")
+
+	return strings.TrimPrefix(value, "www.")
+}
+
+// Прежнее отношение статьи к объекту. Для статей без результатов по объектам
+// используется результат, сохраненный в самой статье
+func previousSentiment(article *domain.Article, object string, primary bool) (string, bool) {
+	for _, result := range article.Objects {
+		if strings.EqualFold(result.Object, object) {
+			return result.Sentiment, true
+		}
+	}
+
+	if len(article.Objects) == 0 && primary && article.Sentiment != "" {
+		return article.Sentiment, true
+	}
+
+	return "", false
+}
+
+// Повторно анализирует сохраненные статьи текущими промптами и моделью без повторной загрузки.
+// Прежние результаты переносятся в историю
+func (bot *Bot) Reanalyze(call *CallContext) (string, error) {
+	filter, err := parseReanalyzeFilter(call.Args)
+	if err != nil {
+		return "", err
+	}
+
+	articles, _, err := bot.conf.GetDB().QueryArticles(filter)
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить статьи: %w", err)
+	}
+	if len(articles) == 0 {
+		return "Подходящих статей нет", nil
+	}
+
+	settings := bot.settingsFor(call.Profile)
+
+	var (
+		processed, failed, changed int
+		withoutContent             int
+		changes                    []string
+		interrupted                bool
+	)
+	for i := range articles {
+		if call.Context().Err() != nil {
+			interrupted = true
+			break
+		}
+
+		stored := &articles[i]
+
+		// Текст мог быть удален правилами хранения
+		if strings.TrimSpace(stored.Content) == "" {
+			withoutContent++
+			continue
+		}
+
+		call.Progress(fmt.Sprintf("🔁 %d/%d: %s", i+1, len(articles), stored.Title))
+
+		// Заголовок известен, поэтому модель спрашивается только об объектах
+		updated := &domain.Article{
+			ID:      stored.ID,
+			Title:   stored.Title,
+			Content: stored.Content,
+		}
+
+		// Текст прежний, поэтому сведения о его извлечении переносятся в новое происхождение
+		if stored.Provenance != nil {
+			updated.Provenance = &domain.Provenance{
+				Fetcher:        stored.Provenance.Fetcher,
+				Extractor:      stored.Provenance.Extractor,
+				FetchMillis:    stored.Provenance.FetchMillis,
+				MaxContentSize: stored.Provenance.MaxContentSize,
+				Truncated:      stored.Provenance.Truncated,
+			}
+		}
+		if err := bot.analyzeContent(call.Context(), updated, settings, nil); err != nil {
+			interrupted = true
+			break
+		}
+		if len(updated.Errors) > 0 {
+			log.Printf("Повторный анализ статьи %d не удался: %v", stored.ID, errors.Join(updated.Errors...))
+			failed++
+			continue
+		}
+
+		err := bot.mirrorArticle(call.Context(), stored.SourceURL, func(store db.ArticleStore, id int64) error {
+			shared := *updated
+			shared.ID = id
+			return store.ReplaceArticleAnalysis(call.Context(), &shared)
+		})
+		if err == nil {
+			err = bot.conf.GetDB().ReplaceArticleAnalysis(call.Context(), updated)
+		}
+		if err != nil {
+			if call.Context().Err() != nil {
+				interrupted = true
+				break
+			}
+			log.Printf("Не удалось сохранить повторный анализ статьи %d: %v", stored.ID, err)
+			failed++
+			continue
+		}
+		processed++
+
+		for index, result := range updated.Objects {
+			before, ok := previousSentiment(stored, result.Object, index == 0)
+			if !ok || before == result.Sentiment {
+				continue
+			}
+
+			changed++
+			if len(changes) < reanalyzeListedChanges {
+				changes = append(changes, fmt.Sprintf(
+					"#%d [\"%s\"](%s), %s: %s → %s",
+					stored.ID, stored.Title, stored.SourceURL, result.Object, before, result.Sentiment,
+				))
+			}
+		}
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("🔁 *Повторный анализ* (модель `%s`)\n", settings.model.Model()))
+	if interrupted {
+		response.WriteString("⛔ Прервано, сохранены результаты уже обработанных статей\n")
+	}
+	response.WriteString(fmt.Sprintf("*Выбрано статей:* %d\n", len(articles)))
+	response.WriteString(fmt.Sprintf("*Проанализировано:* %d\n", processed))
+	if failed > 0 {
+		response.WriteString(fmt.Sprintf("*С ошибками (прежний результат сохранен):* %d\n", failed))
+	}
+	if withoutContent > 0 {
+		response.WriteString(fmt.Sprintf("*Пропущено без текста (удален правилами хранения):* %d\n", withoutContent))
+	}
+	response.WriteString(fmt.Sprintf("*Изменилось отношение:* %d\n", changed))
+
+	if len(changes) > 0 {
+		response.WriteString("\n")
+		for _, change := range changes {
+			response.WriteString("- " + change + "\n")
+		}
+		if changed > len(changes) {
+			response.WriteString(fmt.Sprintf("...и еще %d\n", changed-len(changes)))
+		}
+	}
+
+	return response.String(), nil
+}
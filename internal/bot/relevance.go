@@ -0,0 +1,263 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"Unbewohnte/ACASbot/internal/similarity"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Кэш векторов описаний объектов, чтобы не запрашивать их для каждой статьи
+type embeddingCache struct {
+	mu      sync.Mutex
+	vectors map[string][]float64
+}
+
+func (c *embeddingCache) get(text string, compute func(string) ([]float64, error)) ([]float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if vector, ok := c.vectors[text]; ok {
+		return vector, nil
+	}
+
+	vector, err := compute(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.vectors == nil {
+		c.vectors = make(map[string][]float64)
+	}
+	c.vectors[text] = vector
+
+	return vector, nil
+}
+
+type relevanceResult struct {
+	Relevant    bool
+	KeywordHits uint
+	Similarity  float64
+	Reason      string
+}
+
+// Считает вхождения ключевых слов. Однословные ключи сравниваются как основы
+// (начало слова), фразы ищутся как подстроки
+func countKeywordHits(text string, keywords []string) uint {
+	text = strings.ToLower(text)
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var hits uint
+	for _, keyword := range keywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword == "" {
+			continue
+		}
+
+		if strings.Contains(keyword, " ") {
+			hits += uint(strings.Count(text, keyword))
+			continue
+		}
+
+		for _, word := range words {
+			if strings.HasPrefix(word, keyword) {
+				hits++
+			}
+		}
+	}
+
+	return hits
+}
+
+func objectDescription(object TrackedObject) string {
+	description := object.Name
+	if object.Metadata != "" {
+		description += ". " + object.Metadata
+	}
+
+	return description
+}
+
+// Проверяет, относится ли статья к объекту, прежде чем тратить запросы к LLM.
+// Статья считается релевантной, если проходит хотя бы одну из настроенных проверок
+func (bot *Bot) checkRelevance(ctx context.Context, art *domain.Article, objects []TrackedObject) relevanceResult {
+	conf := bot.conf.Analysis.Relevance
+	result := relevanceResult{Relevant: true}
+
+	if !conf.Enabled {
+		return result
+	}
+
+	checkKeywords := len(conf.Keywords) > 0
+	checkEmbedding := conf.UseEmbedding
+	if !checkKeywords && !checkEmbedding {
+		return result
+	}
+
+	var reasons []string
+	passed := false
+
+	if checkKeywords {
+		minHits := conf.MinKeywordHits
+		if minHits == 0 {
+			minHits = 1
+		}
+
+		result.KeywordHits = countKeywordHits(art.Title+" "+art.Content, conf.Keywords)
+		if result.KeywordHits >= minHits {
+			passed = true
+		}
+		reasons = append(reasons, fmt.Sprintf("ключевых слов %d из необходимых %d", result.KeywordHits, minHits))
+	}
+
+	if checkEmbedding && !passed {
+		if len(art.Embedding) == 0 {
+			embedding, err := bot.model.GetEmbedding(ctx, art.Content)
+			if err != nil {
+				// Не отбрасываем статью из-за ошибки векторизации
+				log.Printf("Не удалось векторизовать статью для проверки релевантности: %v", err)
+				return result
+			}
+			art.Embedding = embedding
+		}
+
+		// Статья релевантна, если достаточно похожа хотя бы на один объект
+		compared := false
+		for _, object := range objects {
+			objectVector, err := bot.objectEmbeddings.get(objectDescription(object), func(text string) ([]float64, error) {
+				return bot.model.GetEmbedding(ctx, text)
+			})
+			if err != nil {
+				log.Printf("Не удалось векторизовать описание объекта \"%s\": %v", object.Name, err)
+				continue
+			}
+
+			sim, err := similarity.CosineSimilarity(art.Embedding, objectVector)
+			if err != nil {
+				log.Printf("Не удалось сравнить статью с объектом \"%s\": %v", object.Name, err)
+				continue
+			}
+
+			compared = true
+			if sim > result.Similarity {
+				result.Similarity = sim
+			}
+		}
+
+		if !compared {
+			return result
+		}
+
+		if result.Similarity >= conf.EmbeddingThreshold {
+			passed = true
+		}
+		reasons = append(reasons, fmt.Sprintf("сходство с объектами %.2f при пороге %.2f", result.Similarity, conf.EmbeddingThreshold))
+	}
+
+	result.Relevant = passed
+	result.Reason = strings.Join(reasons, "; ")
+
+	return result
+}
+
+func (bot *Bot) ToggleRelevance(call *CallContext) (string, error) {
+	bot.conf.Analysis.Relevance.Enabled = !bot.conf.Analysis.Relevance.Enabled
+	bot.conf.Update()
+
+	if bot.conf.Analysis.Relevance.Enabled {
+		return "Проверка релевантности перед анализом включена.", nil
+	} else {
+		return "Проверка релевантности перед анализом выключена.", nil
+	}
+}
+
+func (bot *Bot) SetRelevanceKeywords(call *CallContext) (string, error) {
+	call.Args = strings.TrimSpace(call.Args)
+	if call.Args == "" {
+		return "", errors.New("не указаны ключевые слова")
+	}
+
+	var keywords []string
+	if call.Args != "-" {
+		for _, keyword := range strings.Split(call.Args, ",") {
+			keyword = strings.ToLower(strings.TrimSpace(keyword))
+			if keyword != "" {
+				keywords = append(keywords, keyword)
+			}
+		}
+	}
+
+	bot.conf.Analysis.Relevance.Keywords = keywords
+	bot.conf.Update()
+
+	if len(keywords) == 0 {
+		return "Список ключевых слов очищен", nil
+	}
+
+	return fmt.Sprintf("Ключевые слова обновлены: %s", strings.Join(keywords, ", ")), nil
+}
+
+func (bot *Bot) SetMinKeywordHits(call *CallContext) (string, error) {
+	if call.Args == "" {
+		return "", errors.New("не указано новое значение")
+	}
+
+	hits, err := strconv.ParseUint(strings.TrimSpace(call.Args), 10, 64)
+	if err != nil || hits == 0 {
+		return "", errors.New("указано некорректное значение. Необходимо указать значение > 0")
+	}
+
+	bot.conf.Analysis.Relevance.MinKeywordHits = uint(hits)
+	bot.conf.Update()
+
+	return fmt.Sprintf("Минимальное количество вхождений ключевых слов изменено на %d", hits), nil
+}
+
+func (bot *Bot) SetRelevanceThreshold(call *CallContext) (string, error) {
+	if call.Args == "" {
+		return "", errors.New("не указано новое значение")
+	}
+
+	threshold, err := strconv.ParseFloat(strings.TrimSpace(call.Args), 64)
+	if err != nil || threshold < 0 || threshold > 1.0 {
+		return "", errors.New("некорректное значение. Используйте число от 0.0 до 1.0")
+	}
+
+	if threshold == 0 {
+		bot.conf.Analysis.Relevance.UseEmbedding = false
+		bot.conf.Update()
+		return "Проверка векторного сходства с объектом отключена", nil
+	}
+
+	bot.conf.Analysis.Relevance.UseEmbedding = true
+	bot.conf.Analysis.Relevance.EmbeddingThreshold = threshold
+	bot.conf.Update()
+
+	return fmt.Sprintf("Порог сходства статьи с объектом изменен на %.2f (%.0f%%)", threshold, threshold*100.0), nil
+}
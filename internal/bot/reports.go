@@ -0,0 +1,305 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"Unbewohnte/ACASbot/internal/report"
+	"Unbewohnte/ACASbot/internal/schedule"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Сводка по статьям, добавленным в период [from, to)
+func (bot *Bot) buildDigest(title string, from time.Time, to time.Time) (*report.Digest, error) {
+	articles, _, err := bot.conf.GetDB().QueryArticles(domain.ArticleFilter{
+		AddedFrom: from.Unix(),
+		AddedTo:   to.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report.Build(articles, from, to, report.Options{
+		Title:       title,
+		TopCited:    int(bot.conf.Reports.TopCited),
+		MaxNegative: int(bot.conf.Reports.MaxNegative),
+		MaxHosts:    int(bot.conf.Reports.MaxHosts),
+	}), nil
+}
+
+// Отправляет сводку в Telegram (Markdown) и веб-интерфейс (HTML)
+func (bot *Bot) publishDigest(digest *report.Digest) {
+	if bot.conf.Reports.ChatID != 0 {
+		bot.sendMessage(bot.conf.Reports.ChatID, digest.Markdown(), 0)
+	}
+
+	html, err := digest.HTML()
+	if err != nil {
+		log.Printf("Не удалось сформировать HTML сводки: %v", err)
+		return
+	}
+	bot.server.SendReport(html)
+}
+
+func (bot *Bot) runScheduledReport(s ReportSchedule, at time.Time) {
+	period := time.Duration(s.PeriodHours) * time.Hour
+	if period == 0 {
+		period = 24 * time.Hour
+	}
+
+	digest, err := bot.buildDigest(s.Name, at.Add(-period), at)
+	if err != nil {
+		log.Printf("Не удалось сформировать сводку \"%s\": %v", s.Name, err)
+		return
+	}
+
+	bot.publishDigest(digest)
+	log.Printf("Сводка \"%s\" отправлена: %d статей", s.Name, digest.Total)
+}
+
+// Проверяет расписания сводок и отправляет те, время которых подошло
+func (bot *Bot) StartReportScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		// Время следующего запуска по cron-выражению
+		next := make(map[string]time.Time)
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if !bot.conf.Reports.Enabled {
+					clear(next)
+					continue
+				}
+
+				for _, s := range bot.conf.Reports.Schedules {
+					cron, err := schedule.Parse(s.Cron)
+					if err != nil {
+						continue
+					}
+
+					key := s.Name + "|" + s.Cron
+					at, planned := next[key]
+					if !planned {
+						next[key] = cron.Next(now)
+						continue
+					}
+
+					if !now.Before(at) {
+						next[key] = cron.Next(now)
+						go bot.runScheduledReport(s, at)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Разбирает границу периода. Дата без времени в качестве конца периода включает весь день
+func parseReportTime(value string, end bool) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("неверная дата \"%s\". Используйте YYYY-MM-DD или \"YYYY-MM-DDTHH:MM\"", value)
+}
+
+// Граница from= или to= фильтра статей по дате публикации. Время to - последняя секунда
+// периода, поэтому to с датой без времени включает весь день. Unix время берется как есть
+func parseFilterTime(value string, end bool) (int64, error) {
+	if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return timestamp, nil
+	}
+
+	t, err := parseReportTime(value, end)
+	if err != nil {
+		return 0, err
+	}
+	if end {
+		return t.Unix() - 1, nil
+	}
+
+	return t.Unix(), nil
+}
+
+// Сводка по запросу. Без аргументов - за последние сутки, с одной датой - от нее до текущего момента
+// Сводка за период. Веб-интерфейс показывает ее в HTML по структурированным данным
+func (bot *Bot) Report(call *CallContext) (*Response, error) {
+	from, to, err := bot.reportPeriod(call.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := bot.buildDigest("Сводка", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сформировать сводку: %w", err)
+	}
+
+	return &Response{Text: digest.Markdown(), Data: digest}, nil
+}
+
+func (bot *Bot) reportPeriod(args string) (time.Time, time.Time, error) {
+	now := time.Now()
+	parts := strings.Fields(args)
+
+	from, to := now.Add(-24*time.Hour), now
+	var err error
+	if len(parts) > 0 {
+		if from, err = parseReportTime(parts[0], false); err != nil {
+			return from, to, err
+		}
+	}
+	if len(parts) > 1 {
+		if to, err = parseReportTime(parts[1], true); err != nil {
+			return from, to, err
+		}
+	}
+
+	if !from.Before(to) {
+		return from, to, errors.New("начало периода должно быть раньше конца")
+	}
+
+	return from, to, nil
+}
+
+func (bot *Bot) ListReports(call *CallContext) (string, error) {
+	var response strings.Builder
+	response.WriteString("*Расписание сводок:*\n")
+
+	if len(bot.conf.Reports.Schedules) == 0 {
+		response.WriteString("\nРасписаний нет. Добавьте новое командой `addreport`\n")
+	}
+
+	now := time.Now()
+	for i, s := range bot.conf.Reports.Schedules {
+		response.WriteString(fmt.Sprintf("\n*%d.* %s\n", i+1, s.Name))
+		response.WriteString(fmt.Sprintf("- Расписание: `%s`, период: %d ч.\n", s.Cron, s.PeriodHours))
+
+		cron, err := schedule.Parse(s.Cron)
+		if err != nil {
+			response.WriteString(fmt.Sprintf("- ⚠️ Ошибка: %s\n", err.Error()))
+			continue
+		}
+		response.WriteString(fmt.Sprintf("- Следующая: %s\n", cron.Next(now).Format("2006-01-02 15:04")))
+	}
+
+	if bot.conf.Reports.ChatID == 0 {
+		response.WriteString("\n⚠️ Чат для сводок не указан (`setreportchat`), они отправляются только в веб-интерфейс")
+	}
+	if !bot.conf.Reports.Enabled {
+		response.WriteString("\n⚠️ Отправка сводок выключена (`togglereports`)")
+	}
+
+	return response.String(), nil
+}
+
+// Формат: "cron | часов | название"
+func (bot *Bot) AddReport(call *CallContext) (string, error) {
+	parts := splitArgs(call.Args, 3)
+	if parts[0] == "" {
+		return "", errors.New("укажите расписание в формате \"cron | период в часах | название\"")
+	}
+
+	cron, err := schedule.Parse(parts[0])
+	if err != nil {
+		return "", err
+	}
+
+	s := ReportSchedule{
+		Name:        "Сводка",
+		Cron:        cron.String(),
+		PeriodHours: 24,
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		hours, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil || hours == 0 {
+			return "", errors.New("неверный период. Укажите число часов > 0")
+		}
+		s.PeriodHours = uint(hours)
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		s.Name = parts[2]
+	}
+
+	bot.conf.Reports.Schedules = append(bot.conf.Reports.Schedules, s)
+	bot.conf.Update()
+
+	return fmt.Sprintf(
+		"Сводка \"%s\" добавлена под номером %d. Следующая отправка: %s",
+		s.Name, len(bot.conf.Reports.Schedules), cron.Next(time.Now()).Format("2006-01-02 15:04"),
+	), nil
+}
+
+func (bot *Bot) RemoveReport(call *CallContext) (string, error) {
+	index, err := strconv.Atoi(strings.TrimSpace(call.Args))
+	if err != nil || index < 1 || index > len(bot.conf.Reports.Schedules) {
+		return "", errors.New("неверный номер расписания. Посмотреть номера: `reports`")
+	}
+
+	removed := bot.conf.Reports.Schedules[index-1]
+	bot.conf.Reports.Schedules = append(bot.conf.Reports.Schedules[:index-1], bot.conf.Reports.Schedules[index:]...)
+	bot.conf.Update()
+
+	return fmt.Sprintf("Сводка \"%s\" удалена", removed.Name), nil
+}
+
+func (bot *Bot) ToggleReports(call *CallContext) (string, error) {
+	bot.conf.Reports.Enabled = !bot.conf.Reports.Enabled
+	bot.conf.Update()
+
+	if bot.conf.Reports.Enabled {
+		return "Отправка сводок по расписанию включена", nil
+	} else {
+		return "Отправка сводок по расписанию выключена", nil
+	}
+}
+
+func (bot *Bot) SetReportChat(call *CallContext) (string, error) {
+	if call.Args == "" {
+		return "", errors.New("не указан ID чата")
+	}
+
+	chatID, err := strconv.ParseInt(strings.TrimSpace(call.Args), 10, 64)
+	if err != nil {
+		return "", errors.New("неверный ID чата")
+	}
+
+	bot.conf.Reports.ChatID = chatID
+	bot.conf.Update()
+
+	if chatID == 0 {
+		return "Сводки будут отправляться только в веб-интерфейс", nil
+	}
+
+	return fmt.Sprintf("Сводки будут отправляться в чат %d", chatID), nil
+}
@@ -0,0 +1,115 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"os"
+	"strings"
+)
+
+// Файл, присланный вместе с командой
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
+// Файл в ответе команды. Берется с диска по Path или из Data
+type ResponseFile struct {
+	Name    string
+	Caption string
+	Path    string
+	Data    []byte
+}
+
+// Размер файла в байтах
+func (f *ResponseFile) Size() (int64, error) {
+	if f.Path == "" {
+		return int64(len(f.Data)), nil
+	}
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+// Содержимое файла
+func (f *ResponseFile) Bytes() ([]byte, error) {
+	if f.Path == "" {
+		return f.Data, nil
+	}
+
+	return os.ReadFile(f.Path)
+}
+
+// Ответ команды. Telegram и веб-интерфейс отображают его одинаково:
+// текст в Markdown, затем файлы. Структурированные данные используются тем интерфейсом,
+// который умеет их показать, например сводка в веб-интерфейсе выводится в HTML
+type Response struct {
+	Text  string
+	Files []ResponseFile
+	Data  any
+}
+
+// Структурированные данные, которые веб-интерфейс может показать в виде HTML
+type htmlRenderer interface {
+	HTML() (string, error)
+}
+
+// Приводит обработчик, возвращающий только текст, к общему виду
+func textCommand(handler func(call *CallContext) (string, error)) func(call *CallContext) (*Response, error) {
+	return func(call *CallContext) (*Response, error) {
+		text, err := handler(call)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Response{Text: text}, nil
+	}
+}
+
+// Сообщает вызвавшему о ходе выполнения команды. Вне Telegram и веб-интерфейса ничего не делает
+func (call *CallContext) Progress(text string) {
+	if call.progress != nil {
+		call.progress(text)
+	}
+}
+
+// Передает вызвавшему очередную часть ответа модели по мере ее генерации
+func (call *CallContext) Stream(chunk string) {
+	if call.stream != nil {
+		call.stream(chunk)
+	}
+}
+
+// Вложение с одним из расширений или nil
+func (call *CallContext) attachment(extensions ...string) *Attachment {
+	for i := range call.Attachments {
+		name := strings.ToLower(call.Attachments[i].Name)
+		for _, extension := range extensions {
+			if strings.HasSuffix(name, extension) {
+				return &call.Attachments[i]
+			}
+		}
+	}
+
+	return nil
+}
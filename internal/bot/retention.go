@@ -0,0 +1,409 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/db"
+	"Unbewohnte/ACASbot/internal/domain"
+	"Unbewohnte/ACASbot/internal/schedule"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Статья в архиве: поля как в API вместе с текстом и вектором
+type archivedArticle struct {
+	apiArticle
+	Embedding []float64 `json:"embedding,omitempty"`
+}
+
+// Записывает статьи, которые перебирает each, в сжатый JSONL файл <prefix>-<время>.jsonl.gz
+// (при совпадении времени - <prefix>-<время>-<номер>.jsonl.gz) в каталоге dir. Файл появляется только после успешной записи всех статей.
+// Возвращает путь к архиву (пусто, если статей не было) и количество статей
+func writeArchive(dir string, prefix string, each func(fn func(*domain.Article) error) error) (string, int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", 0, err
+	}
+
+	file, err := os.CreateTemp(dir, prefix+"-*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(file.Name())
+
+	compressed := gzip.NewWriter(file)
+	encoder := json.NewEncoder(compressed)
+
+	count := 0
+	err = each(func(article *domain.Article) error {
+		count++
+		return encoder.Encode(archivedArticle{
+			apiArticle: newAPIArticle(article, true),
+			Embedding:  article.Embedding,
+		})
+	})
+	if err == nil {
+		err = compressed.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || count == 0 {
+		return "", 0, err
+	}
+
+	// Архивы правил хранения и forget могут записываться одновременно: имя занимается
+	// заранее, и готовый архив заменяет пустой файл
+	path, err := reservePath(dir, prefix+"-"+time.Now().Format("20060102-150405"), ".jsonl.gz")
+	if err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		os.Remove(path)
+		return "", 0, err
+	}
+
+	return path, count, nil
+}
+
+// Правила хранения относительно момента now
+func (bot *Bot) retentionPolicy(now time.Time) db.RetentionPolicy {
+	var policy db.RetentionPolicy
+	if days := bot.conf.Retention.DropEmbeddingsDays; days > 0 {
+		policy.EmbeddingsBefore = now.AddDate(0, 0, -int(days)).Unix()
+	}
+	if days := bot.conf.Retention.DropContentDays; days > 0 {
+		policy.ContentBefore = now.AddDate(0, 0, -int(days)).Unix()
+	}
+
+	return policy
+}
+
+// Результат применения правил хранения
+type retentionResult struct {
+	Counts   db.RetentionCounts
+	Archive  string // Путь к архиву, пусто - архив не записывался
+	Archived int
+}
+
+// Архивирует затрагиваемые статьи (если задан каталог архивов) и применяет правила хранения.
+// Если архив записать не удалось, данные не удаляются
+func (bot *Bot) applyRetention(ctx context.Context) (*retentionResult, error) {
+	database := bot.conf.GetDB()
+	policy := bot.retentionPolicy(time.Now())
+	result := &retentionResult{}
+
+	if dir := bot.conf.Retention.ArchiveDir; dir != "" {
+		var err error
+		result.Archive, result.Archived, err = writeArchive(dir, "retention", func(fn func(*domain.Article) error) error {
+			return database.EachRetainedArticle(ctx, policy, fn)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("не удалось записать архив: %w", err)
+		}
+	}
+
+	if bot.conf.DB.shared != nil {
+		err := database.EachRetainedArticle(ctx, policy, func(article *domain.Article) error {
+			embedding := policy.EmbeddingsBefore > 0 && article.CreatedAt < policy.EmbeddingsBefore && len(article.Embedding) > 0
+			content := policy.ContentBefore > 0 && article.CreatedAt < policy.ContentBefore && article.Content != ""
+			return bot.mirrorArticle(ctx, article.SourceURL, func(store db.ArticleStore, id int64) error {
+				return store.ClearArticleData(ctx, id, embedding, content)
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	counts, err := database.ApplyRetention(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+	result.Counts = counts
+
+	return result, nil
+}
+
+func formatRetentionResult(result *retentionResult) string {
+	message := fmt.Sprintf("🗄 Правила хранения применены: удалено векторов - %d, текстов - %d",
+		result.Counts.Embeddings, result.Counts.Content,
+	)
+	if result.Archive != "" {
+		message += fmt.Sprintf("\nАрхив: `%s` (%d статей)", result.Archive, result.Archived)
+	}
+
+	return message
+}
+
+// Применяет правила хранения по расписанию
+func (bot *Bot) StartRetentionScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		var (
+			next    time.Time
+			planned string // Выражение, по которому рассчитан next
+		)
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if !bot.conf.Retention.Enabled {
+					next = time.Time{}
+					continue
+				}
+
+				cron, err := schedule.Parse(bot.conf.Retention.Cron)
+				if err != nil {
+					continue
+				}
+
+				if next.IsZero() || planned != bot.conf.Retention.Cron {
+					next = cron.Next(now)
+					planned = bot.conf.Retention.Cron
+					continue
+				}
+
+				if now.Before(next) {
+					continue
+				}
+				next = cron.Next(now)
+
+				result, err := bot.applyRetention(context.Background())
+				if err != nil {
+					log.Printf("Не удалось применить правила хранения: %v", err)
+					continue
+				}
+				log.Println(formatRetentionResult(result))
+			}
+		}
+	}()
+}
+
+// Правила хранения: без аргументов - сколько статей они затронут, apply - применить сейчас
+func (bot *Bot) Retention(call *CallContext) (string, error) {
+	conf := bot.conf.Retention
+	policy := bot.retentionPolicy(time.Now())
+	if policy == (db.RetentionPolicy{}) {
+		return "Правила хранения не заданы: drop_embeddings_days и drop_content_days равны 0", nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(call.Args)) {
+	case "":
+	case "apply":
+		result, err := bot.applyRetention(call.Context())
+		if err != nil {
+			return "", err
+		}
+		return formatRetentionResult(result), nil
+	default:
+		return "", errors.New("неизвестный аргумент. Используйте retention для просмотра или retention apply для применения")
+	}
+
+	counts, err := bot.conf.GetDB().PreviewRetention(call.Context(), policy)
+	if err != nil {
+		return "", fmt.Errorf("не удалось подсчитать статьи: %w", err)
+	}
+
+	var response strings.Builder
+	response.WriteString("🗄 *Правила хранения*\n")
+	if conf.DropEmbeddingsDays > 0 {
+		response.WriteString(fmt.Sprintf("- Векторы статей старше %d дней: будут удалены у %d статей\n", conf.DropEmbeddingsDays, counts.Embeddings))
+	}
+	if conf.DropContentDays > 0 {
+		response.WriteString(fmt.Sprintf("- Тексты статей старше %d дней: будут удалены у %d статей, метаданные и результаты анализа останутся\n", conf.DropContentDays, counts.Content))
+	}
+	if conf.ArchiveDir != "" {
+		response.WriteString(fmt.Sprintf("- Перед удалением %d статей будут сохранены в архив в каталоге `%s`\n", counts.Articles, conf.ArchiveDir))
+	} else {
+		response.WriteString("- Архив не ведется (archive_dir не задан)\n")
+	}
+
+	if conf.Enabled {
+		if cron, err := schedule.Parse(conf.Cron); err == nil {
+			response.WriteString(fmt.Sprintf("- По расписанию `%s`, следующий запуск: %s\n", conf.Cron, cron.Next(time.Now()).Format("2006-01-02 15:04")))
+		} else {
+			response.WriteString(fmt.Sprintf("- ⚠️ Неверное расписание `%s`: %v\n", conf.Cron, err))
+		}
+	} else {
+		response.WriteString("- По расписанию не применяются\n")
+	}
+
+	if conf.DropEmbeddingsDays > 0 && conf.DropEmbeddingsDays < bot.conf.Analysis.DaysLookback {
+		response.WriteString(fmt.Sprintf(
+			"\n⚠️ Векторы удаляются раньше, чем заканчивается окно поиска похожих статей (%d дней): такие статьи не найдутся как похожие\n",
+			bot.conf.Analysis.DaysLookback,
+		))
+	}
+
+	response.WriteString("\nПрименить сейчас: `retention apply`")
+
+	return response.String(), nil
+}
+
+// Разбирает фильтры удаления статей: all или from=, to= (дата публикации) и host=,
+// а также код подтверждения confirm=
+func parseForgetArgs(args string) (domain.ArticleFilter, string, error) {
+	var (
+		filter  domain.ArticleFilter
+		confirm string
+		all     bool
+	)
+
+	fields := strings.Fields(args)
+	for _, field := range fields {
+		if strings.EqualFold(field, "all") {
+			all = true
+			continue
+		}
+
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return filter, "", fmt.Errorf("неверный фильтр \"%s\", ожидается ключ=значение", field)
+		}
+
+		switch strings.ToLower(key) {
+		case "from", "to":
+			end := strings.EqualFold(key, "to")
+			timestamp, err := parseFilterTime(value, end)
+			if err != nil {
+				return filter, "", fmt.Errorf("%s: %w", key, err)
+			}
+			if end {
+				filter.To = timestamp
+			} else {
+				filter.From = timestamp
+			}
+		case "host":
+			filter.Host = normalizeHost(value)
+		case "confirm":
+			confirm = value
+		default:
+			return filter, "", fmt.Errorf("неизвестный фильтр \"%s\"", key)
+		}
+	}
+
+	if !all && filter.From == 0 && filter.To == 0 && filter.Host == "" {
+		return filter, "", errors.New("укажите фильтры from=, to=, host= или all для удаления всех статей")
+	}
+
+	return filter, confirm, nil
+}
+
+// Код подтверждения удаления. Меняется вместе с фильтрами и количеством подходящих статей,
+// поэтому подтверждение не удалит больше, чем было показано
+func forgetCode(filter domain.ArticleFilter, total int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%d|%s|%d", filter.From, filter.To, filter.Host, total)))
+	return hex.EncodeToString(sum[:3])
+}
+
+// Удаляет статьи по датам публикации и сайту. Первый вызов показывает, сколько статей
+// будет удалено, и код подтверждения; удаление выполняется повторным вызовом с confirm=код.
+// Если задан каталог архивов, статьи сначала сохраняются в архив
+func (bot *Bot) Forget(call *CallContext) (string, error) {
+	filter, confirm, err := parseForgetArgs(call.Args)
+	if err != nil {
+		return "", err
+	}
+
+	database := bot.conf.GetDB()
+
+	_, total, err := database.QueryArticles(domain.ArticleFilter{
+		From: filter.From,
+		To:   filter.To,
+		Host: filter.Host,
+		// Количество считается по всей выборке, сами статьи не нужны
+		Limit: 1,
+	})
+	if err != nil {
+		return "", fmt.Errorf("не удалось подсчитать статьи: %w", err)
+	}
+	if total == 0 {
+		return "Подходящих статей нет", nil
+	}
+
+	code := forgetCode(filter, total)
+	if confirm != code {
+		var args []string
+		for _, field := range strings.Fields(call.Args) {
+			if !strings.HasPrefix(strings.ToLower(field), "confirm=") {
+				args = append(args, field)
+			}
+		}
+
+		var response strings.Builder
+		if confirm != "" {
+			response.WriteString("❌ Код подтверждения не подходит: он неверен или набор статей изменился\n\n")
+		}
+		response.WriteString(fmt.Sprintf("⚠️ Будет удалено статей: %d, вместе с результатами анализа и историей\n", total))
+		if dir := bot.conf.Retention.ArchiveDir; dir != "" {
+			response.WriteString(fmt.Sprintf("Перед удалением они будут сохранены в архив в каталоге `%s`\n", dir))
+		} else {
+			response.WriteString("Архив не ведется (archive_dir не задан), удаление необратимо\n")
+		}
+		response.WriteString(fmt.Sprintf("\nДля подтверждения: `forget %s confirm=%s`", strings.Join(args, " "), code))
+
+		return response.String(), nil
+	}
+
+	var (
+		archive  string
+		archived int
+	)
+	if dir := bot.conf.Retention.ArchiveDir; dir != "" {
+		archive, archived, err = writeArchive(dir, "forget", func(fn func(*domain.Article) error) error {
+			return database.EachArticle(call.Context(), filter, fn)
+		})
+		if err != nil {
+			return "", fmt.Errorf("не удалось записать архив, статьи не удалены: %w", err)
+		}
+	}
+
+	if bot.conf.DB.shared != nil {
+		err := database.EachArticle(call.Context(), filter, func(article *domain.Article) error {
+			return bot.mirrorArticle(call.Context(), article.SourceURL, func(store db.ArticleStore, id int64) error {
+				_, err := store.DeleteArticle(call.Context(), id)
+				return err
+			})
+		})
+		if err != nil {
+			return "", fmt.Errorf("локальная база не изменена: %w", err)
+		}
+	}
+
+	deleted, err := database.DeleteArticles(call.Context(), filter)
+	if err != nil {
+		return "", fmt.Errorf("не удалось удалить статьи: %w", err)
+	}
+
+	response := fmt.Sprintf("🗑 Удалено статей: %d", deleted)
+	if archive != "" {
+		response += fmt.Sprintf("\nАрхив: `%s` (%d статей)", archive, archived)
+	}
+
+	return response, nil
+}
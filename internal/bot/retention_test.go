@@ -0,0 +1,129 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteArchiveNames(t *testing.T) {
+	dir := t.TempDir()
+	each := func(fn func(*domain.Article) error) error {
+		return fn(&domain.Article{ID: 1, SourceURL: "https://example.com/news/1"})
+	}
+
+	// Архивы, записанные в одну секунду, не перезаписывают друг друга
+	const count = 5
+	paths := make([]string, count)
+	var wg sync.WaitGroup
+	for i := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path, archived, err := writeArchive(dir, "forget", each)
+			if err != nil || archived != 1 {
+				t.Errorf("архив не записан: %d, %v", archived, err)
+			}
+			paths[i] = path
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if seen[path] {
+			t.Fatalf("архив %s записан дважды", path)
+		}
+		seen[path] = true
+
+		info, err := os.Stat(path)
+		if err != nil || info.Size() == 0 {
+			t.Fatalf("архив %s пуст: %v", path, err)
+		}
+	}
+
+	// Временные файлы удалены, лишних пустых файлов нет
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != count {
+		t.Fatalf("в каталоге %d файлов, ожидалось %d", len(entries), count)
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "forget-") || !strings.HasSuffix(entry.Name(), ".jsonl.gz") {
+			t.Fatalf("лишний файл %s", entry.Name())
+		}
+	}
+
+	// Без статей архив не создается
+	path, archived, err := writeArchive(filepath.Join(dir, "empty"), "retention", func(fn func(*domain.Article) error) error { return nil })
+	if err != nil || path != "" || archived != 0 {
+		t.Fatalf("пустой архив: %q, %d, %v", path, archived, err)
+	}
+}
+
+func TestFilterPeriod(t *testing.T) {
+	day := time.Date(2025, 6, 1, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		args     string
+		from, to int64
+	}{
+		// Дата в to включает весь день
+		{"from=2025-06-01 to=2025-06-01", day.Unix(), day.AddDate(0, 0, 1).Unix() - 1},
+		{"to=2025-06-01T12:30", 0, day.Add(12*time.Hour+30*time.Minute).Unix() - 1},
+		{"from=1748736000 to=1748822400", 1748736000, 1748822400},
+	}
+
+	for _, test := range tests {
+		t.Run(test.args, func(t *testing.T) {
+			// reanalyze и forget понимают период одинаково
+			reanalyze, err := parseReanalyzeFilter(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			forget, _, err := parseForgetArgs(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, filter := range map[string]domain.ArticleFilter{"reanalyze": reanalyze, "forget": forget} {
+				if filter.From != test.from || filter.To != test.to {
+					t.Errorf("%s: период %d-%d, ожидалось %d-%d", name, filter.From, filter.To, test.from, test.to)
+				}
+			}
+		})
+	}
+
+	for _, args := range []string{"to=вчера", "from=2025-13-01"} {
+		if _, err := parseReanalyzeFilter(args); err == nil {
+			t.Errorf("reanalyze %s: ошибки нет", args)
+		}
+		if _, _, err := parseForgetArgs(args); err == nil {
+			t.Errorf("forget %s: ошибки нет", args)
+		}
+	}
+}
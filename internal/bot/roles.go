@@ -0,0 +1,231 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Роль по умолчанию для пользователей Telegram без назначенной роли
+func (bot *Bot) defaultTelegramRole() domain.Role {
+	role, err := domain.ParseRole(bot.conf.Telegram.DefaultRole)
+	if err != nil {
+		return domain.RoleViewer
+	}
+
+	return role
+}
+
+// Роль пользователя Telegram. Назначенная роль дает доступ и к приватному боту,
+// остальные пользователи получают роль по умолчанию, если бот публичный или они в списке разрешенных
+func (bot *Bot) telegramRole(userID int64) domain.Role {
+	assignment, err := bot.conf.GetDB().GetRole(domain.PlatformTelegram, strconv.FormatInt(userID, 10))
+	if err != nil {
+		log.Printf("Не удалось получить роль пользователя %d: %v", userID, err)
+		return domain.RoleNone
+	}
+	if assignment != nil {
+		return assignment.Role
+	}
+
+	if bot.conf.Telegram.Public || slices.Contains(bot.conf.Telegram.AllowedUserIDs, userID) {
+		return bot.defaultTelegramRole()
+	}
+
+	return domain.RoleNone
+}
+
+// Роль пользователя веб-интерфейса. У отключенных и несуществующих пользователей доступа нет
+func (bot *Bot) webRole(username string) domain.Role {
+	user, err := bot.conf.GetDB().GetWebUser(username)
+	if err != nil {
+		log.Printf("Не удалось получить пользователя %s: %v", username, err)
+		return domain.RoleNone
+	}
+	if user == nil || user.Disabled {
+		return domain.RoleNone
+	}
+
+	assignment, err := bot.conf.GetDB().GetRole(domain.PlatformWeb, username)
+	if err != nil {
+		log.Printf("Не удалось получить роль пользователя %s: %v", username, err)
+		return domain.RoleNone
+	}
+	if assignment == nil {
+		return domain.RoleNone
+	}
+
+	return assignment.Role
+}
+
+// До появления ролей все разрешенные пользователи могли менять настройки.
+// При первом запуске с ролями они становятся администраторами
+func (bot *Bot) seedRoles() error {
+	if !bot.conf.GetDB().RolesCreated() {
+		return nil
+	}
+
+	for _, userID := range bot.conf.Telegram.AllowedUserIDs {
+		err := bot.conf.GetDB().SetRole(domain.PlatformTelegram, strconv.FormatInt(userID, 10), domain.RoleAdmin)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(bot.conf.Telegram.AllowedUserIDs) > 0 {
+		log.Printf("Разрешенным пользователям Telegram (%d) назначена роль admin", len(bot.conf.Telegram.AllowedUserIDs))
+	}
+
+	return nil
+}
+
+func permissionDenied(command *Command, role domain.Role) string {
+	return fmt.Sprintf(
+		"Недостаточно прав для команды `%s`: требуется роль %s, у вас - %s",
+		command.Name, command.MinRole, role,
+	)
+}
+
+// Разбирает пользователя в формате "ID" или "telegram:ID" для Telegram и "web:имя" для веб-интерфейса
+func parseRoleSubject(subject string) (string, string, error) {
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return "", "", errors.New("пользователь не указан")
+	}
+
+	platform, userID, found := strings.Cut(subject, ":")
+	if !found {
+		platform, userID = domain.PlatformTelegram, subject
+	}
+	platform = strings.ToLower(platform)
+	userID = strings.TrimSpace(userID)
+
+	switch platform {
+	case domain.PlatformTelegram:
+		if _, err := strconv.ParseInt(userID, 10, 64); err != nil {
+			return "", "", errors.New("неверный ID пользователя Telegram")
+		}
+	case domain.PlatformWeb:
+		if userID == "" {
+			return "", "", errors.New("имя пользователя веб-интерфейса не указано")
+		}
+	default:
+		return "", "", fmt.Errorf("неизвестная платформа \"%s\". Допустимые: telegram, web", platform)
+	}
+
+	return platform, userID, nil
+}
+
+func (bot *Bot) ListRoles(call *CallContext) (string, error) {
+	assignments, err := bot.conf.GetDB().GetRoles()
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить роли: %w", err)
+	}
+
+	var response strings.Builder
+	response.WriteString("*Назначенные роли:*\n")
+	if len(assignments) == 0 {
+		response.WriteString("\nРолей нет. Назначить роль: `setrole`\n")
+	}
+
+	for _, assignment := range assignments {
+		response.WriteString(fmt.Sprintf(
+			"- %s `%s`: *%s* (с %s)\n",
+			assignment.Platform, assignment.UserID, assignment.Role,
+			time.Unix(assignment.UpdatedAt, 0).Format("2006-01-02"),
+		))
+	}
+
+	response.WriteString(fmt.Sprintf(
+		"\nОстальным пользователям Telegram с доступом назначается роль *%s*, пользователи веб-интерфейса без роли доступа не имеют",
+		bot.defaultTelegramRole(),
+	))
+
+	return response.String(), nil
+}
+
+// Формат: "пользователь роль"
+func (bot *Bot) SetRole(call *CallContext) (string, error) {
+	parts := strings.Fields(call.Args)
+	if len(parts) != 2 {
+		return "", errors.New("укажите пользователя и роль, например: `setrole 5293210034 analyst` или `setrole web:ivan viewer`")
+	}
+
+	platform, userID, err := parseRoleSubject(parts[0])
+	if err != nil {
+		return "", err
+	}
+
+	role, err := domain.ParseRole(parts[1])
+	if err != nil {
+		return "", err
+	}
+
+	if platform == domain.PlatformWeb {
+		user, err := bot.conf.GetDB().GetWebUser(userID)
+		if err != nil {
+			return "", err
+		}
+		if user == nil {
+			return "", fmt.Errorf("пользователь веб-интерфейса \"%s\" не найден. Создайте его командой `addwebuser`", userID)
+		}
+	}
+
+	if err := bot.conf.GetDB().SetRole(platform, userID, role); err != nil {
+		return "", fmt.Errorf("не удалось назначить роль: %w", err)
+	}
+
+	return fmt.Sprintf("Пользователю %s `%s` назначена роль *%s*", platform, userID, role), nil
+}
+
+func (bot *Bot) RemoveRole(call *CallContext) (string, error) {
+	platform, userID, err := parseRoleSubject(call.Args)
+	if err != nil {
+		return "", err
+	}
+
+	removed, err := bot.conf.GetDB().RemoveRole(platform, userID)
+	if err != nil {
+		return "", fmt.Errorf("не удалось снять роль: %w", err)
+	}
+	if !removed {
+		return "", errors.New("у пользователя нет назначенной роли")
+	}
+
+	return fmt.Sprintf("Роль пользователя %s `%s` снята", platform, userID), nil
+}
+
+func (bot *Bot) SetDefaultRole(call *CallContext) (string, error) {
+	role, err := domain.ParseRole(call.Args)
+	if err != nil {
+		return "", err
+	}
+
+	bot.conf.Telegram.DefaultRole = role.String()
+	bot.conf.Update()
+
+	return fmt.Sprintf("Роль по умолчанию изменена на *%s*", role), nil
+}
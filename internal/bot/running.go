@@ -0,0 +1,208 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ошибка, которой завершается отмененная команда
+var errCanceled = errors.New("выполнение отменено")
+
+// Вызывающие для анализов из очереди: пакетов (UserID - номер пакета) и лент (номер ленты).
+// Их задания отменяют администраторы
+const (
+	platformBatch = "batch"
+	platformFeed  = "feed"
+)
+
+// Выполняющаяся команда. Отмена контекста прерывает загрузку страницы,
+// запросы к модели и к базе данных
+type runningJob struct {
+	ID      int64
+	Caller  Caller
+	Command string
+	Args    string // Как в журнале: секретные аргументы скрыты
+	Started time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Владелец задания - пользователь платформы, чат не учитывается
+func (job *runningJob) ownedBy(caller Caller) bool {
+	return job.Caller.Platform == caller.Platform && job.Caller.UserID == caller.UserID
+}
+
+// Выполняющиеся команды всех пользователей
+type jobRegistry struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   map[int64]*runningJob
+}
+
+func (registry *jobRegistry) start(caller Caller, command string, args string) *runningJob {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if registry.jobs == nil {
+		registry.jobs = make(map[int64]*runningJob)
+	}
+
+	registry.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &runningJob{
+		ID:      registry.nextID,
+		Caller:  caller,
+		Command: command,
+		Args:    args,
+		Started: time.Now(),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	registry.jobs[job.ID] = job
+
+	return job
+}
+
+func (registry *jobRegistry) finish(job *runningJob) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	job.cancel()
+	delete(registry.jobs, job.ID)
+}
+
+func (registry *jobRegistry) get(id int64) *runningJob {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	return registry.jobs[id]
+}
+
+// Задания, для которых filter возвращает true, в порядке запуска
+func (registry *jobRegistry) list(filter func(job *runningJob) bool) []*runningJob {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	var jobs []*runningJob
+	for _, job := range registry.jobs {
+		if filter(job) {
+			jobs = append(jobs, job)
+		}
+	}
+	slices.SortFunc(jobs, func(a, b *runningJob) int { return int(a.ID - b.ID) })
+
+	return jobs
+}
+
+// Контекст выполнения команды. Вне runCommand команду отменить нельзя
+func (call *CallContext) Context() context.Context {
+	if call.job == nil {
+		return context.Background()
+	}
+
+	return call.job.ctx
+}
+
+// Номер задания вызова или 0, если команда выполняется вне runCommand
+func (call *CallContext) JobID() int64 {
+	if call.job == nil {
+		return 0
+	}
+
+	return call.job.ID
+}
+
+func formatRunningJob(job *runningJob) string {
+	description := fmt.Sprintf("#%d `%s`", job.ID, job.Command)
+	if job.Args != "" {
+		description = fmt.Sprintf("#%d `%s %s`", job.ID, job.Command, shortenAuditValue(job.Args))
+	}
+
+	return fmt.Sprintf("%s (%s, %d сек.)", description, job.Caller.String(), int(time.Since(job.Started).Seconds()))
+}
+
+// Выполняющиеся команды: свои, а для администраторов - все, включая анализы из очереди
+func (bot *Bot) ListJobs(call *CallContext) (string, error) {
+	all := call.Role.Allows(domain.RoleAdmin)
+	jobs := bot.jobs.list(func(job *runningJob) bool {
+		return job.ID != call.JobID() && (all || job.ownedBy(call.Caller))
+	})
+	if len(jobs) == 0 {
+		return "Нет выполняющихся команд", nil
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("*Выполняются команды (%d):*\n", len(jobs)))
+	for _, job := range jobs {
+		response.WriteString("- " + formatRunningJob(job) + "\n")
+	}
+	response.WriteString("\nОтменить: `cancel номер`")
+
+	return response.String(), nil
+}
+
+// Отменяет выполняющиеся команды: без аргумента - все команды вызвавшего,
+// с номером - конкретную. Чужие команды может отменять только администратор
+func (bot *Bot) Cancel(call *CallContext) (string, error) {
+	arg := strings.TrimPrefix(strings.TrimSpace(call.Args), "#")
+	if arg == "" {
+		jobs := bot.jobs.list(func(job *runningJob) bool {
+			return job.ownedBy(call.Caller) && job.ID != call.JobID()
+		})
+		if len(jobs) == 0 {
+			return "Нет выполняющихся команд", nil
+		}
+
+		var response strings.Builder
+		response.WriteString("*Отменены команды:*\n")
+		for _, job := range jobs {
+			job.cancel()
+			response.WriteString("- " + formatRunningJob(job) + "\n")
+		}
+
+		return response.String(), nil
+	}
+
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id <= 0 {
+		return "", errors.New("укажите номер команды")
+	}
+
+	job := bot.jobs.get(id)
+	if job == nil {
+		return "", fmt.Errorf("команда #%d не выполняется", id)
+	}
+	if !job.ownedBy(call.Caller) && !call.Role.Allows(domain.RoleAdmin) {
+		return "", errors.New("отменять чужие команды могут только администраторы")
+	}
+
+	job.cancel()
+
+	return "Отменена команда " + formatRunningJob(job), nil
+}
@@ -0,0 +1,164 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Telegram ограничивает частоту редактирования сообщений, поэтому части ответа модели
+	// показываются не чаще раза в этот интервал
+	telegramStatusInterval = time.Second
+	// Сколько последних символов ответа модели помещается в сообщение о ходе выполнения
+	telegramStatusTail = 3500
+)
+
+// Сообщение о ходе выполнения команды в Telegram. Создается при первом событии,
+// затем редактируется на месте и удаляется после ответа
+type telegramStatus struct {
+	bot     *Bot
+	chatID  int64
+	replyTo int
+	jobID   func() int64 // Номер задания для кнопки отмены
+
+	mu        sync.Mutex
+	messageID int
+	stages    []string
+	streamed  strings.Builder
+	shown     string
+	lastEdit  time.Time
+}
+
+func newTelegramStatus(bot *Bot, chatID int64, replyTo int, jobID func() int64) *telegramStatus {
+	return &telegramStatus{
+		bot:     bot,
+		chatID:  chatID,
+		replyTo: replyTo,
+		jobID:   jobID,
+	}
+}
+
+// Добавляет этап выполнения. Этапы показываются списком, чтобы были видны и параллельные запросы
+func (status *telegramStatus) progress(text string) {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+
+	status.stages = append(status.stages, text)
+	status.render(true)
+}
+
+// Добавляет часть ответа модели
+func (status *telegramStatus) stream(chunk string) {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+
+	status.streamed.WriteString(chunk)
+	status.render(false)
+}
+
+func (status *telegramStatus) render(force bool) {
+	if !force && time.Since(status.lastEdit) < telegramStatusInterval {
+		return
+	}
+
+	text := strings.Join(status.stages, "\n")
+	if status.streamed.Len() > 0 {
+		streamed := []rune(status.streamed.String())
+		if len(streamed) > telegramStatusTail {
+			streamed = append([]rune("…"), streamed[len(streamed)-telegramStatusTail:]...)
+		}
+		text = string(streamed) + " ▍"
+	}
+	if strings.TrimSpace(text) == "" || text == status.shown {
+		return
+	}
+
+	// Кнопка отмены должна сохраняться при каждом редактировании
+	cancelButton := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✖ Отменить", fmt.Sprintf("%s %d", cancelCallbackPrefix, status.jobID())),
+	))
+
+	if status.messageID == 0 {
+		msg := tgbotapi.NewMessage(status.chatID, text)
+		msg.ReplyToMessageID = status.replyTo
+		msg.ReplyMarkup = cancelButton
+		sent, err := status.bot.api.Send(msg)
+		if err != nil {
+			return
+		}
+		status.messageID = sent.MessageID
+	} else if _, err := status.bot.api.Send(tgbotapi.NewEditMessageTextAndMarkup(status.chatID, status.messageID, text, cancelButton)); err != nil {
+		return
+	}
+
+	status.shown = text
+	status.lastEdit = time.Now()
+}
+
+// Данные кнопки отмены: "cancel <номер задания>"
+const cancelCallbackPrefix = "cancel"
+
+// Нажатие кнопки отмены под сообщением о ходе выполнения. Отмена выполняется
+// командой cancel, поэтому права проверяются и вызов попадает в журнал так же
+func (bot *Bot) handleTelegramCallback(query *tgbotapi.CallbackQuery) {
+	jobID, ok := strings.CutPrefix(query.Data, cancelCallbackPrefix+" ")
+	command := bot.CommandByName("cancel")
+	if !ok || command == nil {
+		return
+	}
+
+	answer := "Команда отменяется..."
+	if role := bot.telegramRole(query.From.ID); !role.Allows(command.MinRole) {
+		answer = "Недостаточно прав"
+	} else {
+		caller := Caller{
+			Platform: domain.PlatformTelegram,
+			UserID:   strconv.FormatInt(query.From.ID, 10),
+			UserName: query.From.UserName,
+		}
+		if query.Message != nil {
+			caller.ChatID = query.Message.Chat.ID
+		}
+
+		if _, err := bot.runCommand(bot.newCall(caller, jobID), command); err != nil {
+			answer = err.Error()
+		}
+	}
+
+	bot.api.Request(tgbotapi.NewCallback(query.ID, answer))
+}
+
+// Удаляет сообщение о ходе выполнения, если оно было отправлено
+func (status *telegramStatus) close() {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+
+	if status.messageID != 0 {
+		status.bot.api.Send(tgbotapi.NewDeleteMessage(status.chatID, status.messageID))
+		status.messageID = 0
+	}
+}
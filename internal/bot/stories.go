@@ -0,0 +1,266 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/db"
+	"Unbewohnte/ACASbot/internal/domain"
+	"Unbewohnte/ACASbot/internal/similarity"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Относит сохраненную статью к сюжету: к сюжету наиболее похожей статьи,
+// опубликованной в пределах окна, или к новому сюжету. Возвращает true, если сюжет новый
+func (bot *Bot) assignStory(ctx context.Context, art *domain.Article) (bool, error) {
+	conf := bot.conf.Analysis.Stories
+	if !conf.Enabled || art.Irrelevant || art.StoryID != 0 || len(art.Embedding) == 0 {
+		return false, nil
+	}
+
+	neighbors, err := bot.conf.GetDB().FindNeighbors(
+		ctx,
+		append([]float64(nil), art.Embedding...),
+		conf.VectorThreshold,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	window := int64(conf.WindowDays) * 24 * 60 * 60
+	composite := similarity.NewCompositeSimilarity(bot.conf.Analysis.CompositeVectorWeight)
+
+	var (
+		bestStory int64
+		bestScore float64
+	)
+	for _, neighbor := range neighbors {
+		if neighbor.ID == art.ID || neighbor.StoryID == 0 {
+			continue
+		}
+
+		distance := neighbor.SeenAt() - art.SeenAt()
+		if distance < 0 {
+			distance = -distance
+		}
+		if window > 0 && distance > window {
+			continue
+		}
+
+		score, err := composite.Compare(art.Content, neighbor.Content, art.Embedding, neighbor.Embedding)
+		if err != nil || score < conf.CompositeThreshold {
+			continue
+		}
+
+		if score > bestScore {
+			bestScore = score
+			bestStory = neighbor.StoryID
+		}
+	}
+
+	created := bestStory == 0
+	if created {
+		bestStory, err = bot.conf.GetDB().CreateStory(art)
+	} else {
+		err = bot.conf.GetDB().AssignStory(art, bestStory)
+	}
+	if err != nil {
+		return false, err
+	}
+	art.StoryID = bestStory
+
+	err = bot.mirrorArticle(ctx, art.SourceURL, func(store db.ArticleStore, id int64) error {
+		return store.SetArticleStory(ctx, id, art.StoryID)
+	})
+
+	return created, err
+}
+
+func (bot *Bot) ListStories(call *CallContext) (string, error) {
+	limit := 10
+	if call.Args != "" {
+		n, err := strconv.Atoi(strings.TrimSpace(call.Args))
+		if err != nil || n <= 0 {
+			return "", errors.New("неверное количество сюжетов")
+		}
+		limit = n
+	}
+
+	// Сюжеты из одной статьи не интересны
+	stories, err := bot.conf.GetDB().GetStories(limit, 2)
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить сюжеты: %w", err)
+	}
+
+	if len(stories) == 0 {
+		return "Сюжетов из нескольких статей пока нет", nil
+	}
+
+	var response strings.Builder
+	response.WriteString("*Сюжеты:*\n")
+	for _, story := range stories {
+		response.WriteString(fmt.Sprintf("\n*#%d.* %s\n", story.ID, story.Title))
+		response.WriteString(fmt.Sprintf("- Статей: %d, ресурсов: %d\n", story.ArticleCount, len(story.Hosts)))
+		response.WriteString(fmt.Sprintf("- Впервые: %s (%s)\n",
+			time.Unix(story.FirstSeen, 0).Format("2006-01-02 15:04"),
+			domain.Hostname(story.FirstURL),
+		))
+		response.WriteString(fmt.Sprintf("- Последняя публикация: %s\n", time.Unix(story.LastSeen, 0).Format("2006-01-02 15:04")))
+	}
+	response.WriteString("\nПодробнее: `story <id>`")
+
+	return response.String(), nil
+}
+
+func (bot *Bot) ShowStory(call *CallContext) (string, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(call.Args), "#"), 10, 64)
+	if err != nil {
+		return "", errors.New("укажите ID сюжета")
+	}
+
+	story, err := bot.conf.GetDB().GetStory(id)
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить сюжет: %w", err)
+	}
+	if story == nil {
+		return "", fmt.Errorf("сюжет #%d не найден", id)
+	}
+
+	articles, err := bot.conf.GetDB().GetStoryArticles(id)
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить статьи сюжета: %w", err)
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("*Сюжет #%d:* %s\n\n", story.ID, story.Title))
+	response.WriteString(fmt.Sprintf("*Впервые:* %s, %s\n",
+		time.Unix(story.FirstSeen, 0).Format("2006-01-02 15:04"),
+		story.FirstURL,
+	))
+	response.WriteString(fmt.Sprintf("*Последняя публикация:* %s\n", time.Unix(story.LastSeen, 0).Format("2006-01-02 15:04")))
+	response.WriteString(fmt.Sprintf("*Длительность:* %s\n", formatStoryDuration(story.LastSeen-story.FirstSeen)))
+
+	response.WriteString(fmt.Sprintf("\n*Ресурсы (%d):*\n", len(story.Hosts)))
+	for _, host := range story.Hosts {
+		response.WriteString(fmt.Sprintf("- %s: %d\n", host.Host, host.Articles))
+	}
+
+	response.WriteString(fmt.Sprintf("\n*Статьи (%d):*\n", len(articles)))
+	for i, art := range articles {
+		title := art.Title
+		if title == "" {
+			title = art.SourceURL
+		}
+		response.WriteString(fmt.Sprintf("%d. %s [%s](%s)", i+1,
+			time.Unix(art.SeenAt(), 0).Format("2006-01-02 15:04"),
+			title,
+			art.SourceURL,
+		))
+		if art.Sentiment != "" {
+			response.WriteString(fmt.Sprintf(" - %s", art.Sentiment))
+		}
+		response.WriteString("\n")
+	}
+
+	return response.String(), nil
+}
+
+func formatStoryDuration(seconds int64) string {
+	duration := time.Duration(seconds) * time.Second
+	if duration < time.Hour {
+		return fmt.Sprintf("%d мин.", int(duration.Minutes()))
+	}
+	if duration < 48*time.Hour {
+		return fmt.Sprintf("%d ч.", int(duration.Hours()))
+	}
+
+	return fmt.Sprintf("%d дн.", int(duration.Hours()/24))
+}
+
+// Распределяет по сюжетам статьи, еще не отнесенные ни к одному. Уже назначенные
+// сюжеты не меняются, поэтому их ID остаются стабильными
+func (bot *Bot) ClusterStories(call *CallContext) (string, error) {
+	if !bot.conf.Analysis.Stories.Enabled {
+		return "", errors.New("сюжеты выключены (`togglestories`)")
+	}
+
+	articles, err := bot.conf.GetDB().GetArticlesWithoutStory()
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить статьи: %w", err)
+	}
+
+	var assigned, created, failed int
+	for i := range articles {
+		if err := call.Context().Err(); err != nil {
+			return "", err
+		}
+
+		isNew, err := bot.assignStory(call.Context(), &articles[i])
+		if err != nil {
+			log.Printf("Не удалось определить сюжет статьи %d: %v", articles[i].ID, err)
+			failed++
+			continue
+		}
+
+		if isNew {
+			created++
+		} else if articles[i].StoryID != 0 {
+			assigned++
+		}
+	}
+
+	response := fmt.Sprintf("Обработано статей: %d. Новых сюжетов: %d, добавлено в существующие: %d", len(articles), created, assigned)
+	if failed > 0 {
+		response += fmt.Sprintf(", ошибок: %d", failed)
+	}
+
+	return response, nil
+}
+
+func (bot *Bot) ToggleStories(call *CallContext) (string, error) {
+	bot.conf.Analysis.Stories.Enabled = !bot.conf.Analysis.Stories.Enabled
+	bot.conf.Update()
+
+	if bot.conf.Analysis.Stories.Enabled {
+		return "Распределение статей по сюжетам включено. Ранее сохраненные статьи можно распределить командой `clusterstories`", nil
+	} else {
+		return "Распределение статей по сюжетам выключено", nil
+	}
+}
+
+func (bot *Bot) SetStoryThreshold(call *CallContext) (string, error) {
+	if call.Args == "" {
+		return "", errors.New("не указан порог")
+	}
+
+	threshold, err := strconv.ParseFloat(strings.TrimSpace(call.Args), 64)
+	if err != nil || threshold < 0 || threshold > 1 {
+		return "", errors.New("порог должен быть числом от 0 до 1")
+	}
+
+	bot.conf.Analysis.Stories.CompositeThreshold = threshold
+	bot.conf.Update()
+
+	return fmt.Sprintf("Порог общей схожести для сюжетов изменен на %v", threshold), nil
+}
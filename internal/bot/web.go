@@ -1,12 +1,15 @@
 package bot
 
 import (
+	"Unbewohnte/ACASbot/internal/domain"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"log"
+	"mime"
 	"net/http"
-	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -46,11 +49,14 @@ type WebMessage struct {
 	Type    string `json:"type"`
 	Content string `json:"content"`
 	From    string `json:"from,omitempty"`
+	Job     int64  `json:"job,omitempty"` // Номер выполняющейся команды, которую можно отменить
 }
 
 type WebClient struct {
-	conn *websocket.Conn
-	send chan WebMessage
+	conn     *websocket.Conn
+	send     chan WebMessage
+	username string
+	jti      string // Сессия, по которой открыто соединение
 }
 
 type WebServer struct {
@@ -58,6 +64,9 @@ type WebServer struct {
 	upgrader websocket.Upgrader
 	clients  map[*WebClient]bool
 	mu       sync.Mutex
+
+	downloads   map[string]webDownload // Файлы из ответов команд по токенам ссылок
+	downloadsMu sync.Mutex
 }
 
 func NewWebServer(bot *Bot) *WebServer {
@@ -66,7 +75,8 @@ func NewWebServer(bot *Bot) *WebServer {
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool { return true },
 		},
-		clients: make(map[*WebClient]bool),
+		clients:   make(map[*WebClient]bool),
+		downloads: make(map[string]webDownload),
 	}
 }
 
@@ -78,9 +88,12 @@ func (ws *WebServer) Start() {
 
 	// Login endpoint
 	r.HandleFunc("/login", ws.handleLogin).Methods("POST")
+	r.HandleFunc("/logout", ws.handleLogout).Methods("POST")
+
+	r.HandleFunc("/download/{token}", ws.handleDownload).Methods("GET")
 
-	r.HandleFunc("/download/logs", ws.handleDownloadLogs).Methods("GET")
-	r.HandleFunc("/download/xlsx", ws.handleDownloadXLSX).Methods("GET")
+	// REST API
+	ws.registerAPI(r)
 
 	// Static files
 	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./web")))
@@ -101,55 +114,75 @@ func (ws *WebServer) Start() {
 }
 
 func (ws *WebServer) handleLogin(w http.ResponseWriter, r *http.Request) {
-	// Simple authentication
 	username := r.FormValue("username")
 	password := r.FormValue("password")
 
-	if username == ws.bot.conf.Web.Username && password == ws.bot.conf.Web.Password {
-		token, err := ws.generateJWT()
-		if err != nil {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-
-		// Устанавливаем куку с дополнительными флагами безопасности
-		http.SetCookie(w, &http.Cookie{
-			Name:     "auth_token",
-			Value:    token,
-			Expires:  time.Now().Add(24 * time.Hour),
-			Path:     "/",
-			HttpOnly: true, // Защита от XSS
-			Secure:   false,
-			SameSite: http.SameSiteStrictMode, // Защита от CSRF
-		})
-
-		w.WriteHeader(http.StatusOK)
+	user, err := ws.bot.authenticateWebUser(username, password)
+	if err != nil {
+		log.Printf("Неудачный вход в веб-интерфейс (%s): %v", username, err)
+		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
-	w.WriteHeader(http.StatusUnauthorized)
-}
+	role := ws.bot.webRole(user.Username)
+	if role == domain.RoleNone {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
 
-func (ws *WebServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	// Проверка аутентификации через JWT
-	cookie, err := r.Cookie("auth_token")
+	token, expiresAt, err := ws.generateJWT(user.Username, role)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	token, err := ws.validateJWT(cookie.Value)
-	if err != nil || !token.Valid {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
+	// Устанавливаем куку с дополнительными флагами безопасности
+	http.SetCookie(w, &http.Cookie{
+		Name:     "auth_token",
+		Value:    token,
+		Expires:  expiresAt,
+		Path:     "/",
+		HttpOnly: true, // Защита от XSS
+		Secure:   false,
+		SameSite: http.SameSiteStrictMode, // Защита от CSRF
+	})
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"username": user.Username,
+		"role":     role.String(),
+	})
+}
+
+// Отзывает токен текущей сессии и удаляет куку
+func (ws *WebServer) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if claims, err := ws.authenticate(r); err == nil {
+		jti, _ := claims["jti"].(string)
+		if _, err := ws.bot.conf.GetDB().RevokeWebSession(jti); err != nil {
+			log.Printf("Не удалось отозвать сессию: %v", err)
+		}
 	}
 
-	// Дополнительная проверка: убедимся, что токен предназначен для этого пользователя
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || claims["username"] != ws.bot.conf.Web.Username {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "auth_token",
+		Value:    "",
+		MaxAge:   -1,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (ws *WebServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Проверка JWT, его сессии и доступа пользователя
+	claims, err := ws.authenticate(r)
+	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
+	username, _ := claims["username"].(string)
+	jti, _ := claims["jti"].(string)
 
 	conn, err := ws.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -158,8 +191,10 @@ func (ws *WebServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &WebClient{
-		conn: conn,
-		send: make(chan WebMessage, 256),
+		conn:     conn,
+		send:     make(chan WebMessage, 256),
+		username: username,
+		jti:      jti,
 	}
 	ws.addClient(client)
 
@@ -177,6 +212,11 @@ func (ws *WebServer) addClient(client *WebClient) {
 func (ws *WebServer) removeClient(client *WebClient) {
 	ws.mu.Lock()
 	defer ws.mu.Unlock()
+	ws.dropClient(client)
+}
+
+// Отключает клиента. Вызывается с захваченным ws.mu
+func (ws *WebServer) dropClient(client *WebClient) {
 	if _, ok := ws.clients[client]; ok {
 		delete(ws.clients, client)
 		close(client.send)
@@ -184,6 +224,23 @@ func (ws *WebServer) removeClient(client *WebClient) {
 	}
 }
 
+// Отправляет сообщение одному клиенту, например ответ на его команду
+func (ws *WebServer) send(client *WebClient, msg WebMessage) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if !ws.clients[client] {
+		return
+	}
+
+	select {
+	case client.send <- msg:
+	default:
+		// Клиент не успевает читать сообщения
+		ws.dropClient(client)
+	}
+}
+
 func (ws *WebServer) broadcast(msg WebMessage) {
 	ws.mu.Lock()
 	defer ws.mu.Unlock()
@@ -192,7 +249,7 @@ func (ws *WebServer) broadcast(msg WebMessage) {
 		select {
 		case client.send <- msg:
 		default:
-			ws.removeClient(client)
+			ws.dropClient(client)
 		}
 	}
 }
@@ -227,13 +284,32 @@ func (c *WebClient) readPump(ws *WebServer) {
 
 		switch msg.Type {
 		case "command":
-			ws.handleCommand(msg.Content)
+			// Команды выполняются параллельно, чтобы во время долгого анализа можно было его отменить
+			go ws.handleCommand(c, msg.Content)
 		}
 	}
 }
 
-func (ws *WebServer) handleCommand(cmd string) {
-	log.Printf("Web command: %s", cmd)
+// Проверяет, может ли пользователь клиента вызвать команду, и сообщает об отказе
+func (ws *WebServer) permit(client *WebClient, command *Command) bool {
+	role := ws.bot.webRole(client.username)
+	if role.Allows(command.MinRole) {
+		return true
+	}
+
+	ws.send(client, WebMessage{Type: "log", Content: permissionDenied(command, role)})
+	return false
+}
+
+func (ws *WebServer) handleCommand(client *WebClient, cmd string) {
+	log.Printf("Web command [%s]: %s", client.username, ws.bot.loggableText(cmd))
+
+	// Сессия могла быть отозвана уже после подключения
+	session, err := ws.bot.conf.GetDB().GetWebSession(client.jti)
+	if err != nil || session == nil || !session.Active(time.Now().Unix()) {
+		ws.send(client, WebMessage{Type: "error", Content: "Сессия завершена. Войдите заново"})
+		return
+	}
 
 	// Разделяем команду на части
 	parts := strings.Fields(cmd)
@@ -245,75 +321,19 @@ func (ws *WebServer) handleCommand(cmd string) {
 	commandName := strings.ToLower(strings.TrimPrefix(parts[0], "/"))
 	args := strings.Join(parts[1:], " ")
 
-	// Специальная обработка для getlogs
-	if commandName == "getlogs" {
-		// Проверяем, существует ли файл логов
-		if _, err := os.Stat(ws.bot.conf.LogsFile); os.IsNotExist(err) {
-			ws.SendLog("Файл логов не найден")
-			return
-		}
-
-		// Формируем HTML-ссылку для скачивания
-		response := `<div class="download-container">
-            <p>Логи доступны для скачивания:</p>
-            <a href="/download/logs" target="_blank" class="download-btn">
-                <i class="bi bi-download me-2"></i>Скачать логи
-            </a>
-        </div>`
-		ws.SendResponse(response)
-		return
-	}
-
-	// Специальная обработка для xlsx
-	if commandName == "xlsx" {
-		// Проверяем, существует ли файл таблицы
-		fileName := "ACASbot_Results.xlsx"
-		if _, err := os.Stat(fileName); os.IsNotExist(err) {
-			_, err := ws.bot.GenerateSpreadsheet("")
-			if err != nil {
-				ws.SendLog("Не вышло сгенерировать локальную таблицу: " + err.Error())
-				return
-			}
-		}
-
-		// Формируем HTML-ссылку для скачивания
-		response := `<div class="download-container">
-            <p>Таблица результатов доступна для скачивания:</p>
-            <a href="/download/xlsx" target="_blank" class="download-btn">
-                <i class="bi bi-file-earmark-spreadsheet me-2"></i>Скачать таблицу
-            </a>
-        </div>`
-		ws.SendResponse(response)
-		return
-	}
-
-	// Ищем и вызываем команду
-	for _, command := range ws.bot.commands {
-		if command.Name == commandName {
-			// Вызываем команду и получаем результат
-			response, err := command.Call(args)
-			if err != nil {
-				ws.SendLog("Error executing command: " + err.Error())
-				return
-			}
-			ws.SendResponse(response)
-			return
+	command := ws.bot.CommandByName(commandName)
+	if command == nil {
+		switch {
+		case len(extractURLs(cmd)) > 1:
+			// Несколько URL сразу отправляем пакетом
+			command, args = ws.bot.CommandByName("batch"), cmd
+		case strings.HasPrefix(cmd, "http"):
+			// Для URL обрабатываем как команду "do"
+			command, args = ws.bot.CommandByName("do"), cmd
 		}
 	}
 
-	// Fallback для URL
-	if strings.HasPrefix(cmd, "http") {
-		do := ws.bot.CommandByName("do")
-		if do != nil {
-			// Для URL обрабатываем как команду "do"
-			response, err := do.Call(cmd)
-			if err != nil {
-				ws.SendLog("Error executing do command: " + err.Error())
-				return
-			}
-			ws.SendResponse(response)
-		}
-	} else {
+	if command == nil {
 		// Такой команды просто нет
 		var response string
 
@@ -330,20 +350,84 @@ func (ws *WebServer) handleCommand(cmd string) {
 			}
 		}
 
-		ws.SendLog(response)
+		ws.send(client, WebMessage{Type: "log", Content: response})
+		return
+	}
+
+	if !ws.permit(client, command) {
+		return
+	}
+
+	call := ws.bot.newCall(Caller{Platform: domain.PlatformWeb, UserID: client.username}, args)
+	call.progress = func(text string) { ws.SendProgress(client, call.JobID(), text) }
+	stream := &webStream{flush: func(text string) { ws.SendStream(client, call.JobID(), text) }}
+	call.stream = stream.write
+
+	response, err := ws.bot.runCommand(call, command)
+	stream.close()
+	if err != nil {
+		ws.send(client, WebMessage{Type: "log", Content: "Error executing command: " + err.Error()})
+		return
+	}
+
+	ws.sendCommandResponse(client, response)
+}
+
+// Отправляет ответ команды только вызвавшему ее клиенту: структурированные данные в HTML,
+// если их можно так показать, иначе текст, затем ссылки на скачивание файлов
+func (ws *WebServer) sendCommandResponse(client *WebClient, response *Response) {
+	if renderer, ok := response.Data.(htmlRenderer); ok {
+		html, err := renderer.HTML()
+		if err == nil {
+			ws.send(client, WebMessage{Type: "report", Content: html})
+		} else {
+			log.Printf("Не удалось представить ответ в HTML: %v", err)
+			ws.send(client, WebMessage{Type: "analysis", Content: markdownHTML(response.Text)})
+		}
+	} else if response.Text != "" {
+		ws.send(client, WebMessage{Type: "analysis", Content: markdownHTML(response.Text)})
+	}
+
+	for _, file := range response.Files {
+		token := ws.offerDownload(client.username, file)
+
+		caption := file.Caption
+		if caption == "" {
+			caption = "Файл доступен для скачивания:"
+		}
+
+		ws.send(client, WebMessage{Type: "analysis", Content: fmt.Sprintf(`<div class="download-container">
+            <p>%s</p>
+            <a href="/download/%s" target="_blank" class="download-btn">
+                <i class="bi bi-download me-2"></i>Скачать %s
+            </a>
+        </div>`, template.HTMLEscapeString(caption), token, template.HTMLEscapeString(file.Name))})
 	}
 }
 
-func (ws *WebServer) SendResponse(result string) {
-	// Преобразуем Markdown в HTML
+// Преобразует Markdown в HTML для отправки клиентам
+func markdownHTML(result string) string {
 	html, err := RenderMarkdown(result)
 	if err != nil {
 		// В случае ошибки рендеринга, отправляем как простой текст с заменой \n на <br>
 		html = strings.ReplaceAll(result, "\n", "<br>")
 	}
 
+	return html
+}
+
+// SendResponse sends a notification to all web clients
+func (ws *WebServer) SendResponse(result string) {
 	ws.broadcast(WebMessage{
 		Type:    "analysis",
+		Content: markdownHTML(result),
+	})
+}
+
+// SendReport sends an HTML digest to web clients
+func (ws *WebServer) SendReport(html string) {
+	ws.broadcast(WebMessage{
+		Type:    "report",
 		Content: html,
 	})
 }
@@ -356,15 +440,94 @@ func (ws *WebServer) SendLog(log string) {
 	})
 }
 
-func (ws *WebServer) generateJWT() (string, error) {
+// Этап выполнения команды. Этапы показываются одним обновляемым блоком до прихода ответа
+func (ws *WebServer) SendProgress(client *WebClient, job int64, text string) {
+	ws.send(client, WebMessage{
+		Type:    "progress",
+		Content: text,
+		Job:     job,
+	})
+}
+
+// Часть ответа модели по мере генерации
+func (ws *WebServer) SendStream(client *WebClient, job int64, chunk string) {
+	ws.send(client, WebMessage{
+		Type:    "stream",
+		Content: chunk,
+		Job:     job,
+	})
+}
+
+// Части ответа модели отправляются клиенту не чаще раза в этот интервал,
+// чтобы не переполнять очередь его сообщений отдельными токенами
+const webStreamInterval = 100 * time.Millisecond
+
+// Собирает части ответа модели и отправляет их клиенту пачками
+type webStream struct {
+	flush func(text string)
+
+	mu       sync.Mutex
+	pending  strings.Builder
+	lastSent time.Time
+}
+
+// Добавляет часть ответа модели
+func (stream *webStream) write(chunk string) {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	stream.pending.WriteString(chunk)
+	if time.Since(stream.lastSent) >= webStreamInterval {
+		stream.send()
+	}
+}
+
+// Отправляет оставшиеся части перед ответом команды
+func (stream *webStream) close() {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	stream.send()
+}
+
+func (stream *webStream) send() {
+	if stream.pending.Len() == 0 {
+		return
+	}
+
+	stream.flush(stream.pending.String())
+	stream.pending.Reset()
+	stream.lastSent = time.Now()
+}
+
+// Выдает токен и запоминает его сессию, чтобы токен можно было отозвать по jti
+func (ws *WebServer) generateJWT(username string, role domain.Role) (string, time.Time, error) {
+	now := time.Now()
+	session := domain.WebSession{
+		JTI:       uuid.New().String(), // Уникальный идентификатор токена
+		Username:  username,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(24 * time.Hour).Unix(),
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": ws.bot.conf.Web.Username,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(),
-		"iat":      time.Now().Unix(),
-		"jti":      uuid.New().String(), // Уникальный идентификатор токена
+		"username": session.Username,
+		"role":     role.String(),
+		"exp":      session.ExpiresAt,
+		"iat":      session.IssuedAt,
+		"jti":      session.JTI,
 	})
 
-	return token.SignedString([]byte(ws.bot.conf.Web.JWTSecret))
+	signed, err := token.SignedString([]byte(ws.bot.conf.Web.JWTSecret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if err := ws.bot.conf.GetDB().CreateWebSession(session); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, time.Unix(session.ExpiresAt, 0), nil
 }
 
 func (ws *WebServer) validateJWT(tokenString string) (*jwt.Token, error) {
@@ -377,59 +540,69 @@ func (ws *WebServer) validateJWT(tokenString string) (*jwt.Token, error) {
 	})
 }
 
-func (ws *WebServer) handleDownloadLogs(w http.ResponseWriter, r *http.Request) {
-	// Проверка аутентификации через JWT
-	cookie, err := r.Cookie("auth_token")
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
+// Время, в течение которого доступна ссылка на файл из ответа команды
+const downloadTTL = time.Hour
 
-	_, err = ws.validateJWT(cookie.Value)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
+// Файл из ответа команды, который может скачать вызвавший ее пользователь
+type webDownload struct {
+	file      ResponseFile
+	username  string
+	expiresAt time.Time
+}
 
-	// Проверяем, существует ли файл логов
-	if _, err := os.Stat(ws.bot.conf.LogsFile); os.IsNotExist(err) {
-		http.Error(w, "Log file not found", http.StatusNotFound)
-		return
+// Запоминает файл и возвращает токен для ссылки на скачивание
+func (ws *WebServer) offerDownload(username string, file ResponseFile) string {
+	ws.downloadsMu.Lock()
+	defer ws.downloadsMu.Unlock()
+
+	now := time.Now()
+	for token, download := range ws.downloads {
+		if now.After(download.expiresAt) {
+			delete(ws.downloads, token)
+		}
 	}
 
-	// Устанавливаем заголовки для скачивания
-	w.Header().Set("Content-Disposition", "attachment; filename=acasbot_logs.txt")
-	w.Header().Set("Content-Type", "text/plain")
+	token := uuid.New().String()
+	ws.downloads[token] = webDownload{
+		file:      file,
+		username:  username,
+		expiresAt: now.Add(downloadTTL),
+	}
 
-	// Отправляем файл
-	http.ServeFile(w, r, ws.bot.conf.LogsFile)
+	return token
 }
 
-func (ws *WebServer) handleDownloadXLSX(w http.ResponseWriter, r *http.Request) {
-	// Проверка аутентификации через JWT
-	cookie, err := r.Cookie("auth_token")
+func (ws *WebServer) handleDownload(w http.ResponseWriter, r *http.Request) {
+	// Файл доступен только пользователю, вызвавшему команду
+	claims, err := ws.authenticate(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	username, _ := claims["username"].(string)
 
-	_, err = ws.validateJWT(cookie.Value)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	ws.downloadsMu.Lock()
+	download, ok := ws.downloads[mux.Vars(r)["token"]]
+	ws.downloadsMu.Unlock()
+	if !ok || time.Now().After(download.expiresAt) {
+		http.Error(w, "File not found", http.StatusNotFound)
 		return
 	}
-
-	// Проверяем, существует ли файл таблицы
-	fileName := "ACASbot_Results.xlsx"
-	if _, err := os.Stat(fileName); os.IsNotExist(err) {
-		http.Error(w, "XLSX file not found", http.StatusNotFound)
+	if download.username != username {
+		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
 	// Устанавливаем заголовки для скачивания
-	w.Header().Set("Content-Disposition", "attachment; filename=ACASbot_Results.xlsx")
-	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", download.file.Name))
+	if contentType := mime.TypeByExtension(filepath.Ext(download.file.Name)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	if download.file.Path != "" {
+		http.ServeFile(w, r, download.file.Path)
+		return
+	}
 
-	// Отправляем файл
-	http.ServeFile(w, r, fileName)
+	http.ServeContent(w, r, download.file.Name, time.Now(), bytes.NewReader(download.file.Data))
 }
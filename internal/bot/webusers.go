@@ -0,0 +1,337 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minWebPasswordLength = 8
+
+var errWebCredentials = errors.New("неверное имя пользователя или пароль")
+
+// Хеш для сравнения при входе несуществующего пользователя, чтобы время ответа не выдавало его отсутствие
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("ACASbot"), bcrypt.DefaultCost)
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minWebPasswordLength {
+		return "", fmt.Errorf("пароль должен быть не короче %d символов", minWebPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// Случайный пароль для новых пользователей и сброса
+func generatePassword() string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// Проверяет имя и пароль пользователя веб-интерфейса
+func (bot *Bot) authenticateWebUser(username string, password string) (*domain.WebUser, error) {
+	user, err := bot.conf.GetDB().GetWebUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, errWebCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, errWebCredentials
+	}
+
+	if user.Disabled {
+		return nil, errors.New("пользователь отключен")
+	}
+
+	if err := bot.conf.GetDB().TouchWebUserLogin(user.Username); err != nil {
+		log.Printf("Не удалось обновить время входа %s: %v", user.Username, err)
+	}
+
+	return user, nil
+}
+
+// Переносит логин и пароль из конфигурации в базу. Пароль в конфигурации также
+// позволяет восстановить доступ: существующему пользователю он задается заново
+func (bot *Bot) migrateWebLogin() error {
+	username := strings.TrimSpace(bot.conf.Web.Username)
+	password := bot.conf.Web.Password
+	if username == "" || password == "" {
+		count, err := bot.conf.GetDB().CountWebUsers()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			log.Printf("ВНИМАНИЕ: Нет пользователей веб-интерфейса. Укажите username и password в разделе web конфигурации или добавьте пользователя командой addwebuser")
+		}
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user, err := bot.conf.GetDB().GetWebUser(username)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		if _, err := bot.conf.GetDB().CreateWebUser(username, string(hash)); err != nil {
+			return err
+		}
+		if err := bot.conf.GetDB().SetRole(domain.PlatformWeb, username, domain.RoleAdmin); err != nil {
+			return err
+		}
+		log.Printf("Пользователь веб-интерфейса %s перенесен из конфигурации в базу с ролью admin", username)
+	} else {
+		if _, err := bot.conf.GetDB().SetWebUserPassword(username, string(hash)); err != nil {
+			return err
+		}
+		if _, err := bot.conf.GetDB().SetWebUserDisabled(username, false); err != nil {
+			return err
+		}
+		log.Printf("Пароль пользователя веб-интерфейса %s изменен из конфигурации", username)
+	}
+
+	// Пароль больше не хранится открытым текстом
+	bot.conf.Web.Username = ""
+	bot.conf.Web.Password = ""
+	bot.conf.Update()
+
+	return nil
+}
+
+func (bot *Bot) ListWebUsers(call *CallContext) (string, error) {
+	users, err := bot.conf.GetDB().GetWebUsers()
+	if err != nil {
+		return "", fmt.Errorf("не удалось получить пользователей: %w", err)
+	}
+
+	if len(users) == 0 {
+		return "Пользователей веб-интерфейса нет. Добавьте нового командой `addwebuser`", nil
+	}
+
+	var response strings.Builder
+	response.WriteString("*Пользователи веб-интерфейса:*\n")
+	for _, user := range users {
+		response.WriteString(fmt.Sprintf("\n*%s* - %s", user.Username, bot.webRole(user.Username)))
+		if user.Disabled {
+			response.WriteString(" (отключен)")
+		}
+		response.WriteString("\n")
+
+		if user.LastLogin != 0 {
+			response.WriteString(fmt.Sprintf("- Последний вход: %s\n", time.Unix(user.LastLogin, 0).Format("2006-01-02 15:04")))
+		} else {
+			response.WriteString("- Ни разу не входил\n")
+		}
+
+		sessions, err := bot.conf.GetDB().GetActiveWebSessions(user.Username)
+		if err == nil && len(sessions) > 0 {
+			response.WriteString(fmt.Sprintf("- Действующих сессий: %d\n", len(sessions)))
+		}
+	}
+
+	return response.String(), nil
+}
+
+// Формат: "имя роль [пароль]". Без пароля он генерируется
+func (bot *Bot) AddWebUser(call *CallContext) (string, error) {
+	parts := strings.Fields(call.Args)
+	if len(parts) < 2 {
+		return "", errors.New("укажите имя и роль пользователя, например: `addwebuser ivan analyst`")
+	}
+
+	username := parts[0]
+	role, err := domain.ParseRole(parts[1])
+	if err != nil {
+		return "", err
+	}
+
+	existing, err := bot.conf.GetDB().GetWebUser(username)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return "", fmt.Errorf("пользователь \"%s\" уже существует", username)
+	}
+
+	password := generatePassword()
+	if len(parts) > 2 {
+		password = parts[2]
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := bot.conf.GetDB().CreateWebUser(username, hash); err != nil {
+		return "", fmt.Errorf("не удалось создать пользователя: %w", err)
+	}
+	if err := bot.conf.GetDB().SetRole(domain.PlatformWeb, username, role); err != nil {
+		return "", fmt.Errorf("не удалось назначить роль: %w", err)
+	}
+
+	return fmt.Sprintf("Пользователь *%s* с ролью %s создан. Пароль: `%s`", username, role, password), nil
+}
+
+func (bot *Bot) setWebUserDisabled(args string, disabled bool) (string, error) {
+	username := strings.TrimSpace(args)
+	if username == "" {
+		return "", errors.New("имя пользователя не указано")
+	}
+
+	found, err := bot.conf.GetDB().SetWebUserDisabled(username, disabled)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("пользователь \"%s\" не найден", username)
+	}
+
+	if !disabled {
+		return fmt.Sprintf("Пользователь *%s* снова может входить в веб-интерфейс", username), nil
+	}
+
+	revoked, err := bot.conf.GetDB().RevokeWebSessions(username)
+	if err != nil {
+		return "", fmt.Errorf("пользователь отключен, но не удалось отозвать его сессии: %w", err)
+	}
+
+	return fmt.Sprintf("Пользователь *%s* отключен, отозвано сессий: %d", username, revoked), nil
+}
+
+func (bot *Bot) DisableWebUser(call *CallContext) (string, error) {
+	return bot.setWebUserDisabled(call.Args, true)
+}
+
+func (bot *Bot) EnableWebUser(call *CallContext) (string, error) {
+	return bot.setWebUserDisabled(call.Args, false)
+}
+
+// Формат: "имя [пароль]". Без пароля он генерируется. Все сессии пользователя отзываются
+func (bot *Bot) ResetWebPassword(call *CallContext) (string, error) {
+	parts := strings.Fields(call.Args)
+	if len(parts) == 0 {
+		return "", errors.New("имя пользователя не указано")
+	}
+
+	password := generatePassword()
+	if len(parts) > 1 {
+		password = parts[1]
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return "", err
+	}
+
+	found, err := bot.conf.GetDB().SetWebUserPassword(parts[0], hash)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("пользователь \"%s\" не найден", parts[0])
+	}
+
+	revoked, err := bot.conf.GetDB().RevokeWebSessions(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("пароль изменен, но не удалось отозвать сессии: %w", err)
+	}
+
+	return fmt.Sprintf("Пароль пользователя *%s* изменен на `%s`. Отозвано сессий: %d", parts[0], password, revoked), nil
+}
+
+func (bot *Bot) ListWebSessions(call *CallContext) (string, error) {
+	username := strings.TrimSpace(call.Args)
+	if username == "" {
+		return "", errors.New("имя пользователя не указано")
+	}
+
+	sessions, err := bot.conf.GetDB().GetActiveWebSessions(username)
+	if err != nil {
+		return "", err
+	}
+	if len(sessions) == 0 {
+		return fmt.Sprintf("У пользователя *%s* нет действующих сессий", username), nil
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("*Действующие сессии %s:*\n", username))
+	for _, session := range sessions {
+		response.WriteString(fmt.Sprintf(
+			"- `%s`: выдана %s, истекает %s\n",
+			session.JTI,
+			time.Unix(session.IssuedAt, 0).Format("2006-01-02 15:04"),
+			time.Unix(session.ExpiresAt, 0).Format("2006-01-02 15:04"),
+		))
+	}
+
+	return response.String(), nil
+}
+
+// Отзывает сессию по jti или все сессии пользователя по имени
+func (bot *Bot) RevokeWebSession(call *CallContext) (string, error) {
+	target := strings.TrimSpace(call.Args)
+	if target == "" {
+		return "", errors.New("укажите jti сессии или имя пользователя")
+	}
+
+	user, err := bot.conf.GetDB().GetWebUser(target)
+	if err != nil {
+		return "", err
+	}
+	if user != nil {
+		revoked, err := bot.conf.GetDB().RevokeWebSessions(user.Username)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Отозвано сессий пользователя *%s*: %d", user.Username, revoked), nil
+	}
+
+	revoked, err := bot.conf.GetDB().RevokeWebSession(target)
+	if err != nil {
+		return "", err
+	}
+	if !revoked {
+		return "", errors.New("действующая сессия или пользователь не найдены")
+	}
+
+	return "Сессия отозвана", nil
+}
@@ -0,0 +1,169 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"database/sql"
+	"encoding/json"
+	"strings"
+)
+
+const auditSchema = `CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at INTEGER NOT NULL,
+		platform TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		user_name TEXT DEFAULT '',
+		command TEXT NOT NULL,
+		args TEXT DEFAULT '',
+		error TEXT DEFAULT '',
+		changes TEXT DEFAULT '[]',
+		has_changes BOOLEAN DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_time ON audit_log(created_at);
+`
+
+const auditColumns = `id, created_at, platform, user_id, user_name, command, args, error, changes`
+
+func scanAuditEntry(row rowScanner) (*domain.AuditEntry, error) {
+	var (
+		entry   domain.AuditEntry
+		changes string
+	)
+	err := row.Scan(
+		&entry.ID, &entry.CreatedAt, &entry.Platform, &entry.UserID, &entry.UserName,
+		&entry.Command, &entry.Args, &entry.Error, &changes,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (db *DB) SaveAuditEntry(entry *domain.AuditEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+	if entry.Changes == nil {
+		changes = []byte("[]")
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO audit_log(created_at, platform, user_id, user_name, command, args, error, changes, has_changes)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.CreatedAt, entry.Platform, entry.UserID, entry.UserName,
+		entry.Command, entry.Args, entry.Error, string(changes), len(entry.Changes) > 0,
+	)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+func auditFilterClause(filter domain.AuditFilter) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	if filter.User != "" {
+		conditions = append(conditions, "(user_id = ? OR user_name = ?)")
+		args = append(args, filter.User, strings.TrimPrefix(filter.User, "@"))
+	}
+
+	if filter.Command != "" {
+		conditions = append(conditions, "command = ?")
+		args = append(args, filter.Command)
+	}
+
+	if filter.From > 0 {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From)
+	}
+
+	if filter.To > 0 {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, filter.To)
+	}
+
+	if filter.ChangesOnly {
+		conditions = append(conditions, "has_changes = 1")
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Возвращает страницу журнала по фильтру (новые сначала) и общее количество подходящих записей
+func (db *DB) QueryAudit(filter domain.AuditFilter) ([]domain.AuditEntry, int, error) {
+	where, args := auditFilterClause(filter)
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_log" + where + " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []domain.AuditEntry
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, *entry)
+	}
+
+	return entries, total, rows.Err()
+}
+
+// Возвращает запись журнала по ID или nil, если ее нет
+func (db *DB) GetAuditEntry(id int64) (*domain.AuditEntry, error) {
+	entry, err := scanAuditEntry(db.QueryRow("SELECT "+auditColumns+" FROM audit_log WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
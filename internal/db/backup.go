@@ -0,0 +1,87 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Записывает согласованную копию базы в файл path (VACUUM INTO). База при этом
+// остается доступной для чтения и записи. Файл не должен существовать
+func (db *DB) BackupTo(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("файл %s уже существует", path)
+	}
+
+	_, err := db.ExecContext(ctx, "VACUUM INTO ?", path)
+	return err
+}
+
+// Проверяет копию базы перед восстановлением: целостность файла и версию схемы.
+// Базы старше текущей схемы допустимы (недостающие миграции применятся при открытии),
+// базы новее - нет. Возвращает версию схемы копии, 0 - база создана до появления миграций
+func CheckBackup(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+
+	conn, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var integrity string
+	if err := conn.QueryRow("PRAGMA quick_check").Scan(&integrity); err != nil {
+		return 0, fmt.Errorf("файл не является базой SQLite: %w", err)
+	}
+	if integrity != "ok" {
+		return 0, fmt.Errorf("база повреждена: %s", integrity)
+	}
+
+	articlesExist, err := tableExists(conn, "articles")
+	if err != nil {
+		return 0, err
+	}
+	if !articlesExist {
+		return 0, errors.New("в базе нет таблицы статей")
+	}
+
+	versioned, err := tableExists(conn, "schema_version")
+	if err != nil {
+		return 0, err
+	}
+	if !versioned {
+		return 0, nil
+	}
+
+	version, err := schemaVersion(conn)
+	if err != nil {
+		return 0, err
+	}
+	if version > LatestSchemaVersion() {
+		return version, fmt.Errorf("версия схемы копии (%d) новее поддерживаемой (%d): обновите бота", version, LatestSchemaVersion())
+	}
+
+	return version, nil
+}
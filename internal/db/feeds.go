@@ -0,0 +1,177 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"time"
+)
+
+const feedsSchema = `CREATE TABLE IF NOT EXISTS feeds (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL UNIQUE,
+		title TEXT DEFAULT '',
+		interval_minutes INTEGER NOT NULL,
+		last_checked INTEGER DEFAULT 0,
+		last_error TEXT DEFAULT '',
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS feed_items (
+		feed_id INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		seen_at INTEGER NOT NULL,
+		PRIMARY KEY (feed_id, url)
+	);
+`
+
+func (db *DB) AddFeed(url string, title string, intervalMinutes uint) (*domain.Feed, error) {
+	now := time.Now().Unix()
+	result, err := db.Exec(
+		"INSERT INTO feeds(url, title, interval_minutes, last_checked, created_at) VALUES(?, ?, ?, ?, ?)",
+		url, title, intervalMinutes, now, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.Feed{
+		ID:              id,
+		URL:             url,
+		Title:           title,
+		IntervalMinutes: intervalMinutes,
+		LastChecked:     now,
+		CreatedAt:       now,
+	}, nil
+}
+
+// Удаляет ленту вместе с запомненными записями. Возвращает false, если ленты нет
+func (db *DB) RemoveFeed(feedID int64) (bool, error) {
+	result, err := db.Exec("DELETE FROM feeds WHERE id = ?", feedID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = db.Exec("DELETE FROM feed_items WHERE feed_id = ?", feedID)
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (db *DB) GetFeeds() ([]domain.Feed, error) {
+	rows, err := db.Query(`
+		SELECT id, url, title, interval_minutes, last_checked, last_error, created_at
+		FROM feeds
+		ORDER BY id ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feeds []domain.Feed
+	for rows.Next() {
+		var f domain.Feed
+		if err := rows.Scan(
+			&f.ID,
+			&f.URL,
+			&f.Title,
+			&f.IntervalMinutes,
+			&f.LastChecked,
+			&f.LastError,
+			&f.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, f)
+	}
+
+	return feeds, nil
+}
+
+func (db *DB) GetFeed(feedID int64) (*domain.Feed, error) {
+	return db.getFeedWhere("id = ?", feedID)
+}
+
+func (db *DB) GetFeedByURL(url string) (*domain.Feed, error) {
+	return db.getFeedWhere("url = ?", url)
+}
+
+func (db *DB) getFeedWhere(condition string, args ...any) (*domain.Feed, error) {
+	var f domain.Feed
+	err := db.QueryRow(`
+		SELECT id, url, title, interval_minutes, last_checked, last_error, created_at
+		FROM feeds
+		WHERE `+condition,
+		args...,
+	).Scan(
+		&f.ID,
+		&f.URL,
+		&f.Title,
+		&f.IntervalMinutes,
+		&f.LastChecked,
+		&f.LastError,
+		&f.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+func (db *DB) UpdateFeedChecked(feedID int64, checkedAt int64, lastError string) error {
+	_, err := db.Exec(
+		"UPDATE feeds SET last_checked = ?, last_error = ? WHERE id = ?",
+		checkedAt, lastError, feedID,
+	)
+	return err
+}
+
+func (db *DB) IsFeedItemSeen(feedID int64, url string) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM feed_items WHERE feed_id = ? AND url = ?",
+		feedID, url,
+	).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (db *DB) MarkFeedItemSeen(feedID int64, url string) error {
+	_, err := db.Exec(
+		"INSERT OR IGNORE INTO feed_items(feed_id, url, seen_at) VALUES(?, ?, ?)",
+		feedID, url, time.Now().Unix(),
+	)
+	return err
+}
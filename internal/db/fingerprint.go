@@ -0,0 +1,137 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"Unbewohnte/ACASbot/internal/similarity"
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// Вычисляет отпечатки текста статей, у которых их еще нет. update получает
+// хеш, SimHash, MinHash и ID статьи
+func fillFingerprints(db querier, update string) error {
+	rows, err := db.Query("SELECT id, content FROM articles WHERE content_hash = '' AND content <> ''")
+	if err != nil {
+		return err
+	}
+
+	fingerprints := make(map[int64]similarity.Fingerprint)
+	for rows.Next() {
+		var (
+			id      int64
+			content string
+		)
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		fingerprints[id] = similarity.NewFingerprint(content)
+	}
+	rows.Close()
+
+	for id, fp := range fingerprints {
+		if _, err := db.Exec(
+			update,
+			fp.ContentHash, int64(fp.SimHash), similarity.EncodeMinHash(fp.MinHash), id,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Найденная почти дословная копия
+type nearDuplicate struct {
+	id    int64
+	score float64
+}
+
+// Отбирает почти дословные копии среди строк (id, simhash, minhash) по убыванию оценки
+func scanNearDuplicates(rows *sql.Rows, fp similarity.Fingerprint, threshold float64) ([]nearDuplicate, error) {
+	var found []nearDuplicate
+	for rows.Next() {
+		var (
+			id        int64
+			simHash   int64
+			signature []byte
+		)
+		if err := rows.Scan(&id, &simHash, &signature); err != nil {
+			return nil, err
+		}
+
+		if score, ok := fp.NearDuplicate(uint64(simHash), similarity.DecodeMinHash(signature), threshold); ok {
+			found = append(found, nearDuplicate{id, score})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].score > found[j].score
+	})
+
+	return found, nil
+}
+
+// Оригинальные статьи за последние maxAgeDays дней, почти дословно совпадающие
+// с content: близкий SimHash и оценка Жаккара по MinHash не ниже threshold.
+// Оценка записывается в TextSimilarity
+func (db *DB) FindNearDuplicates(ctx context.Context, content string, threshold float64, maxAgeDays uint) ([]domain.Article, error) {
+	fp := similarity.NewFingerprint(content)
+	if len(fp.MinHash) == 0 {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+        SELECT id, simhash, minhash
+        FROM articles
+        WHERE original >= 1 AND created_at >= ? AND minhash IS NOT NULL`,
+		time.Now().AddDate(0, 0, -int(maxAgeDays)).Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	found, err := scanNearDuplicates(rows, fp, threshold)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	articles := make([]domain.Article, 0, len(found))
+	for _, duplicate := range found {
+		article, err := db.GetArticle(duplicate.id)
+		if err != nil {
+			return nil, err
+		}
+		if article == nil {
+			continue
+		}
+
+		article.TextSimilarity = duplicate.score
+		articles = append(articles, *article)
+	}
+
+	return articles, nil
+}
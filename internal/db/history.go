@@ -0,0 +1,238 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+	"time"
+)
+
+// Прежние результаты анализа статей. Пустой объект - результат, хранившийся только
+// в самой статье (статьи, сохраненные до появления результатов по объектам)
+const analysisHistorySchema = `
+CREATE TABLE IF NOT EXISTS analysis_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    article_id INTEGER NOT NULL,
+    object TEXT NOT NULL,
+    affiliation TEXT,
+    sentiment TEXT,
+    justification TEXT,
+    confidence REAL DEFAULT 0,
+    replaced_at INTEGER NOT NULL,
+    provenance TEXT DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_analysis_history_article ON analysis_history(article_id);
+`
+
+// Заменяет результаты анализа статьи новыми (article.Objects, результат основного объекта
+// и происхождение), перенося прежние в историю. Заголовок и текст статьи не меняются,
+// отметка о ручном исправлении снимается
+func (db *DB) ReplaceArticleAnalysis(ctx context.Context, article *domain.Article) error {
+	provenance, err := encodeProvenance(article.Provenance)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	replacedAt := time.Now().Unix()
+	result, err := tx.ExecContext(ctx, `INSERT INTO analysis_history(
+            article_id, object, affiliation, sentiment, justification, confidence, replaced_at, provenance
+        )
+        SELECT o.article_id, o.object, o.affiliation, o.sentiment, o.justification, o.confidence, ?, COALESCE(a.provenance, '')
+        FROM article_objects o
+        JOIN articles a ON a.id = o.article_id
+        WHERE o.article_id = ?
+        ORDER BY o.id ASC`,
+		replacedAt,
+		article.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if moved, err := result.RowsAffected(); err != nil {
+		return err
+	} else if moved == 0 {
+		_, err = tx.ExecContext(ctx, `INSERT INTO analysis_history(
+                article_id, object, affiliation, sentiment, justification, confidence, replaced_at, provenance
+            )
+            SELECT id, '', affiliation, sentiment, justification, confidence, ?, COALESCE(provenance, '')
+            FROM articles
+            WHERE id = ?`,
+			replacedAt,
+			article.ID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE articles
+        SET affiliation = ?, sentiment = ?, justification = ?, confidence = ?, provenance = ?,
+            verified_by = '', verified_at = 0
+        WHERE id = ?`,
+		article.Affiliation,
+		article.Sentiment,
+		article.Justification,
+		article.Confidence,
+		provenance,
+		article.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM article_objects WHERE article_id = ?", article.ID); err != nil {
+		return err
+	}
+	if err := saveArticleObjects(tx, article.ID, article.Objects); err != nil {
+		return err
+	}
+	if err := savePrompts(tx, article.Provenance); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Прежние результаты анализа статьи, новые сначала
+func (db *DB) GetAnalysisHistory(articleID int64) ([]domain.AnalysisHistoryEntry, error) {
+	rows, err := db.Query(`
+        SELECT id, article_id, object, COALESCE(affiliation, ''), COALESCE(sentiment, ''),
+            COALESCE(justification, ''), confidence, replaced_at, COALESCE(provenance, '')
+        FROM analysis_history
+        WHERE article_id = ?
+        ORDER BY replaced_at DESC, id ASC`,
+		articleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []domain.AnalysisHistoryEntry
+	for rows.Next() {
+		var (
+			entry      domain.AnalysisHistoryEntry
+			provenance string
+		)
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.ArticleID,
+			&entry.Object,
+			&entry.Affiliation,
+			&entry.Sentiment,
+			&entry.Justification,
+			&entry.Confidence,
+			&entry.ReplacedAt,
+			&provenance,
+		); err != nil {
+			return nil, err
+		}
+
+		entry.Provenance, err = decodeProvenance(provenance)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// Ручное исправление отношения статьи к объекту. Прежний результат переносится в историю,
+// новый помечается как проверенный человеком. Пустой объект - статья без результатов
+// по объектам; primary - объект основной, и его результат хранится и в самой статье
+func (db *DB) CorrectSentiment(ctx context.Context, articleID int64, object string, primary bool, sentiment string, justification string, verifiedBy string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	if object != "" {
+		_, err = tx.ExecContext(ctx, `INSERT INTO analysis_history(
+                article_id, object, affiliation, sentiment, justification, confidence, replaced_at, provenance
+            )
+            SELECT o.article_id, o.object, o.affiliation, o.sentiment, o.justification, o.confidence, ?, COALESCE(a.provenance, '')
+            FROM article_objects o
+            JOIN articles a ON a.id = o.article_id
+            WHERE o.article_id = ? AND o.object = ?`,
+			now,
+			articleID,
+			object,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE article_objects
+            SET sentiment = ?, justification = ?, confidence = 1, verified = 1
+            WHERE article_id = ? AND object = ?`,
+			sentiment,
+			justification,
+			articleID,
+			object,
+		)
+		if err != nil {
+			return err
+		}
+	} else {
+		_, err = tx.ExecContext(ctx, `INSERT INTO analysis_history(
+                article_id, object, affiliation, sentiment, justification, confidence, replaced_at, provenance
+            )
+            SELECT id, '', affiliation, sentiment, justification, confidence, ?, COALESCE(provenance, '')
+            FROM articles
+            WHERE id = ?`,
+			now,
+			articleID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if object == "" || primary {
+		_, err = tx.ExecContext(ctx, `UPDATE articles
+            SET sentiment = ?, justification = ?, confidence = 1
+            WHERE id = ?`,
+			sentiment,
+			justification,
+			articleID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE articles SET verified_by = ?, verified_at = ? WHERE id = ?", verifiedBy, now, articleID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,368 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"Unbewohnte/ACASbot/internal/similarity"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Начальное число соседей при поиске по индексу
+const indexInitialK = 32
+
+// Кодирует вектор в BLOB из float32 (little-endian)
+func encodeEmbedding(embedding []float64) []byte {
+	encoded := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(encoded[i*4:], math.Float32bits(float32(v)))
+	}
+
+	return encoded
+}
+
+// Старые базы хранят векторы в JSON
+func isJSONEmbedding(raw []byte) bool {
+	raw = bytes.TrimSpace(raw)
+	if bytes.Equal(raw, []byte("null")) {
+		return true
+	}
+
+	return len(raw) >= 2 && raw[0] == '[' && raw[len(raw)-1] == ']' && json.Valid(raw)
+}
+
+// Декодирует вектор из BLOB float32 или устаревшего JSON
+func decodeEmbedding(raw []byte) ([]float64, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	if isJSONEmbedding(raw) {
+		var embedding []float64
+		if err := json.Unmarshal(raw, &embedding); err != nil {
+			return nil, err
+		}
+		return embedding, nil
+	}
+
+	if len(raw)%4 != 0 {
+		return nil, ErrCorruptEmbedding
+	}
+
+	embedding := make([]float64, len(raw)/4)
+	for i := range embedding {
+		embedding[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:])))
+	}
+
+	return embedding, nil
+}
+
+// Переводит векторы, сохраненные в JSON, в двоичный формат
+func migrateEmbeddings(db querier) error {
+	rows, err := db.Query(`SELECT id, embedding FROM articles WHERE substr(embedding, 1, 1) IN (X'5B', X'6E', '[', 'n')`)
+	if err != nil {
+		return err
+	}
+
+	converted := make(map[int64][]byte)
+	for rows.Next() {
+		var (
+			id  int64
+			raw []byte
+		)
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+
+		if !isJSONEmbedding(raw) {
+			continue
+		}
+
+		embedding, err := decodeEmbedding(raw)
+		if err != nil {
+			continue
+		}
+		converted[id] = encodeEmbedding(embedding)
+	}
+	rows.Close()
+
+	if len(converted) == 0 {
+		return nil
+	}
+
+	for id, encoded := range converted {
+		if _, err := db.Exec("UPDATE articles SET embedding = ? WHERE id = ?", encoded, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type indexedArticle struct {
+	createdAt int64
+	original  bool
+}
+
+// Индекс векторов статей для поиска похожих
+type embeddingIndex struct {
+	mu        sync.RWMutex
+	hnsw      *similarity.HNSW
+	articles  map[int64]indexedArticle
+	ready     atomic.Bool
+	buildTime time.Duration
+}
+
+func newEmbeddingIndex() *embeddingIndex {
+	return &embeddingIndex{
+		hnsw:     similarity.NewHNSW(similarity.DefaultHNSWM, similarity.DefaultHNSWEfConstruction, similarity.DefaultHNSWEfSearch),
+		articles: make(map[int64]indexedArticle),
+	}
+}
+
+func (index *embeddingIndex) add(id int64, embedding []float64, createdAt int64, original bool) {
+	if len(embedding) == 0 {
+		return
+	}
+
+	if !index.hnsw.Add(id, similarity.ToFloat32(embedding)) {
+		// Вектор другой модели, найдется только полным перебором
+		return
+	}
+
+	index.mu.Lock()
+	index.articles[id] = indexedArticle{createdAt: createdAt, original: original}
+	index.mu.Unlock()
+}
+
+func (index *embeddingIndex) reset() {
+	index.hnsw.Reset()
+
+	index.mu.Lock()
+	index.articles = make(map[int64]indexedArticle)
+	index.mu.Unlock()
+}
+
+func (index *embeddingIndex) remove(id int64) {
+	index.hnsw.Remove(id)
+
+	index.mu.Lock()
+	delete(index.articles, id)
+	index.mu.Unlock()
+}
+
+// Заполняет индекс всеми статьями. Новые статьи добавляются первыми,
+// чтобы размерность индекса соответствовала текущей модели векторизации.
+// До окончания построения поиск идет полным перебором
+func (index *embeddingIndex) build(db *sql.DB) error {
+	start := time.Now()
+
+	// Строки читаются целиком до построения графа: открытое на все построение чтение
+	// мешало бы одновременному сохранению статей
+	type storedVector struct {
+		id        int64
+		raw       []byte
+		createdAt int64
+		original  bool
+	}
+
+	rows, err := db.Query("SELECT id, embedding, created_at, original FROM articles ORDER BY id DESC")
+	if err != nil {
+		return err
+	}
+
+	var stored []storedVector
+	for rows.Next() {
+		var vector storedVector
+		if err := rows.Scan(&vector.id, &vector.raw, &vector.createdAt, &vector.original); err != nil {
+			rows.Close()
+			return err
+		}
+		stored = append(stored, vector)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range stored {
+		embedding, err := decodeEmbedding(stored[i].raw)
+		stored[i].raw = nil
+		if err != nil {
+			continue
+		}
+		index.add(stored[i].id, embedding, stored[i].createdAt, stored[i].original)
+	}
+
+	index.mu.Lock()
+	index.buildTime = time.Since(start)
+	index.mu.Unlock()
+	index.ready.Store(true)
+
+	return nil
+}
+
+// Угловое сходство, как в similarity.SemanticSimilarity
+func angularSimilarity(cosine float64) float64 {
+	cosine = math.Max(-1, math.Min(1, cosine))
+	return 1 - math.Acos(cosine)/math.Pi
+}
+
+// ID статей не старше since со сходством не ниже threshold
+func (index *embeddingIndex) search(query []float32, threshold float64, since int64, originalOnly bool) map[int64]float64 {
+	found := make(map[int64]float64)
+
+	size := index.hnsw.Len()
+	for k := indexInitialK; ; k *= 2 {
+		neighbors := index.hnsw.Search(query, k)
+
+		index.mu.RLock()
+		for _, neighbor := range neighbors {
+			sim := angularSimilarity(neighbor.Similarity)
+			if sim < threshold || math.IsNaN(sim) {
+				continue
+			}
+
+			article, ok := index.articles[neighbor.ID]
+			if !ok || (originalOnly && !article.original) || article.createdAt < since {
+				continue
+			}
+			found[neighbor.ID] = sim
+		}
+		index.mu.RUnlock()
+
+		// Расширяем поиск, пока самый дальний из найденных еще проходит порог
+		if len(neighbors) < k || k >= size {
+			break
+		}
+		if angularSimilarity(neighbors[len(neighbors)-1].Similarity) < threshold {
+			break
+		}
+	}
+
+	return found
+}
+
+// Сведения об индексе
+type IndexStats struct {
+	Ready      bool
+	Vectors    int
+	Dimensions int
+	BuildTime  time.Duration
+}
+
+func (db *DB) IndexStats() IndexStats {
+	db.index.mu.RLock()
+	buildTime := db.index.buildTime
+	db.index.mu.RUnlock()
+
+	return IndexStats{
+		Ready:      db.index.ready.Load(),
+		Vectors:    db.index.hnsw.Len(),
+		Dimensions: db.index.hnsw.Dimensions(),
+		BuildTime:  buildTime,
+	}
+}
+
+// Загружает статьи по ID
+func (db *DB) getArticlesByIDs(ctx context.Context, ids []int64) ([]domain.Article, error) {
+	var articles []domain.Article
+	for start := 0; start < len(ids); start += 500 {
+		end := start + 500
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", end-start), ",")
+		args := make([]any, 0, end-start)
+		for _, id := range ids[start:end] {
+			args = append(args, id)
+		}
+
+		rows, err := db.QueryContext(ctx, "SELECT "+articleColumns+" FROM articles WHERE id IN ("+placeholders+")", args...)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			a, err := scanArticle(rows)
+			if err != nil {
+				continue
+			}
+			articles = append(articles, *a)
+		}
+		rows.Close()
+	}
+
+	return articles, nil
+}
+
+// Поиск похожих оригинальных статей через индекс. Если размерность запроса
+// не совпадает с индексом (сменилась модель), используется полный перебор
+func (db *DB) FindSimilar(ctx context.Context, target []float64, threshold float64, maxAgeDays uint) ([]domain.Article, error) {
+	return db.findSimilar(ctx, target, threshold, time.Now().AddDate(0, 0, -int(maxAgeDays)).Unix(), true)
+}
+
+// Поиск похожих статей любого возраста, включая неоригинальные
+func (db *DB) FindNeighbors(ctx context.Context, target []float64, threshold float64) ([]domain.Article, error) {
+	return db.findSimilar(ctx, target, threshold, 0, false)
+}
+
+func (db *DB) findSimilar(ctx context.Context, target []float64, threshold float64, since int64, originalOnly bool) ([]domain.Article, error) {
+	// Normalize the target vector once
+	similarity.NormalizeVector(target)
+
+	if !db.index.ready.Load() || db.index.hnsw.Dimensions() != len(target) {
+		return db.findSimilarLinear(ctx, target, threshold, since, originalOnly)
+	}
+
+	found := db.index.search(similarity.ToFloat32(target), threshold, since, originalOnly)
+	if len(found) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int64, 0, len(found))
+	for id := range found {
+		ids = append(ids, id)
+	}
+
+	articles, err := db.getArticlesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range articles {
+		similarity.NormalizeVector(articles[i].Embedding)
+		articles[i].Similarity = found[articles[i].ID]
+	}
+	sort.Slice(articles, func(i, j int) bool { return articles[i].Similarity > articles[j].Similarity })
+
+	return articles, nil
+}
@@ -0,0 +1,88 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import "context"
+
+// Количество строк в таблице
+type TableRows struct {
+	Table string
+	Rows  int64
+}
+
+// Сведения о базе данных
+type Info struct {
+	SchemaVersion int
+	LatestVersion int
+	Migrations    []AppliedMigration
+	SizeBytes     int64
+	Tables        []TableRows
+}
+
+func (db *DB) Info(ctx context.Context) (*Info, error) {
+	info := Info{LatestVersion: LatestSchemaVersion()}
+
+	var err error
+	info.SchemaVersion, err = db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	info.Migrations, err = db.AppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.QueryRowContext(ctx, "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&info.SizeBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Обычные таблицы без служебных и таблиц полнотекстового индекса
+	rows, err := db.QueryContext(ctx, `SELECT name FROM pragma_table_list
+        WHERE schema = 'main' AND type = 'table' AND name NOT LIKE 'sqlite_%'
+        ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, table := range tables {
+		count := TableRows{Table: table}
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM "`+table+`"`).Scan(&count.Rows); err != nil {
+			return nil, err
+		}
+		info.Tables = append(info.Tables, count)
+	}
+
+	return &info, nil
+}
@@ -0,0 +1,311 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"database/sql"
+	"time"
+)
+
+const jobsSchema = `CREATE TABLE IF NOT EXISTS batches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_id INTEGER DEFAULT 0,
+		message_id INTEGER DEFAULT 0,
+		profile TEXT DEFAULT '',
+		created_at INTEGER NOT NULL,
+		finished_at INTEGER DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		batch_id INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		error TEXT DEFAULT '',
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+	CREATE INDEX IF NOT EXISTS idx_jobs_batch ON jobs(batch_id);
+`
+
+// Создает новый пакет и ставит все URL в очередь одной транзакцией.
+// profile - область действия профиля, настройки которого применяются к заданиям
+func (db *DB) CreateBatch(chatID int64, profile string, urls []string) (*domain.Batch, error) {
+	return db.createBatch(&domain.Batch{ChatID: chatID, Profile: profile}, urls)
+}
+
+// Ставит новые записи ленты в очередь отдельным пакетом
+func (db *DB) CreateFeedBatch(feedID int64, urls []string) (*domain.Batch, error) {
+	return db.createBatch(&domain.Batch{FeedID: feedID}, urls)
+}
+
+func (db *DB) createBatch(batch *domain.Batch, urls []string) (*domain.Batch, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	result, err := tx.Exec(
+		"INSERT INTO batches(chat_id, profile, feed_id, created_at) VALUES(?, ?, ?, ?)",
+		batch.ChatID, batch.Profile, batch.FeedID, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	batchID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, url := range urls {
+		_, err = tx.Exec(
+			"INSERT INTO jobs(batch_id, url, status, created_at, updated_at) VALUES(?, ?, ?, ?, ?)",
+			batchID, url, domain.JobPending, now, now,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	batch.ID = batchID
+	batch.CreatedAt = now
+
+	return batch, nil
+}
+
+func (db *DB) GetBatch(batchID int64) (*domain.Batch, error) {
+	var batch domain.Batch
+	err := db.QueryRow(
+		"SELECT id, chat_id, message_id, profile, feed_id, created_at, finished_at FROM batches WHERE id = ?",
+		batchID,
+	).Scan(
+		&batch.ID,
+		&batch.ChatID,
+		&batch.MessageID,
+		&batch.Profile,
+		&batch.FeedID,
+		&batch.CreatedAt,
+		&batch.FinishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &batch, nil
+}
+
+// Возвращает пакеты, задания которых еще не обработаны до конца
+func (db *DB) GetUnfinishedBatches() ([]domain.Batch, error) {
+	rows, err := db.Query(
+		"SELECT id, chat_id, message_id, profile, feed_id, created_at, finished_at FROM batches WHERE finished_at = 0 ORDER BY id ASC",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []domain.Batch
+	for rows.Next() {
+		var batch domain.Batch
+		if err := rows.Scan(
+			&batch.ID,
+			&batch.ChatID,
+			&batch.MessageID,
+			&batch.Profile,
+			&batch.FeedID,
+			&batch.CreatedAt,
+			&batch.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+
+	return batches, nil
+}
+
+func (db *DB) SetBatchMessageID(batchID int64, messageID int) error {
+	_, err := db.Exec("UPDATE batches SET message_id = ? WHERE id = ?", messageID, batchID)
+	return err
+}
+
+// Помечает пакет завершенным. Возвращает false, если пакет уже был завершен ранее
+func (db *DB) FinishBatch(batchID int64) (bool, error) {
+	result, err := db.Exec(
+		"UPDATE batches SET finished_at = ? WHERE id = ? AND finished_at = 0",
+		time.Now().Unix(), batchID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// Забирает следующее ожидающее задание и помечает его выполняемым.
+// Возвращает nil, если очередь пуста
+func (db *DB) ClaimNextJob() (*domain.Job, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var job domain.Job
+	err = tx.QueryRow(`
+		SELECT id, batch_id, url, status, error, created_at, updated_at
+		FROM jobs
+		WHERE status = ?
+		ORDER BY id ASC
+		LIMIT 1`,
+		domain.JobPending,
+	).Scan(
+		&job.ID,
+		&job.BatchID,
+		&job.URL,
+		&job.Status,
+		&job.Error,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	job.Status = domain.JobRunning
+	job.UpdatedAt = time.Now().Unix()
+	_, err = tx.Exec(
+		"UPDATE jobs SET status = ?, updated_at = ? WHERE id = ?",
+		job.Status, job.UpdatedAt, job.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (db *DB) FinishJob(jobID int64, status string, errText string) error {
+	_, err := db.Exec(
+		"UPDATE jobs SET status = ?, error = ?, updated_at = ? WHERE id = ?",
+		status, errText, time.Now().Unix(), jobID,
+	)
+	return err
+}
+
+// Возвращает прерванные (например, перезапуском) задания обратно в очередь
+func (db *DB) ResetRunningJobs() (int64, error) {
+	result, err := db.Exec(
+		"UPDATE jobs SET status = ?, updated_at = ? WHERE status = ?",
+		domain.JobPending, time.Now().Unix(), domain.JobRunning,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (db *DB) GetBatchProgress(batchID int64) (domain.BatchProgress, error) {
+	var progress domain.BatchProgress
+
+	rows, err := db.Query(
+		"SELECT status, COUNT(*) FROM jobs WHERE batch_id = ? GROUP BY status",
+		batchID,
+	)
+	if err != nil {
+		return progress, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return progress, err
+		}
+
+		switch status {
+		case domain.JobPending:
+			progress.Pending = count
+		case domain.JobRunning:
+			progress.Running = count
+		case domain.JobDone:
+			progress.Done = count
+		case domain.JobFailed:
+			progress.Failed = count
+		}
+		progress.Total += count
+	}
+
+	return progress, nil
+}
+
+func (db *DB) GetFailedJobs(batchID int64) ([]domain.Job, error) {
+	rows, err := db.Query(`
+		SELECT id, batch_id, url, status, error, created_at, updated_at
+		FROM jobs
+		WHERE batch_id = ? AND status = ?
+		ORDER BY id ASC`,
+		batchID, domain.JobFailed,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []domain.Job
+	for rows.Next() {
+		var job domain.Job
+		if err := rows.Scan(
+			&job.ID,
+			&job.BatchID,
+			&job.URL,
+			&job.Status,
+			&job.Error,
+			&job.CreatedAt,
+			&job.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
@@ -0,0 +1,259 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Исходная схема статей. Колонки, появившиеся позже, добавляются миграциями
+const articlesSchema = `CREATE TABLE IF NOT EXISTS articles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		content TEXT NOT NULL,
+		title TEXT,
+		embedding BLOB NOT NULL,
+		source_url TEXT UNIQUE,
+		created_at INTEGER NOT NULL,
+		published_at INTEGER,
+		citations INTEGER DEFAULT 0,
+		original BOOLEAN DEFAULT 0,
+		similar_urls TEXT DEFAULT '[]',
+		affiliation TEXT,
+		sentiment TEXT,
+		justification TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_articles_time ON articles(created_at);
+	CREATE INDEX IF NOT EXISTS idx_articles_original ON articles(original);
+`
+
+// Примененные миграции
+const schemaVersionSchema = `CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at INTEGER NOT NULL
+	);
+`
+
+type querier interface {
+	execer
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type migration struct {
+	version     int
+	description string
+	up          func(tx querier) error
+}
+
+// Выполняет запросы схемы
+func execSchema(schemas ...string) func(tx querier) error {
+	return func(tx querier) error {
+		for _, schema := range schemas {
+			if _, err := tx.Exec(schema); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// Миграции схемы по возрастанию версии. Новые миграции только дописываются в конец.
+//
+// Миграции до 15 включительно повторяют изменения схемы, которые раньше применялись
+// при каждом запуске без учета версии, поэтому они идемпотентны: базы, созданные
+// до появления schema_version, могут находиться в любом промежуточном состоянии
+var migrations = []migration{
+	{1, "статьи", execSchema(articlesSchema)},
+	{2, "очередь заданий", execSchema(jobsSchema)},
+	{3, "подписки на ленты", execSchema(feedsSchema)},
+	{4, "нерелевантные статьи", func(tx querier) error {
+		return ensureColumn(tx, "articles", "irrelevant", "BOOLEAN DEFAULT 0")
+	}},
+	{5, "результаты по объектам", execSchema(articleObjectsSchema)},
+	{6, "уверенность модели", func(tx querier) error {
+		if err := ensureColumn(tx, "articles", "confidence", "REAL DEFAULT 0"); err != nil {
+			return err
+		}
+		return ensureColumn(tx, "article_objects", "confidence", "REAL DEFAULT 0")
+	}},
+	{7, "векторы в двоичном формате", migrateEmbeddings},
+	{8, "сюжеты", func(tx querier) error {
+		if err := ensureColumn(tx, "articles", "story_id", "INTEGER DEFAULT 0"); err != nil {
+			return err
+		}
+		return execSchema(storiesSchema)(tx)
+	}},
+	{9, "роли пользователей", execSchema(rolesSchema)},
+	{10, "пользователи веб-интерфейса", execSchema(webUsersSchema)},
+	{11, "журнал команд", execSchema(auditSchema)},
+	{12, "профили настроек", func(tx querier) error {
+		if err := execSchema(profilesSchema)(tx); err != nil {
+			return err
+		}
+		return ensureColumn(tx, "batches", "profile", "TEXT DEFAULT ''")
+	}},
+	{13, "история анализа", execSchema(analysisHistorySchema)},
+	{14, "происхождение анализа", func(tx querier) error {
+		if err := ensureColumn(tx, "articles", "provenance", "TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		if err := ensureColumn(tx, "analysis_history", "provenance", "TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+		return execSchema(promptsSchema)(tx)
+	}},
+	{15, "ручная проверка и полнотекстовый поиск", func(tx querier) error {
+		for _, column := range []struct{ table, name, definition string }{
+			{"articles", "verified_by", "TEXT DEFAULT ''"},
+			{"articles", "verified_at", "INTEGER DEFAULT 0"},
+			{"article_objects", "verified", "BOOLEAN DEFAULT 0"},
+		} {
+			if err := ensureColumn(tx, column.table, column.name, column.definition); err != nil {
+				return err
+			}
+		}
+		return createSearchIndex(tx)
+	}},
+	{16, "отпечатки текста", func(tx querier) error {
+		for _, column := range []struct{ name, definition string }{
+			{"content_hash", "TEXT DEFAULT ''"},
+			{"simhash", "INTEGER DEFAULT 0"},
+			{"minhash", "BLOB"},
+		} {
+			if err := ensureColumn(tx, "articles", column.name, column.definition); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_articles_content_hash ON articles(content_hash)"); err != nil {
+			return err
+		}
+		return fillFingerprints(tx, "UPDATE articles SET content_hash = ?, simhash = ?, minhash = ? WHERE id = ?")
+	}},
+	{17, "пакеты из лент", func(tx querier) error {
+		return ensureColumn(tx, "batches", "feed_id", "INTEGER DEFAULT 0")
+	}},
+}
+
+// Версия схемы, которую ожидает эта сборка
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// Примененная миграция
+type AppliedMigration struct {
+	Version     int
+	Description string
+	AppliedAt   int64
+}
+
+func schemaVersion(db querier) (int, error) {
+	var version int
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// Применяет недостающие миграции, каждую в своей транзакции
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(schemaVersionSchema); err != nil {
+		return err
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	latest := LatestSchemaVersion()
+	if current > latest {
+		return fmt.Errorf("версия схемы базы данных (%d) новее поддерживаемой (%d): база создана более новой версией ACASbot", current, latest)
+	}
+
+	for i, m := range migrations {
+		if m.version != i+1 {
+			return fmt.Errorf("миграция %d (%s) стоит не на своем месте", m.version, m.description)
+		}
+		if m.version <= current {
+			continue
+		}
+
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("не удалось применить миграцию %d (%s): %w", m.version, m.description, err)
+		}
+	}
+
+	if current < latest {
+		log.Printf("Схема базы данных обновлена с версии %d до %d", current, latest)
+	}
+
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO schema_version(version, description, applied_at) VALUES(?, ?, ?)",
+		m.version,
+		m.description,
+		time.Now().Unix(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Текущая версия схемы базы данных
+func (db *DB) SchemaVersion() (int, error) {
+	return schemaVersion(db)
+}
+
+// Примененные миграции по возрастанию версии
+func (db *DB) AppliedMigrations() ([]AppliedMigration, error) {
+	rows, err := db.Query("SELECT version, description, applied_at FROM schema_version ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var m AppliedMigration
+		if err := rows.Scan(&m.Version, &m.Description, &m.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, m)
+	}
+
+	return applied, rows.Err()
+}
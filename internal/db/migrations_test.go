@@ -0,0 +1,309 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Статья в том виде, в котором ее сохраняла первая версия бота
+type baselineArticle struct {
+	title     string
+	content   string
+	embedding []float64 // nil сохраняется как JSON null
+	url       string
+	citations int64
+	original  bool
+	similar   []string
+	sentiment string
+}
+
+var baselineArticles = []baselineArticle{
+	{
+		title:     "Завод запустил новую линию",
+		content:   "Предприятие сообщило о запуске производственной линии",
+		embedding: []float64{0.5, -0.25, 0.125, 1},
+		url:       "https://example.com/plant",
+		citations: 2,
+		original:  true,
+		similar:   []string{"https://example.org/copy"},
+		sentiment: "Положительный",
+	},
+	{
+		title:     "Копия новости о заводе",
+		content:   "Предприятие сообщило о запуске линии, пишет издание",
+		embedding: []float64{0.5, -0.25, 0.125, 0.75},
+		url:       "https://example.org/copy",
+		similar:   []string{},
+		sentiment: "Нейтральный",
+	},
+	{
+		title:     "Статья без вектора",
+		content:   "Текст статьи, для которой вектор не был получен",
+		url:       "https://example.net/empty",
+		similar:   []string{},
+		sentiment: "Отрицательный",
+	},
+}
+
+// Создает базу со схемой первой версии бота: векторы в JSON, без schema_version
+func createBaselineDB(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "baseline.db")
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Exec(articlesSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, article := range baselineArticles {
+		embedding, err := json.Marshal(article.embedding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		similar, err := json.Marshal(article.similar)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = conn.Exec(`INSERT INTO articles(
+            content, title, embedding, source_url,
+            created_at, published_at, citations, original, similar_urls,
+            affiliation, sentiment, justification
+        ) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			article.content,
+			article.title,
+			embedding,
+			article.url,
+			1700000000+int64(i),
+			1700000000+int64(i),
+			article.citations,
+			article.original,
+			similar,
+			"Упоминается",
+			article.sentiment,
+			"Обоснование",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return path
+}
+
+// Определения таблиц, индексов и триггеров базы
+func schemaDefinitions(t *testing.T, db *DB) map[string]string {
+	t.Helper()
+
+	rows, err := db.Query("SELECT name, COALESCE(sql, '') FROM sqlite_master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	definitions := make(map[string]string)
+	for rows.Next() {
+		var name, definition string
+		if err := rows.Scan(&name, &definition); err != nil {
+			t.Fatal(err)
+		}
+		definitions[name] = definition
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return definitions
+}
+
+func openDB(t *testing.T, path string) *DB {
+	t.Helper()
+
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// Индекс строится в фоне; база не должна закрываться посреди построения
+	deadline := time.Now().Add(5 * time.Second)
+	for !db.index.ready.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("индекс векторов не построен")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	return db
+}
+
+func checkBaselineArticles(t *testing.T, db *DB) {
+	t.Helper()
+
+	articles, err := db.GetAllArticles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != len(baselineArticles) {
+		t.Fatalf("статей после миграции: %d, ожидалось %d", len(articles), len(baselineArticles))
+	}
+
+	for i, expected := range baselineArticles {
+		article := articles[i]
+		if article.Title != expected.title || article.Content != expected.content || article.SourceURL != expected.url {
+			t.Errorf("статья %d изменилась: %q %q", i, article.Title, article.SourceURL)
+		}
+		if article.Citations != expected.citations || article.Original != expected.original || article.Sentiment != expected.sentiment {
+			t.Errorf("результат анализа статьи %d изменился: %+v", i, article)
+		}
+		if !reflect.DeepEqual(article.SimilarURLs, expected.similar) {
+			t.Errorf("похожие статьи %d: %v, ожидалось %v", i, article.SimilarURLs, expected.similar)
+		}
+		// Значения фикстуры точно представимы в float32
+		if len(article.Embedding) != len(expected.embedding) || (len(expected.embedding) > 0 && !reflect.DeepEqual(article.Embedding, expected.embedding)) {
+			t.Errorf("вектор статьи %d: %v, ожидалось %v", i, article.Embedding, expected.embedding)
+		}
+	}
+
+	// Векторы переведены в двоичный формат
+	var jsonEmbeddings int
+	err = db.QueryRow(`SELECT COUNT(*) FROM articles WHERE substr(embedding, 1, 1) IN (X'5B', X'6E', '[', 'n')`).Scan(&jsonEmbeddings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jsonEmbeddings != 0 {
+		t.Errorf("векторов в JSON после миграции: %d", jsonEmbeddings)
+	}
+
+	// Отпечатки текста посчитаны для старых статей
+	var withoutHash int
+	if err := db.QueryRow("SELECT COUNT(*) FROM articles WHERE content_hash = ''").Scan(&withoutHash); err != nil {
+		t.Fatal(err)
+	}
+	if withoutHash != 0 {
+		t.Errorf("статей без отпечатка текста: %d", withoutHash)
+	}
+
+	// Старые статьи попали в полнотекстовый индекс
+	hits, err := db.SearchArticles(context.Background(), "завод", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 {
+		t.Errorf("найдено статей о заводе: %d, ожидалось 2", len(hits))
+	}
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	db := openDB(t, createBaselineDB(t))
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Fatalf("версия схемы %d, ожидалась %d", version, LatestSchemaVersion())
+	}
+
+	applied, err := db.AppliedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("применено миграций: %d, ожидалось %d", len(applied), len(migrations))
+	}
+	for i, m := range applied {
+		if m.Version != migrations[i].version || m.Description != migrations[i].description {
+			t.Errorf("миграция %d записана как %d (%s)", migrations[i].version, m.Version, m.Description)
+		}
+	}
+
+	checkBaselineArticles(t, db)
+}
+
+func TestMigrateTwice(t *testing.T) {
+	path := createBaselineDB(t)
+
+	first := openDB(t, path)
+	applied, err := first.AppliedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := schemaDefinitions(t, first)
+	first.Close()
+
+	second := openDB(t, path)
+	reapplied, err := second.AppliedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(applied, reapplied) {
+		t.Errorf("повторный запуск изменил журнал миграций: %v -> %v", applied, reapplied)
+	}
+	if !reflect.DeepEqual(schema, schemaDefinitions(t, second)) {
+		t.Error("повторный запуск изменил схему")
+	}
+
+	// Каждая миграция сама по себе повторяема: базы до schema_version могли
+	// находиться в любом промежуточном состоянии
+	for _, m := range migrations {
+		tx, err := second.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.up(tx)
+		tx.Rollback()
+		if err != nil {
+			t.Errorf("повторное применение миграции %d (%s): %v", m.version, m.description, err)
+		}
+	}
+
+	checkBaselineArticles(t, second)
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	path := createBaselineDB(t)
+
+	db := openDB(t, path)
+	_, err := db.Exec(
+		"INSERT INTO schema_version(version, description, applied_at) VALUES(?, ?, ?)",
+		LatestSchemaVersion()+1, "из будущего", 0,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if newer, err := NewDB(path); err == nil {
+		newer.Close()
+		t.Fatal("база с более новой схемой открылась без ошибки")
+	}
+}
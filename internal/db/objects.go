@@ -0,0 +1,175 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"database/sql"
+	"strings"
+)
+
+const articleObjectsSchema = `
+CREATE TABLE IF NOT EXISTS article_objects (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    article_id INTEGER NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    object TEXT NOT NULL,
+    affiliation TEXT,
+    sentiment TEXT,
+    justification TEXT,
+    confidence REAL DEFAULT 0,
+    verified BOOLEAN DEFAULT 0,
+    UNIQUE(article_id, object)
+);
+CREATE INDEX IF NOT EXISTS idx_article_objects_article ON article_objects(article_id);
+`
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func saveArticleObjects(ex execer, articleID int64, objects []domain.ObjectAnalysis) error {
+	for _, object := range objects {
+		_, err := ex.Exec(`INSERT INTO article_objects(
+            article_id, object, affiliation, sentiment, justification, confidence, verified
+        ) VALUES(?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT(article_id, object) DO UPDATE SET
+            affiliation = excluded.affiliation,
+            sentiment = excluded.sentiment,
+            justification = excluded.justification,
+            confidence = excluded.confidence,
+            verified = excluded.verified`,
+			articleID,
+			object.Object,
+			object.Affiliation,
+			object.Sentiment,
+			object.Justification,
+			object.Confidence,
+			object.Verified,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Сохраняет (или обновляет) результаты анализа статьи по объектам
+func (db *DB) SaveArticleObjects(articleID int64, objects []domain.ObjectAnalysis) error {
+	return saveArticleObjects(db, articleID, objects)
+}
+
+func (db *DB) GetArticleObjects(articleID int64) ([]domain.ObjectAnalysis, error) {
+	rows, err := db.Query(`
+        SELECT article_id, object, affiliation, sentiment, justification, confidence, verified
+        FROM article_objects
+        WHERE article_id = ?
+        ORDER BY id ASC`,
+		articleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []domain.ObjectAnalysis
+	for rows.Next() {
+		object, err := scanObjectAnalysis(rows)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, *object)
+	}
+
+	return objects, rows.Err()
+}
+
+func scanObjectAnalysis(row rowScanner) (*domain.ObjectAnalysis, error) {
+	var (
+		object        domain.ObjectAnalysis
+		affiliation   sql.NullString
+		sentiment     sql.NullString
+		justification sql.NullString
+	)
+
+	if err := row.Scan(
+		&object.ArticleID,
+		&object.Object,
+		&affiliation,
+		&sentiment,
+		&justification,
+		&object.Confidence,
+		&object.Verified,
+	); err != nil {
+		return nil, err
+	}
+
+	object.Affiliation = affiliation.String
+	object.Sentiment = sentiment.String
+	object.Justification = justification.String
+
+	return &object, nil
+}
+
+// Подгружает результаты по объектам для всех переданных статей
+func (db *DB) attachArticleObjects(articles []domain.Article) error {
+	if len(articles) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*domain.Article, len(articles))
+	for i := range articles {
+		byID[articles[i].ID] = &articles[i]
+	}
+
+	query := `
+        SELECT article_id, object, affiliation, sentiment, justification, confidence, verified
+        FROM article_objects`
+	var args []any
+
+	// Для небольших выборок не читаем всю таблицу
+	if len(articles) <= 500 {
+		placeholders := make([]string, 0, len(articles))
+		for _, art := range articles {
+			placeholders = append(placeholders, "?")
+			args = append(args, art.ID)
+		}
+		query += " WHERE article_id IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	query += " ORDER BY id ASC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		object, err := scanObjectAnalysis(rows)
+		if err != nil {
+			return err
+		}
+
+		if art, ok := byID[object.ArticleID]; ok {
+			art.Objects = append(art.Objects, *object)
+		}
+	}
+
+	return rows.Err()
+}
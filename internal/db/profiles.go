@@ -0,0 +1,145 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"database/sql"
+	"time"
+)
+
+const profilesSchema = `CREATE TABLE IF NOT EXISTS profiles (
+		scope TEXT PRIMARY KEY,
+		object TEXT DEFAULT '',
+		metadata TEXT DEFAULT '',
+		prompt_affiliation TEXT DEFAULT '',
+		prompt_sentiment TEXT DEFAULT '',
+		prompt_title TEXT DEFAULT '',
+		prompt_structured TEXT DEFAULT '',
+		model TEXT DEFAULT '',
+		spreadsheet_id TEXT DEFAULT '',
+		sheet_name TEXT DEFAULT '',
+		updated_at INTEGER NOT NULL
+	);
+`
+
+const profileColumns = `scope, object, metadata, prompt_affiliation, prompt_sentiment,
+	prompt_title, prompt_structured, model, spreadsheet_id, sheet_name, updated_at`
+
+func scanProfile(row rowScanner) (*domain.Profile, error) {
+	var profile domain.Profile
+	err := row.Scan(
+		&profile.Scope,
+		&profile.Object,
+		&profile.Metadata,
+		&profile.PromptAffiliation,
+		&profile.PromptSentiment,
+		&profile.PromptTitle,
+		&profile.PromptStructured,
+		&profile.Model,
+		&profile.SpreadsheetID,
+		&profile.SheetName,
+		&profile.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// Сохраняет профиль целиком, заменяя прежний
+func (db *DB) SaveProfile(profile *domain.Profile) error {
+	profile.UpdatedAt = time.Now().Unix()
+	_, err := db.Exec(`
+		INSERT INTO profiles(`+profileColumns+`) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(scope) DO UPDATE SET
+			object = excluded.object,
+			metadata = excluded.metadata,
+			prompt_affiliation = excluded.prompt_affiliation,
+			prompt_sentiment = excluded.prompt_sentiment,
+			prompt_title = excluded.prompt_title,
+			prompt_structured = excluded.prompt_structured,
+			model = excluded.model,
+			spreadsheet_id = excluded.spreadsheet_id,
+			sheet_name = excluded.sheet_name,
+			updated_at = excluded.updated_at`,
+		profile.Scope,
+		profile.Object,
+		profile.Metadata,
+		profile.PromptAffiliation,
+		profile.PromptSentiment,
+		profile.PromptTitle,
+		profile.PromptStructured,
+		profile.Model,
+		profile.SpreadsheetID,
+		profile.SheetName,
+		profile.UpdatedAt,
+	)
+
+	return err
+}
+
+// Возвращает профиль или nil, если его нет
+func (db *DB) GetProfile(scope string) (*domain.Profile, error) {
+	profile, err := scanProfile(db.QueryRow(
+		"SELECT "+profileColumns+" FROM profiles WHERE scope = ?",
+		scope,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return profile, err
+}
+
+// Все профили в порядке областей действия
+func (db *DB) GetProfiles() ([]domain.Profile, error) {
+	rows, err := db.Query("SELECT " + profileColumns + " FROM profiles ORDER BY scope")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []domain.Profile
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *profile)
+	}
+
+	return profiles, rows.Err()
+}
+
+// Удаляет профиль. Возвращает false, если профиля не было
+func (db *DB) RemoveProfile(scope string) (bool, error) {
+	result, err := db.Exec("DELETE FROM profiles WHERE scope = ?", scope)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
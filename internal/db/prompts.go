@@ -0,0 +1,150 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Полные тексты промптов, на которые ссылается происхождение результатов анализа
+const promptsSchema = `
+CREATE TABLE IF NOT EXISTS prompts (
+    hash TEXT PRIMARY KEY,
+    text TEXT NOT NULL,
+    created_at INTEGER NOT NULL
+);
+`
+
+// Сохраняет тексты промптов происхождения. Уже известные промпты не перезаписываются
+func savePrompts(ex execer, provenance *domain.Provenance) error {
+	if provenance == nil {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	for _, ref := range provenance.Prompts {
+		if ref.Hash == "" || ref.Text == "" {
+			continue
+		}
+
+		_, err := ex.Exec(
+			"INSERT OR IGNORE INTO prompts(hash, text, created_at) VALUES(?, ?, ?)",
+			ref.Hash,
+			ref.Text,
+			now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Происхождение в виде JSON для колонки provenance
+func encodeProvenance(provenance *domain.Provenance) (string, error) {
+	if provenance == nil {
+		return "", nil
+	}
+
+	encoded, err := json.Marshal(provenance)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+func decodeProvenance(encoded string) (*domain.Provenance, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+
+	var provenance domain.Provenance
+	if err := json.Unmarshal([]byte(encoded), &provenance); err != nil {
+		return nil, err
+	}
+
+	return &provenance, nil
+}
+
+// Возвращает полный текст промпта по хешу или его началу. Пустая строка - промпт не найден
+func (db *DB) GetPrompt(hash string) (string, string, error) {
+	var fullHash, text string
+	err := db.QueryRow(
+		"SELECT hash, text FROM prompts WHERE hash LIKE ? ORDER BY created_at ASC LIMIT 1",
+		strings.ToLower(hash)+"%",
+	).Scan(&fullHash, &text)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return fullHash, text, nil
+}
+
+// Тексты промптов, на которые ссылаются статьи, по их хешам
+func (db *DB) GetArticlesPrompts(articles []domain.Article) (map[string]string, error) {
+	var hashes []any
+	seen := make(map[string]bool)
+	for _, article := range articles {
+		if article.Provenance == nil {
+			continue
+		}
+		for _, ref := range article.Provenance.Prompts {
+			if ref.Hash != "" && !seen[ref.Hash] {
+				seen[ref.Hash] = true
+				hashes = append(hashes, ref.Hash)
+			}
+		}
+	}
+
+	prompts := make(map[string]string, len(hashes))
+	for start := 0; start < len(hashes); start += 500 {
+		end := min(start+500, len(hashes))
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", end-start), ",")
+
+		rows, err := db.Query("SELECT hash, text FROM prompts WHERE hash IN ("+placeholders+")", hashes[start:end]...)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var hash, text string
+			if err := rows.Scan(&hash, &text); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			prompts[hash] = text
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return prompts, nil
+}
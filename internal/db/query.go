@@ -0,0 +1,184 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// Собирает условие WHERE и его аргументы по фильтру
+func articleFilterClause(filter domain.ArticleFilter) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	if filter.Search != "" {
+		conditions = append(conditions, "(title LIKE ? OR source_url LIKE ?)")
+		pattern := "%" + filter.Search + "%"
+		args = append(args, pattern, pattern)
+	}
+
+	if filter.Host != "" {
+		conditions = append(conditions, "(source_url LIKE ? OR source_url LIKE ? OR source_url LIKE ? OR source_url LIKE ?)")
+		args = append(args,
+			"%://"+filter.Host, "%://"+filter.Host+"/%",
+			"%://%."+filter.Host, "%://%."+filter.Host+"/%",
+		)
+	}
+
+	if len(filter.IDs) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(filter.IDs)), ",")
+		conditions = append(conditions, "id IN ("+placeholders+")")
+		for _, id := range filter.IDs {
+			args = append(args, id)
+		}
+	}
+
+	if filter.Object != "" {
+		if filter.Sentiment != "" {
+			conditions = append(conditions, "id IN (SELECT article_id FROM article_objects WHERE object = ? AND sentiment = ?)")
+			args = append(args, filter.Object, filter.Sentiment)
+		} else {
+			conditions = append(conditions, "id IN (SELECT article_id FROM article_objects WHERE object = ?)")
+			args = append(args, filter.Object)
+		}
+	} else if filter.Sentiment != "" {
+		conditions = append(conditions, "sentiment = ?")
+		args = append(args, filter.Sentiment)
+	}
+
+	if filter.From > 0 {
+		conditions = append(conditions, "published_at >= ?")
+		args = append(args, filter.From)
+	}
+
+	if filter.To > 0 {
+		conditions = append(conditions, "published_at <= ?")
+		args = append(args, filter.To)
+	}
+
+	if filter.AddedFrom > 0 {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.AddedFrom)
+	}
+
+	if filter.AddedTo > 0 {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.AddedTo)
+	}
+
+	if filter.Original != nil {
+		conditions = append(conditions, "original = ?")
+		args = append(args, *filter.Original)
+	}
+
+	if filter.Irrelevant != nil {
+		conditions = append(conditions, "irrelevant = ?")
+		args = append(args, *filter.Irrelevant)
+	}
+
+	if filter.Verified != nil {
+		if *filter.Verified {
+			conditions = append(conditions, "verified_at > 0")
+		} else {
+			conditions = append(conditions, "verified_at = 0")
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Возвращает страницу статей по фильтру (новые сначала) и общее количество подходящих статей
+func (db *DB) QueryArticles(filter domain.ArticleFilter) ([]domain.Article, int, error) {
+	where, args := articleFilterClause(filter)
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM articles"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + articleColumns + " FROM articles" + where + " ORDER BY published_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var articles []domain.Article
+	for rows.Next() {
+		a, err := scanArticle(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		articles = append(articles, *a)
+	}
+	rows.Close()
+
+	if err := db.attachArticleObjects(articles); err != nil {
+		return nil, 0, err
+	}
+
+	return articles, total, nil
+}
+
+// Возвращает статью по ID вместе с результатами по объектам или nil, если ее нет
+func (db *DB) GetArticle(id int64) (*domain.Article, error) {
+	return db.getArticleWhere(context.Background(), "id = ?", id)
+}
+
+// Возвращает статью по URL источника вместе с результатами по объектам или nil, если ее нет
+func (db *DB) GetArticleByURL(ctx context.Context, sourceURL string) (*domain.Article, error) {
+	return db.getArticleWhere(ctx, "source_url = ?", sourceURL)
+}
+
+func (db *DB) getArticleWhere(ctx context.Context, condition string, args ...any) (*domain.Article, error) {
+	article, err := scanArticle(db.QueryRowContext(ctx, `
+        SELECT `+articleColumns+`
+        FROM articles
+        WHERE `+condition,
+		args...,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	article.Objects, err = db.GetArticleObjects(article.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return article, nil
+}
@@ -0,0 +1,295 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+	"strings"
+)
+
+// Статей за одно чтение при обходе
+const articlesPageSize = 500
+
+// Правила хранения: границы по времени добавления статьи (Unix), 0 - правило не применяется
+type RetentionPolicy struct {
+	EmbeddingsBefore int64 // Удалить векторы статей, добавленных раньше
+	ContentBefore    int64 // Удалить тексты статей, добавленных раньше
+}
+
+// Сколько статей затрагивают правила хранения
+type RetentionCounts struct {
+	Embeddings int // Статей, у которых удаляется вектор
+	Content    int // Статей, у которых удаляется текст
+	Articles   int // Всего затронутых статей
+}
+
+const (
+	embeddingRetained = "(created_at < ? AND length(embedding) > 0)"
+	contentRetained   = "(created_at < ? AND content <> '')"
+)
+
+// Условие отбора статей, которые затрагивают правила
+func (policy RetentionPolicy) clause() (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if policy.EmbeddingsBefore > 0 {
+		conditions = append(conditions, embeddingRetained)
+		args = append(args, policy.EmbeddingsBefore)
+	}
+	if policy.ContentBefore > 0 {
+		conditions = append(conditions, contentRetained)
+		args = append(args, policy.ContentBefore)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE (" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// Обходит статьи, подходящие под условие where (вместе с " WHERE "), по возрастанию ID
+// порциями, чтобы не держать все статьи в памяти
+func (db *DB) eachArticleWhere(ctx context.Context, where string, args []any, fn func(*domain.Article) error) error {
+	if where == "" {
+		where = " WHERE 1"
+	}
+
+	var lastID int64
+	for {
+		rows, err := db.QueryContext(ctx,
+			"SELECT "+articleColumns+" FROM articles"+where+" AND id > ? ORDER BY id LIMIT ?",
+			append(append([]any{}, args...), lastID, articlesPageSize)...,
+		)
+		if err != nil {
+			return err
+		}
+
+		var page []domain.Article
+		for rows.Next() {
+			article, err := scanArticle(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			page = append(page, *article)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		if err := db.attachArticleObjects(page); err != nil {
+			return err
+		}
+
+		for i := range page {
+			if err := fn(&page[i]); err != nil {
+				return err
+			}
+		}
+		lastID = page[len(page)-1].ID
+	}
+}
+
+// Обходит статьи по фильтру (без учета Limit и Offset) по возрастанию ID
+func (db *DB) EachArticle(ctx context.Context, filter domain.ArticleFilter, fn func(*domain.Article) error) error {
+	where, args := articleFilterClause(filter)
+	return db.eachArticleWhere(ctx, where, args, fn)
+}
+
+// Удаляет статьи по фильтру (без учета Limit и Offset) в одной транзакции.
+// Возвращает количество удаленных статей
+func (db *DB) DeleteArticles(ctx context.Context, filter domain.ArticleFilter) (int, error) {
+	where, args := articleFilterClause(filter)
+
+	rows, err := db.QueryContext(ctx, "SELECT id FROM articles"+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	deleted := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		ok, err := deleteArticle(ctx, tx, id)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			deleted = append(deleted, id)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range deleted {
+		db.index.remove(id)
+	}
+
+	return len(deleted), nil
+}
+
+// Сколько статей затронут правила хранения
+func (db *DB) PreviewRetention(ctx context.Context, policy RetentionPolicy) (RetentionCounts, error) {
+	var counts RetentionCounts
+
+	where, args := policy.clause()
+	if where == "" {
+		return counts, nil
+	}
+
+	err := db.QueryRowContext(ctx, `
+        SELECT
+            COUNT(*),
+            COALESCE(SUM(`+embeddingRetained+`), 0),
+            COALESCE(SUM(`+contentRetained+`), 0)
+        FROM articles`+where,
+		append([]any{policy.EmbeddingsBefore, policy.ContentBefore}, args...)...,
+	).Scan(&counts.Articles, &counts.Embeddings, &counts.Content)
+
+	return counts, err
+}
+
+// Обходит статьи, которые затронут правила хранения, вместе с текстами и векторами
+func (db *DB) EachRetainedArticle(ctx context.Context, policy RetentionPolicy, fn func(*domain.Article) error) error {
+	where, args := policy.clause()
+	if where == "" {
+		return nil
+	}
+
+	return db.eachArticleWhere(ctx, where, args, fn)
+}
+
+// Применяет правила хранения: удаляет векторы и тексты старых статей, сохраняя
+// метаданные, результаты анализа и отпечатки текста (по ним по-прежнему находятся
+// дубликаты). Статьи без векторов удаляются из индекса
+func (db *DB) ApplyRetention(ctx context.Context, policy RetentionPolicy) (RetentionCounts, error) {
+	var counts RetentionCounts
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return counts, err
+	}
+	defer tx.Rollback()
+
+	// Затронутые статьи считаются до изменений: после них условия уже не выполняются
+	if where, args := policy.clause(); where != "" {
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM articles"+where, args...).Scan(&counts.Articles); err != nil {
+			return counts, err
+		}
+	}
+
+	var dropped []int64
+	if policy.EmbeddingsBefore > 0 {
+		rows, err := tx.QueryContext(ctx, "SELECT id FROM articles WHERE "+embeddingRetained, policy.EmbeddingsBefore)
+		if err != nil {
+			return counts, err
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return counts, err
+			}
+			dropped = append(dropped, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return counts, err
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE articles SET embedding = X'' WHERE "+embeddingRetained, policy.EmbeddingsBefore); err != nil {
+			return counts, err
+		}
+		counts.Embeddings = len(dropped)
+	}
+
+	if policy.ContentBefore > 0 {
+		result, err := tx.ExecContext(ctx, "UPDATE articles SET content = '' WHERE "+contentRetained, policy.ContentBefore)
+		if err != nil {
+			return counts, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return counts, err
+		}
+		counts.Content = int(affected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return counts, err
+	}
+
+	for _, id := range dropped {
+		db.index.remove(id)
+	}
+
+	return counts, nil
+}
+
+// Удаляет вектор и (или) текст одной статьи, как это делают правила хранения
+func (db *DB) ClearArticleData(ctx context.Context, articleID int64, embedding bool, content bool) error {
+	var assignments []string
+	if embedding {
+		assignments = append(assignments, "embedding = X''")
+	}
+	if content {
+		assignments = append(assignments, "content = ''")
+	}
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, "UPDATE articles SET "+strings.Join(assignments, ", ")+" WHERE id = ?", articleID)
+	if err != nil {
+		return err
+	}
+
+	if embedding {
+		db.index.remove(articleID)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestApplyRetention(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t, filepath.Join(t.TempDir(), "retention.sqlite3"))
+	now := time.Now()
+
+	articles := map[string]*domain.Article{
+		"старая":             {CreatedAt: now.AddDate(0, 0, -100).Unix(), Embedding: []float64{1, 0}},
+		"месячная":           {CreatedAt: now.AddDate(0, 0, -40).Unix(), Embedding: []float64{0, 1}},
+		"старая без вектора": {CreatedAt: now.AddDate(0, 0, -100).Unix()},
+		"новая":              {CreatedAt: now.Unix(), Embedding: []float64{1, 1}},
+	}
+	for name, article := range articles {
+		article.Title = name
+		article.Content = "Текст статьи " + name
+		article.SourceURL = "https://example.com/" + name
+		if err := db.SaveArticle(ctx, article); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policy := RetentionPolicy{
+		EmbeddingsBefore: now.AddDate(0, 0, -30).Unix(),
+		ContentBefore:    now.AddDate(0, 0, -60).Unix(),
+	}
+
+	// Вектор удаляется у двух статей, текст - у двух, а всего затронуты три статьи
+	want := RetentionCounts{Embeddings: 2, Content: 2, Articles: 3}
+
+	preview, err := db.PreviewRetention(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview != want {
+		t.Fatalf("предпросмотр %+v, ожидалось %+v", preview, want)
+	}
+
+	counts, err := db.ApplyRetention(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts != want {
+		t.Fatalf("применено %+v, ожидалось %+v", counts, want)
+	}
+
+	for name, wantData := range map[string][2]bool{
+		"старая":             {false, false},
+		"месячная":           {false, true},
+		"старая без вектора": {false, false},
+		"новая":              {true, true},
+	} {
+		article, err := db.GetArticle(articles[name].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := [2]bool{len(article.Embedding) > 0, article.Content != ""}
+		if got != wantData {
+			t.Errorf("%s: вектор и текст %v, ожидалось %v", name, got, wantData)
+		}
+		if article.Sentiment != articles[name].Sentiment || article.Title != name {
+			t.Errorf("%s: метаданные изменились", name)
+		}
+	}
+
+	// Повторное применение ничего не затрагивает
+	counts, err = db.ApplyRetention(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts != (RetentionCounts{}) {
+		t.Fatalf("повторно применено %+v", counts)
+	}
+
+	if counts, err := db.ApplyRetention(ctx, RetentionPolicy{}); err != nil || counts != (RetentionCounts{}) {
+		t.Fatalf("без правил: %+v, %v", counts, err)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+const rolesSchema = `CREATE TABLE IF NOT EXISTS user_roles (
+		platform TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		role TEXT NOT NULL,
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (platform, user_id)
+	);
+`
+
+// Создана ли таблица ролей при открытии базы, то есть до этого ролей не было
+func (db *DB) RolesCreated() bool {
+	return db.rolesCreated
+}
+
+// Назначает роль пользователю, заменяя прежнюю
+func (db *DB) SetRole(platform string, userID string, role domain.Role) error {
+	_, err := db.Exec(`
+		INSERT INTO user_roles(platform, user_id, role, updated_at) VALUES(?, ?, ?, ?)
+		ON CONFLICT(platform, user_id) DO UPDATE SET role = excluded.role, updated_at = excluded.updated_at`,
+		platform, userID, role.String(), time.Now().Unix(),
+	)
+
+	return err
+}
+
+// Возвращает назначенную роль или nil, если роли нет
+func (db *DB) GetRole(platform string, userID string) (*domain.RoleAssignment, error) {
+	var (
+		assignment domain.RoleAssignment
+		role       string
+	)
+	err := db.QueryRow(
+		"SELECT platform, user_id, role, updated_at FROM user_roles WHERE platform = ? AND user_id = ?",
+		platform, userID,
+	).Scan(&assignment.Platform, &assignment.UserID, &role, &assignment.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	assignment.Role, err = domain.ParseRole(role)
+	if err != nil {
+		return nil, err
+	}
+
+	return &assignment, nil
+}
+
+// Снимает роль с пользователя. Возвращает false, если роли не было
+func (db *DB) RemoveRole(platform string, userID string) (bool, error) {
+	result, err := db.Exec("DELETE FROM user_roles WHERE platform = ? AND user_id = ?", platform, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// Все назначенные роли, сначала с наибольшими правами
+func (db *DB) GetRoles() ([]domain.RoleAssignment, error) {
+	rows, err := db.Query("SELECT platform, user_id, role, updated_at FROM user_roles ORDER BY platform, user_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assignments []domain.RoleAssignment
+	for rows.Next() {
+		var (
+			assignment domain.RoleAssignment
+			role       string
+		)
+		if err := rows.Scan(&assignment.Platform, &assignment.UserID, &role, &assignment.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		assignment.Role, err = domain.ParseRole(role)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, assignment)
+	}
+
+	sort.SliceStable(assignments, func(i, j int) bool {
+		return assignments[i].Role > assignments[j].Role
+	})
+
+	return assignments, rows.Err()
+}
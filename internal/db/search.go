@@ -0,0 +1,152 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+	"database/sql"
+	"strings"
+	"unicode"
+)
+
+// Полнотекстовый индекс по заголовку, тексту и примечанию статей. Индекс хранит
+// только токены, сами значения читаются из articles; триггеры поддерживают его актуальным
+const articlesSearchSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS articles_fts USING fts5(
+    title, content, affiliation,
+    content='articles', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2'
+);
+CREATE TRIGGER IF NOT EXISTS articles_fts_insert AFTER INSERT ON articles BEGIN
+    INSERT INTO articles_fts(rowid, title, content, affiliation)
+    VALUES (new.id, new.title, new.content, new.affiliation);
+END;
+CREATE TRIGGER IF NOT EXISTS articles_fts_delete AFTER DELETE ON articles BEGIN
+    INSERT INTO articles_fts(articles_fts, rowid, title, content, affiliation)
+    VALUES ('delete', old.id, old.title, old.content, old.affiliation);
+END;
+CREATE TRIGGER IF NOT EXISTS articles_fts_update AFTER UPDATE OF title, content, affiliation ON articles BEGIN
+    INSERT INTO articles_fts(articles_fts, rowid, title, content, affiliation)
+    VALUES ('delete', old.id, old.title, old.content, old.affiliation);
+    INSERT INTO articles_fts(rowid, title, content, affiliation)
+    VALUES (new.id, new.title, new.content, new.affiliation);
+END;
+`
+
+// Создает полнотекстовый индекс. Статьи, сохраненные до его появления, индексируются сразу
+func createSearchIndex(db querier) error {
+	existed, err := tableExists(db, "articles_fts")
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(articlesSearchSchema); err != nil {
+		return err
+	}
+
+	if !existed {
+		_, err = db.Exec("INSERT INTO articles_fts(articles_fts) VALUES('rebuild')")
+	}
+
+	return err
+}
+
+// Превращает текст пользователя в запрос FTS5: каждое слово ищется по началу,
+// операторы и кавычки из текста не интерпретируются
+func searchQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, `"`+word+`"*`)
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// Полнотекстовый поиск статей. Совпадения в заголовке весят больше, чем в тексте
+// и примечании; результаты упорядочены по релевантности
+func (db *DB) SearchArticles(ctx context.Context, text string, limit int) ([]domain.ArticleSearchHit, error) {
+	query := searchQuery(text)
+	if query == "" {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+        SELECT rowid, snippet(articles_fts, -1, '«', '»', '…', 16)
+        FROM articles_fts
+        WHERE articles_fts MATCH ?
+        ORDER BY bm25(articles_fts, 5.0, 1.0, 2.0)
+        LIMIT ?`,
+		query,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		ids      []int64
+		snippets = make(map[int64]string)
+	)
+	for rows.Next() {
+		var (
+			id      int64
+			snippet sql.NullString
+		)
+		if err := rows.Scan(&id, &snippet); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		snippets[id] = snippet.String
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	articles, err := db.getArticlesByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.attachArticleObjects(articles); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]domain.Article, len(articles))
+	for _, article := range articles {
+		byID[article.ID] = article
+	}
+
+	hits := make([]domain.ArticleSearchHit, 0, len(ids))
+	for _, id := range ids {
+		if article, ok := byID[id]; ok {
+			hits = append(hits, domain.ArticleSearchHit{
+				Article: article,
+				Snippet: strings.Join(strings.Fields(snippets[id]), " "),
+			})
+		}
+	}
+
+	return hits, nil
+}
@@ -21,21 +21,29 @@ package db
 import (
 	"Unbewohnte/ACASbot/internal/domain"
 	"Unbewohnte/ACASbot/internal/similarity"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"math"
-	"time"
 
 	_ "modernc.org/sqlite"
 )
 
 type DB struct {
 	*sql.DB
+	index *embeddingIndex
+
+	// Таблица ролей создана при этом запуске
+	rolesCreated bool
 }
 
+var ErrCorruptEmbedding = errors.New("поврежденный вектор статьи")
+
 func NewDB(path string) (*DB, error) {
-	db, err := sql.Open("sqlite", path+"?_journal=WAL&_timeout=5000&_fk=true")
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, err
 	}
@@ -45,51 +53,160 @@ func NewDB(path string) (*DB, error) {
 		return nil, err
 	}
 
-	// Инициализация таблицы
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS articles (
-            id INTEGER PRIMARY KEY AUTOINCREMENT,
-            content TEXT NOT NULL,
-			title TEXT,
-            embedding BLOB NOT NULL,
-            source_url TEXT UNIQUE,
-            created_at INTEGER NOT NULL,
-			published_at INTEGER,
-			citations INTEGER DEFAULT 0,
-			original BOOLEAN DEFAULT 0,
-			similar_urls TEXT DEFAULT '[]',
-			affiliation TEXT,
-			sentiment TEXT,
-			justification TEXT
-        );
-        CREATE INDEX IF NOT EXISTS idx_articles_time ON articles(created_at);
-		CREATE INDEX IF NOT EXISTS idx_articles_original ON articles(original);
-    `)
+	// Таблица ролей создается миграцией, поэтому ее наличие проверяется заранее
+	rolesExisted, err := tableExists(db, "user_roles")
 	if err != nil {
 		return nil, err
 	}
 
-	return &DB{db}, nil
+	// Схема базы данных
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+
+	// Индекс векторов для поиска похожих
+	index := newEmbeddingIndex()
+	go func() {
+		if err := index.build(db); err != nil {
+			log.Printf("Не удалось построить индекс векторов: %v", err)
+			return
+		}
+
+		stats := index.hnsw
+		log.Printf("Индекс векторов построен за %v: %d статей, размерность %d", index.buildTime, stats.Len(), stats.Dimensions())
+	}()
+
+	return &DB{DB: db, index: index, rolesCreated: !rolesExisted}, nil
 }
 
-func (db *DB) SaveArticle(article *domain.Article) error {
-	embJSON, err := json.Marshal(article.Embedding)
+func tableExists(db querier, table string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Добавляет колонку в существующую таблицу, если ее там еще нет
+func ensureColumn(db querier, table string, column string, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid          int
+			name         string
+			columnType   string
+			notNull      bool
+			defaultValue sql.NullString
+			primaryKey   int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+			return err
+		}
+
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// Колонки статьи в порядке, ожидаемом scanArticle
+const articleColumns = `id, content, title, embedding, source_url, created_at, published_at, citations, original, similar_urls, affiliation, sentiment, justification, irrelevant, confidence, story_id, COALESCE(provenance, ''), COALESCE(verified_by, ''), verified_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Считывает статью, выбранную с колонками articleColumns
+func scanArticle(row rowScanner) (*domain.Article, error) {
+	var a domain.Article
+	var embedding, similarURLsJSON []byte
+	var provenance string
+
+	if err := row.Scan(
+		&a.ID,
+		&a.Content,
+		&a.Title,
+		&embedding,
+		&a.SourceURL,
+		&a.CreatedAt,
+		&a.PublishedAt,
+		&a.Citations,
+		&a.Original,
+		&similarURLsJSON,
+		&a.Affiliation,
+		&a.Sentiment,
+		&a.Justification,
+		&a.Irrelevant,
+		&a.Confidence,
+		&a.StoryID,
+		&provenance,
+		&a.VerifiedBy,
+		&a.VerifiedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	// Распаковываем embedding
+	var err error
+	a.Embedding, err = decodeEmbedding(embedding)
+	if err != nil {
+		return nil, err
+	}
+
+	// Распаковываем similar_urls
+	if len(similarURLsJSON) > 0 {
+		if err := json.Unmarshal(similarURLsJSON, &a.SimilarURLs); err != nil {
+			return nil, err
+		}
+	}
 
+	a.Provenance, err = decodeProvenance(provenance)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+func (db *DB) SaveArticle(ctx context.Context, article *domain.Article) error {
 	similarJSON, err := json.Marshal(article.SimilarURLs)
 	if err != nil {
 		return err
 	}
 
-	_, err = db.Exec(`INSERT INTO articles(
+	provenance, err := encodeProvenance(article.Provenance)
+	if err != nil {
+		return err
+	}
+
+	fp := similarity.NewFingerprint(article.Content)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO articles(
         content, title, embedding, source_url, 
         created_at, published_at, citations, original, similar_urls, 
-        affiliation, sentiment, justification
-    ) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        affiliation, sentiment, justification, irrelevant, confidence, story_id, provenance,
+        content_hash, simhash, minhash
+    ) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		article.Content,
 		article.Title,
-		embJSON,
+		encodeEmbedding(article.Embedding),
 		article.SourceURL,
 		article.CreatedAt,
 		article.PublishedAt,
@@ -99,19 +216,50 @@ func (db *DB) SaveArticle(article *domain.Article) error {
 		article.Affiliation,
 		article.Sentiment,
 		article.Justification,
+		article.Irrelevant,
+		article.Confidence,
+		article.StoryID,
+		provenance,
+		fp.ContentHash,
+		int64(fp.SimHash),
+		similarity.EncodeMinHash(fp.MinHash),
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	article.ID, err = result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	if err := saveArticleObjects(tx, article.ID, article.Objects); err != nil {
+		return err
+	}
+
+	if err := savePrompts(tx, article.Provenance); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	db.index.add(article.ID, article.Embedding, article.CreatedAt, article.Original)
+
+	return nil
 }
 
-func (db *DB) FindSimilar(target []float64, threshold float64, maxAgeDays uint) ([]domain.Article, error) {
+func (db *DB) findSimilarLinear(ctx context.Context, target []float64, threshold float64, since int64, originalOnly bool) ([]domain.Article, error) {
 	// Normalize the target vector once
 	similarity.NormalizeVector(target)
 
-	rows, err := db.Query(`
-        SELECT id, content, title, embedding, source_url, created_at, published_at, citations, original, similar_urls, affiliation, sentiment, justification
-        FROM articles 
-        WHERE created_at >= ? AND original >= 1
-    `, time.Now().AddDate(0, 0, -int(maxAgeDays)).Unix())
+	query := "SELECT " + articleColumns + " FROM articles WHERE created_at >= ?"
+	if originalOnly {
+		query += " AND original >= 1"
+	}
+
+	rows, err := db.QueryContext(ctx, query, since)
 	if err != nil {
 		return nil, err
 	}
@@ -119,36 +267,12 @@ func (db *DB) FindSimilar(target []float64, threshold float64, maxAgeDays uint)
 
 	var results []domain.Article
 	for rows.Next() {
-		var a domain.Article
-		var embJSON, similarURLsJSON []byte
-
-		if err := rows.Scan(
-			&a.ID,
-			&a.Content,
-			&a.Title,
-			&embJSON,
-			&a.SourceURL,
-			&a.CreatedAt,
-			&a.PublishedAt,
-			&a.Citations,
-			&a.Original,
-			&similarURLsJSON,
-			&a.Affiliation,
-			&a.Sentiment,
-			&a.Justification,
-		); err != nil {
+		a, err := scanArticle(rows)
+		if err != nil {
 			continue // Skip problematic rows but continue processing
 		}
 
-		var embedding []float64
-		if err := json.Unmarshal(embJSON, &embedding); err != nil {
-			continue
-		}
-
-		if err := json.Unmarshal(similarURLsJSON, &a.SimilarURLs); err != nil {
-			continue
-		}
-
+		embedding := a.Embedding
 		similarity.NormalizeVector(embedding)
 		sim, err := similarity.SemanticSimilarity(target, embedding)
 		if err != nil || sim < threshold || math.IsNaN(sim) {
@@ -157,17 +281,25 @@ func (db *DB) FindSimilar(target []float64, threshold float64, maxAgeDays uint)
 
 		a.Embedding = embedding
 		a.Similarity = sim
-		results = append(results, a)
+		results = append(results, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
 	return results, nil
 }
 
 func (db *DB) HasExactDuplicate(content string) (bool, error) {
+	hash := similarity.ContentHash(content)
+	if hash == "" {
+		return false, nil
+	}
+
 	var count int
 	err := db.QueryRow(
-		"SELECT COUNT(*) FROM articles WHERE content = ?",
-		content,
+		"SELECT COUNT(*) FROM articles WHERE content_hash = ?",
+		hash,
 	).Scan(&count)
 
 	if err != nil {
@@ -176,32 +308,22 @@ func (db *DB) HasExactDuplicate(content string) (bool, error) {
 	return count > 0, nil
 }
 
-func (db *DB) GetExactDuplicate(content string) (*domain.Article, error) {
-	var article domain.Article
-	var embJSON, similarURLsJSON []byte
+// Статья с тем же нормализованным текстом (совпадение хеша content_hash)
+// или nil, если ее нет
+func (db *DB) GetExactDuplicate(ctx context.Context, content string) (*domain.Article, error) {
+	hash := similarity.ContentHash(content)
+	if hash == "" {
+		return nil, nil
+	}
 
-	err := db.QueryRow(`
-        SELECT id, content, title, embedding, source_url, created_at, published_at, citations, original, similar_urls, affiliation, sentiment, justification
+	article, err := scanArticle(db.QueryRowContext(ctx, `
+        SELECT `+articleColumns+`
         FROM articles 
-        WHERE content = ?
+        WHERE content_hash = ?
+        ORDER BY id
         LIMIT 1`,
-		content,
-	).Scan(
-		&article.ID,
-		&article.Content,
-		&article.Title,
-		&embJSON,
-		&article.SourceURL,
-		&article.CreatedAt,
-		&article.PublishedAt,
-		&article.Citations,
-		&article.Original,
-		&similarURLsJSON,
-		&article.Affiliation,
-		&article.Sentiment,
-		&article.Justification,
-	)
-
+		hash,
+	))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -209,37 +331,82 @@ func (db *DB) GetExactDuplicate(content string) (*domain.Article, error) {
 		return nil, err
 	}
 
-	// Unmarshal embedding if needed
-	if len(embJSON) > 0 {
-		if err := json.Unmarshal(embJSON, &article.Embedding); err != nil {
-			return nil, err
-		}
+	article.Objects, err = db.GetArticleObjects(article.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(similarURLsJSON) > 0 {
-		if err := json.Unmarshal(similarURLsJSON, &article.SimilarURLs); err != nil {
-			return nil, err
-		}
+	return article, nil
+}
+
+func (db *DB) DeleteAllArticles() error {
+	_, err := db.Exec("DELETE FROM article_objects; DELETE FROM analysis_history; DELETE FROM articles; DELETE FROM stories")
+	if err != nil {
+		return err
 	}
 
-	return &article, nil
+	db.index.reset()
+
+	return nil
 }
 
-func (db *DB) DeleteAllArticles() error {
-	_, err := db.Exec("DELETE FROM articles")
-	return err
+// Удаляет статью вместе с результатами по объектам и историей анализа. Сюжет статьи
+// теряет ее, а сюжет без статей удаляется. Возвращает false, если статьи не было
+func (db *DB) DeleteArticle(ctx context.Context, id int64) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	deleted, err := deleteArticle(ctx, tx, id)
+	if err != nil || !deleted {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	db.index.remove(id)
+
+	return true, nil
 }
 
-func (db *DB) IncrementCitation(articleID int64) error {
-	_, err := db.Exec("UPDATE articles SET citations = citations + 1 WHERE id = ?", articleID)
+func deleteArticle(ctx context.Context, tx *sql.Tx, id int64) (bool, error) {
+	var storyID int64
+	err := tx.QueryRowContext(ctx, "SELECT story_id FROM articles WHERE id = ?", id).Scan(&storyID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM article_objects WHERE article_id = ?",
+		"DELETE FROM analysis_history WHERE article_id = ?",
+		"DELETE FROM articles WHERE id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return false, err
+		}
+	}
+
+	if err := detachStory(ctx, tx, id, storyID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (db *DB) IncrementCitation(ctx context.Context, articleID int64) error {
+	_, err := db.ExecContext(ctx, "UPDATE articles SET citations = citations + 1 WHERE id = ?", articleID)
 	return err
 }
-func (db *DB) GetAllArticles() ([]domain.Article, error) {
-	rows, err := db.Query(`
-        SELECT 
-            id, content, title, embedding, source_url, 
-            created_at, published_at, citations, original, similar_urls, 
-            affiliation, sentiment, justification
+func (db *DB) GetAllArticles(ctx context.Context) ([]domain.Article, error) {
+	rows, err := db.QueryContext(ctx, `
+        SELECT `+articleColumns+`
         FROM articles
         ORDER BY published_at ASC
     `)
@@ -250,49 +417,26 @@ func (db *DB) GetAllArticles() ([]domain.Article, error) {
 
 	var articles []domain.Article
 	for rows.Next() {
-		var a domain.Article
-		var embJSON, similarURLsJSON []byte
-
-		if err := rows.Scan(
-			&a.ID,
-			&a.Content,
-			&a.Title,
-			&embJSON,
-			&a.SourceURL,
-			&a.CreatedAt,
-			&a.PublishedAt,
-			&a.Citations,
-			&a.Original,
-			&similarURLsJSON,
-			&a.Affiliation,
-			&a.Sentiment,
-			&a.Justification,
-		); err != nil {
+		a, err := scanArticle(rows)
+		if err != nil {
 			return nil, err
 		}
 
-		// Распаковываем embedding
-		if len(embJSON) > 0 {
-			if err := json.Unmarshal(embJSON, &a.Embedding); err != nil {
-				return nil, err
-			}
-		}
-
-		// Распаковываем similar_urls
-		if len(similarURLsJSON) > 0 {
-			if err := json.Unmarshal(similarURLsJSON, &a.SimilarURLs); err != nil {
-				return nil, err
-			}
-		}
+		articles = append(articles, *a)
+	}
+	rows.Close()
 
-		articles = append(articles, a)
+	if err := db.attachArticleObjects(articles); err != nil {
+		return nil, err
 	}
+
 	return articles, nil
 }
 
-func (db *DB) HasArticleByURL(url string) (bool, error) {
+func (db *DB) HasArticleByURL(ctx context.Context, url string) (bool, error) {
 	var count int
-	err := db.QueryRow(
+	err := db.QueryRowContext(
+		ctx,
 		"SELECT COUNT(*) FROM articles WHERE source_url = ?",
 		url,
 	).Scan(&count)
@@ -303,10 +447,10 @@ func (db *DB) HasArticleByURL(url string) (bool, error) {
 	return count > 0, nil
 }
 
-func (db *DB) AddSimilarURL(originalArticleID int64, citingArticleURL string) error {
+func (db *DB) AddSimilarURL(ctx context.Context, originalArticleID int64, citingArticleURL string) error {
 	// Проверяем, что статья оригинальная
 	var original bool
-	err := db.QueryRow("SELECT original FROM articles WHERE id = ?", originalArticleID).Scan(&original)
+	err := db.QueryRowContext(ctx, "SELECT original FROM articles WHERE id = ?", originalArticleID).Scan(&original)
 	if err != nil {
 		return err
 	}
@@ -315,7 +459,7 @@ func (db *DB) AddSimilarURL(originalArticleID int64, citingArticleURL string) er
 		return fmt.Errorf("статья с ID %d не является оригинальной", originalArticleID)
 	}
 
-	tx, err := db.Begin()
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -326,7 +470,7 @@ func (db *DB) AddSimilarURL(originalArticleID int64, citingArticleURL string) er
 	}()
 
 	var similarJSON []byte
-	err = tx.QueryRow("SELECT similar_urls FROM articles WHERE id = ?", originalArticleID).Scan(&similarJSON)
+	err = tx.QueryRowContext(ctx, "SELECT similar_urls FROM articles WHERE id = ?", originalArticleID).Scan(&similarJSON)
 	if err != nil {
 		return err
 	}
@@ -351,7 +495,7 @@ func (db *DB) AddSimilarURL(originalArticleID int64, citingArticleURL string) er
 		return err
 	}
 
-	_, err = tx.Exec("UPDATE articles SET similar_urls = ? WHERE id = ?", newSimilarJSON, originalArticleID)
+	_, err = tx.ExecContext(ctx, "UPDATE articles SET similar_urls = ? WHERE id = ?", newSimilarJSON, originalArticleID)
 	if err != nil {
 		return err
 	}
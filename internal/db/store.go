@@ -0,0 +1,66 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+)
+
+// Поддерживаемые хранилища статей
+const (
+	StoreSQLite   = "sqlite"
+	StorePostgres = "postgres" // Общее хранилище нескольких экземпляров бота
+)
+
+var StoreBackends = []string{StoreSQLite, StorePostgres}
+
+// Хранилище статей, через которое идут проверка дубликатов, поиск похожих,
+// учет цитирований и сохранение новых статей
+type ArticleStore interface {
+	// Сохраняет новую статью вместе с результатами по объектам и задает ее ID
+	SaveArticle(ctx context.Context, article *domain.Article) error
+	// Оригинальные статьи не старше maxAgeDays с косинусной схожестью векторов не ниже threshold
+	FindSimilar(ctx context.Context, target []float64, threshold float64, maxAgeDays uint) ([]domain.Article, error)
+	// Статья с тем же нормализованным текстом или nil
+	GetExactDuplicate(ctx context.Context, content string) (*domain.Article, error)
+	// Оригинальные статьи не старше maxAgeDays, почти дословно совпадающие с content
+	// (оценка Жаккара по MinHash не ниже threshold), по убыванию оценки
+	FindNearDuplicates(ctx context.Context, content string, threshold float64, maxAgeDays uint) ([]domain.Article, error)
+	// Добавляет URL цитирующей статьи в оригинальную
+	AddSimilarURL(ctx context.Context, originalArticleID int64, citingArticleURL string) error
+	IncrementCitation(ctx context.Context, articleID int64) error
+	// Все статьи по возрастанию даты публикации
+	GetAllArticles(ctx context.Context) ([]domain.Article, error)
+	HasArticleByURL(ctx context.Context, url string) (bool, error)
+	// Статья по URL источника или nil
+	GetArticleByURL(ctx context.Context, url string) (*domain.Article, error)
+	// Заменяет результаты анализа статьи (article.ID) новыми и снимает отметку о ручном исправлении
+	ReplaceArticleAnalysis(ctx context.Context, article *domain.Article) error
+	// Ручное исправление отношения статьи к объекту, см. DB.CorrectSentiment
+	CorrectSentiment(ctx context.Context, articleID int64, object string, primary bool, sentiment string, justification string, verifiedBy string) error
+	// Удаляет статью вместе с результатами по объектам. Возвращает false, если статьи не было
+	DeleteArticle(ctx context.Context, id int64) (bool, error)
+	// Относит статью к сюжету (0 - ни к какому)
+	SetArticleStory(ctx context.Context, articleID int64, storyID int64) error
+	// Удаляет вектор и (или) текст статьи по правилам хранения
+	ClearArticleData(ctx context.Context, articleID int64, embedding bool, content bool) error
+}
+
+var _ ArticleStore = (*DB)(nil)
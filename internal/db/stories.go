@@ -0,0 +1,338 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const storiesSchema = `CREATE TABLE IF NOT EXISTS stories (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT DEFAULT '',
+		first_article_id INTEGER NOT NULL,
+		first_seen INTEGER NOT NULL,
+		last_seen INTEGER NOT NULL,
+		article_count INTEGER DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_stories_last_seen ON stories(last_seen);
+	CREATE INDEX IF NOT EXISTS idx_articles_story ON articles(story_id);
+`
+
+const storyColumns = `id, title, first_article_id, first_seen, last_seen, article_count`
+
+func scanStory(row rowScanner) (*domain.Story, error) {
+	var story domain.Story
+	if err := row.Scan(
+		&story.ID,
+		&story.Title,
+		&story.FirstArticleID,
+		&story.FirstSeen,
+		&story.LastSeen,
+		&story.ArticleCount,
+	); err != nil {
+		return nil, err
+	}
+
+	return &story, nil
+}
+
+// Создает сюжет, начинающийся со статьи
+func (db *DB) CreateStory(article *domain.Article) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	title := article.Title
+	if title == "" {
+		title = article.SourceURL
+	}
+
+	seenAt := article.SeenAt()
+	result, err := tx.Exec(
+		"INSERT INTO stories(title, first_article_id, first_seen, last_seen, article_count) VALUES(?, ?, ?, ?, 1)",
+		title, article.ID, seenAt, seenAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	storyID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE articles SET story_id = ? WHERE id = ?", storyID, article.ID); err != nil {
+		return 0, err
+	}
+
+	return storyID, tx.Commit()
+}
+
+// Добавляет статью в существующий сюжет. Если статья опубликована раньше первой,
+// она становится первым появлением сюжета
+func (db *DB) AssignStory(article *domain.Article, storyID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE articles SET story_id = ? WHERE id = ?", storyID, article.ID); err != nil {
+		return err
+	}
+
+	if err := attachStory(context.Background(), tx, article.ID, article.SeenAt(), storyID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Переносит статью в другой сюжет (0 - убирает из сюжета), пересчитывая оба сюжета.
+// Сюжет, в котором не осталось статей, удаляется
+func (db *DB) SetArticleStory(ctx context.Context, articleID int64, storyID int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous, seenAt int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT story_id, CASE WHEN published_at > 0 THEN published_at ELSE created_at END FROM articles WHERE id = ?",
+		articleID,
+	).Scan(&previous, &seenAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("статья с ID %d не найдена", articleID)
+	}
+	if err != nil {
+		return err
+	}
+	if previous == storyID {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE articles SET story_id = ? WHERE id = ?", storyID, articleID); err != nil {
+		return err
+	}
+	if err := detachStory(ctx, tx, articleID, previous); err != nil {
+		return err
+	}
+	if err := attachStory(ctx, tx, articleID, seenAt, storyID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Учитывает статью, добавленную в сюжет
+func attachStory(ctx context.Context, tx *sql.Tx, articleID int64, seenAt int64, storyID int64) error {
+	if storyID == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE stories SET
+			article_count = article_count + 1,
+			first_article_id = CASE WHEN ? < first_seen THEN ? ELSE first_article_id END,
+			first_seen = MIN(first_seen, ?),
+			last_seen = MAX(last_seen, ?)
+		WHERE id = ?`,
+		seenAt, articleID, seenAt, seenAt, storyID,
+	)
+
+	return err
+}
+
+// Учитывает статью, покинувшую сюжет (удаленную или перенесенную). Вызывается после того,
+// как статья уже не относится к сюжету
+func detachStory(ctx context.Context, tx *sql.Tx, articleID int64, storyID int64) error {
+	if storyID == 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM stories WHERE id = ? AND article_count <= 1", storyID); err != nil {
+		return err
+	}
+
+	// Первой статьей сюжета становится самая ранняя из оставшихся
+	_, err := tx.ExecContext(ctx, `UPDATE stories
+        SET article_count = article_count - 1,
+            first_article_id = CASE WHEN first_article_id = ? THEN COALESCE((
+                SELECT id FROM articles
+                WHERE story_id = stories.id
+                ORDER BY CASE WHEN published_at > 0 THEN published_at ELSE created_at END ASC, id ASC
+                LIMIT 1
+            ), first_article_id) ELSE first_article_id END
+        WHERE id = ?`,
+		articleID,
+		storyID,
+	)
+
+	return err
+}
+
+// Заполняет ресурсы и ссылку на первую статью сюжетов
+func (db *DB) attachStoryHosts(stories []domain.Story) error {
+	if len(stories) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*domain.Story, len(stories))
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(stories)), ",")
+	args := make([]any, 0, len(stories))
+	for i := range stories {
+		byID[stories[i].ID] = &stories[i]
+		args = append(args, stories[i].ID)
+	}
+
+	rows, err := db.Query("SELECT id, story_id, source_url FROM articles WHERE story_id IN ("+placeholders+")", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]map[string]int)
+	for rows.Next() {
+		var (
+			articleID, storyID int64
+			sourceURL          string
+		)
+		if err := rows.Scan(&articleID, &storyID, &sourceURL); err != nil {
+			return err
+		}
+
+		story := byID[storyID]
+		if articleID == story.FirstArticleID {
+			story.FirstURL = sourceURL
+		}
+
+		if counts[storyID] == nil {
+			counts[storyID] = make(map[string]int)
+		}
+		counts[storyID][domain.Hostname(sourceURL)]++
+	}
+
+	for storyID, hosts := range counts {
+		story := byID[storyID]
+		for host, articles := range hosts {
+			story.Hosts = append(story.Hosts, domain.StoryHost{Host: host, Articles: articles})
+		}
+		sort.Slice(story.Hosts, func(i, j int) bool {
+			if story.Hosts[i].Articles != story.Hosts[j].Articles {
+				return story.Hosts[i].Articles > story.Hosts[j].Articles
+			}
+			return story.Hosts[i].Host < story.Hosts[j].Host
+		})
+	}
+
+	return rows.Err()
+}
+
+// Сюжеты по убыванию времени последней публикации
+func (db *DB) GetStories(limit int, minArticles int) ([]domain.Story, error) {
+	rows, err := db.Query(
+		"SELECT "+storyColumns+" FROM stories WHERE article_count >= ? ORDER BY last_seen DESC, id DESC LIMIT ?",
+		minArticles, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stories []domain.Story
+	for rows.Next() {
+		story, err := scanStory(rows)
+		if err != nil {
+			return nil, err
+		}
+		stories = append(stories, *story)
+	}
+	rows.Close()
+
+	if err := db.attachStoryHosts(stories); err != nil {
+		return nil, err
+	}
+
+	return stories, nil
+}
+
+// Возвращает сюжет по ID или nil, если его нет
+func (db *DB) GetStory(id int64) (*domain.Story, error) {
+	story, err := scanStory(db.QueryRow("SELECT "+storyColumns+" FROM stories WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stories := []domain.Story{*story}
+	if err := db.attachStoryHosts(stories); err != nil {
+		return nil, err
+	}
+
+	return &stories[0], nil
+}
+
+// Статьи сюжета в порядке появления
+func (db *DB) GetStoryArticles(storyID int64) ([]domain.Article, error) {
+	return db.queryArticles(`
+        SELECT `+articleColumns+`
+        FROM articles
+        WHERE story_id = ?
+        ORDER BY CASE WHEN published_at > 0 THEN published_at ELSE created_at END ASC, id ASC`,
+		storyID,
+	)
+}
+
+// Релевантные статьи без сюжета в порядке появления
+func (db *DB) GetArticlesWithoutStory() ([]domain.Article, error) {
+	return db.queryArticles(`
+        SELECT ` + articleColumns + `
+        FROM articles
+        WHERE story_id = 0 AND irrelevant = 0
+        ORDER BY CASE WHEN published_at > 0 THEN published_at ELSE created_at END ASC, id ASC`,
+	)
+}
+
+func (db *DB) queryArticles(query string, args ...any) ([]domain.Article, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var articles []domain.Article
+	for rows.Next() {
+		a, err := scanArticle(rows)
+		if err != nil {
+			return nil, err
+		}
+		articles = append(articles, *a)
+	}
+
+	return articles, rows.Err()
+}
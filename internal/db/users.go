@@ -0,0 +1,223 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package db
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"database/sql"
+	"time"
+)
+
+const webUsersSchema = `CREATE TABLE IF NOT EXISTS web_users (
+		username TEXT PRIMARY KEY,
+		password_hash TEXT NOT NULL,
+		disabled BOOLEAN DEFAULT 0,
+		created_at INTEGER NOT NULL,
+		last_login INTEGER DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS web_sessions (
+		jti TEXT PRIMARY KEY,
+		username TEXT NOT NULL,
+		issued_at INTEGER NOT NULL,
+		expires_at INTEGER NOT NULL,
+		revoked BOOLEAN DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_web_sessions_username ON web_sessions(username);
+`
+
+const webUserColumns = `username, password_hash, disabled, created_at, last_login`
+
+func scanWebUser(row rowScanner) (*domain.WebUser, error) {
+	var user domain.WebUser
+	err := row.Scan(&user.Username, &user.PasswordHash, &user.Disabled, &user.CreatedAt, &user.LastLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (db *DB) CreateWebUser(username string, passwordHash string) (*domain.WebUser, error) {
+	user := domain.WebUser{
+		Username:     username,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now().Unix(),
+	}
+
+	_, err := db.Exec(
+		"INSERT INTO web_users(username, password_hash, created_at) VALUES(?, ?, ?)",
+		user.Username, user.PasswordHash, user.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Возвращает пользователя по имени или nil, если его нет
+func (db *DB) GetWebUser(username string) (*domain.WebUser, error) {
+	user, err := scanWebUser(db.QueryRow("SELECT "+webUserColumns+" FROM web_users WHERE username = ?", username))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (db *DB) GetWebUsers() ([]domain.WebUser, error) {
+	rows, err := db.Query("SELECT " + webUserColumns + " FROM web_users ORDER BY username")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []domain.WebUser
+	for rows.Next() {
+		user, err := scanWebUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+
+	return users, rows.Err()
+}
+
+func (db *DB) CountWebUsers() (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM web_users").Scan(&count)
+	return count, err
+}
+
+// Возвращает false, если пользователя нет
+func (db *DB) SetWebUserPassword(username string, passwordHash string) (bool, error) {
+	return db.updateWebUser("UPDATE web_users SET password_hash = ? WHERE username = ?", passwordHash, username)
+}
+
+// Возвращает false, если пользователя нет
+func (db *DB) SetWebUserDisabled(username string, disabled bool) (bool, error) {
+	return db.updateWebUser("UPDATE web_users SET disabled = ? WHERE username = ?", disabled, username)
+}
+
+func (db *DB) TouchWebUserLogin(username string) error {
+	_, err := db.Exec("UPDATE web_users SET last_login = ? WHERE username = ?", time.Now().Unix(), username)
+	return err
+}
+
+func (db *DB) updateWebUser(query string, args ...any) (bool, error) {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// Запоминает выданный токен, чтобы его можно было отозвать
+func (db *DB) CreateWebSession(session domain.WebSession) error {
+	_, err := db.Exec(
+		"INSERT INTO web_sessions(jti, username, issued_at, expires_at) VALUES(?, ?, ?, ?)",
+		session.JTI, session.Username, session.IssuedAt, session.ExpiresAt,
+	)
+
+	// Заодно забываем давно истекшие сессии
+	if err == nil {
+		_, err = db.Exec("DELETE FROM web_sessions WHERE expires_at < ?", time.Now().Add(-7*24*time.Hour).Unix())
+	}
+
+	return err
+}
+
+// Возвращает сессию по jti или nil, если такой токен не выдавался
+func (db *DB) GetWebSession(jti string) (*domain.WebSession, error) {
+	var session domain.WebSession
+	err := db.QueryRow(
+		"SELECT jti, username, issued_at, expires_at, revoked FROM web_sessions WHERE jti = ?", jti,
+	).Scan(&session.JTI, &session.Username, &session.IssuedAt, &session.ExpiresAt, &session.Revoked)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Действующие сессии пользователя, новые сначала
+func (db *DB) GetActiveWebSessions(username string) ([]domain.WebSession, error) {
+	rows, err := db.Query(`
+		SELECT jti, username, issued_at, expires_at, revoked FROM web_sessions
+		WHERE username = ? AND revoked = 0 AND expires_at > ?
+		ORDER BY issued_at DESC`,
+		username, time.Now().Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []domain.WebSession
+	for rows.Next() {
+		var session domain.WebSession
+		if err := rows.Scan(&session.JTI, &session.Username, &session.IssuedAt, &session.ExpiresAt, &session.Revoked); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Отзывает токен. Возвращает false, если действующей сессии с таким jti нет
+func (db *DB) RevokeWebSession(jti string) (bool, error) {
+	result, err := db.Exec("UPDATE web_sessions SET revoked = 1 WHERE jti = ? AND revoked = 0", jti)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// Отзывает все токены пользователя и возвращает их количество
+func (db *DB) RevokeWebSessions(username string) (int64, error) {
+	result, err := db.Exec(
+		"UPDATE web_sessions SET revoked = 1 WHERE username = ? AND revoked = 0 AND expires_at > ?",
+		username, time.Now().Unix(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
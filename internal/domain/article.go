@@ -19,20 +19,93 @@
 package domain
 
 type Article struct {
-	ID             int64     `db:"id"`
-	Title          string    `db:"title"`
-	Content        string    `db:"content"`
-	Embedding      []float64 `db:"embedding"`
-	SourceURL      string    `db:"source_url"`
-	CreatedAt      int64     `db:"created_at"`   // Unix timestamp
-	PublishedAt    int64     `db:"published_at"` // Unix timestamp
-	Citations      int64     `db:"citations"`
-	Original       bool      `db:"original"` // Флаг оригинальности
-	SimilarURLs    []string  `db:"similar_urls"`
-	Similarity     float64   `db:"-"`
-	TrueSimilarity float64   `db:"-"`
-	Affiliation    string    `db:"affiliation"`
-	Sentiment      string    `db:"sentiment"`
-	Justification  string    `db:"justification"`
-	Errors         []error   `db:"-"`
+	ID             int64            `db:"id"`
+	Title          string           `db:"title"`
+	Content        string           `db:"content"`
+	Embedding      []float64        `db:"embedding"`
+	SourceURL      string           `db:"source_url"`
+	CreatedAt      int64            `db:"created_at"`   // Unix timestamp
+	PublishedAt    int64            `db:"published_at"` // Unix timestamp
+	Citations      int64            `db:"citations"`
+	Original       bool             `db:"original"` // Флаг оригинальности
+	SimilarURLs    []string         `db:"similar_urls"`
+	Similarity     float64          `db:"-"`
+	TrueSimilarity float64          `db:"-"`
+	TextSimilarity float64          `db:"-"` // Оценка MinHash для почти дословных копий, 0 - не копия
+	Affiliation    string           `db:"affiliation"`
+	Sentiment      string           `db:"sentiment"`
+	Justification  string           `db:"justification"`
+	Confidence     float64          `db:"confidence"`  // Уверенность модели в отношении (0 - неизвестна)
+	Irrelevant     bool             `db:"irrelevant"`  // Статья не относится к объекту анализа
+	StoryID        int64            `db:"story_id"`    // Сюжет, 0 - не определен
+	Objects        []ObjectAnalysis `db:"-"`           // Результаты по каждому отслеживаемому объекту
+	Provenance     *Provenance      `db:"provenance"`  // Модель, промпты и способ извлечения текста (nil - неизвестны)
+	VerifiedBy     string           `db:"verified_by"` // Кто последним исправил результат вручную
+	VerifiedAt     int64            `db:"verified_at"` // Unix timestamp ручного исправления, 0 - не исправлялся
+	Errors         []error          `db:"-"`
+}
+
+// Результат статьи исправлен человеком
+func (a *Article) Verified() bool {
+	return a.VerifiedAt > 0
+}
+
+// Результат анализа статьи относительно одного объекта
+type ObjectAnalysis struct {
+	ArticleID     int64   `db:"article_id"`
+	Object        string  `db:"object"`
+	Affiliation   string  `db:"affiliation"`
+	Sentiment     string  `db:"sentiment"`
+	Justification string  `db:"justification"`
+	Confidence    float64 `db:"confidence"`
+	Verified      bool    `db:"verified"` // Исправлен вручную
+}
+
+// Прежний результат анализа статьи, замененный повторным анализом или ручным исправлением.
+// Пустой объект - результат, хранившийся только в самой статье
+type AnalysisHistoryEntry struct {
+	ID            int64       `db:"id"`
+	ArticleID     int64       `db:"article_id"`
+	Object        string      `db:"object"`
+	Affiliation   string      `db:"affiliation"`
+	Sentiment     string      `db:"sentiment"`
+	Justification string      `db:"justification"`
+	Confidence    float64     `db:"confidence"`
+	ReplacedAt    int64       `db:"replaced_at"` // Unix timestamp
+	Provenance    *Provenance `db:"provenance"`
+}
+
+// Статья, найденная полнотекстовым поиском, и фрагмент текста с совпадением
+type ArticleSearchHit struct {
+	Article Article
+	Snippet string
+}
+
+// Возвращает результат анализа по объекту с указанным именем или nil
+func (a *Article) ObjectResult(object string) *ObjectAnalysis {
+	for i := range a.Objects {
+		if a.Objects[i].Object == object {
+			return &a.Objects[i]
+		}
+	}
+
+	return nil
+}
+
+// Параметры выборки статей
+type ArticleFilter struct {
+	Limit      int
+	Offset     int
+	Search     string // Подстрока заголовка или URL
+	Host       string // Сайт источника, включая поддомены
+	IDs        []int64
+	Sentiment  string // Отношение к объекту (или к основному объекту, если Object не указан)
+	Object     string // Только статьи с результатом по этому объекту
+	From       int64  // Опубликованы не раньше (Unix)
+	To         int64  // Опубликованы не позже (Unix)
+	AddedFrom  int64  // Добавлены в базу не раньше (Unix)
+	AddedTo    int64  // Добавлены в базу раньше (Unix)
+	Original   *bool
+	Irrelevant *bool
+	Verified   *bool // Результат исправлен вручную
 }
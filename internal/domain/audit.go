@@ -0,0 +1,50 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package domain
+
+// Изменение одного параметра конфигурации
+type ConfigChange struct {
+	Path   string `json:"path"` // Например, analysis.objects[0].prompts.sentiment
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// Запись журнала: кто, когда и с какими аргументами вызвал команду и что изменилось в конфигурации
+type AuditEntry struct {
+	ID        int64          `db:"id"`
+	CreatedAt int64          `db:"created_at"`
+	Platform  string         `db:"platform"` // telegram или web
+	UserID    string         `db:"user_id"`  // ID в Telegram или имя пользователя веб-интерфейса
+	UserName  string         `db:"user_name"`
+	Command   string         `db:"command"`
+	Args      string         `db:"args"`
+	Error     string         `db:"error"` // Пусто, если команда выполнена успешно
+	Changes   []ConfigChange `db:"changes"`
+}
+
+// Параметры выборки журнала
+type AuditFilter struct {
+	Limit       int
+	Offset      int
+	User        string // ID или имя пользователя
+	Command     string
+	From        int64 // Не раньше (Unix)
+	To          int64 // Не позже (Unix)
+	ChangesOnly bool  // Только записи с изменениями конфигурации
+}
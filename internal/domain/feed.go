@@ -0,0 +1,35 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package domain
+
+// Подписка на RSS/Atom ленту
+type Feed struct {
+	ID              int64  `db:"id"`
+	URL             string `db:"url"`
+	Title           string `db:"title"`
+	IntervalMinutes uint   `db:"interval_minutes"`
+	LastChecked     int64  `db:"last_checked"` // Unix timestamp
+	LastError       string `db:"last_error"`
+	CreatedAt       int64  `db:"created_at"`
+}
+
+// Пора ли снова проверять ленту
+func (f Feed) Due(now int64) bool {
+	return f.LastChecked+int64(f.IntervalMinutes)*60 <= now
+}
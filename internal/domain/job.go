@@ -0,0 +1,62 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package domain
+
+// Статусы заданий очереди
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Пакет URL, поставленных в очередь одной командой
+type Batch struct {
+	ID         int64  `db:"id"`
+	ChatID     int64  `db:"chat_id"`    // 0 - пакет из веб-интерфейса
+	MessageID  int    `db:"message_id"` // Сообщение с прогрессом в Telegram
+	Profile    string `db:"profile"`    // Область действия профиля настроек, пустая - общая конфигурация
+	FeedID     int64  `db:"feed_id"`    // Лента, записи которой поставлены в очередь, 0 - пакет пользователя
+	CreatedAt  int64  `db:"created_at"`
+	FinishedAt int64  `db:"finished_at"`
+}
+
+// Задание на анализ одного URL
+type Job struct {
+	ID        int64  `db:"id"`
+	BatchID   int64  `db:"batch_id"`
+	URL       string `db:"url"`
+	Status    string `db:"status"`
+	Error     string `db:"error"`
+	CreatedAt int64  `db:"created_at"`
+	UpdatedAt int64  `db:"updated_at"`
+}
+
+// Количество заданий пакета по статусам
+type BatchProgress struct {
+	Total   int
+	Pending int
+	Running int
+	Done    int
+	Failed  int
+}
+
+func (p BatchProgress) Finished() bool {
+	return p.Pending == 0 && p.Running == 0
+}
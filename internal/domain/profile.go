@@ -0,0 +1,42 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package domain
+
+import "strings"
+
+// Профиль настроек чата Telegram или пользователя веб-интерфейса.
+// Пустые поля берутся из общей конфигурации
+type Profile struct {
+	Scope             string `json:"-" db:"scope"` // telegram:<ID чата> или web:<имя пользователя>
+	Object            string `json:"object" db:"object"`
+	Metadata          string `json:"metadata" db:"metadata"`
+	PromptAffiliation string `json:"prompt_affiliation" db:"prompt_affiliation"`
+	PromptSentiment   string `json:"prompt_sentiment" db:"prompt_sentiment"`
+	PromptTitle       string `json:"prompt_title" db:"prompt_title"`
+	PromptStructured  string `json:"prompt_structured" db:"prompt_structured"`
+	Model             string `json:"model" db:"model"`
+	SpreadsheetID     string `json:"spreadsheet_id" db:"spreadsheet_id"`
+	SheetName         string `json:"sheet_name" db:"sheet_name"`
+	UpdatedAt         int64  `json:"-" db:"updated_at"`
+}
+
+// Область действия профиля для платформы и ID чата или пользователя
+func ProfileScope(platform string, id string) string {
+	return platform + ":" + strings.TrimSpace(id)
+}
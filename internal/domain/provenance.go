@@ -0,0 +1,105 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Способы загрузки страницы
+const (
+	FetcherHeadless = "headless" // Headless браузер
+	FetcherPlain    = "plain"    // Обычный HTTP запрос
+)
+
+// Способы извлечения текста из HTML
+const (
+	ExtractorTrafilatura = "trafilatura"
+	ExtractorCustom      = "custom" // Собственный разбор по селекторам
+)
+
+// Промпт, использованный при анализе. Полный текст хранится в таблице prompts по хешу
+type PromptRef struct {
+	Kind   string `json:"kind"` // title, affiliation, sentiment или structured
+	Object string `json:"object,omitempty"`
+	Hash   string `json:"hash"`
+	Text   string `json:"-"` // Промпт с подставленными объектом и метаданными, без текста статьи
+}
+
+// Короткая форма хеша для сообщений и таблиц
+func (ref PromptRef) ShortHash() string {
+	if len(ref.Hash) > 12 {
+		return ref.Hash[:12]
+	}
+
+	return ref.Hash
+}
+
+// Происхождение результата анализа: чем и как он был получен
+type Provenance struct {
+	Backend        string      `json:"backend,omitempty"`
+	Model          string      `json:"model,omitempty"`
+	Prompts        []PromptRef `json:"prompts,omitempty"`
+	MaxContentSize uint        `json:"max_content_size,omitempty"` // Ограничение текста, символов
+	Truncated      bool        `json:"truncated,omitempty"`        // Текст был урезан до MaxContentSize
+	Fetcher        string      `json:"fetcher,omitempty"`
+	Extractor      string      `json:"extractor,omitempty"`
+	FetchMillis    int64       `json:"fetch_ms,omitempty"`    // Загрузка и извлечение текста
+	AnalysisMillis int64       `json:"analysis_ms,omitempty"` // Запросы к LLM
+	Errors         []string    `json:"errors,omitempty"`
+	AnalyzedAt     int64       `json:"analyzed_at,omitempty"` // Unix timestamp
+}
+
+// Способ получения текста, например "headless+trafilatura"
+func (p *Provenance) ExtractionMethod() string {
+	var parts []string
+	if p.Fetcher != "" {
+		parts = append(parts, p.Fetcher)
+	}
+	if p.Extractor != "" {
+		parts = append(parts, p.Extractor)
+	}
+
+	return strings.Join(parts, "+")
+}
+
+// Короткие хеши промптов в виде "тип(объект):хеш", разделенные ";"
+func (p *Provenance) PromptHashes() string {
+	refs := make([]string, 0, len(p.Prompts))
+	for _, ref := range p.Prompts {
+		kind := ref.Kind
+		if ref.Object != "" && ref.Kind != "title" {
+			kind += "(" + ref.Object + ")"
+		}
+		refs = append(refs, kind+":"+ref.ShortHash())
+	}
+
+	return strings.Join(refs, ";")
+}
+
+// Время загрузки страницы
+func (p *Provenance) FetchDuration() time.Duration {
+	return time.Duration(p.FetchMillis) * time.Millisecond
+}
+
+// Время запросов к LLM
+func (p *Provenance) AnalysisDuration() time.Duration {
+	return time.Duration(p.AnalysisMillis) * time.Millisecond
+}
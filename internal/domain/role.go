@@ -0,0 +1,79 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Уровень доступа пользователя. Каждая следующая роль включает права предыдущих
+type Role int
+
+const (
+	RoleNone    Role = iota // Нет доступа
+	RoleViewer              // Просмотр результатов и сводок
+	RoleAnalyst             // Анализ статей
+	RoleAdmin               // Настройка бота и управление доступом
+)
+
+var Roles = []Role{RoleViewer, RoleAnalyst, RoleAdmin}
+
+// Платформы, пользователям которых назначаются роли
+const (
+	PlatformTelegram = "telegram"
+	PlatformWeb      = "web"
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleAnalyst:
+		return "analyst"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+// Разрешает ли роль действия, требующие роли required
+func (r Role) Allows(required Role) bool {
+	return r != RoleNone && r >= required
+}
+
+func ParseRole(name string) (Role, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, role := range Roles {
+		if role.String() == name {
+			return role, nil
+		}
+	}
+
+	return RoleNone, fmt.Errorf("неизвестная роль \"%s\". Допустимые: viewer, analyst, admin", name)
+}
+
+// Роль, назначенная пользователю
+type RoleAssignment struct {
+	Platform  string `db:"platform"`
+	UserID    string `db:"user_id"` // ID в Telegram или имя пользователя веб-интерфейса
+	Role      Role   `db:"role"`
+	UpdatedAt int64  `db:"updated_at"`
+}
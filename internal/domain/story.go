@@ -0,0 +1,61 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package domain
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Сюжет - группа статей об одном событии
+type Story struct {
+	ID             int64       `db:"id"`
+	Title          string      `db:"title"`
+	FirstArticleID int64       `db:"first_article_id"`
+	FirstURL       string      `db:"-"`
+	FirstSeen      int64       `db:"first_seen"` // Unix timestamp первой публикации
+	LastSeen       int64       `db:"last_seen"`  // Unix timestamp последней публикации
+	ArticleCount   int64       `db:"article_count"`
+	Hosts          []StoryHost `db:"-"` // Ресурсы по убыванию числа статей
+}
+
+// Количество статей сюжета на одном ресурсе
+type StoryHost struct {
+	Host     string
+	Articles int
+}
+
+// Время появления статьи: дата публикации, если известна, иначе дата добавления
+func (a *Article) SeenAt() int64 {
+	if a.PublishedAt > 0 {
+		return a.PublishedAt
+	}
+
+	return a.CreatedAt
+}
+
+// Имя ресурса без www
+func Hostname(sourceURL string) string {
+	u, err := url.Parse(sourceURL)
+	if err != nil || u.Hostname() == "" {
+		return sourceURL
+	}
+
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
@@ -0,0 +1,42 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package domain
+
+// Пользователь веб-интерфейса
+type WebUser struct {
+	Username     string `db:"username"`
+	PasswordHash string `db:"password_hash"`
+	Disabled     bool   `db:"disabled"`
+	CreatedAt    int64  `db:"created_at"`
+	LastLogin    int64  `db:"last_login"` // Unix timestamp, 0 - ни разу не входил
+}
+
+// Выданный веб-пользователю JWT, определяемый его jti
+type WebSession struct {
+	JTI       string `db:"jti"`
+	Username  string `db:"username"`
+	IssuedAt  int64  `db:"issued_at"`
+	ExpiresAt int64  `db:"expires_at"`
+	Revoked   bool   `db:"revoked"`
+}
+
+// Действует ли сессия в момент now (Unix)
+func (s *WebSession) Active(now int64) bool {
+	return !s.Revoked && s.ExpiresAt > now
+}
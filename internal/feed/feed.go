@@ -0,0 +1,237 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Наибольший размер загружаемой ленты
+const maxFeedSize = 10 << 20
+
+// Запись ленты
+type Item struct {
+	Title       string
+	Link        string
+	PublishedAt time.Time
+}
+
+// Разобранная RSS/Atom лента
+type Feed struct {
+	Title string
+	Items []Item
+}
+
+type rssItem struct {
+	Title   string `xml:"title"`
+	Link    string `xml:"link"`
+	GUID    string `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+	Date    string `xml:"date"` // dc:date в RSS 1.0
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	ID        string     `xml:"id"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+// Общая структура для RSS 2.0, RSS 1.0 (RDF) и Atom
+type document struct {
+	XMLName xml.Name
+	Title   string `xml:"title"`
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []rssItem   `xml:"item"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+}
+
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (item rssItem) toItem() Item {
+	link := strings.TrimSpace(item.Link)
+	if link == "" && strings.HasPrefix(strings.TrimSpace(item.GUID), "http") {
+		link = strings.TrimSpace(item.GUID)
+	}
+
+	published := parseDate(item.PubDate)
+	if published.IsZero() {
+		published = parseDate(item.Date)
+	}
+
+	return Item{
+		Title:       strings.TrimSpace(item.Title),
+		Link:        link,
+		PublishedAt: published,
+	}
+}
+
+func (entry atomEntry) toItem() Item {
+	var link string
+	for _, l := range entry.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			link = strings.TrimSpace(l.Href)
+			break
+		}
+	}
+	if link == "" && strings.HasPrefix(strings.TrimSpace(entry.ID), "http") {
+		link = strings.TrimSpace(entry.ID)
+	}
+
+	published := parseDate(entry.Published)
+	if published.IsZero() {
+		published = parseDate(entry.Updated)
+	}
+
+	return Item{
+		Title:       strings.TrimSpace(entry.Title),
+		Link:        link,
+		PublishedAt: published,
+	}
+}
+
+// Абсолютная ссылка записи. Относительные ссылки разрешаются относительно адреса ленты base
+func resolveLink(link string, base *url.URL) string {
+	if link == "" || base == nil {
+		return link
+	}
+
+	resolved, err := base.Parse(link)
+	if err != nil {
+		return link
+	}
+
+	return resolved.String()
+}
+
+// Разбирает RSS или Atom ленту, загруженную по адресу base (nil - относительные ссылки
+// остаются как есть). Записи без ссылок отбрасываются
+func Parse(r io.Reader, base *url.URL) (*Feed, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.Strict = false
+
+	var doc document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("ошибка разбора ленты: %w", err)
+	}
+
+	feed := &Feed{}
+	var items []Item
+
+	switch strings.ToLower(doc.XMLName.Local) {
+	case "rss":
+		feed.Title = doc.Channel.Title
+		for _, item := range doc.Channel.Items {
+			items = append(items, item.toItem())
+		}
+	case "rdf":
+		feed.Title = doc.Channel.Title
+		for _, item := range doc.Items {
+			items = append(items, item.toItem())
+		}
+	case "feed":
+		feed.Title = doc.Title
+		for _, entry := range doc.Entries {
+			items = append(items, entry.toItem())
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный формат ленты: <%s>", doc.XMLName.Local)
+	}
+
+	for _, item := range items {
+		if item.Link != "" {
+			item.Link = resolveLink(item.Link, base)
+			feed.Items = append(feed.Items, item)
+		}
+	}
+	feed.Title = strings.TrimSpace(feed.Title)
+
+	return feed, nil
+}
+
+// Загружает и разбирает ленту по URL
+func Fetch(feedURL string, timeout time.Duration) (*Feed, error) {
+	client := &http.Client{
+		Timeout: timeout,
+	}
+
+	req, err := http.NewRequest("GET", feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; ACASbot; +https://github.com/Unbewohnte/ACASbot)")
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.8, */*;q=0.5")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки ленты: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("неожиданный ответ сервера: %s", resp.Status)
+	}
+
+	// Ссылки разрешаются относительно адреса после перенаправлений
+	return Parse(io.LimitReader(resp.Body, maxFeedSize), resp.Request.URL)
+}
@@ -0,0 +1,176 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package feed
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseFixture(t *testing.T, name string, base string) *Feed {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(file, baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parsed
+}
+
+func checkItems(t *testing.T, got []Item, want []Item) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("записей %d, ожидалось %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Title != want[i].Title || got[i].Link != want[i].Link || !got[i].PublishedAt.Equal(want[i].PublishedAt) {
+			t.Errorf("запись %d: %+v, ожидалось %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseRSS(t *testing.T) {
+	parsed := parseFixture(t, "rss2.xml", "https://example.com/rss/all.xml")
+
+	if parsed.Title != "Новости города" {
+		t.Fatalf("заголовок ленты %q", parsed.Title)
+	}
+
+	// Записи без даты и с неразобранной датой сохраняются с нулевым временем, без ссылки - отбрасываются
+	checkItems(t, parsed.Items, []Item{
+		{"Открыт новый парк", "https://example.com/news/park", time.Date(2025, 6, 2, 6, 30, 0, 0, time.UTC)},
+		{"Относительная ссылка", "https://example.com/news/relative", time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)},
+		{"Ссылка в guid", "https://example.com/news/guid", time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{"Без даты", "https://example.com/rss/news/no-date?id=5", time.Time{}},
+		{"Дата в неизвестном формате", "https://example.com/news/bad-date", time.Time{}},
+	})
+}
+
+func TestParseAtom(t *testing.T) {
+	parsed := parseFixture(t, "atom.xml", "https://example.org/feeds/main.atom")
+
+	if parsed.Title != "Лента Atom" {
+		t.Fatalf("заголовок ленты %q", parsed.Title)
+	}
+
+	// Дата публикации предпочтительнее даты обновления
+	checkItems(t, parsed.Items, []Item{
+		{"Запись с alternate", "https://example.org/posts/1", time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC)},
+		{"Относительная ссылка без rel", "https://example.org/posts/2", time.Date(2025, 6, 2, 11, 0, 0, 0, time.UTC)},
+		{"Ссылка в id, без даты", "https://example.org/posts/3", time.Time{}},
+	})
+}
+
+func TestParseRDF(t *testing.T) {
+	parsed := parseFixture(t, "rdf.xml", "https://example.net/rss")
+
+	// Лента в windows-1251 перекодируется
+	if parsed.Title != "RSS 1.0" {
+		t.Fatalf("заголовок ленты %q", parsed.Title)
+	}
+	checkItems(t, parsed.Items, []Item{
+		{"Запись RDF", "https://example.net/1", time.Date(2025, 6, 2, 7, 15, 0, 0, time.UTC)},
+	})
+}
+
+func TestParseWithoutBase(t *testing.T) {
+	parsed, err := Parse(strings.NewReader(`<rss><channel><item><link>/news/1</link></item></channel></rss>`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkItems(t, parsed.Items, []Item{{"", "/news/1", time.Time{}}})
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]string{
+		"не XML":             "Not found",
+		"неизвестный формат": `<html><body>Страница</body></html>`,
+		"пустой документ":    "",
+	}
+
+	for name, document := range tests {
+		if _, err := Parse(strings.NewReader(document), nil); err == nil {
+			t.Errorf("%s: ошибки нет", name)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "rss2.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/rss/all.xml", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/rss/all.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write(fixture)
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<rss><channel><title>`)
+		chunk := strings.Repeat("x", 1<<20)
+		for i := 0; i <= maxFeedSize>>20; i++ {
+			fmt.Fprint(w, chunk)
+		}
+		fmt.Fprint(w, `</title></channel></rss>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// Относительные ссылки разрешаются относительно адреса после перенаправления
+	parsed, err := Fetch(server.URL+"/old", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Items) != 5 || parsed.Items[3].Link != server.URL+"/rss/news/no-date?id=5" {
+		t.Fatalf("записи: %+v", parsed.Items)
+	}
+
+	if _, err := Fetch(server.URL+"/missing", 5*time.Second); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("ошибка %v, ожидался ответ 404", err)
+	}
+
+	// Лента больше maxFeedSize обрезается и не разбирается
+	if _, err := Fetch(server.URL+"/huge", 5*time.Second); err == nil {
+		t.Fatal("слишком большая лента разобрана")
+	}
+}
@@ -21,119 +21,102 @@ package inference
 import (
 	"Unbewohnte/ACASbot/internal/similarity"
 	"context"
+	"encoding/json"
 	"fmt"
-	"math"
 	"regexp"
 	"strings"
-	"time"
+)
 
-	ollama "github.com/ollama/ollama/api"
+// Поддерживаемые бэкенды LLM
+const (
+	BackendOllama = "ollama"
+	BackendOpenAI = "openai" // OpenAI-совместимый API (llama.cpp server, vLLM и т.д.)
 )
 
-type Client struct {
-	ModelName      string
-	EmbeddingModel string
-	Client         *ollama.Client
-	TimeoutSeconds uint
+var Backends = []string{BackendOllama, BackendOpenAI}
+
+// Общий интерфейс клиентов LLM. Запросы прерываются при отмене ctx
+// и в любом случае ограничены таймаутом клиента
+type Client interface {
+	// Текстовый запрос к модели
+	Query(ctx context.Context, prompt string) (string, error)
+	// Текстовый запрос, ответ которого по мере генерации передается в onChunk по частям
+	QueryStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error)
+	// Запрос с ответом, ограниченным JSON схемой
+	QueryJSON(ctx context.Context, prompt string, schema json.RawMessage) (string, error)
+	// Нормализованный вектор текста
+	GetEmbedding(ctx context.Context, text string) ([]float64, error)
+	// Модели, доступные на сервере
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+
+	Backend() string
+	Model() string
+	SetModel(name string)
+	SetTimeout(seconds uint)
 }
 
-func NewClient(ollamaModel string, embeddingModel string, timeoutSeconds uint) (*Client, error) {
-	inference := &Client{
-		ModelName:      ollamaModel,
-		EmbeddingModel: embeddingModel,
-		TimeoutSeconds: timeoutSeconds,
-	}
-
-	client, err := ollama.ClientFromEnvironment()
-	if err != nil {
-		return nil, err
-	}
-	inference.Client = client
+// Сведения о доступной модели
+type ModelInfo struct {
+	Name          string
+	ParameterSize string
+	Quantization  string
+}
 
-	return inference, nil
+// Параметры подключения к LLM
+type Options struct {
+	Backend        string
+	BaseURL        string // Пустой адрес - значение по умолчанию для бэкенда
+	APIKey         string
+	Model          string
+	EmbeddingModel string
+	TimeoutSeconds uint
 }
 
-func (i *Client) ListModels() ([]ollama.ListModelResponse, error) {
-	response, err := i.Client.List(context.Background())
-	if err != nil {
-		return nil, err
+// Создает клиент выбранного бэкенда
+func NewClient(options Options) (Client, error) {
+	switch strings.ToLower(strings.TrimSpace(options.Backend)) {
+	case "", BackendOllama:
+		return NewOllamaClient(options)
+	case BackendOpenAI:
+		return NewOpenAIClient(options)
+	default:
+		return nil, fmt.Errorf("неизвестный бэкенд LLM \"%s\". Допустимые: %s", options.Backend, strings.Join(Backends, ", "))
 	}
-
-	return response.Models, nil
 }
 
-func (c *Client) Query(prompt string) (string, error) {
-	ctx, cancel := context.WithTimeout(
-		context.Background(),
-		time.Duration(c.TimeoutSeconds)*time.Second,
-	)
-	defer cancel()
-
-	var response strings.Builder
-	err := c.Client.Generate(ctx, &ollama.GenerateRequest{
-		Model:  c.ModelName,
-		Prompt: prompt,
-		Options: map[string]interface{}{
-			"temperature": 0.2, // Для более детерминированного вывода
-		},
-	}, func(res ollama.GenerateResponse) error {
-		response.WriteString(res.Response)
-		return nil
-	})
-
-	if err != nil {
-		return "", err
+// Проверяет, что имя бэкенда поддерживается
+func IsBackend(name string) bool {
+	for _, backend := range Backends {
+		if strings.EqualFold(backend, name) {
+			return true
+		}
 	}
 
-	return removeThinkBlock(response.String()), nil
+	return false
 }
 
-func (c *Client) GetEmbedding(text string) ([]float64, error) {
+// Текст, отправляемый на векторизацию
+func embeddingInput(text string) (string, error) {
 	if len([]rune(text)) < 50 {
-		return nil, fmt.Errorf("text too short for meaningful embedding")
+		return "", fmt.Errorf("text too short for meaningful embedding")
 	}
 
 	// Add context for better semantic understanding
-	contextualized := fmt.Sprintf("новостная статья: %s", text)
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.TimeoutSeconds)*time.Second)
-	defer cancel()
-
-	req := &ollama.EmbeddingRequest{
-		Model:  c.EmbeddingModel,
-		Prompt: contextualized,
-	}
-
-	resp, err := c.Client.Embeddings(ctx, req)
-	if err != nil {
-		return nil, fmt.Errorf("embedding request failed: %w", err)
-	}
+	return fmt.Sprintf("новостная статья: %s", text), nil
+}
 
-	if len(resp.Embedding) == 0 {
+// Копирует и нормализует полученный вектор
+func finishEmbedding(raw []float64) ([]float64, error) {
+	if len(raw) == 0 {
 		return nil, fmt.Errorf("empty embedding returned")
 	}
 
 	// Make a copy of the embedding slice
-	embedding := make([]float64, len(resp.Embedding))
-	copy(embedding, resp.Embedding)
-
-	// Debug: Check normalization
-	var sum float64
-	for _, v := range embedding {
-		sum += v * v
-	}
-	magnitude := math.Sqrt(sum)
-	fmt.Printf("Embedding magnitude before normalization: %f\n", magnitude)
+	embedding := make([]float64, len(raw))
+	copy(embedding, raw)
 
 	similarity.NormalizeVector(embedding)
 
-	sum = 0
-	for _, v := range embedding {
-		sum += v * v
-	}
-	magnitude = math.Sqrt(sum)
-	fmt.Printf("Embedding magnitude after normalization: %f\n", magnitude)
-
 	return embedding, nil
 }
 
@@ -0,0 +1,397 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Поддельный LLM сервер для проверки конвейера анализа без настоящей модели.
+// Отвечает по API Ollama (/api/...) и OpenAI-совместимому API (/v1/...).
+// Ответы детерминированы: отношение определяется по словарю маркеров,
+// векторы строятся хешированием слов, поэтому одинаковые тексты похожи
+package fakellm
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultModel          = "fake"
+	DefaultEmbeddingModel = "fake-embed"
+	EmbeddingDimensions   = 256
+)
+
+// Маркеры отношения (начала слов)
+var (
+	positiveMarkers = []string{"успех", "рост", "рекорд", "побед", "награ", "развит", "открыл", "открыт", "поддерж", "улучш", "достиж", "благодар"}
+	negativeMarkers = []string{"скандал", "кризис", "авари", "паден", "убыт", "провал", "наруш", "штраф", "банкрот", "уголовн", "обвин", "сокращ"}
+)
+
+var wordRegexp = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+type Server struct {
+	Model          string
+	EmbeddingModel string
+	Latency        time.Duration // Искусственная задержка ответа
+
+	requests atomic.Int64
+}
+
+func NewServer() *Server {
+	return &Server{
+		Model:          DefaultModel,
+		EmbeddingModel: DefaultEmbeddingModel,
+	}
+}
+
+// Количество обработанных запросов
+func (s *Server) Requests() int64 {
+	return s.requests.Load()
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	// Ollama
+	mux.HandleFunc("GET /api/tags", s.ollamaTags)
+	mux.HandleFunc("POST /api/generate", s.ollamaGenerate)
+	mux.HandleFunc("POST /api/embeddings", s.ollamaEmbeddings)
+
+	// OpenAI-совместимый API
+	mux.HandleFunc("GET /v1/models", s.openAIModels)
+	mux.HandleFunc("POST /v1/chat/completions", s.openAIChat)
+	mux.HandleFunc("POST /v1/embeddings", s.openAIEmbeddings)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		if s.Latency > 0 {
+			time.Sleep(s.Latency)
+		}
+		log.Printf("fakellm: %s %s", r.Method, r.URL.Path)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func (s *Server) ollamaTags(w http.ResponseWriter, r *http.Request) {
+	details := map[string]string{
+		"parameter_size":     "0B",
+		"quantization_level": "none",
+	}
+
+	writeJSON(w, map[string]any{
+		"models": []map[string]any{
+			{"name": s.Model, "model": s.Model, "details": details},
+			{"name": s.EmbeddingModel, "model": s.EmbeddingModel, "details": details},
+		},
+	})
+}
+
+func (s *Server) ollamaGenerate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model  string          `json:"model"`
+		Prompt string          `json:"prompt"`
+		Format json.RawMessage `json:"format"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	// Поток NDJSON: по сообщению на каждый фрагмент ответа и завершающее с done
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	for _, chunk := range chunks(Answer(req.Prompt, len(req.Format) > 0)) {
+		encoder.Encode(map[string]any{
+			"model":      req.Model,
+			"created_at": time.Now().UTC().Format(time.RFC3339),
+			"response":   chunk,
+			"done":       false,
+		})
+		flush(w)
+	}
+	encoder.Encode(map[string]any{
+		"model":      req.Model,
+		"created_at": time.Now().UTC().Format(time.RFC3339),
+		"response":   "",
+		"done":       true,
+	})
+}
+
+// Делит ответ на фрагменты по словам, как это делает настоящая модель при потоковой генерации
+func chunks(answer string) []string {
+	var parts []string
+	for len(answer) > 0 {
+		index := strings.IndexAny(answer[1:], " \n")
+		if index < 0 {
+			parts = append(parts, answer)
+			break
+		}
+		parts = append(parts, answer[:index+1])
+		answer = answer[index+1:]
+	}
+
+	return parts
+}
+
+func flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (s *Server) ollamaEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, map[string]any{"embedding": Embedding(req.Prompt)})
+}
+
+func (s *Server) openAIModels(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{
+		"object": "list",
+		"data": []map[string]any{
+			{"id": s.Model, "object": "model"},
+			{"id": s.EmbeddingModel, "object": "model"},
+		},
+	})
+}
+
+func (s *Server) openAIChat(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+		ResponseFormat *struct {
+			Type string `json:"type"`
+		} `json:"response_format"`
+		Stream bool `json:"stream"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]any{"error": map[string]string{"message": "bad request"}})
+		return
+	}
+
+	structured := req.ResponseFormat != nil && req.ResponseFormat.Type != "text"
+	prompt := req.Messages[len(req.Messages)-1].Content
+
+	if req.Stream {
+		// Server-Sent Events с фрагментами в choices[].delta
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks(Answer(prompt, structured)) {
+			encoded, _ := json.Marshal(map[string]any{
+				"object": "chat.completion.chunk",
+				"model":  req.Model,
+				"choices": []map[string]any{
+					{"index": 0, "delta": map[string]string{"content": chunk}},
+				},
+			})
+			fmt.Fprintf(w, "data: %s\n\n", encoded)
+			flush(w)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"object": "chat.completion",
+		"model":  req.Model,
+		"choices": []map[string]any{
+			{
+				"index":         0,
+				"message":       map[string]string{"role": "assistant", "content": Answer(prompt, structured)},
+				"finish_reason": "stop",
+			},
+		},
+	})
+}
+
+func (s *Server) openAIEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input string `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]any{"error": map[string]string{"message": "bad request"}})
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"object": "list",
+		"data": []map[string]any{
+			{"index": 0, "object": "embedding", "embedding": Embedding(req.Input)},
+		},
+	})
+}
+
+// Текст статьи из промпта - все после последней метки "Текст:"
+func promptText(prompt string) string {
+	if index := strings.LastIndex(prompt, "Текст:"); index >= 0 {
+		return strings.TrimSpace(prompt[index+len("Текст:"):])
+	}
+
+	return strings.TrimSpace(prompt)
+}
+
+// Объект анализа - первая строка в кавычках
+func promptObject(prompt string) string {
+	start := strings.Index(prompt, "\"")
+	if start < 0 {
+		return ""
+	}
+	end := strings.Index(prompt[start+1:], "\"")
+	if end < 0 {
+		return ""
+	}
+
+	return prompt[start+1 : start+1+end]
+}
+
+func countMarkers(words []string, markers []string) int {
+	count := 0
+	for _, word := range words {
+		for _, marker := range markers {
+			if strings.HasPrefix(word, marker) {
+				count++
+				break
+			}
+		}
+	}
+
+	return count
+}
+
+// Отношение к объекту по маркерам и уверенность в нем
+func sentiment(text string) (string, float64, string) {
+	words := wordRegexp.FindAllString(strings.ToLower(text), -1)
+	positive := countMarkers(words, positiveMarkers)
+	negative := countMarkers(words, negativeMarkers)
+
+	justification := fmt.Sprintf("Маркеров одобрения: %d, маркеров осуждения: %d.", positive, negative)
+	total := float64(positive + negative)
+	switch {
+	case positive > negative:
+		return "Позитивный", float64(positive) / total, justification
+	case negative > positive:
+		return "Отрицательный", float64(negative) / total, justification
+	default:
+		return "Информационный", 0.5, justification
+	}
+}
+
+// Первая непустая строка текста
+func title(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			runes := []rune(line)
+			if len(runes) > 120 {
+				line = string(runes[:120])
+			}
+			return line
+		}
+	}
+
+	return "Без заголовка"
+}
+
+func affiliation(text string, object string) string {
+	if object == "" || !strings.Contains(strings.ToLower(text), strings.ToLower(object)) {
+		return "Связи нет"
+	}
+
+	return fmt.Sprintf("В тексте упоминается %s.", object)
+}
+
+// Детерминированный ответ на промпт. structured - ответ должен быть JSON по схеме анализа
+func Answer(prompt string, structured bool) string {
+	text := promptText(prompt)
+	object := promptObject(prompt)
+	label, confidence, justification := sentiment(text)
+
+	if structured {
+		encoded, _ := json.Marshal(map[string]any{
+			"title":         title(text),
+			"affiliation":   affiliation(text, object),
+			"sentiment":     label,
+			"confidence":    math.Round(confidence*100) / 100,
+			"justification": justification,
+		})
+		return string(encoded)
+	}
+
+	lowered := strings.ToLower(prompt)
+	switch {
+	case strings.Contains(lowered, "заголов"):
+		return title(text)
+	case strings.Contains(lowered, "имеет отношение"), strings.Contains(lowered, "информация в тексте"):
+		return affiliation(text, object)
+	case strings.Contains(lowered, "отношение"):
+		return fmt.Sprintf("%s\nОбоснование: %s", label, justification)
+	default:
+		return fmt.Sprintf("Тестовый ответ на запрос из %d символов.", len([]rune(prompt)))
+	}
+}
+
+// Нормализованный вектор из хешей слов текста
+func Embedding(text string) []float64 {
+	vector := make([]float64, EmbeddingDimensions)
+	for _, word := range wordRegexp.FindAllString(strings.ToLower(text), -1) {
+		hash := fnv.New32a()
+		hash.Write([]byte(word))
+		sum := hash.Sum32()
+
+		sign := 1.0
+		if sum&1 == 1 {
+			sign = -1.0
+		}
+		vector[(sum>>1)%EmbeddingDimensions] += sign
+	}
+
+	var norm float64
+	for _, value := range vector {
+		norm += value * value
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		vector[0] = 1
+		return vector
+	}
+
+	for i := range vector {
+		vector[i] /= norm
+	}
+
+	return vector
+}
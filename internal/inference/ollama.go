@@ -0,0 +1,164 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package inference
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	ollama "github.com/ollama/ollama/api"
+)
+
+// Клиент Ollama
+type OllamaClient struct {
+	ModelName      string
+	EmbeddingModel string
+	Client         *ollama.Client
+	TimeoutSeconds uint
+}
+
+// Без адреса используется OLLAMA_HOST из окружения
+func NewOllamaClient(options Options) (*OllamaClient, error) {
+	inference := &OllamaClient{
+		ModelName:      options.Model,
+		EmbeddingModel: options.EmbeddingModel,
+		TimeoutSeconds: options.TimeoutSeconds,
+	}
+
+	if options.BaseURL != "" {
+		base, err := url.Parse(options.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("неверный адрес Ollama: %w", err)
+		}
+		inference.Client = ollama.NewClient(base, http.DefaultClient)
+		return inference, nil
+	}
+
+	client, err := ollama.ClientFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	inference.Client = client
+
+	return inference, nil
+}
+
+func (c *OllamaClient) Backend() string {
+	return BackendOllama
+}
+
+func (c *OllamaClient) Model() string {
+	return c.ModelName
+}
+
+func (c *OllamaClient) SetModel(name string) {
+	c.ModelName = name
+}
+
+func (c *OllamaClient) SetTimeout(seconds uint) {
+	c.TimeoutSeconds = seconds
+}
+
+func (c *OllamaClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	response, err := c.Client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	models := make([]ModelInfo, 0, len(response.Models))
+	for _, model := range response.Models {
+		models = append(models, ModelInfo{
+			Name:          model.Name,
+			ParameterSize: model.Details.ParameterSize,
+			Quantization:  model.Details.QuantizationLevel,
+		})
+	}
+
+	return models, nil
+}
+
+// Запрос к модели. Ollama всегда отдает ответ потоком, части передаются в onChunk, если он задан
+func (c *OllamaClient) generate(ctx context.Context, prompt string, format json.RawMessage, onChunk func(chunk string)) (string, error) {
+	ctx, cancel := context.WithTimeout(
+		ctx,
+		time.Duration(c.TimeoutSeconds)*time.Second,
+	)
+	defer cancel()
+
+	var response strings.Builder
+	err := c.Client.Generate(ctx, &ollama.GenerateRequest{
+		Model:  c.ModelName,
+		Prompt: prompt,
+		Format: format,
+		Options: map[string]interface{}{
+			"temperature": 0.2, // Для более детерминированного вывода
+		},
+	}, func(res ollama.GenerateResponse) error {
+		response.WriteString(res.Response)
+		if onChunk != nil && res.Response != "" {
+			onChunk(res.Response)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return removeThinkBlock(response.String()), nil
+}
+
+func (c *OllamaClient) Query(ctx context.Context, prompt string) (string, error) {
+	return c.generate(ctx, prompt, nil, nil)
+}
+
+func (c *OllamaClient) QueryStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	return c.generate(ctx, prompt, nil, onChunk)
+}
+
+func (c *OllamaClient) QueryJSON(ctx context.Context, prompt string, schema json.RawMessage) (string, error) {
+	return c.generate(ctx, prompt, schema, nil)
+}
+
+func (c *OllamaClient) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	contextualized, err := embeddingInput(text)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(c.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	req := &ollama.EmbeddingRequest{
+		Model:  c.EmbeddingModel,
+		Prompt: contextualized,
+	}
+
+	resp, err := c.Client.Embeddings(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+
+	return finishEmbedding(resp.Embedding)
+}
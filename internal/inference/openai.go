@@ -0,0 +1,339 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package inference
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const DefaultOpenAIBaseURL = "http://localhost:8080/v1"
+
+// Клиент OpenAI-совместимого API (/v1/chat/completions, /v1/embeddings, /v1/models)
+type OpenAIClient struct {
+	ModelName      string
+	EmbeddingModel string
+	BaseURL        string
+	APIKey         string
+	TimeoutSeconds uint
+	HTTPClient     *http.Client
+}
+
+func NewOpenAIClient(options Options) (*OpenAIClient, error) {
+	baseURL := strings.TrimRight(strings.TrimSpace(options.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+
+	return &OpenAIClient{
+		ModelName:      options.Model,
+		EmbeddingModel: options.EmbeddingModel,
+		BaseURL:        baseURL,
+		APIKey:         options.APIKey,
+		TimeoutSeconds: options.TimeoutSeconds,
+		HTTPClient:     &http.Client{},
+	}, nil
+}
+
+func (c *OpenAIClient) Backend() string {
+	return BackendOpenAI
+}
+
+func (c *OpenAIClient) Model() string {
+	return c.ModelName
+}
+
+func (c *OpenAIClient) SetModel(name string) {
+	c.ModelName = name
+}
+
+func (c *OpenAIClient) SetTimeout(seconds uint) {
+	c.TimeoutSeconds = seconds
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	Temperature    float64               `json:"temperature"`
+	Stream         bool                  `json:"stream"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// Фрагмент потокового ответа
+type openAIChatChunk struct {
+	Choices []struct {
+		Delta openAIMessage `json:"delta"`
+	} `json:"choices"`
+}
+
+type openAIEmbeddingRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+type openAIModelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+type openAIErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Отправляет запрос к API. При статусе, отличном от 200, возвращает ошибку с текстом ответа
+func (c *OpenAIClient) send(ctx context.Context, method string, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		contents, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		var apiErr openAIErrorResponse
+		if json.Unmarshal(contents, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("%s: %s", resp.Status, apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(contents)))
+	}
+
+	return resp, nil
+}
+
+// Выполняет запрос к API и декодирует ответ в result
+func (c *OpenAIClient) do(ctx context.Context, method string, path string, body any, result any) error {
+	ctx, cancel := context.WithTimeout(
+		ctx,
+		time.Duration(c.TimeoutSeconds)*time.Second,
+	)
+	defer cancel()
+
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	contents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(contents, result)
+}
+
+func (c *OpenAIClient) chat(ctx context.Context, prompt string, format *openAIResponseFormat) (string, error) {
+	var response openAIChatResponse
+	err := c.do(ctx, http.MethodPost, "/chat/completions", openAIChatRequest{
+		Model: c.ModelName,
+		Messages: []openAIMessage{
+			{Role: "user", Content: prompt},
+		},
+		Temperature:    0.2, // Для более детерминированного вывода
+		ResponseFormat: format,
+	}, &response)
+	if err != nil {
+		return "", err
+	}
+
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("пустой ответ модели")
+	}
+
+	return removeThinkBlock(response.Choices[0].Message.Content), nil
+}
+
+// Потоковый запрос к модели. Ответ приходит событиями SSE вида "data: {...}",
+// поток завершается строкой "data: [DONE]"
+func (c *OpenAIClient) chatStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	ctx, cancel := context.WithTimeout(
+		ctx,
+		time.Duration(c.TimeoutSeconds)*time.Second,
+	)
+	defer cancel()
+
+	resp, err := c.send(ctx, http.MethodPost, "/chat/completions", openAIChatRequest{
+		Model: c.ModelName,
+		Messages: []openAIMessage{
+			{Role: "user", Content: prompt},
+		},
+		Temperature: 0.2,
+		Stream:      true,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIChatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", fmt.Errorf("некорректный фрагмент ответа: %w", err)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		response.WriteString(chunk.Choices[0].Delta.Content)
+		if onChunk != nil {
+			onChunk(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if response.Len() == 0 {
+		return "", fmt.Errorf("пустой ответ модели")
+	}
+
+	return removeThinkBlock(response.String()), nil
+}
+
+func (c *OpenAIClient) Query(ctx context.Context, prompt string) (string, error) {
+	return c.chat(ctx, prompt, nil)
+}
+
+func (c *OpenAIClient) QueryStream(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	return c.chatStream(ctx, prompt, onChunk)
+}
+
+func (c *OpenAIClient) QueryJSON(ctx context.Context, prompt string, schema json.RawMessage) (string, error) {
+	return c.chat(ctx, prompt, &openAIResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &openAIJSONSchema{
+			Name:   "response",
+			Schema: schema,
+			Strict: true,
+		},
+	})
+}
+
+func (c *OpenAIClient) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	input, err := embeddingInput(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var response openAIEmbeddingResponse
+	err = c.do(ctx, http.MethodPost, "/embeddings", openAIEmbeddingRequest{
+		Model: c.EmbeddingModel,
+		Input: input,
+	}, &response)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+
+	if len(response.Data) == 0 {
+		return nil, fmt.Errorf("empty embedding returned")
+	}
+
+	return finishEmbedding(response.Data[0].Embedding)
+}
+
+func (c *OpenAIClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	var response openAIModelsResponse
+	if err := c.do(ctx, http.MethodGet, "/models", nil, &response); err != nil {
+		return nil, err
+	}
+
+	models := make([]ModelInfo, 0, len(response.Data))
+	for _, model := range response.Data {
+		models = append(models, ModelInfo{Name: model.ID})
+	}
+
+	return models, nil
+}
@@ -0,0 +1,149 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package inference
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Допустимые метки отношения к объекту
+const (
+	SentimentPositive = "Позитивный"
+	SentimentNeutral  = "Информационный"
+	SentimentNegative = "Отрицательный"
+)
+
+var SentimentLabels = []string{SentimentPositive, SentimentNeutral, SentimentNegative}
+
+// Ответ модели при структурированном анализе статьи
+type StructuredAnalysis struct {
+	Title         string  `json:"title"`
+	Affiliation   string  `json:"affiliation"`
+	Sentiment     string  `json:"sentiment"`
+	Confidence    float64 `json:"confidence"`
+	Justification string  `json:"justification"`
+}
+
+// JSON схема ответа, передаваемая Ollama в поле format и OpenAI-совместимым серверам
+// в строгом режиме. Строгий режим OpenAI не поддерживает ограничения minimum/maximum,
+// поэтому диапазон confidence проверяется при разборе ответа
+var AnalysisSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"title": {"type": "string"},
+		"affiliation": {"type": "string"},
+		"sentiment": {"type": "string", "enum": ["Позитивный", "Информационный", "Отрицательный"]},
+		"confidence": {"type": "number", "description": "Уверенность от 0 до 1"},
+		"justification": {"type": "string"}
+	},
+	"required": ["title", "affiliation", "sentiment", "confidence", "justification"],
+	"additionalProperties": false
+}`)
+
+// Приводит метку отношения к одной из допустимых, если она отличается лишь регистром или пробелами
+func normalizeSentimentLabel(label string) (string, bool) {
+	label = strings.TrimSpace(label)
+	for _, allowed := range SentimentLabels {
+		if strings.EqualFold(label, allowed) {
+			return allowed, true
+		}
+	}
+
+	return label, false
+}
+
+// Разбирает и проверяет ответ модели
+func ParseStructuredAnalysis(response string) (*StructuredAnalysis, error) {
+	// Некоторые модели оборачивают JSON в блок кода или сопровождают пояснениями,
+	// поэтому разбирается текст от первой открывающей до последней закрывающей скобки
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start >= 0 && end > start {
+		response = response[start : end+1]
+	}
+
+	var analysis StructuredAnalysis
+	if err := json.Unmarshal([]byte(strings.TrimSpace(response)), &analysis); err != nil {
+		return nil, fmt.Errorf("ответ не является корректным JSON: %w", err)
+	}
+
+	var problems []string
+	if strings.TrimSpace(analysis.Affiliation) == "" {
+		problems = append(problems, "пустое поле affiliation")
+	}
+
+	label, ok := normalizeSentimentLabel(analysis.Sentiment)
+	if !ok {
+		problems = append(problems, fmt.Sprintf("недопустимое значение sentiment \"%s\"", analysis.Sentiment))
+	}
+	analysis.Sentiment = label
+
+	if analysis.Confidence < 0 || analysis.Confidence > 1 {
+		problems = append(problems, fmt.Sprintf("confidence %.2f вне диапазона 0..1", analysis.Confidence))
+	}
+
+	if len(problems) > 0 {
+		return &analysis, errors.New(strings.Join(problems, "; "))
+	}
+
+	analysis.Title = strings.TrimSpace(analysis.Title)
+	analysis.Affiliation = strings.TrimSpace(analysis.Affiliation)
+	analysis.Justification = strings.TrimSpace(analysis.Justification)
+
+	return &analysis, nil
+}
+
+// Структурированный анализ статьи. При некорректном ответе запрос повторяется
+// до retries раз с указанием модели на ошибку. Если все попытки неудачны, возвращается
+// последний разобранный (возможно, неполный) ответ вместе с ошибкой
+func QueryAnalysis(ctx context.Context, c Client, prompt string, retries uint) (*StructuredAnalysis, error) {
+	var (
+		lastAnalysis *StructuredAnalysis
+		lastErr      error
+	)
+
+	currentPrompt := prompt
+	for attempt := uint(0); attempt <= retries; attempt++ {
+		response, err := c.QueryJSON(ctx, currentPrompt, AnalysisSchema)
+		if err != nil {
+			// Ошибки связи повторять бессмысленно
+			return nil, err
+		}
+
+		analysis, err := ParseStructuredAnalysis(response)
+		if err == nil {
+			return analysis, nil
+		}
+
+		if analysis != nil {
+			lastAnalysis = analysis
+		}
+		lastErr = err
+
+		currentPrompt = fmt.Sprintf(
+			"%s\n\nПредыдущий ответ был отклонен (%s). Ответь строго JSON объектом по заданной схеме. Поле sentiment - одно из: %s.",
+			prompt, err.Error(), strings.Join(SentimentLabels, ", "),
+		)
+	}
+
+	return lastAnalysis, fmt.Errorf("некорректный структурированный ответ после %d попыток: %w", retries+1, lastErr)
+}
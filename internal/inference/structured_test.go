@@ -0,0 +1,177 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package inference
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseStructuredAnalysis(t *testing.T) {
+	const valid = `{"title": " Заголовок ", "affiliation": "Упоминается объект.", "sentiment": "Позитивный", "confidence": 0.8, "justification": "Рост показателей."}`
+
+	tests := []struct {
+		name      string
+		response  string
+		sentiment string
+		problem   string // Часть ожидаемой ошибки, пустая - ответ корректен
+	}{
+		{name: "корректный JSON", response: valid, sentiment: SentimentPositive},
+		{name: "блок кода", response: "```json\n" + valid + "\n```", sentiment: SentimentPositive},
+		{name: "блок кода без языка", response: "```\n" + valid + "\n```", sentiment: SentimentPositive},
+		{name: "JSON среди пояснений", response: "Вот результат анализа:\n" + valid + "\nНадеюсь, это поможет.", sentiment: SentimentPositive},
+		{name: "блок кода среди пояснений", response: "Ответ:\n```json\n" + valid + "\n```\nГотово.", sentiment: SentimentPositive},
+		{
+			name:      "метка в другом регистре",
+			response:  `{"affiliation": "Да.", "sentiment": " отрицательный ", "confidence": 1}`,
+			sentiment: SentimentNegative,
+		},
+		{name: "не JSON", response: "Отношение позитивное.", problem: "не является корректным JSON"},
+		{name: "оборванный JSON", response: `{"affiliation": "Да.", "sentiment": "Позитив`, problem: "не является корректным JSON"},
+		{name: "пустой ответ", response: "", problem: "не является корректным JSON"},
+		{
+			name:     "недопустимая метка",
+			response: `{"affiliation": "Да.", "sentiment": "Восторженный", "confidence": 0.5}`,
+			problem:  "недопустимое значение sentiment",
+		},
+		{
+			name:     "уверенность больше 1",
+			response: `{"affiliation": "Да.", "sentiment": "Позитивный", "confidence": 1.5}`,
+			problem:  "вне диапазона",
+		},
+		{
+			name:     "отрицательная уверенность",
+			response: `{"affiliation": "Да.", "sentiment": "Позитивный", "confidence": -0.1}`,
+			problem:  "вне диапазона",
+		},
+		{
+			name:     "пустая связь с объектом",
+			response: `{"affiliation": " ", "sentiment": "Позитивный", "confidence": 0.5}`,
+			problem:  "пустое поле affiliation",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			analysis, err := ParseStructuredAnalysis(test.response)
+			if test.problem != "" {
+				if err == nil || !strings.Contains(err.Error(), test.problem) {
+					t.Fatalf("ошибка %v, ожидалась \"%s\"", err, test.problem)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if analysis.Sentiment != test.sentiment {
+				t.Fatalf("отношение %q, ожидалось %q", analysis.Sentiment, test.sentiment)
+			}
+			if strings.TrimSpace(analysis.Title) != analysis.Title {
+				t.Fatalf("заголовок не очищен от пробелов: %q", analysis.Title)
+			}
+		})
+	}
+}
+
+// Клиент, возвращающий заданные ответы по порядку и запоминающий промпты
+type scriptedClient struct {
+	Client
+
+	responses []string
+	err       error
+	prompts   []string
+}
+
+func (c *scriptedClient) QueryJSON(ctx context.Context, prompt string, schema json.RawMessage) (string, error) {
+	c.prompts = append(c.prompts, prompt)
+	if c.err != nil {
+		return "", c.err
+	}
+
+	response := c.responses[0]
+	if len(c.responses) > 1 {
+		c.responses = c.responses[1:]
+	}
+
+	return response, nil
+}
+
+func TestQueryAnalysis(t *testing.T) {
+	const (
+		valid    = `{"affiliation": "Да.", "sentiment": "Информационный", "confidence": 0.5}`
+		invalid  = `{"affiliation": "Да.", "sentiment": "Непонятный", "confidence": 0.5}`
+		notJSON  = "не знаю"
+		failure  = "connection refused"
+		attempts = 3
+	)
+
+	tests := []struct {
+		name      string
+		responses []string
+		err       error
+		queries   int
+		problem   string
+		partial   bool // После неудачи возвращается последний разобранный ответ
+	}{
+		{name: "успех с первой попытки", responses: []string{valid}, queries: 1},
+		{name: "успех после повтора", responses: []string{notJSON, invalid, valid}, queries: 3},
+		{name: "попытки исчерпаны", responses: []string{invalid}, queries: attempts, problem: "после 3 попыток", partial: true},
+		{name: "попытки исчерпаны без JSON", responses: []string{notJSON}, queries: attempts, problem: "не является корректным JSON"},
+		{name: "ошибка связи не повторяется", err: errors.New(failure), queries: 1, problem: failure},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &scriptedClient{responses: test.responses, err: test.err}
+
+			analysis, err := QueryAnalysis(context.Background(), client, "Промпт", attempts-1)
+			if len(client.prompts) != test.queries {
+				t.Fatalf("запросов %d, ожидалось %d", len(client.prompts), test.queries)
+			}
+
+			// Повторный запрос указывает модели на ошибку предыдущего ответа
+			for _, prompt := range client.prompts[1:] {
+				if !strings.HasPrefix(prompt, "Промпт") || !strings.Contains(prompt, "Предыдущий ответ был отклонен") {
+					t.Fatalf("промпт повтора: %q", prompt)
+				}
+			}
+
+			if test.problem == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if analysis.Sentiment != SentimentNeutral {
+					t.Fatalf("отношение %q", analysis.Sentiment)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.problem) {
+				t.Fatalf("ошибка %v, ожидалась \"%s\"", err, test.problem)
+			}
+			if (analysis != nil) != test.partial {
+				t.Fatalf("неполный ответ: %+v", analysis)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Сводки по статьям, добавленным за период
+package report
+
+import (
+	"Unbewohnte/ACASbot/internal/domain"
+	"sort"
+	"time"
+)
+
+const negativeSentiment = "Отрицательный"
+
+// Количество статей с одним значением признака
+type Count struct {
+	Name     string
+	Articles int
+}
+
+// Сводка за период
+type Digest struct {
+	Title       string
+	From        time.Time
+	To          time.Time
+	Total       int
+	Irrelevant  int
+	BySentiment []Count
+	ByHost      []Count
+	TopCited    []domain.Article // Самые цитируемые оригинальные статьи
+	NewNegative []domain.Article // Новые статьи с отрицательным отношением
+}
+
+// Параметры сводки
+type Options struct {
+	Title       string
+	TopCited    int
+	MaxNegative int
+	MaxHosts    int
+}
+
+func sortedCounts(counts map[string]int, limit int) []Count {
+	result := make([]Count, 0, len(counts))
+	for name, articles := range counts {
+		result = append(result, Count{Name: name, Articles: articles})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Articles != result[j].Articles {
+			return result[i].Articles > result[j].Articles
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result
+}
+
+// Собирает сводку по статьям, добавленным в период [from, to)
+func Build(articles []domain.Article, from time.Time, to time.Time, options Options) *Digest {
+	digest := &Digest{
+		Title: options.Title,
+		From:  from,
+		To:    to,
+	}
+
+	sentiments := make(map[string]int)
+	hosts := make(map[string]int)
+	var originals []domain.Article
+
+	for _, art := range articles {
+		digest.Total++
+		hosts[domain.Hostname(art.SourceURL)]++
+
+		if art.Irrelevant {
+			digest.Irrelevant++
+			continue
+		}
+
+		sentiment := art.Sentiment
+		if sentiment == "" {
+			sentiment = "Не определено"
+		}
+		sentiments[sentiment]++
+
+		if art.Original && art.Citations > 0 {
+			originals = append(originals, art)
+		}
+
+		if art.Sentiment == negativeSentiment {
+			digest.NewNegative = append(digest.NewNegative, art)
+		}
+	}
+
+	digest.BySentiment = sortedCounts(sentiments, 0)
+	digest.ByHost = sortedCounts(hosts, options.MaxHosts)
+
+	sort.SliceStable(originals, func(i, j int) bool { return originals[i].Citations > originals[j].Citations })
+	if options.TopCited > 0 && len(originals) > options.TopCited {
+		originals = originals[:options.TopCited]
+	}
+	digest.TopCited = originals
+
+	// Сначала самые свежие
+	sort.SliceStable(digest.NewNegative, func(i, j int) bool {
+		return digest.NewNegative[i].CreatedAt > digest.NewNegative[j].CreatedAt
+	})
+	if options.MaxNegative > 0 && len(digest.NewNegative) > options.MaxNegative {
+		digest.NewNegative = digest.NewNegative[:options.MaxNegative]
+	}
+
+	return digest
+}
+
+func articleTitle(art domain.Article) string {
+	if art.Title != "" {
+		return art.Title
+	}
+
+	return art.SourceURL
+}
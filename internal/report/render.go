@@ -0,0 +1,135 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+const dateFormat = "2006-01-02 15:04"
+
+// Убирает символы, ломающие Markdown разметку Telegram
+func markdownSafe(text string) string {
+	return strings.NewReplacer("*", "", "_", " ", "[", "(", "]", ")", "`", "'").Replace(text)
+}
+
+func (d *Digest) heading() string {
+	title := d.Title
+	if title == "" {
+		title = "Сводка"
+	}
+
+	return title
+}
+
+// Сводка в Markdown для Telegram
+func (d *Digest) Markdown() string {
+	var response strings.Builder
+
+	response.WriteString(fmt.Sprintf("📊 *%s*\n", markdownSafe(d.heading())))
+	response.WriteString(fmt.Sprintf("%s — %s\n\n", d.From.Format(dateFormat), d.To.Format(dateFormat)))
+
+	if d.Total == 0 {
+		response.WriteString("За период новых статей нет")
+		return response.String()
+	}
+
+	response.WriteString(fmt.Sprintf("*Статей добавлено:* %d", d.Total))
+	if d.Irrelevant > 0 {
+		response.WriteString(fmt.Sprintf(" (нерелевантных: %d)", d.Irrelevant))
+	}
+	response.WriteString("\n")
+
+	if len(d.BySentiment) > 0 {
+		response.WriteString("\n*По отношению:*\n")
+		for _, count := range d.BySentiment {
+			response.WriteString(fmt.Sprintf("- %s: %d\n", count.Name, count.Articles))
+		}
+	}
+
+	if len(d.ByHost) > 0 {
+		response.WriteString("\n*По ресурсам:*\n")
+		for _, count := range d.ByHost {
+			response.WriteString(fmt.Sprintf("- %s: %d\n", markdownSafe(count.Name), count.Articles))
+		}
+	}
+
+	if len(d.TopCited) > 0 {
+		response.WriteString("\n*Самые цитируемые оригиналы:*\n")
+		for i, art := range d.TopCited {
+			response.WriteString(fmt.Sprintf("%d. [%s](%s) - цитирований: %d\n", i+1, markdownSafe(articleTitle(art)), art.SourceURL, art.Citations))
+		}
+	}
+
+	if len(d.NewNegative) > 0 {
+		response.WriteString("\n*Новые отрицательные статьи:*\n")
+		for i, art := range d.NewNegative {
+			response.WriteString(fmt.Sprintf("%d. [%s](%s)\n", i+1, markdownSafe(articleTitle(art)), art.SourceURL))
+			if art.Justification != "" {
+				response.WriteString(fmt.Sprintf("   %s\n", markdownSafe(art.Justification)))
+			}
+		}
+	}
+
+	return response.String()
+}
+
+var htmlTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"date":  func(t time.Time) string { return t.Format(dateFormat) },
+	"title": articleTitle,
+}).Parse(`<div class="digest">
+<h5>📊 {{.Heading}}</h5>
+<p class="text-muted">{{date .From}} — {{date .To}}</p>
+{{if eq .Total 0}}<p>За период новых статей нет</p>{{else}}
+<p><strong>Статей добавлено:</strong> {{.Total}}{{if .Irrelevant}} (нерелевантных: {{.Irrelevant}}){{end}}</p>
+{{if .BySentiment}}<h6>По отношению</h6>
+<table class="table table-sm"><tbody>
+{{range .BySentiment}}<tr><td>{{.Name}}</td><td>{{.Articles}}</td></tr>
+{{end}}</tbody></table>{{end}}
+{{if .ByHost}}<h6>По ресурсам</h6>
+<table class="table table-sm"><tbody>
+{{range .ByHost}}<tr><td>{{.Name}}</td><td>{{.Articles}}</td></tr>
+{{end}}</tbody></table>{{end}}
+{{if .TopCited}}<h6>Самые цитируемые оригиналы</h6>
+<ol>
+{{range .TopCited}}<li><a href="{{.SourceURL}}" target="_blank">{{title .}}</a> - цитирований: {{.Citations}}</li>
+{{end}}</ol>{{end}}
+{{if .NewNegative}}<h6>Новые отрицательные статьи</h6>
+<ol>
+{{range .NewNegative}}<li><a href="{{.SourceURL}}" target="_blank">{{title .}}</a>{{if .Justification}}<br><small>{{.Justification}}</small>{{end}}</li>
+{{end}}</ol>{{end}}
+{{end}}</div>`))
+
+// Сводка в HTML для веб-интерфейса
+func (d *Digest) HTML() (string, error) {
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, struct {
+		*Digest
+		Heading string
+	}{d, d.heading()})
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
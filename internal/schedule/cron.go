@@ -0,0 +1,207 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Разбор cron-выражений из пяти полей: минута, час, день месяца, месяц, день недели.
+// Поддерживаются *, списки (1,15), диапазоны (1-5), шаги (*/10, 8-18/2)
+// и сокращения @hourly, @daily, @weekly, @monthly
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type field struct {
+	min, max int
+}
+
+var fields = [5]field{
+	{0, 59}, // Минута
+	{0, 23}, // Час
+	{1, 31}, // День месяца
+	{1, 12}, // Месяц
+	{0, 7},  // День недели (0 и 7 - воскресенье)
+}
+
+var macros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// Разобранное cron-выражение
+type Cron struct {
+	expression string
+	sets       [5]uint64 // Битовые маски допустимых значений полей
+	anyDay     bool      // Поле дня месяца начинается с * (*, */2)
+	anyWeekday bool      // Поле дня недели начинается с *
+}
+
+func (c *Cron) String() string {
+	return c.expression
+}
+
+func parseField(value string, f field) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(value, ",") {
+		step := 1
+		base, stepValue, stepped := strings.Cut(part, "/")
+		if stepped {
+			n, err := strconv.Atoi(stepValue)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("неверный шаг \"%s\"", stepValue)
+			}
+			step = n
+			part = base
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			from, to, _ := strings.Cut(part, "-")
+			var err error
+			if start, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("неверное значение \"%s\"", from)
+			}
+			if end, err = strconv.Atoi(to); err != nil {
+				return 0, fmt.Errorf("неверное значение \"%s\"", to)
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("неверное значение \"%s\"", part)
+			}
+			// Число с шагом (5/15) - диапазон от числа до конца поля
+			start = n
+			if !stepped {
+				end = n
+			}
+		}
+
+		if start < f.min || end > f.max || start > end {
+			return 0, fmt.Errorf("значение \"%s\" вне диапазона %d-%d", part, f.min, f.max)
+		}
+
+		for i := start; i <= end; i += step {
+			set |= 1 << uint(i)
+		}
+	}
+
+	return set, nil
+}
+
+// Разбирает cron-выражение
+func Parse(expression string) (*Cron, error) {
+	expression = strings.TrimSpace(expression)
+	normalized := expression
+	if macro, ok := macros[strings.ToLower(expression)]; ok {
+		normalized = macro
+	}
+
+	parts := strings.Fields(normalized)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron-выражение должно состоять из 5 полей (минута час день месяц день_недели), получено %d", len(parts))
+	}
+
+	cron := &Cron{
+		expression: expression,
+		anyDay:     strings.HasPrefix(parts[2], "*"),
+		anyWeekday: strings.HasPrefix(parts[4], "*"),
+	}
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("поле %d: %w", i+1, err)
+		}
+		cron.sets[i] = set
+	}
+
+	// Воскресенье можно указать как 0 или 7
+	if cron.sets[4]&(1<<7) != 0 {
+		cron.sets[4] |= 1
+	}
+
+	return cron, nil
+}
+
+func (c *Cron) has(index int, value int) bool {
+	return c.sets[index]&(1<<uint(value)) != 0
+}
+
+// Подходит ли день. Как в Vixie cron, если оба поля дней заданы без *,
+// достаточно совпадения одного из них, иначе должны совпасть оба.
+// Поле с шагом от * (*/2) считается начинающимся с * и правило ИЛИ не включает
+func (c *Cron) matchesDay(t time.Time) bool {
+	day := c.has(2, t.Day())
+	weekday := c.has(4, int(t.Weekday()))
+
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+
+	return day || weekday
+}
+
+// Совпадает ли минута t с выражением
+func (c *Cron) Matches(t time.Time) bool {
+	return c.has(0, t.Minute()) && c.has(1, t.Hour()) && c.has(3, int(t.Month())) && c.matchesDay(t)
+}
+
+// Следующее проверяемое время. Время, пропущенное при переводе часов вперед, time.Date
+// переносит назад (2:00 - в 1:00), поэтому в таком случае проверка продолжается по минутам
+func forward(t time.Time, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+
+	return t.Add(time.Minute)
+}
+
+// Ближайшее время срабатывания строго после after. Нулевое время, если его нет в ближайшие 5 лет
+func (c *Cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !c.has(3, int(t.Month())) {
+			t = forward(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+		if !c.matchesDay(t) {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+		if !c.has(1, t.Hour()) {
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location()))
+			continue
+		}
+		if !c.has(0, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
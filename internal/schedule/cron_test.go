@@ -0,0 +1,158 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expression string
+		field      int   // Индекс проверяемого поля
+		values     []int // Ожидаемые значения поля
+		problem    string
+	}{
+		{expression: "*/15 * * * *", field: 0, values: []int{0, 15, 30, 45}},
+		{expression: "0 8-18/4 * * *", field: 1, values: []int{8, 12, 16}},
+		{expression: "0 0 1,15,31 * *", field: 2, values: []int{1, 15, 31}},
+		{expression: "0 0 * 1-3,12 *", field: 3, values: []int{1, 2, 3, 12}},
+		{expression: "0 0 * * 5/1", field: 4, values: []int{0, 5, 6, 7}},
+		{expression: "0 0 * * 7", field: 4, values: []int{0, 7}},
+		{expression: "0 0 * * 5-7", field: 4, values: []int{0, 5, 6, 7}},
+		{expression: "@weekly", field: 4, values: []int{0}},
+		{expression: " @Daily ", field: 1, values: []int{0}},
+
+		{expression: "", problem: "5 полей"},
+		{expression: "* * * *", problem: "5 полей"},
+		{expression: "* * * * * *", problem: "5 полей"},
+		{expression: "@yearly", problem: "5 полей"},
+		{expression: "60 * * * *", problem: "вне диапазона"},
+		{expression: "* 24 * * *", problem: "вне диапазона"},
+		{expression: "* * 0 * *", problem: "вне диапазона"},
+		{expression: "* * * 13 *", problem: "вне диапазона"},
+		{expression: "* * * * 8", problem: "вне диапазона"},
+		{expression: "5-1 * * * *", problem: "вне диапазона"},
+		{expression: "*/0 * * * *", problem: "неверный шаг"},
+		{expression: "*/x * * * *", problem: "неверный шаг"},
+		{expression: "a * * * *", problem: "неверное значение"},
+		{expression: "1-x * * * *", problem: "неверное значение"},
+		{expression: "1,,2 * * * *", problem: "неверное значение"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			cron, err := Parse(test.expression)
+			if test.problem != "" {
+				if err == nil || !strings.Contains(err.Error(), test.problem) {
+					t.Fatalf("ошибка %v, ожидалась \"%s\"", err, test.problem)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var values []int
+			for value := fields[test.field].min; value <= fields[test.field].max; value++ {
+				if cron.has(test.field, value) {
+					values = append(values, value)
+				}
+			}
+			if len(values) != len(test.values) {
+				t.Fatalf("значения поля %v, ожидались %v", values, test.values)
+			}
+			for i := range values {
+				if values[i] != test.values[i] {
+					t.Fatalf("значения поля %v, ожидались %v", values, test.values)
+				}
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("нет базы часовых поясов: %v", err)
+	}
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skipf("нет базы часовых поясов: %v", err)
+	}
+
+	date := func(location *time.Location, year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, location)
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		after      time.Time
+		want       time.Time
+	}{
+		{"строго после", "30 9 * * *", date(time.UTC, 2025, 6, 2, 9, 30), date(time.UTC, 2025, 6, 3, 9, 30)},
+		{"секунды отбрасываются", "* * * * *", time.Date(2025, 6, 2, 9, 30, 59, 0, time.UTC), date(time.UTC, 2025, 6, 2, 9, 31)},
+		{"шаг минут", "*/20 * * * *", date(time.UTC, 2025, 6, 2, 9, 41), date(time.UTC, 2025, 6, 2, 10, 0)},
+		{"диапазон с шагом", "0 8-18/4 * * *", date(time.UTC, 2025, 6, 2, 16, 0), date(time.UTC, 2025, 6, 3, 8, 0)},
+		{"конец года", "0 0 1 1 *", date(time.UTC, 2025, 6, 2, 0, 0), date(time.UTC, 2026, 1, 1, 0, 0)},
+		{"переход месяца", "0 12 31 * *", date(time.UTC, 2025, 3, 31, 12, 0), date(time.UTC, 2025, 5, 31, 12, 0)},
+		{"29 февраля", "0 0 29 2 *", date(time.UTC, 2025, 1, 1, 0, 0), date(time.UTC, 2028, 2, 29, 0, 0)},
+		{"воскресенье как 7", "0 10 * * 7", date(time.UTC, 2025, 6, 2, 0, 0), date(time.UTC, 2025, 6, 8, 10, 0)},
+		{"воскресенье как 0", "0 10 * * 0", date(time.UTC, 2025, 6, 2, 0, 0), date(time.UTC, 2025, 6, 8, 10, 0)},
+
+		// 13 июня 2025 - пятница: при обоих ограниченных полях достаточно одного совпадения
+		{"правило ИЛИ: день недели", "0 0 13 * 5", date(time.UTC, 2025, 6, 1, 0, 0), date(time.UTC, 2025, 6, 6, 0, 0)},
+		{"правило ИЛИ: день месяца", "0 0 13 * 5", date(time.UTC, 2025, 6, 7, 0, 0), date(time.UTC, 2025, 6, 13, 0, 0)},
+		{"правило ИЛИ: после совпадения обоих", "0 0 13 * 5", date(time.UTC, 2025, 6, 13, 0, 0), date(time.UTC, 2025, 6, 20, 0, 0)},
+		// Поле с шагом от * правило ИЛИ не включает: нужны нечетный день и понедельник
+		{"шаг от * без правила ИЛИ", "0 0 */2 * 1", date(time.UTC, 2025, 6, 1, 0, 0), date(time.UTC, 2025, 6, 9, 0, 0)},
+		{"шаг дня недели без правила ИЛИ", "0 0 10 * */2", date(time.UTC, 2025, 6, 1, 0, 0), date(time.UTC, 2025, 6, 10, 0, 0)},
+
+		// 9 марта 2025 в Нью-Йорке часы переводятся с 2:00 на 3:00: несуществующее время пропускается
+		{"переход на летнее время", "30 2 * * *", date(newYork, 2025, 3, 9, 0, 0), date(newYork, 2025, 3, 10, 2, 30)},
+		{"час после перехода", "0 * * * *", date(newYork, 2025, 3, 9, 1, 0), date(newYork, 2025, 3, 9, 3, 0)},
+		{"минуты после перехода", "15 2-3 * * *", date(newYork, 2025, 3, 9, 1, 30), date(newYork, 2025, 3, 9, 3, 15)},
+		// 4 ноября 2018 в Сан-Паулу часы переводились в полночь, 0:00 не существовало
+		{"пропущенная полночь", "0 12 * * *", date(saoPaulo, 2018, 11, 3, 13, 0), date(saoPaulo, 2018, 11, 4, 12, 0)},
+		// 2 ноября 2025 час с 1:00 до 2:00 повторяется: ежечасное задание срабатывает через час
+		{"переход на зимнее время", "0 * * * *", date(newYork, 2025, 11, 2, 1, 0), date(newYork, 2025, 11, 2, 1, 0).Add(time.Hour)},
+
+		{"нет такой даты", "0 0 30 2 *", date(time.UTC, 2025, 1, 1, 0, 0), time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cron, err := Parse(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := cron.Next(test.after)
+			if !got.Equal(test.want) {
+				t.Fatalf("Next(%s) = %s, ожидалось %s", test.after, got, test.want)
+			}
+			if !got.IsZero() && !cron.Matches(got) {
+				t.Fatalf("%s не совпадает с выражением", got)
+			}
+		})
+	}
+}
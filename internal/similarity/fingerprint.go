@@ -0,0 +1,231 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package similarity
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// Параметры отпечатков. Отпечатки хранятся в базе, поэтому менять их нельзя без пересчета
+const (
+	ShingleSize   = 3  // Слов в шингле
+	MinHashLength = 64 // Хешей в сигнатуре MinHash
+
+	// SimHash используется как грубый фильтр перед сравнением MinHash: у текстов
+	// с Jaccard около 0.8 расстояние Хэмминга обычно укладывается в эту границу
+	SimHashMaxDistance = 20
+)
+
+// Отпечатки текста для поиска точных и почти дословных копий
+type Fingerprint struct {
+	ContentHash string   // SHA-256 нормализованного текста, пусто для текста без слов
+	SimHash     uint64   // SimHash по шинглам
+	MinHash     []uint32 // Сигнатура MinHash по шинглам, nil для текста без слов
+}
+
+// Сиды хеш-функций MinHash, получаются детерминированно
+var minHashSeeds = func() []uint64 {
+	seeds := make([]uint64, MinHashLength)
+	state := uint64(0x41434153626f74) // "ACASbot"
+	for i := range seeds {
+		state += 0x9e3779b97f4a7c15
+		seeds[i] = mix64(state)
+	}
+	return seeds
+}()
+
+// Финализатор splitmix64
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Слова текста в нижнем регистре без пунктуации
+func normalizedWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Нормализует текст: нижний регистр, без пунктуации, слова через один пробел.
+// Тексты, различающиеся лишь разметкой, пробелами и регистром, совпадают
+func NormalizeText(text string) string {
+	return strings.Join(normalizedWords(text), " ")
+}
+
+// SHA-256 нормализованного текста в hex. Для текста без слов - пустая строка
+func ContentHash(text string) string {
+	normalized := NormalizeText(text)
+	if normalized == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// Хеши шинглов по ShingleSize слов. Короткие тексты дают один шингл из всех слов
+func shingleHashes(words []string) []uint64 {
+	if len(words) == 0 {
+		return nil
+	}
+
+	size := ShingleSize
+	if len(words) < size {
+		size = len(words)
+	}
+
+	seen := make(map[uint64]struct{}, len(words))
+	hashes := make([]uint64, 0, len(words)-size+1)
+	for i := 0; i+size <= len(words); i++ {
+		hasher := fnv.New64a()
+		hasher.Write([]byte(strings.Join(words[i:i+size], " ")))
+		hash := hasher.Sum64()
+		if _, ok := seen[hash]; ok {
+			continue
+		}
+		seen[hash] = struct{}{}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes
+}
+
+func simHash(shingles []uint64) uint64 {
+	var weights [64]int
+	for _, hash := range shingles {
+		for bit := 0; bit < 64; bit++ {
+			if hash&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var result uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			result |= 1 << bit
+		}
+	}
+	return result
+}
+
+func minHash(shingles []uint64) []uint32 {
+	if len(shingles) == 0 {
+		return nil
+	}
+
+	signature := make([]uint32, MinHashLength)
+	for i := range signature {
+		signature[i] = ^uint32(0)
+	}
+	for _, hash := range shingles {
+		for i, seed := range minHashSeeds {
+			if value := uint32(mix64(hash^seed) >> 32); value < signature[i] {
+				signature[i] = value
+			}
+		}
+	}
+	return signature
+}
+
+// Вычисляет все отпечатки текста
+func NewFingerprint(text string) Fingerprint {
+	words := normalizedWords(text)
+	if len(words) == 0 {
+		return Fingerprint{}
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(words, " ")))
+	shingles := shingleHashes(words)
+
+	return Fingerprint{
+		ContentHash: hex.EncodeToString(sum[:]),
+		SimHash:     simHash(shingles),
+		MinHash:     minHash(shingles),
+	}
+}
+
+// Количество различающихся бит двух SimHash
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Оценка коэффициента Жаккара множеств шинглов по сигнатурам MinHash (0..1)
+func MinHashSimilarity(a, b []uint32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	matches := 0
+	for i := range a {
+		if a[i] == b[i] {
+			matches++
+		}
+	}
+	return float64(matches) / float64(len(a))
+}
+
+// Почти дословная копия: SimHash близки, а оценка Жаккара не ниже порога.
+// Возвращает оценку Жаккара и признак совпадения
+func (fp Fingerprint) NearDuplicate(simHash uint64, signature []uint32, threshold float64) (float64, bool) {
+	if len(fp.MinHash) == 0 || HammingDistance(fp.SimHash, simHash) > SimHashMaxDistance {
+		return 0, false
+	}
+
+	score := MinHashSimilarity(fp.MinHash, signature)
+	return score, score >= threshold
+}
+
+// Сигнатура MinHash для хранения в базе
+func EncodeMinHash(signature []uint32) []byte {
+	if len(signature) == 0 {
+		return nil
+	}
+
+	data := make([]byte, 4*len(signature))
+	for i, value := range signature {
+		binary.LittleEndian.PutUint32(data[4*i:], value)
+	}
+	return data
+}
+
+func DecodeMinHash(data []byte) []uint32 {
+	if len(data) == 0 || len(data)%4 != 0 {
+		return nil
+	}
+
+	signature := make([]uint32, len(data)/4)
+	for i := range signature {
+		signature[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	return signature
+}
@@ -0,0 +1,150 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package similarity
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Текст из words случайных слов словаря
+func randomText(rng *rand.Rand, words int) []string {
+	vocabulary := strings.Fields("город жители администрация парк дорога школа больница завод бюджет программа " +
+		"развитие ремонт строительство открытие мэр депутат совет решение проект работа " +
+		"новый старый большой главный районный областной год месяц неделя день")
+
+	text := make([]string, words)
+	for i := range text {
+		text[i] = vocabulary[rng.Intn(len(vocabulary))]
+	}
+	return text
+}
+
+// Копия текста с заменой каждого every-го слова
+func editedText(words []string, every int) []string {
+	edited := slices.Clone(words)
+	for i := every / 2; i < len(edited); i += every {
+		edited[i] = "правка"
+	}
+	return edited
+}
+
+func TestNormalizeText(t *testing.T) {
+	a := "Жители  города\nоткрыли ПАРК!"
+	b := "<<жители города>> — открыли парк..."
+
+	if NormalizeText(a) != "жители города открыли парк" {
+		t.Fatalf("NormalizeText = %q", NormalizeText(a))
+	}
+	if ContentHash(a) != ContentHash(b) || ContentHash(a) == "" {
+		t.Fatal("тексты, различающиеся пунктуацией и регистром, имеют разные хеши")
+	}
+	if ContentHash("!!! ...") != "" || NewFingerprint("!!! ...").MinHash != nil {
+		t.Fatal("у текста без слов есть отпечаток")
+	}
+}
+
+func TestNearDuplicate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	original := randomText(rng, 400)
+	fingerprint := NewFingerprint(strings.Join(original, " "))
+
+	tests := []struct {
+		name      string
+		words     []string
+		duplicate bool
+	}{
+		{"та же статья", original, true},
+		{"правка каждого 50-го слова", editedText(original, 50), true},
+		{"добавлен абзац", append(slices.Clone(original), randomText(rng, 20)...), true},
+		{"правка каждого 5-го слова", editedText(original, 5), false},
+		{"половина статьи", original[:200], false},
+		{"другая статья", randomText(rng, 400), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			other := NewFingerprint(strings.Join(test.words, " "))
+			distance := HammingDistance(fingerprint.SimHash, other.SimHash)
+			jaccard := MinHashSimilarity(fingerprint.MinHash, other.MinHash)
+
+			score, duplicate := fingerprint.NearDuplicate(other.SimHash, other.MinHash, 0.8)
+			if duplicate != test.duplicate {
+				t.Fatalf("дубликат %v, ожидалось %v (Хэмминг %d, Жаккар %.2f)", duplicate, test.duplicate, distance, jaccard)
+			}
+
+			// Почти дословные копии проходят грубый фильтр SimHash
+			if test.duplicate && (distance > SimHashMaxDistance || score != jaccard) {
+				t.Fatalf("Хэмминг %d, оценка %.2f, Жаккар %.2f", distance, score, jaccard)
+			}
+		})
+	}
+
+	// Разные тексты отличаются примерно в половине бит SimHash
+	different := NewFingerprint(strings.Join(randomText(rng, 400), " "))
+	if distance := HammingDistance(fingerprint.SimHash, different.SimHash); distance <= SimHashMaxDistance {
+		t.Fatalf("расстояние Хэмминга разных текстов %d", distance)
+	}
+	if MinHashSimilarity(fingerprint.MinHash, different.MinHash) > 0.2 {
+		t.Fatal("высокая оценка Жаккара у разных текстов")
+	}
+}
+
+func TestMinHashSimilarity(t *testing.T) {
+	a := []uint32{1, 2, 3, 4}
+
+	tests := []struct {
+		name string
+		b    []uint32
+		want float64
+	}{
+		{"совпадают", []uint32{1, 2, 3, 4}, 1},
+		{"половина", []uint32{1, 2, 0, 0}, 0.5},
+		{"не совпадают", []uint32{5, 6, 7, 8}, 0},
+		{"разная длина", []uint32{1, 2, 3}, 0},
+		{"пустая", nil, 0},
+	}
+
+	for _, test := range tests {
+		if got := MinHashSimilarity(a, test.b); got != test.want {
+			t.Fatalf("%s: %.2f, ожидалось %.2f", test.name, got, test.want)
+		}
+	}
+
+	// Без сигнатуры совпадения нет
+	if _, ok := (Fingerprint{}).NearDuplicate(0, a, 0); ok {
+		t.Fatal("пустой отпечаток совпал")
+	}
+}
+
+func TestEncodeMinHash(t *testing.T) {
+	signature := NewFingerprint("жители города открыли новый парк").MinHash
+	if len(signature) != MinHashLength {
+		t.Fatalf("длина сигнатуры %d", len(signature))
+	}
+
+	if decoded := DecodeMinHash(EncodeMinHash(signature)); !slices.Equal(decoded, signature) {
+		t.Fatal("сигнатура изменилась после кодирования")
+	}
+	if DecodeMinHash([]byte{1, 2, 3}) != nil || EncodeMinHash(nil) != nil {
+		t.Fatal("некорректные данные декодированы")
+	}
+}
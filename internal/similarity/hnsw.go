@@ -0,0 +1,370 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package similarity
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Найденный соседний вектор и его косинусное сходство с запросом
+type Neighbor struct {
+	ID         int64
+	Similarity float64
+}
+
+// Параметры индекса по умолчанию
+const (
+	DefaultHNSWM              = 16
+	DefaultHNSWEfConstruction = 100
+	DefaultHNSWEfSearch       = 64
+)
+
+type hnswNode struct {
+	id        int64
+	vector    []float32
+	neighbors [][]int32 // Связи по уровням графа
+	deleted   bool
+}
+
+// Приближенный поиск ближайших соседей (Hierarchical Navigable Small World).
+// Векторы должны быть нормализованы: сходство считается скалярным произведением.
+// Удаление помечает узел, не перестраивая граф
+type HNSW struct {
+	mu sync.RWMutex
+
+	M              int // Связей на узел на верхних уровнях (на нулевом - 2M)
+	EfConstruction int // Ширина поиска при вставке
+	EfSearch       int // Ширина поиска при запросе
+
+	levelMult  float64
+	nodes      []*hnswNode
+	ids        map[int64]int32
+	entry      int32
+	maxLevel   int
+	dimensions int
+	deleted    int
+	rng        *rand.Rand
+}
+
+func NewHNSW(m int, efConstruction int, efSearch int) *HNSW {
+	if m < 2 {
+		m = 2
+	}
+
+	return &HNSW{
+		M:              m,
+		EfConstruction: efConstruction,
+		EfSearch:       efSearch,
+		levelMult:      1 / math.Log(float64(m)),
+		ids:            make(map[int64]int32),
+		entry:          -1,
+		rng:            rand.New(rand.NewSource(42)),
+	}
+}
+
+// Очищает индекс
+func (h *HNSW) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nodes = nil
+	h.ids = make(map[int64]int32)
+	h.entry = -1
+	h.maxLevel = 0
+	h.dimensions = 0
+	h.deleted = 0
+}
+
+// Количество векторов в индексе (без удаленных)
+func (h *HNSW) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.nodes) - h.deleted
+}
+
+// Размерность векторов индекса, 0 - индекс пуст
+func (h *HNSW) Dimensions() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.dimensions
+}
+
+func dot(a, b []float32) float64 {
+	b = b[:len(a)]
+
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+
+	return float64(s0 + s1 + s2 + s3)
+}
+
+// Переводит вектор в float32 с нормализацией
+func ToFloat32(vector []float64) []float32 {
+	magnitude := Magnitude(vector)
+	if magnitude == 0 || math.IsNaN(magnitude) {
+		magnitude = 1
+	}
+
+	converted := make([]float32, len(vector))
+	for i, v := range vector {
+		converted[i] = float32(v / magnitude)
+	}
+
+	return converted
+}
+
+type candidate struct {
+	node       int32
+	similarity float64
+}
+
+// Куча с наиболее похожим кандидатом наверху
+type nearestHeap []candidate
+
+func (h nearestHeap) Len() int           { return len(h) }
+func (h nearestHeap) Less(i, j int) bool { return h[i].similarity > h[j].similarity }
+func (h nearestHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *nearestHeap) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *nearestHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// Куча с наименее похожим кандидатом наверху
+type furthestHeap []candidate
+
+func (h furthestHeap) Len() int           { return len(h) }
+func (h furthestHeap) Less(i, j int) bool { return h[i].similarity < h[j].similarity }
+func (h furthestHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *furthestHeap) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *furthestHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+func (h *HNSW) randomLevel() int {
+	return int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+}
+
+func (h *HNSW) maxConnections(level int) int {
+	if level == 0 {
+		return 2 * h.M
+	}
+
+	return h.M
+}
+
+// Жадный поиск на уровне графа, возвращает до ef ближайших узлов по убыванию сходства
+func (h *HNSW) searchLayer(query []float32, entries []candidate, ef int, level int) []candidate {
+	visited := make([]bool, len(h.nodes))
+	candidates := &nearestHeap{}
+	results := &furthestHeap{}
+
+	for _, entry := range entries {
+		visited[entry.node] = true
+		heap.Push(candidates, entry)
+		heap.Push(results, entry)
+	}
+
+	for candidates.Len() > 0 {
+		current := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && current.similarity < (*results)[0].similarity {
+			break
+		}
+
+		node := h.nodes[current.node]
+		if level >= len(node.neighbors) {
+			continue
+		}
+
+		for _, neighbor := range node.neighbors[level] {
+			if visited[neighbor] {
+				continue
+			}
+			visited[neighbor] = true
+
+			sim := dot(query, h.nodes[neighbor].vector)
+			if results.Len() < ef || sim > (*results)[0].similarity {
+				heap.Push(candidates, candidate{neighbor, sim})
+				heap.Push(results, candidate{neighbor, sim})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	found := make([]candidate, results.Len())
+	for i := len(found) - 1; i >= 0; i-- {
+		found[i] = heap.Pop(results).(candidate)
+	}
+
+	return found
+}
+
+// Оставляет не более max наиболее похожих связей узла
+func (h *HNSW) pruneConnections(nodeIndex int32, level int, max int) {
+	node := h.nodes[nodeIndex]
+	connections := node.neighbors[level]
+	if len(connections) <= max {
+		return
+	}
+
+	scored := make([]candidate, len(connections))
+	for i, neighbor := range connections {
+		scored[i] = candidate{neighbor, dot(node.vector, h.nodes[neighbor].vector)}
+	}
+	sort.Slice(scored, func(i, j int) bool { return scored[i].similarity > scored[j].similarity })
+
+	pruned := make([]int32, max)
+	for i := 0; i < max; i++ {
+		pruned[i] = scored[i].node
+	}
+	node.neighbors[level] = pruned
+}
+
+// Добавляет нормализованный вектор. Повторное добавление того же ID заменяет вектор.
+// Векторы другой размерности отклоняются
+func (h *HNSW) Add(id int64, vector []float32) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(vector) == 0 || (h.dimensions != 0 && len(vector) != h.dimensions) {
+		return false
+	}
+	h.dimensions = len(vector)
+
+	if existing, ok := h.ids[id]; ok && !h.nodes[existing].deleted {
+		h.nodes[existing].deleted = true
+		h.deleted++
+	}
+
+	level := h.randomLevel()
+	nodeIndex := int32(len(h.nodes))
+	node := &hnswNode{
+		id:        id,
+		vector:    vector,
+		neighbors: make([][]int32, level+1),
+	}
+	h.nodes = append(h.nodes, node)
+	h.ids[id] = nodeIndex
+
+	if h.entry < 0 {
+		h.entry = nodeIndex
+		h.maxLevel = level
+		return true
+	}
+
+	entries := []candidate{{h.entry, dot(vector, h.nodes[h.entry].vector)}}
+	for l := h.maxLevel; l > level; l-- {
+		entries = h.searchLayer(vector, entries, 1, l)
+	}
+
+	for l := int(math.Min(float64(level), float64(h.maxLevel))); l >= 0; l-- {
+		found := h.searchLayer(vector, entries, h.EfConstruction, l)
+
+		count := h.M
+		if count > len(found) {
+			count = len(found)
+		}
+		for _, neighbor := range found[:count] {
+			node.neighbors[l] = append(node.neighbors[l], neighbor.node)
+			h.nodes[neighbor.node].neighbors[l] = append(h.nodes[neighbor.node].neighbors[l], nodeIndex)
+			h.pruneConnections(neighbor.node, l, h.maxConnections(l))
+		}
+
+		entries = found
+	}
+
+	if level > h.maxLevel {
+		h.maxLevel = level
+		h.entry = nodeIndex
+	}
+
+	return true
+}
+
+// Помечает вектор удаленным
+func (h *HNSW) Remove(id int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if index, ok := h.ids[id]; ok && !h.nodes[index].deleted {
+		h.nodes[index].deleted = true
+		h.deleted++
+		delete(h.ids, id)
+	}
+}
+
+// До k ближайших векторов по убыванию сходства
+func (h *HNSW) Search(query []float32, k int) []Neighbor {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.entry < 0 || len(query) != h.dimensions || k <= 0 {
+		return nil
+	}
+
+	entries := []candidate{{h.entry, dot(query, h.nodes[h.entry].vector)}}
+	for l := h.maxLevel; l > 0; l-- {
+		entries = h.searchLayer(query, entries, 1, l)
+	}
+
+	ef := h.EfSearch
+	if ef < k {
+		ef = k
+	}
+	// Удаленные узлы занимают место в выдаче
+	ef += h.deleted * ef / (len(h.nodes) + 1)
+
+	found := h.searchLayer(query, entries, ef, 0)
+
+	neighbors := make([]Neighbor, 0, k)
+	for _, c := range found {
+		node := h.nodes[c.node]
+		if node.deleted {
+			continue
+		}
+		neighbors = append(neighbors, Neighbor{ID: node.id, Similarity: c.similarity})
+		if len(neighbors) == k {
+			break
+		}
+	}
+
+	return neighbors
+}
@@ -0,0 +1,208 @@
+/*
+   ACASbot - Article Context And Sentiment bot
+   Copyright (C) 2025  Unbewohnte (Kasyanov Nikolay Alexeevich)
+
+   This program is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package similarity
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func randomUnitVector(rng *rand.Rand, dimensions int) []float64 {
+	vector := make([]float64, dimensions)
+	for i := range vector {
+		vector[i] = rng.NormFloat64()
+	}
+	NormalizeVector(vector)
+
+	return vector
+}
+
+// Вектор рядом с центром: имитирует тексты на одну тему
+func noisyVector(rng *rand.Rand, center []float64, noise float64) []float64 {
+	vector := make([]float64, len(center))
+	for i := range vector {
+		vector[i] = center[i] + rng.NormFloat64()*noise/math.Sqrt(float64(len(center)))
+	}
+	NormalizeVector(vector)
+
+	return vector
+}
+
+// Векторы, сгруппированные по темам, и запросы к ним
+type clusteredData struct {
+	vectors map[int64][]float32
+	queries [][]float32
+}
+
+func newClusteredData(vectors int, dimensions int, queries int) clusteredData {
+	rng := rand.New(rand.NewSource(1))
+
+	topics := vectors/50 + 1
+	centers := make([][]float64, topics)
+	for i := range centers {
+		centers[i] = randomUnitVector(rng, dimensions)
+	}
+
+	data := clusteredData{vectors: make(map[int64][]float32, vectors)}
+	for id := 0; id < vectors; id++ {
+		data.vectors[int64(id)] = ToFloat32(noisyVector(rng, centers[rng.Intn(topics)], 0.8))
+	}
+	for q := 0; q < queries; q++ {
+		data.queries = append(data.queries, ToFloat32(noisyVector(rng, centers[rng.Intn(topics)], 0.8)))
+	}
+
+	return data
+}
+
+func (data clusteredData) index() *HNSW {
+	ids := make([]int64, 0, len(data.vectors))
+	for id := range data.vectors {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	index := NewHNSW(DefaultHNSWM, DefaultHNSWEfConstruction, DefaultHNSWEfSearch)
+	for _, id := range ids {
+		index.Add(id, data.vectors[id])
+	}
+
+	return index
+}
+
+// Точный поиск полным перебором
+func linearSearch(vectors map[int64][]float32, query []float32, k int) []Neighbor {
+	neighbors := make([]Neighbor, 0, len(vectors))
+	for id, vector := range vectors {
+		if len(vector) != len(query) {
+			continue
+		}
+		neighbors = append(neighbors, Neighbor{ID: id, Similarity: dot(query, vector)})
+	}
+
+	sort.Slice(neighbors, func(i, j int) bool { return neighbors[i].Similarity > neighbors[j].Similarity })
+	if len(neighbors) > k {
+		neighbors = neighbors[:k]
+	}
+
+	return neighbors
+}
+
+// Доля точных k соседей, найденных индексом
+func recall(index *HNSW, data clusteredData, k int) float64 {
+	var matched, expected int
+	for _, query := range data.queries {
+		found := make(map[int64]struct{})
+		for _, neighbor := range index.Search(query, k) {
+			found[neighbor.ID] = struct{}{}
+		}
+		for _, neighbor := range linearSearch(data.vectors, query, k) {
+			expected++
+			if _, ok := found[neighbor.ID]; ok {
+				matched++
+			}
+		}
+	}
+
+	return float64(matched) / float64(expected)
+}
+
+func TestHNSWRecall(t *testing.T) {
+	for _, tc := range []struct {
+		vectors, dimensions int
+	}{
+		{500, 32},
+		{5000, 128},
+	} {
+		data := newClusteredData(tc.vectors, tc.dimensions, 100)
+		if got := recall(data.index(), data, 10); got < 0.95 {
+			t.Errorf("%d векторов размерности %d: полнота %.3f, ожидается не ниже 0.95", tc.vectors, tc.dimensions, got)
+		}
+	}
+}
+
+func TestHNSWFindsStoredVector(t *testing.T) {
+	data := newClusteredData(2000, 64, 0)
+	index := data.index()
+
+	for id := int64(0); id < 100; id++ {
+		neighbors := index.Search(data.vectors[id], 1)
+		if len(neighbors) != 1 || data.vectors[neighbors[0].ID] == nil || neighbors[0].Similarity < 0.9999 {
+			t.Fatalf("вектор %d: найдено %+v", id, neighbors)
+		}
+	}
+}
+
+func TestHNSWRemove(t *testing.T) {
+	data := newClusteredData(2000, 64, 50)
+	index := data.index()
+
+	for id := int64(0); id < 1000; id++ {
+		index.Remove(id)
+		delete(data.vectors, id)
+	}
+	if index.Len() != 1000 {
+		t.Fatalf("после удаления в индексе %d векторов, ожидается 1000", index.Len())
+	}
+
+	for _, query := range data.queries {
+		for _, neighbor := range index.Search(query, 10) {
+			if neighbor.ID < 1000 {
+				t.Fatalf("найден удаленный вектор %d", neighbor.ID)
+			}
+		}
+	}
+
+	if got := recall(index, data, 10); got < 0.9 {
+		t.Errorf("полнота после удаления половины векторов %.3f, ожидается не ниже 0.9", got)
+	}
+}
+
+func TestHNSWRejectsOtherDimensions(t *testing.T) {
+	index := NewHNSW(DefaultHNSWM, DefaultHNSWEfConstruction, DefaultHNSWEfSearch)
+	if !index.Add(1, []float32{1, 0, 0}) {
+		t.Fatal("первый вектор не добавлен")
+	}
+	if index.Add(2, []float32{1, 0}) {
+		t.Fatal("добавлен вектор другой размерности")
+	}
+	if neighbors := index.Search([]float32{1, 0}, 1); neighbors != nil {
+		t.Fatalf("поиск вектором другой размерности вернул %+v", neighbors)
+	}
+}
+
+// Поиск 10 ближайших соседей среди 10000 векторов размерности 1024: по индексу и полным перебором
+func BenchmarkSearch(b *testing.B) {
+	data := newClusteredData(10000, 1024, 100)
+	index := data.index()
+
+	b.Run("hnsw", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			index.Search(data.queries[i%len(data.queries)], 10)
+		}
+		b.ReportMetric(recall(index, data, 10), "recall")
+	})
+
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			linearSearch(data.vectors, data.queries[i%len(data.queries)], 10)
+		}
+	})
+}
@@ -27,6 +27,8 @@ func SemanticSimilarity(a, b []float64) (float64, error) {
 		return 0, err
 	}
 
-	// Convert to angular similarity
+	// Convert to angular similarity. Для одинаковых векторов косинус из-за
+	// погрешности может чуть превысить 1, и арккосинус вернул бы NaN
+	cosine = math.Max(-1, math.Min(1, cosine))
 	return 1 - math.Acos(cosine)/math.Pi, nil
 }
@@ -22,10 +22,12 @@ import (
 	"Unbewohnte/ACASbot/internal/domain"
 	"Unbewohnte/ACASbot/internal/inference"
 	"bytes"
+	"context"
 	"fmt"
 	"net/url"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -33,8 +35,118 @@ import (
 	"github.com/tealeg/xlsx/v3"
 )
 
-// GenerateFromDatabase создаёт Excel-файл в памяти на основе статей из БД
-func GenerateFromDatabase(articles []domain.Article) (*bytes.Buffer, error) {
+// Возвращает примечание и тональность статьи по объекту. Для статей, проанализированных
+// до появления нескольких объектов, результатом основного объекта считаются поля самой статьи
+func objectValues(art domain.Article, object string, primary bool) (string, string) {
+	if result := art.ObjectResult(object); result != nil {
+		return result.Affiliation, result.Sentiment
+	}
+
+	if primary && len(art.Objects) == 0 {
+		return art.Affiliation, art.Sentiment
+	}
+
+	return "", ""
+}
+
+// Заголовки колонок примечания и тональности для каждого объекта
+func objectHeaders(objects []string) []string {
+	if len(objects) <= 1 {
+		return []string{"Примечание", "Тональность"}
+	}
+
+	var headers []string
+	for _, object := range objects {
+		headers = append(headers,
+			fmt.Sprintf("Примечание (%s)", object),
+			fmt.Sprintf("Тональность (%s)", object),
+		)
+	}
+
+	return headers
+}
+
+// Значения колонок примечания и тональности для каждого объекта
+func objectColumns(art domain.Article, objects []string) []string {
+	if len(objects) == 0 {
+		return []string{art.Affiliation, art.Sentiment}
+	}
+
+	var values []string
+	for i, object := range objects {
+		affiliation, sentiment := objectValues(art, object, i == 0)
+		values = append(values, affiliation, sentiment)
+	}
+
+	return values
+}
+
+// Заголовки колонок происхождения результата анализа
+var provenanceHeaders = []string{
+	"Модель", "Промпты", "Извлечение текста", "Лимит текста", "Загрузка, с", "Анализ LLM, с", "Ошибки анализа",
+}
+
+// Значения колонок происхождения. Для статей без сведений о происхождении колонки пусты
+func provenanceColumns(art *domain.Article) []string {
+	provenance := art.Provenance
+	if provenance == nil {
+		return make([]string, len(provenanceHeaders))
+	}
+
+	var limit, fetch, analysis string
+	if provenance.MaxContentSize > 0 {
+		limit = strconv.FormatUint(uint64(provenance.MaxContentSize), 10)
+		if provenance.Truncated {
+			limit += " (урезан)"
+		}
+	}
+	if provenance.FetchMillis > 0 {
+		fetch = strconv.FormatFloat(provenance.FetchDuration().Seconds(), 'f', 1, 64)
+	}
+	if provenance.AnalysisMillis > 0 {
+		analysis = strconv.FormatFloat(provenance.AnalysisDuration().Seconds(), 'f', 1, 64)
+	}
+
+	model := provenance.Model
+	if model != "" && provenance.Backend != "" {
+		model += " (" + provenance.Backend + ")"
+	}
+
+	return []string{
+		model,
+		provenance.PromptHashes(),
+		provenance.ExtractionMethod(),
+		limit,
+		fetch,
+		analysis,
+		strings.Join(provenance.Errors, "; "),
+	}
+}
+
+// Кто и когда исправил результат статьи вручную, пусто - не исправлялся
+func verifiedValue(art domain.Article) string {
+	if !art.Verified() {
+		return ""
+	}
+
+	return fmt.Sprintf("%s, %s", art.VerifiedBy, formatDate(time.Unix(art.VerifiedAt, 0)))
+}
+
+// Поля происхождения для настраиваемых колонок и их место в provenanceColumns
+var provenanceFields = map[string]int{
+	"model":            0,
+	"prompts":          1,
+	"extractor":        2,
+	"max_content_size": 3,
+	"fetch_seconds":    4,
+	"analysis_seconds": 5,
+	"analysis_errors":  6,
+}
+
+// GenerateFromDatabase создаёт Excel-файл в памяти на основе статей из БД.
+// Для каждого объекта из objects добавляется своя пара колонок примечания и тональности.
+// Полные тексты промптов из prompts (хеш - текст) выводятся на отдельный лист
+func GenerateFromDatabase(articles []domain.Article, objects []string, prompts map[string]string) (*bytes.Buffer, error) {
 	file := xlsx.NewFile()
 	sheet, err := file.AddSheet("Результаты")
 	if err != nil {
@@ -45,8 +157,10 @@ func GenerateFromDatabase(articles []domain.Article) (*bytes.Buffer, error) {
 	headerRow := sheet.AddRow()
 	headers := []string{
 		"Дата добавления", "Дата публикации", "Ресурс", "Заголовок", "URL",
-		"Примечание", "Тональность", "Цитирований", "Похожие статьи", "Оригинал?",
 	}
+	headers = append(headers, objectHeaders(objects)...)
+	headers = append(headers, "Цитирований", "Похожие статьи", "Оригинал?", "Сюжет", "Проверено вручную")
+	headers = append(headers, provenanceHeaders...)
 	for _, h := range headers {
 		cell := headerRow.AddCell()
 		cell.Value = h
@@ -89,13 +203,11 @@ func GenerateFromDatabase(articles []domain.Article) (*bytes.Buffer, error) {
 		cell = row.AddCell()
 		cell.Value = art.SourceURL
 
-		// Аффилиация
-		cell = row.AddCell()
-		cell.Value = art.Affiliation
-
-		// Тональность
-		cell = row.AddCell()
-		cell.Value = art.Sentiment
+		// Аффилиация и тональность по каждому объекту
+		for _, value := range objectColumns(art, objects) {
+			cell = row.AddCell()
+			cell.Value = value
+		}
 
 		// Цитирований
 		cell = row.AddCell()
@@ -112,6 +224,28 @@ func GenerateFromDatabase(articles []domain.Article) (*bytes.Buffer, error) {
 		} else {
 			cell.Value = "Нет"
 		}
+
+		// Сюжет
+		cell = row.AddCell()
+		if art.StoryID != 0 {
+			cell.SetInt64(art.StoryID)
+		}
+
+		// Ручное исправление
+		cell = row.AddCell()
+		cell.Value = verifiedValue(art)
+
+		// Происхождение результата
+		for _, value := range provenanceColumns(&art) {
+			cell = row.AddCell()
+			cell.Value = value
+		}
+	}
+
+	if len(prompts) > 0 {
+		if err := addPromptsSheet(file, prompts); err != nil {
+			return nil, err
+		}
 	}
 
 	// Сохраняем в буфер
@@ -123,8 +257,34 @@ func GenerateFromDatabase(articles []domain.Article) (*bytes.Buffer, error) {
 	return buf, nil
 }
 
+// Лист с полными текстами промптов, на хеши которых ссылаются результаты
+func addPromptsSheet(file *xlsx.File, prompts map[string]string) error {
+	sheet, err := file.AddSheet("Промпты")
+	if err != nil {
+		return err
+	}
+
+	headerRow := sheet.AddRow()
+	headerRow.AddCell().Value = "Хеш"
+	headerRow.AddCell().Value = "Промпт"
+
+	hashes := make([]string, 0, len(prompts))
+	for hash := range prompts {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	for _, hash := range hashes {
+		row := sheet.AddRow()
+		row.AddCell().Value = hash
+		row.AddCell().Value = prompts[hash]
+	}
+
+	return nil
+}
+
 // GenerateCustomXLSX создает Excel-файл с настраиваемыми колонками на основе пользовательского конфига
-func GenerateCustomXLSX(articles []domain.Article, columns []domain.XLSXColumn, model *inference.Client) (*bytes.Buffer, error) {
+func GenerateCustomXLSX(ctx context.Context, articles []domain.Article, columns []domain.XLSXColumn, model inference.Client) (*bytes.Buffer, error) {
 	file := xlsx.NewFile()
 	sheet, err := file.AddSheet("Результаты")
 	if err != nil {
@@ -144,7 +304,7 @@ func GenerateCustomXLSX(articles []domain.Article, columns []domain.XLSXColumn,
 
 		for _, col := range columns {
 			cell := row.AddCell()
-			value, err := getColumnValue(art, col, model)
+			value, err := getColumnValue(ctx, art, col, model)
 			if err != nil {
 				value = "Ошибка: " + err.Error()
 			}
@@ -157,8 +317,32 @@ func GenerateCustomXLSX(articles []domain.Article, columns []domain.XLSXColumn,
 	return buf, err
 }
 
-// Универсальный обработчик полей (используется и для LLM, и для прямых полей)
+// Универсальный обработчик полей (используется и для LLM, и для прямых полей).
+// Поля affiliation, sentiment и justification можно указать для конкретного объекта: "sentiment@Объект"
 func getField(art domain.Article, fieldName string) (string, error) {
+	if field, object, found := strings.Cut(fieldName, "@"); found {
+		result := art.ObjectResult(object)
+		if result == nil {
+			return "", nil
+		}
+
+		switch strings.ToLower(field) {
+		case "affiliation":
+			return result.Affiliation, nil
+		case "sentiment":
+			return result.Sentiment, nil
+		case "justification":
+			return result.Justification, nil
+		default:
+			return "", fmt.Errorf("unknown object field: %s", field)
+		}
+	}
+
+	// Сведения о происхождении результата
+	if index, ok := provenanceFields[strings.ToLower(fieldName)]; ok {
+		return provenanceColumns(&art)[index], nil
+	}
+
 	// Специальные обработки (сохраняем текущую логику)
 	switch strings.ToLower(fieldName) {
 	case "created_at", "createdat":
@@ -174,8 +358,15 @@ func getField(art domain.Article, fieldName string) (string, error) {
 			return art.SourceURL, nil
 		}
 		return u.Hostname(), nil
+	case "story_id", "story":
+		if art.StoryID == 0 {
+			return "", nil
+		}
+		return strconv.FormatInt(art.StoryID, 10), nil
 	case "similar_urls", "similarurls":
 		return strings.Join(art.SimilarURLs, ";"), nil
+	case "verified":
+		return verifiedValue(art), nil
 	case "original":
 		if art.Original {
 			return "Да", nil
@@ -229,14 +420,14 @@ func processTemplate(template string, art domain.Article) (string, error) {
 	}), nil
 }
 
-func getColumnValue(art domain.Article, col domain.XLSXColumn, model *inference.Client) (string, error) {
+func getColumnValue(ctx context.Context, art domain.Article, col domain.XLSXColumn, model inference.Client) (string, error) {
 	if col.LLMQuery != "" {
 		// Обрабатываем шаблон LLMQuery через универсальный метод
 		query, err := processTemplate(col.LLMQuery, art)
 		if err != nil {
 			return "", err
 		}
-		response, err := model.Query(query)
+		response, err := model.Query(ctx, query)
 		if err != nil {
 			return "", fmt.Errorf("LLM query failed: %v", err)
 		}
@@ -82,6 +82,35 @@ func formatDate(date time.Time) string {
 	return fmt.Sprintf("%d.%d.%d", date.Day(), date.Month(), date.Year())
 }
 
+// Строка таблицы с результатом анализа: по паре колонок примечания и тональности на каждый объект,
+// в конце - происхождение результата
+func analysisRow(art *domain.Article, u *url.URL) []interface{} {
+	values := []interface{}{
+		formatDate(time.Unix(art.PublishedAt, 0)),
+		u.Hostname(),
+		art.Title,
+		art.SourceURL,
+	}
+
+	if len(art.Objects) == 0 {
+		values = append(values, art.Affiliation, art.Sentiment)
+	}
+	for _, object := range art.Objects {
+		values = append(values, object.Affiliation, object.Sentiment)
+	}
+
+	values = append(values,
+		art.Citations,
+		strings.Join(art.SimilarURLs, ";"),
+	)
+
+	for _, value := range provenanceColumns(art) {
+		values = append(values, value)
+	}
+
+	return values
+}
+
 // AddAnalysisResult добавляет результат анализа в таблицу
 func (gsc *GoogleSheetsClient) AddAnalysisResult(art *domain.Article) error {
 	// Формируем строку для добавления
@@ -92,16 +121,7 @@ func (gsc *GoogleSheetsClient) AddAnalysisResult(art *domain.Article) error {
 		}
 	}
 
-	values := []interface{}{
-		formatDate(time.Unix(art.PublishedAt, 0)),
-		u.Hostname(),
-		art.Title,
-		art.SourceURL,
-		art.Affiliation,
-		art.Sentiment,
-		art.Citations,
-		strings.Join(art.SimilarURLs, ";"),
-	}
+	values := analysisRow(art, u)
 
 	// Создаем запрос на добавление
 	row := &sheets.ValueRange{
@@ -155,16 +175,7 @@ func (gsc *GoogleSheetsClient) BatchAddResults(articles []*domain.Article) error
 			}
 		}
 
-		vr.Values = append(vr.Values, []interface{}{
-			formatDate(time.Unix(art.PublishedAt, 0)),
-			u.Hostname(),
-			art.Title,
-			art.SourceURL,
-			art.Affiliation,
-			art.Sentiment,
-			art.Citations,
-			strings.Join(art.SimilarURLs, ";"),
-		})
+		vr.Values = append(vr.Values, analysisRow(art, u))
 	}
 
 	_, err := gsc.service.Spreadsheets.Values.Append(